@@ -15,6 +15,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/lzjever/mbos-wvs/internal/api"
+	"github.com/lzjever/mbos-wvs/internal/executorclient"
 	"github.com/lzjever/mbos-wvs/internal/observability"
 	"github.com/lzjever/mbos-wvs/internal/store"
 )
@@ -44,8 +45,18 @@ func main() {
 	}
 	defer pool.Close()
 
+	var exec *executorclient.Client
+	if cfg.ExecutorAddr != "" {
+		exec, err = executorclient.New(cfg.ExecutorAddr)
+		if err != nil {
+			log.Fatal("executor connect failed", zap.Error(err))
+		}
+		defer exec.Close()
+	}
+
 	// Main API server
-	apiHandler := api.NewAPI(pool, log)
+	apiHandler := api.NewAPI(pool, cfg, exec, log)
+	go apiHandler.RunEvents(ctx)
 	srv := &http.Server{
 		Addr:         cfg.HTTPAddr,
 		Handler:      apiHandler.Router(),
@@ -0,0 +1,87 @@
+// Command wvs-guest-agent is an example guest agent built on pkg/guest. It
+// answers the executor's quiesce requests for one workspace by running a
+// shell command to freeze the workload and another to resume it; with
+// neither set it only acknowledges.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/observability"
+	"github.com/lzjever/mbos-wvs/pkg/guest"
+)
+
+type config struct {
+	Root              string        `envconfig:"WVS_GUEST_ROOT" required:"true"`
+	AgentID           string        `envconfig:"WVS_GUEST_AGENT_ID"`
+	FreezeCmd         string        `envconfig:"WVS_GUEST_FREEZE_CMD"`
+	ResumeCmd         string        `envconfig:"WVS_GUEST_RESUME_CMD"`
+	FreezeTimeout     time.Duration `envconfig:"WVS_GUEST_FREEZE_TIMEOUT" default:"10s"`
+	ResumeTimeout     time.Duration `envconfig:"WVS_GUEST_RESUME_TIMEOUT" default:"10s"`
+	HeartbeatInterval time.Duration `envconfig:"WVS_GUEST_HEARTBEAT_INTERVAL" default:"5s"`
+	PollInterval      time.Duration `envconfig:"WVS_GUEST_POLL_INTERVAL" default:"1s"`
+	DisableNotify     bool          `envconfig:"WVS_GUEST_DISABLE_NOTIFY"`
+	MaxFrozen         time.Duration `envconfig:"WVS_GUEST_MAX_FROZEN" default:"10m"`
+	LogLevel          string        `envconfig:"WVS_LOG_LEVEL" default:"info"`
+}
+
+func main() {
+	var cfg config
+	if err := envconfig.Process("", &cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		os.Exit(1)
+	}
+
+	log, _ := observability.NewLogger(cfg.LogLevel)
+	defer log.Sync()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	agent, err := guest.New(guest.Config{
+		Root:              cfg.Root,
+		AgentID:           cfg.AgentID,
+		Freeze:            shell(cfg.FreezeCmd, cfg.Root, log),
+		Resume:            shell(cfg.ResumeCmd, cfg.Root, log),
+		FreezeTimeout:     cfg.FreezeTimeout,
+		ResumeTimeout:     cfg.ResumeTimeout,
+		HeartbeatInterval: cfg.HeartbeatInterval,
+		PollInterval:      cfg.PollInterval,
+		DisableNotify:     cfg.DisableNotify,
+		MaxFrozen:         cfg.MaxFrozen,
+		Logger:            log,
+	})
+	if err != nil {
+		log.Fatal("agent config invalid", zap.Error(err))
+	}
+	if err := agent.Run(ctx); err != nil {
+		log.Fatal("agent failed", zap.Error(err))
+	}
+}
+
+// shell returns a callback running command with sh -c in dir, or one that
+// does nothing if command is empty.
+func shell(command, dir string, log *zap.Logger) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if command == "" {
+			return nil
+		}
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %w: %s", command, err, out)
+		}
+		log.Debug("command done", zap.String("command", command), zap.ByteString("output", out))
+		return nil
+	}
+}
@@ -59,5 +59,7 @@ func main() {
 	}()
 
 	w := worker.New(pool, exec, cfg, log)
+	go w.RunReplication(ctx)
+	go w.RunScrub(ctx)
 	w.Run(ctx)
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+type BatchResponse struct {
+	BatchID   string    `json:"batch_id"`
+	WSID      string    `json:"wsid"`
+	Status    string    `json:"status"`
+	CreatedAt string    `json:"created_at"`
+	Tasks     []TaskRow `json:"tasks"`
+}
+
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Run several ops as one ordered unit",
+}
+
+var batchSubmitCmd = &cobra.Command{
+	Use:   "submit <wsid> <steps-file|->",
+	Short: "Submit a batch of steps from a JSON file",
+	Long: `Submit a batch of steps. The file holds {"steps": [...]}, each step being
+{"op": "snapshot_create", "message": "..."},
+{"op": "set_current", "snapshot_id": "..."} or {"op": "set_current", "snapshot_step": 0},
+{"op": "snapshot_drop", "snapshot_id": "..."}.
+Steps run in order; a failed step cancels the steps after it.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		var raw []byte
+		var err error
+		if args[1] == "-" {
+			raw, err = io.ReadAll(os.Stdin)
+		} else {
+			raw, err = os.ReadFile(args[1])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		var req map[string]interface{}
+		if err := json.Unmarshal(raw, &req); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid steps file: %v\n", err)
+			os.Exit(1)
+		}
+
+		client := NewClient(apiURL)
+		var resp BatchResponse
+		if err := postWithHeaders(client, withPriority("/v1/workspaces/"+wsid+"/batch"), req, &resp, mutationHeaders()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printResult(resp)
+		if waitFor > 0 && resp.Status != "SUCCEEDED" {
+			os.Exit(1)
+		}
+	},
+}
+
+var batchGetCmd = &cobra.Command{
+	Use:   "get <batch-id>",
+	Short: "Get a batch and the status of its steps",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := NewClient(apiURL)
+
+		var resp BatchResponse
+		if err := client.Get("/v1/batches/"+args[0], &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printResult(resp)
+	},
+}
+
+func init() {
+	addMutationFlags(batchSubmitCmd)
+	batchCmd.AddCommand(batchSubmitCmd, batchGetCmd)
+	rootCmd.AddCommand(batchCmd)
+}
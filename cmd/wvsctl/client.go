@@ -1,11 +1,13 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
 type Client struct {
@@ -25,6 +27,39 @@ func (c *Client) Get(path string, out interface{}) error {
 	return parseResponse(resp, out)
 }
 
+// Download copies a raw response body to w.
+func (c *Client) Download(path string, w io.Writer) error {
+	resp, err := http.Get(c.baseURL + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return parseResponse(resp, nil)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Upload posts a raw request body of the given size and content type.
+func (c *Client) Upload(path string, body io.Reader, size int64, contentType string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequest("POST", c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return parseResponse(resp, out)
+}
+
 func (c *Client) Post(path string, body interface{}, out interface{}) error {
 	var reqBody io.Reader
 	if body != nil {
@@ -39,6 +74,18 @@ func (c *Client) Post(path string, body interface{}, out interface{}) error {
 	return parseResponse(resp, out)
 }
 
+func (c *Client) Put(path string, body interface{}, out interface{}) error {
+	b, _ := json.Marshal(body)
+	req, _ := http.NewRequest("PUT", c.baseURL+path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return parseResponse(resp, out)
+}
+
 func (c *Client) Delete(path string, out interface{}) error {
 	req, _ := http.NewRequest("DELETE", c.baseURL+path, nil)
 	resp, err := http.DefaultClient.Do(req)
@@ -49,6 +96,58 @@ func (c *Client) Delete(path string, out interface{}) error {
 	return parseResponse(resp, out)
 }
 
+// SSEEvent is a single Server-Sent Event received from the API.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// Stream opens a Server-Sent Events stream and calls fn for each event until
+// fn returns false or the server closes the stream.
+func (c *Client) Stream(path, lastEventID string, fn func(SSEEvent) bool) error {
+	req, _ := http.NewRequest("GET", c.baseURL+path, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return parseResponse(resp, nil)
+	}
+
+	var ev SSEEvent
+	var data []string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				ev.Data = strings.Join(data, "\n")
+				if !fn(ev) {
+					return nil
+				}
+			}
+			ev, data = SSEEvent{}, nil
+		case strings.HasPrefix(line, ":"):
+			// comment / keepalive
+		case strings.HasPrefix(line, "id:"):
+			ev.ID = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "event:"):
+			ev.Event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return scanner.Err()
+}
+
 func parseResponse(resp *http.Response, out interface{}) error {
 	b, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 400 {
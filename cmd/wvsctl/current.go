@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -45,19 +44,19 @@ var currentSetCmd = &cobra.Command{
 		wsid := args[0]
 		snapshotID := args[1]
 
-		idempotencyKey := uuid.New().String()
 		client := NewClient(apiURL)
 
 		var resp TaskRef
 		req := map[string]string{"snapshot_id": snapshotID}
 
-		err := postWithHeaders(client, "/v1/workspaces/"+wsid+"/current:set", req, &resp, map[string]string{
-			"Idempotency-Key": idempotencyKey,
-		})
+		err := postWithHeaders(client, withPriority("/v1/workspaces/"+wsid+"/current:set"), req, &resp, mutationHeaders())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		if printTaskOutcome(resp) {
+			return
+		}
 
 		fmt.Printf("Set current task created.\n")
 		fmt.Printf("Task ID: %s\n", resp.TaskID)
@@ -65,7 +64,38 @@ var currentSetCmd = &cobra.Command{
 	},
 }
 
+var currentRestoreCmd = &cobra.Command{
+	Use:   "restore <wsid> <snapshot-id> [message]",
+	Short: "Checkpoint current, then switch current to a snapshot",
+	Args:  cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		req := map[string]string{"snapshot_id": args[1]}
+		if len(args) > 2 {
+			req["message"] = args[2]
+		}
+
+		client := NewClient(apiURL)
+
+		var resp TaskRef
+		err := postWithHeaders(client, withPriority("/v1/workspaces/"+wsid+"/current:restore"), req, &resp, mutationHeaders())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Checkpoint ID: %s\n", resp.CheckpointID)
+		if printTaskOutcome(resp) {
+			return
+		}
+
+		fmt.Printf("Restore task created.\n")
+		fmt.Printf("Task ID: %s\n", resp.TaskID)
+		fmt.Printf("Check status: wvsctl task get %s\n", resp.TaskID)
+	},
+}
+
 func init() {
-	currentCmd.AddCommand(currentGetCmd, currentSetCmd)
+	addMutationFlags(currentSetCmd, currentRestoreCmd)
+	currentCmd.AddCommand(currentGetCmd, currentSetCmd, currentRestoreCmd)
 	rootCmd.AddCommand(currentCmd)
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// DeadTaskFilter mirrors the API's filter for the dead-letter bulk endpoints.
+type DeadTaskFilter struct {
+	WSID       string `json:"wsid,omitempty"`
+	Op         string `json:"op,omitempty"`
+	ErrorClass string `json:"error_class,omitempty"`
+	Since      string `json:"since,omitempty"`
+	Until      string `json:"until,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+type DeadTaskOutcome struct {
+	TaskID      string `json:"task_id"`
+	WSID        string `json:"wsid"`
+	Op          string `json:"op"`
+	RetryTaskID string `json:"retry_task_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type DeadTaskBulkResponse struct {
+	Requeued  int               `json:"requeued"`
+	Discarded int               `json:"discarded"`
+	Tasks     []DeadTaskOutcome `json:"tasks"`
+}
+
+var deadFilter DeadTaskFilter
+
+var taskRetryCmd = &cobra.Command{
+	Use:   "retry <task-id>",
+	Short: "Retry a DEAD task as a new task",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		taskID := args[0]
+		client := NewClient(apiURL)
+
+		var resp TaskRef
+		headers := map[string]string{}
+		if waitFor > 0 {
+			headers["Prefer"] = fmt.Sprintf("wait=%d", int(waitFor.Seconds()))
+		}
+		if err := postWithHeaders(client, "/v1/tasks/"+taskID+":retry", nil, &resp, headers); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if printTaskOutcome(resp) {
+			return
+		}
+
+		fmt.Printf("Retry task created.\n")
+		fmt.Printf("Task ID: %s\n", resp.TaskID)
+		fmt.Printf("Check status: wvsctl task get %s\n", resp.TaskID)
+	},
+}
+
+var taskDeadCmd = &cobra.Command{
+	Use:   "dead",
+	Short: "Inspect and resolve DEAD tasks",
+}
+
+var taskDeadListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List DEAD tasks matching the filter",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := NewClient(apiURL)
+
+		q := url.Values{}
+		for k, v := range map[string]string{
+			"wsid":        deadFilter.WSID,
+			"op":          deadFilter.Op,
+			"error_class": deadFilter.ErrorClass,
+			"since":       deadFilter.Since,
+			"until":       deadFilter.Until,
+		} {
+			if v != "" {
+				q.Set(k, v)
+			}
+		}
+		if deadFilter.Limit > 0 {
+			q.Set("limit", strconv.Itoa(deadFilter.Limit))
+		}
+
+		var resp TaskListResponse
+		if err := client.Get("/v1/tasks/dead?"+q.Encode(), &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printResult(resp.Tasks)
+	},
+}
+
+var taskDeadRequeueCmd = &cobra.Command{
+	Use:   "requeue",
+	Short: "Retry every DEAD task matching the filter",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := NewClient(apiURL)
+
+		var resp DeadTaskBulkResponse
+		if err := client.Post("/v1/tasks/dead:requeue", deadFilter, &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printResult(resp.Tasks)
+		fmt.Printf("Requeued %d of %d dead tasks.\n", resp.Requeued, len(resp.Tasks))
+	},
+}
+
+var taskDeadDiscardCmd = &cobra.Command{
+	Use:   "discard",
+	Short: "Discard every DEAD task matching the filter",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := NewClient(apiURL)
+
+		var resp DeadTaskBulkResponse
+		if err := client.Post("/v1/tasks/dead:discard", deadFilter, &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printResult(resp.Tasks)
+		fmt.Printf("Discarded %d of %d dead tasks.\n", resp.Discarded, len(resp.Tasks))
+	},
+}
+
+func init() {
+	taskRetryCmd.Flags().DurationVar(&waitFor, "wait", 0, "Wait up to this long for the task to finish (e.g. 30s)")
+	for _, c := range []*cobra.Command{taskDeadListCmd, taskDeadRequeueCmd, taskDeadDiscardCmd} {
+		c.Flags().StringVar(&deadFilter.WSID, "wsid", "", "Only tasks for this workspace")
+		c.Flags().StringVar(&deadFilter.Op, "op", "", "Only tasks for this op")
+		c.Flags().StringVar(&deadFilter.ErrorClass, "error-class", "", "Only tasks that failed with this error class (e.g. NO_SPACE)")
+		c.Flags().StringVar(&deadFilter.Since, "since", "", "Only tasks that died at or after this RFC 3339 time")
+		c.Flags().StringVar(&deadFilter.Until, "until", "", "Only tasks that died before this RFC 3339 time")
+		c.Flags().IntVar(&deadFilter.Limit, "limit", 0, "Maximum tasks to act on (server default 100, max 1000)")
+	}
+	taskDeadCmd.AddCommand(taskDeadListCmd, taskDeadRequeueCmd, taskDeadDiscardCmd)
+	taskCmd.AddCommand(taskRetryCmd, taskDeadCmd)
+}
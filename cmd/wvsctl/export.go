@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type ExportRow struct {
+	ExportID         string `json:"export_id"`
+	WSID             string `json:"wsid"`
+	SnapshotID       string `json:"snapshot_id"`
+	TaskID           string `json:"task_id"`
+	Status           string `json:"status"`
+	Compression      string `json:"compression"`
+	Destination      string `json:"destination"`
+	Location         string `json:"location,omitempty"`
+	Digest           string `json:"digest,omitempty"`
+	SizeBytes        *int64 `json:"size_bytes,omitempty"`
+	FileCount        *int64 `json:"file_count,omitempty"`
+	ManifestLocation string `json:"manifest_location,omitempty"`
+	ManifestDigest   string `json:"manifest_digest,omitempty"`
+	CreatedAt        string `json:"created_at"`
+	CompletedAt      string `json:"completed_at,omitempty"`
+}
+
+var (
+	exportCompression string
+	exportDestination string
+	exportOutput      string
+)
+
+var snapExportCmd = &cobra.Command{
+	Use:   "export <wsid> <snapshot-id>",
+	Short: "Export a snapshot as a tar archive",
+	Long: `Export a snapshot as a tar archive, zstd-compressed by default, with a
+SHA-256 manifest of every file as its last entry. With -o the command waits
+for the export to finish, downloads it and verifies its digest.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		snapshotID := args[1]
+		req := map[string]string{
+			"compression": exportCompression,
+			"destination": exportDestination,
+		}
+
+		client := NewClient(apiURL)
+
+		var resp TaskRef
+		err := postWithHeaders(client, withPriority("/v1/workspaces/"+wsid+"/snapshots/"+snapshotID+":export"), req, &resp, mutationHeaders())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if exportOutput != "" {
+			if err := waitAndDownloadExport(client, resp.ExportID, exportOutput); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		if printTaskOutcome(resp) {
+			return
+		}
+
+		fmt.Printf("Export task created.\n")
+		fmt.Printf("Export ID: %s\n", resp.ExportID)
+		fmt.Printf("Task ID: %s\n", resp.TaskID)
+		fmt.Printf("Check status: wvsctl export get %s\n", resp.ExportID)
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Snapshot export commands",
+}
+
+var exportListCmd = &cobra.Command{
+	Use:   "list <wsid> <snapshot-id>",
+	Short: "List the exports of a snapshot",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := NewClient(apiURL)
+
+		var resp struct {
+			Exports []ExportRow `json:"exports"`
+		}
+		if err := client.Get("/v1/workspaces/"+args[0]+"/snapshots/"+args[1]+"/exports", &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printResult(resp.Exports)
+	},
+}
+
+var exportGetCmd = &cobra.Command{
+	Use:   "get <export-id>",
+	Short: "Get export details",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := NewClient(apiURL)
+
+		var resp ExportRow
+		if err := client.Get("/v1/exports/"+args[0], &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printResult(resp)
+	},
+}
+
+var exportDownloadCmd = &cobra.Command{
+	Use:   "download <export-id>",
+	Short: "Download a finished export and verify its digest",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := NewClient(apiURL)
+
+		var e ExportRow
+		if err := client.Get("/v1/exports/"+args[0], &e); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := downloadExport(client, e, exportOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// waitAndDownloadExport polls an export until its task finishes, then
+// downloads it.
+func waitAndDownloadExport(client *Client, exportID, out string) error {
+	var e ExportRow
+	for {
+		if err := client.Get("/v1/exports/"+exportID, &e); err != nil {
+			return err
+		}
+		if isTerminalTaskStatus(e.Status) {
+			break
+		}
+		time.Sleep(1 * time.Second)
+	}
+	if e.Status != "SUCCEEDED" {
+		return fmt.Errorf("export %s: task %s is %s", exportID, e.TaskID, e.Status)
+	}
+	return downloadExport(client, e, out)
+}
+
+// downloadExport writes an export to out ("" picks a name from the export
+// ID) and checks the bytes against the recorded digest, removing the file on
+// a mismatch.
+func downloadExport(client *Client, e ExportRow, out string) error {
+	if e.Digest == "" {
+		return fmt.Errorf("export %s is not finished (%s)", e.ExportID, e.Status)
+	}
+	if out == "" {
+		out = e.ExportID + ".tar"
+		if e.Compression == "zstd" {
+			out += ".zst"
+		}
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if err := client.Download("/v1/exports/"+e.ExportID+"/download", io.MultiWriter(f, h)); err != nil {
+		os.Remove(out)
+		return err
+	}
+	if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, e.Digest) {
+		os.Remove(out)
+		return fmt.Errorf("digest mismatch: got %s, want %s", got, e.Digest)
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	abs, _ := filepath.Abs(out)
+	fmt.Printf("Downloaded %s (%s)\n", abs, e.Digest)
+	return nil
+}
+
+func init() {
+	snapExportCmd.Flags().StringVar(&exportCompression, "compression", "", "Archive compression: zstd (default) or none")
+	snapExportCmd.Flags().StringVar(&exportDestination, "destination", "", "Where to write the archive: local (default) or s3")
+	snapExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Wait for the export and download it to this file")
+	exportDownloadCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "File to write (default <export-id>.tar[.zst])")
+	addMutationFlags(snapExportCmd)
+	snapshotCmd.AddCommand(snapExportCmd)
+	exportCmd.AddCommand(exportListCmd, exportGetCmd, exportDownloadCmd)
+	rootCmd.AddCommand(exportCmd)
+}
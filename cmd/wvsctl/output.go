@@ -36,6 +36,47 @@ func printTable(v interface{}) {
 		for _, s := range data {
 			fmt.Fprintf(w, "%s\t%s\t%s\n", s.SnapshotID, truncate(s.Message, 40), s.CreatedAt)
 		}
+	case []TreeEntryRow:
+		if len(data) == 0 {
+			fmt.Println("Empty directory.")
+			return
+		}
+		fmt.Fprintln(w, "MODE\tTYPE\tSIZE\tMODIFIED\tNAME")
+		for _, e := range data {
+			name := e.Name
+			if e.LinkTarget != "" {
+				name += " -> " + e.LinkTarget
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", e.Mode, e.Type, e.Size, e.ModTime, name)
+		}
+	case []ExportRow:
+		if len(data) == 0 {
+			fmt.Println("No exports found.")
+			return
+		}
+		fmt.Fprintln(w, "EXPORT ID\tSTATUS\tCOMPRESSION\tDESTINATION\tCREATED")
+		for _, e := range data {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.ExportID, e.Status, e.Compression, e.Destination, e.CreatedAt)
+		}
+	case ReplicationRow:
+		fmt.Fprintf(w, "WSID:\t%s\n", data.WSID)
+		fmt.Fprintf(w, "Peer:\t%s (workspace %s)\n", data.PeerURL, data.PeerWSID)
+		fmt.Fprintf(w, "Enabled:\t%t\n", data.Enabled)
+		fmt.Fprintf(w, "Pending:\t%d snapshots\n", data.PendingSnapshots)
+		fmt.Fprintf(w, "Lag:\t%.0fs\n", data.LagSeconds)
+		w.Flush()
+		if len(data.Replicas) == 0 {
+			fmt.Println("\nNo replicas yet.")
+			return
+		}
+		fmt.Fprintln(w, "\nSNAPSHOT ID\tSTATE\tBASE\tBYTES\tREPLICATED")
+		for _, r := range data.Replicas {
+			var bytes int64
+			if r.BytesShipped != nil {
+				bytes = *r.BytesShipped
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", r.SnapshotID, r.State, r.BaseSnapshotID, bytes, r.ReplicatedAt)
+		}
 	case []TaskRow:
 		if len(data) == 0 {
 			fmt.Println("No tasks found.")
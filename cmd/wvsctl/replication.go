@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+type ReplicaRow struct {
+	SnapshotID     string `json:"snapshot_id"`
+	State          string `json:"state"`
+	BaseSnapshotID string `json:"base_snapshot_id,omitempty"`
+	TaskID         string `json:"task_id"`
+	BytesShipped   *int64 `json:"bytes_shipped,omitempty"`
+	FilesChanged   *int64 `json:"files_changed,omitempty"`
+	FilesDeleted   *int64 `json:"files_deleted,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	ReplicatedAt   string `json:"replicated_at,omitempty"`
+}
+
+type ReplicationRow struct {
+	WSID             string       `json:"wsid"`
+	PeerURL          string       `json:"peer_url"`
+	PeerWSID         string       `json:"peer_wsid"`
+	Enabled          bool         `json:"enabled"`
+	PendingSnapshots int64        `json:"pending_snapshots"`
+	LagSeconds       float64      `json:"lag_seconds"`
+	Replicas         []ReplicaRow `json:"replicas"`
+	CreatedAt        string       `json:"created_at"`
+	UpdatedAt        string       `json:"updated_at"`
+}
+
+var replicationCmd = &cobra.Command{
+	Use:     "replication",
+	Aliases: []string{"repl"},
+	Short:   "Snapshot replication commands",
+}
+
+var replSetCmd = &cobra.Command{
+	Use:   "set <wsid> <peer-url> [peer-wsid]",
+	Short: "Replicate a workspace's snapshots to a peer WVS",
+	Long: `Replicate a workspace's snapshots to the WVS API at peer-url, into
+peer-wsid (default: the same workspace ID), which must already exist there.
+Pointing a workspace at a different peer ships the next snapshot in full.`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		req := map[string]string{"peer_url": args[1]}
+		if len(args) == 3 {
+			req["peer_wsid"] = args[2]
+		}
+
+		client := NewClient(apiURL)
+
+		var resp ReplicationRow
+		if err := client.Put("/v1/workspaces/"+wsid+"/replication", req, &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Workspace %s replicates to %s (workspace %s).\n", resp.WSID, resp.PeerURL, resp.PeerWSID)
+	},
+}
+
+var replGetCmd = &cobra.Command{
+	Use:   "get <wsid>",
+	Short: "Show replication target, lag and recent replicas",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := NewClient(apiURL)
+
+		var resp ReplicationRow
+		if err := client.Get("/v1/workspaces/"+args[0]+"/replication", &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printResult(resp)
+	},
+}
+
+var replDisableCmd = &cobra.Command{
+	Use:   "disable <wsid>",
+	Short: "Stop replicating a workspace",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := NewClient(apiURL)
+
+		var resp ReplicationRow
+		if err := client.Delete("/v1/workspaces/"+args[0]+"/replication", &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Replication disabled for workspace %s.\n", resp.WSID)
+	},
+}
+
+func init() {
+	replicationCmd.AddCommand(replSetCmd, replGetCmd, replDisableCmd)
+	rootCmd.AddCommand(replicationCmd)
+}
@@ -3,18 +3,29 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 
-	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
 type SnapshotRow struct {
-	SnapshotID string `json:"snapshot_id"`
-	WSID       string `json:"wsid"`
-	FSPath     string `json:"fs_path"`
-	Message    string `json:"message"`
-	CreatedAt  string `json:"created_at"`
+	SnapshotID  string                 `json:"snapshot_id"`
+	WSID        string                 `json:"wsid"`
+	FSPath      string                 `json:"fs_path"`
+	Message     string                 `json:"message"`
+	State       string                 `json:"state"`
+	TaskID      string                 `json:"task_id,omitempty"`
+	SizeBytes   *int64                 `json:"size_bytes,omitempty"`
+	FileCount   *int64                 `json:"file_count,omitempty"`
+	UniqueBytes *int64                 `json:"unique_bytes,omitempty"`
+	SharedBytes *int64                 `json:"shared_bytes,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	TreeDigest  string                 `json:"tree_digest,omitempty"`
+	VerifyState string                 `json:"verify_state,omitempty"`
+	VerifiedAt  string                 `json:"verified_at,omitempty"`
+	CreatedAt   string                 `json:"created_at"`
 }
 
 type SnapshotListResponse struct {
@@ -39,19 +50,26 @@ var snapCreateCmd = &cobra.Command{
 			message = args[1]
 		}
 
-		idempotencyKey := uuid.New().String()
 		client := NewClient(apiURL)
 
 		var resp TaskRef
 		req := map[string]string{"message": message}
 
-		err := postWithHeaders(client, "/v1/workspaces/"+wsid+"/snapshots", req, &resp, map[string]string{
-			"Idempotency-Key": idempotencyKey,
-		})
+		err := postWithHeaders(client, withPriority("/v1/workspaces/"+wsid+"/snapshots"), req, &resp, mutationHeaders())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		snapshotID := resp.SnapshotID
+		if snapshotID == "" {
+			snapshotID, _ = resp.Params["snapshot_id"].(string)
+		}
+		if snapshotID != "" {
+			fmt.Printf("Snapshot ID: %s\n", snapshotID)
+		}
+		if printTaskOutcome(resp) {
+			return
+		}
 
 		fmt.Printf("Snapshot creation task created.\n")
 		fmt.Printf("Task ID: %s\n", resp.TaskID)
@@ -76,6 +94,24 @@ var snapListCmd = &cobra.Command{
 	},
 }
 
+var snapGetCmd = &cobra.Command{
+	Use:   "get <wsid> <snapshot-id>",
+	Short: "Get a snapshot, including its size and metadata",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		snapshotID := args[1]
+		client := NewClient(apiURL)
+
+		var resp SnapshotRow
+		if err := client.Get("/v1/workspaces/"+wsid+"/snapshots/"+snapshotID, &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printResult(resp)
+	},
+}
+
 var snapDropCmd = &cobra.Command{
 	Use:   "drop <wsid> <snapshot-id>",
 	Short: "Drop a snapshot",
@@ -84,14 +120,15 @@ var snapDropCmd = &cobra.Command{
 		wsid := args[0]
 		snapshotID := args[1]
 
-		idempotencyKey := uuid.New().String()
 		client := NewClient(apiURL)
 
 		var resp TaskRef
 
 		// Need to do DELETE with Idempotency-Key header
-		req, _ := http.NewRequest("DELETE", client.baseURL+"/v1/workspaces/"+wsid+"/snapshots/"+snapshotID, nil)
-		req.Header.Set("Idempotency-Key", idempotencyKey)
+		req, _ := http.NewRequest("DELETE", client.baseURL+withPriority("/v1/workspaces/"+wsid+"/snapshots/"+snapshotID), nil)
+		for k, v := range mutationHeaders() {
+			req.Header.Set(k, v)
+		}
 		httpResp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -99,13 +136,207 @@ var snapDropCmd = &cobra.Command{
 		}
 		defer httpResp.Body.Close()
 		parseResponse(httpResp, &resp)
+		if printTaskOutcome(resp) {
+			return
+		}
 
 		fmt.Printf("Snapshot drop task created.\n")
 		fmt.Printf("Task ID: %s\n", resp.TaskID)
 	},
 }
 
+var snapVerifyCmd = &cobra.Command{
+	Use:   "verify <wsid> <snapshot-id>",
+	Short: "Check a snapshot's files against its recorded tree digest",
+	Long: `Recompute a snapshot's tree digest and compare it with the one recorded
+when the snapshot was taken. With --wait the command exits non-zero if the
+snapshot is CORRUPT.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		snapshotID := args[1]
+		client := NewClient(apiURL)
+
+		var resp TaskRef
+		err := postWithHeaders(client, withPriority("/v1/workspaces/"+wsid+"/snapshots/"+snapshotID+":verify"), nil, &resp, mutationHeaders())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if printTaskOutcome(resp) {
+			if resp.Result["verify_state"] == "CORRUPT" {
+				fmt.Fprintf(os.Stderr, "Snapshot %s is CORRUPT: expected %v, got %v\n",
+					snapshotID, resp.Result["expected_digest"], resp.Result["tree_digest"])
+				os.Exit(2)
+			}
+			return
+		}
+
+		fmt.Printf("Verify task created.\n")
+		fmt.Printf("Task ID: %s\n", resp.TaskID)
+		fmt.Printf("Check status: wvsctl task get %s\n", resp.TaskID)
+	},
+}
+
+type TreeEntryRow struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Type       string `json:"type"`
+	Size       int64  `json:"size"`
+	Mode       string `json:"mode"`
+	ModTime    string `json:"mod_time"`
+	LinkTarget string `json:"link_target,omitempty"`
+}
+
+var snapTreeCmd = &cobra.Command{
+	Use:   "tree <wsid> <snapshot-id> [path]",
+	Short: "List a directory inside a snapshot",
+	Args:  cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		snapshotID := args[1]
+		q := url.Values{}
+		if len(args) > 2 {
+			q.Set("path", args[2])
+		}
+		client := NewClient(apiURL)
+
+		var resp struct {
+			Entries []TreeEntryRow `json:"entries"`
+		}
+		if err := client.Get("/v1/workspaces/"+wsid+"/snapshots/"+snapshotID+"/tree?"+q.Encode(), &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printResult(resp.Entries)
+	},
+}
+
+var snapCatCmd = &cobra.Command{
+	Use:   "cat <wsid> <snapshot-id> <path>",
+	Short: "Print a file from a snapshot",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		snapshotID := args[1]
+		q := url.Values{"path": {args[2]}}
+		client := NewClient(apiURL)
+
+		if err := client.Download("/v1/workspaces/"+wsid+"/snapshots/"+snapshotID+"/files?"+q.Encode(), os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var snapImportCmd = &cobra.Command{
+	Use:   "import <wsid> <archive-file|url> [message]",
+	Short: "Create a snapshot from a tar or tar.zst archive",
+	Long: `Create a snapshot from a tar archive, optionally zstd-compressed. A local
+file is uploaded through the API; an s3:// or http(s):// URL is fetched by
+the executor. If the archive carries a .wvs/MANIFEST.sha256, as exports do,
+every file must match it.`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		source := args[1]
+		message := ""
+		if len(args) > 2 {
+			message = args[2]
+		}
+
+		client := NewClient(apiURL)
+		path := "/v1/workspaces/" + wsid + "/snapshots:import"
+
+		var resp TaskRef
+		var err error
+		if strings.HasPrefix(source, "s3://") || strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+			req := map[string]string{"source": source, "message": message}
+			err = postWithHeaders(client, withPriority(path), req, &resp, mutationHeaders())
+		} else {
+			err = uploadArchive(client, path, source, message, &resp)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if printTaskOutcome(resp) {
+			return
+		}
+
+		fmt.Printf("Import task created.\n")
+		fmt.Printf("Snapshot ID: %s\n", resp.SnapshotID)
+		fmt.Printf("Task ID: %s\n", resp.TaskID)
+		fmt.Printf("Check status: wvsctl task get %s\n", resp.TaskID)
+	},
+}
+
+// uploadArchive posts a local archive file as the body of an import.
+func uploadArchive(client *Client, path, file, message string, out *TaskRef) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{}
+	if message != "" {
+		q.Set("message", message)
+	}
+	if priority != "" {
+		q.Set("priority", priority)
+	}
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	contentType := "application/x-tar"
+	if strings.HasSuffix(file, ".zst") {
+		contentType = "application/zstd"
+	}
+	return client.Upload(path, f, info.Size(), contentType, mutationHeaders(), out)
+}
+
+var snapRestoreCmd = &cobra.Command{
+	Use:   "restore <wsid> <snapshot-id> -- <path>...",
+	Short: "Copy files or directories from a snapshot into current",
+	Long: `Copy files or directories from a snapshot into current, replacing
+whatever is at those paths. Paths are relative to the workspace root;
+everything else in current is left untouched.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.ArgsLenAtDash() != 2 || len(args) < 3 {
+			return fmt.Errorf("usage: restore <wsid> <snapshot-id> -- <path>...")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		snapshotID := args[1]
+		req := map[string][]string{"paths": args[2:]}
+
+		client := NewClient(apiURL)
+
+		var resp TaskRef
+		err := postWithHeaders(client, withPriority("/v1/workspaces/"+wsid+"/snapshots/"+snapshotID+":restore"), req, &resp, mutationHeaders())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if printTaskOutcome(resp) {
+			return
+		}
+
+		fmt.Printf("Restore task created.\n")
+		fmt.Printf("Task ID: %s\n", resp.TaskID)
+		fmt.Printf("Check status: wvsctl task get %s\n", resp.TaskID)
+	},
+}
+
 func init() {
-	snapshotCmd.AddCommand(snapCreateCmd, snapListCmd, snapDropCmd)
+	addMutationFlags(snapCreateCmd, snapDropCmd, snapRestoreCmd, snapImportCmd, snapVerifyCmd)
+	snapshotCmd.AddCommand(snapCreateCmd, snapListCmd, snapGetCmd, snapDropCmd, snapRestoreCmd, snapTreeCmd, snapCatCmd, snapImportCmd, snapVerifyCmd)
 	rootCmd.AddCommand(snapshotCmd)
 }
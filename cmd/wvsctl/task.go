@@ -13,6 +13,7 @@ type TaskRow struct {
 	WSID            string                 `json:"wsid"`
 	Op              string                 `json:"op"`
 	Status          string                 `json:"status"`
+	Priority        string                 `json:"priority"`
 	IdempotencyKey  string                 `json:"idempotency_key"`
 	CreatedAt       string                 `json:"created_at"`
 	Attempt         int32                  `json:"attempt"`
@@ -20,6 +21,10 @@ type TaskRow struct {
 	Params          map[string]interface{} `json:"params"`
 	Result          map[string]interface{} `json:"result,omitempty"`
 	Error           map[string]interface{} `json:"error,omitempty"`
+	RetryOf         string                 `json:"retry_of,omitempty"`
+	DLQResolution   string                 `json:"dlq_resolution,omitempty"`
+	DependsOn       string                 `json:"depends_on,omitempty"`
+	BatchID         string                 `json:"batch_id,omitempty"`
 }
 
 type TaskListResponse struct {
@@ -72,30 +77,70 @@ var taskWatchCmd = &cobra.Command{
 		taskID := args[0]
 		client := NewClient(apiURL)
 
+		var resp TaskRow
+		if err := client.Get("/v1/tasks/"+taskID, &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printTaskProgress(taskID, resp)
+		if isTerminalTaskStatus(resp.Status) {
+			return
+		}
+
+		// Follow the task's event stream; every event triggers a refresh.
+		done := false
+		err := client.Stream("/v1/tasks/"+taskID+"/events", "", func(ev SSEEvent) bool {
+			if err := client.Get("/v1/tasks/"+taskID, &resp); err != nil {
+				return true
+			}
+			if resp.Status != "" {
+				printTaskProgress(taskID, resp)
+			}
+			done = isTerminalTaskStatus(resp.Status)
+			return !done
+		})
+		if done {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Event stream unavailable (%v), falling back to polling\n", err)
+		}
+
 		for {
-			var resp TaskRow
 			if err := client.Get("/v1/tasks/"+taskID, &resp); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
-
-			fmt.Printf("Task %s: %s (attempt %d/%d)\n", taskID[:8], resp.Status, resp.Attempt, resp.MaxAttempts)
-
-			if resp.Status == "SUCCEEDED" || resp.Status == "FAILED" || resp.Status == "CANCELED" || resp.Status == "DEAD" {
-				if resp.Result != nil {
-					fmt.Printf("Result: %v\n", resp.Result)
-				}
-				if resp.Error != nil {
-					fmt.Printf("Error: %v\n", resp.Error)
-				}
+			printTaskProgress(taskID, resp)
+			if isTerminalTaskStatus(resp.Status) {
 				break
 			}
-
 			time.Sleep(1 * time.Second)
 		}
 	},
 }
 
+func printTaskProgress(taskID string, t TaskRow) {
+	fmt.Printf("Task %s: %s (attempt %d/%d)\n", taskID[:8], t.Status, t.Attempt, t.MaxAttempts)
+	if !isTerminalTaskStatus(t.Status) {
+		return
+	}
+	if t.Result != nil {
+		fmt.Printf("Result: %v\n", t.Result)
+	}
+	if t.Error != nil {
+		fmt.Printf("Error: %v\n", t.Error)
+	}
+}
+
+func isTerminalTaskStatus(status string) bool {
+	switch status {
+	case "SUCCEEDED", "CANCELED", "DEAD":
+		return true
+	}
+	return false
+}
+
 var taskCancelCmd = &cobra.Command{
 	Use:   "cancel <task-id>",
 	Short: "Cancel a task",
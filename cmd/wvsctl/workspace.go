@@ -6,18 +6,24 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
 type WorkspaceRow struct {
-	WSID              string `json:"wsid"`
-	State             string `json:"state"`
-	Owner             string `json:"owner"`
-	CurrentSnapshotID string `json:"current_snapshot_id"`
-	CreatedAt         string `json:"created_at"`
+	WSID              string          `json:"wsid"`
+	State             string          `json:"state"`
+	Owner             string          `json:"owner"`
+	CurrentSnapshotID string          `json:"current_snapshot_id"`
+	QuiescePolicy     string          `json:"quiesce_policy"`
+	Hooks             json.RawMessage `json:"hooks,omitempty"`
+	ExcludePatterns   []string        `json:"exclude_patterns,omitempty"`
+	UsageBytes        int64           `json:"usage_bytes"`
+	CreatedAt         string          `json:"created_at"`
 }
 
 type WorkspaceListResponse struct {
@@ -26,9 +32,74 @@ type WorkspaceListResponse struct {
 }
 
 type TaskRef struct {
-	TaskID    string `json:"task_id"`
-	Status    string `json:"status"`
-	StatusURL string `json:"status_href"`
+	TaskID    string                 `json:"task_id"`
+	Status    string                 `json:"status"`
+	StatusURL string                 `json:"status_href"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	Result    map[string]interface{} `json:"result,omitempty"`
+	Error     map[string]interface{} `json:"error,omitempty"`
+
+	// Set by snapshot create and import, which reserve the snapshot up front.
+	SnapshotID   string `json:"snapshot_id,omitempty"`
+	SnapshotHref string `json:"snapshot_href,omitempty"`
+
+	// Set by current restore, which reserves the checkpoint up front.
+	CheckpointID string `json:"checkpoint_id,omitempty"`
+
+	// Set by snapshot export, which reserves the export up front.
+	ExportID string `json:"export_id,omitempty"`
+}
+
+// waitFor is the --wait flag shared by the async mutating commands.
+var waitFor time.Duration
+
+// priority is the --priority flag shared by the async mutating commands.
+var priority string
+
+// withPriority appends the --priority flag, if set, to a mutation's path.
+func withPriority(path string) string {
+	if priority == "" {
+		return path
+	}
+	return path + "?priority=" + url.QueryEscape(priority)
+}
+
+// addMutationFlags registers --wait and --priority on async mutating commands.
+func addMutationFlags(cmds ...*cobra.Command) {
+	for _, c := range cmds {
+		c.Flags().DurationVar(&waitFor, "wait", 0, "Wait up to this long for the task to finish (e.g. 30s)")
+		c.Flags().StringVar(&priority, "priority", "", "Queue priority: interactive, normal or background")
+	}
+}
+
+// mutationHeaders returns the headers for an async mutation, asking the API
+// to block until the task finishes when --wait is set.
+func mutationHeaders() map[string]string {
+	headers := map[string]string{"Idempotency-Key": uuid.New().String()}
+	if waitFor > 0 {
+		headers["Prefer"] = fmt.Sprintf("wait=%d", int(waitFor.Seconds()))
+	}
+	return headers
+}
+
+// printTaskOutcome reports a finished task when the API answered synchronously.
+// It returns false if the task is still in flight.
+func printTaskOutcome(resp TaskRef) bool {
+	if !isTerminalTaskStatus(resp.Status) {
+		return false
+	}
+	fmt.Printf("Task ID: %s\n", resp.TaskID)
+	fmt.Printf("Status: %s\n", resp.Status)
+	if resp.Result != nil {
+		fmt.Printf("Result: %v\n", resp.Result)
+	}
+	if resp.Error != nil {
+		fmt.Printf("Error: %v\n", resp.Error)
+	}
+	if resp.Status != "SUCCEEDED" {
+		os.Exit(1)
+	}
+	return true
 }
 
 var workspaceCmd = &cobra.Command{
@@ -46,23 +117,37 @@ var wsCreateCmd = &cobra.Command{
 		rootPath := args[1]
 		owner := args[2]
 
-		idempotencyKey := uuid.New().String()
 		client := NewClient(apiURL)
 
 		var resp TaskRef
-		req := map[string]string{
+		req := map[string]interface{}{
 			"wsid":      wsid,
 			"root_path": rootPath,
 			"owner":     owner,
 		}
+		if createQuiescePolicy != "" {
+			req["quiesce_policy"] = createQuiescePolicy
+		}
+		if createHooksFile != "" {
+			hooks, err := readHooksFile(createHooksFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			req["hooks"] = hooks
+		}
+		if len(createExcludes) > 0 {
+			req["exclude_patterns"] = createExcludes
+		}
 
-		err := postWithHeaders(client, "/v1/workspaces", req, &resp, map[string]string{
-			"Idempotency-Key": idempotencyKey,
-		})
+		err := postWithHeaders(client, withPriority("/v1/workspaces"), req, &resp, mutationHeaders())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		if printTaskOutcome(resp) {
+			return
+		}
 
 		fmt.Printf("Workspace creation task created.\n")
 		fmt.Printf("Task ID: %s\n", resp.TaskID)
@@ -87,6 +172,172 @@ var wsGetCmd = &cobra.Command{
 	},
 }
 
+// WorkspaceQuota mirrors the API's per-workspace storage limits.
+type WorkspaceQuota struct {
+	SoftBytes     *int64 `json:"soft_bytes,omitempty"`
+	HardBytes     *int64 `json:"hard_bytes,omitempty"`
+	SoftSnapshots *int32 `json:"soft_snapshots,omitempty"`
+	HardSnapshots *int32 `json:"hard_snapshots,omitempty"`
+}
+
+// WorkspaceUsage mirrors the API's workspace usage rollup.
+type WorkspaceUsage struct {
+	WSID            string          `json:"wsid"`
+	SnapshotCount   int64           `json:"snapshot_count"`
+	FileCount       int64           `json:"file_count"`
+	LogicalBytes    int64           `json:"logical_bytes"`
+	UniqueBytes     int64           `json:"unique_bytes"`
+	LiveUniqueBytes int64           `json:"live_unique_bytes"`
+	UsageBytes      int64           `json:"usage_bytes"`
+	Quota           *WorkspaceQuota `json:"quota,omitempty"`
+}
+
+var wsUsageCmd = &cobra.Command{
+	Use:   "usage <wsid>",
+	Short: "Show storage used by a workspace's snapshots",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		client := NewClient(apiURL)
+
+		var usage WorkspaceUsage
+		if err := client.Get("/v1/workspaces/"+wsid+"/usage", &usage); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printResult(usage)
+	},
+}
+
+var (
+	quotaSoftBytes     int64
+	quotaHardBytes     int64
+	quotaSoftSnapshots int32
+	quotaHardSnapshots int32
+
+	createQuiescePolicy string
+	createHooksFile     string
+	createExcludes      []string
+)
+
+var wsQuiescePolicyCmd = &cobra.Command{
+	Use:   "quiesce-policy <wsid> <require-guest|best-effort|none>",
+	Short: "Set how the guest is quiesced before snapshots and switches",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		client := NewClient(apiURL)
+
+		var ws WorkspaceRow
+		if err := client.Put("/v1/workspaces/"+wsid+"/quiesce-policy", map[string]string{"policy": args[1]}, &ws); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Quiesce policy for workspace %s is now %s.\n", ws.WSID, ws.QuiescePolicy)
+	},
+}
+
+var wsHooksCmd = &cobra.Command{
+	Use:   "hooks <wsid> <file|->",
+	Short: "Replace the workspace's pre_snapshot and post_restore hooks from a JSON file",
+	Long: `Replace the workspace's hooks with the JSON object in <file>, or on stdin
+for "-". An empty object removes them. Hooks give their authors a shell on
+the executor, so the API refuses them unless started with
+WVS_HOOKS_ENABLED=true, and they only run on executors started with
+EXECUTOR_HOOKS_ENABLED=true. For example:
+
+  {"pre_snapshot": [{"name": "wal", "command": "sqlite3 app.db 'PRAGMA wal_checkpoint(TRUNCATE)'",
+                     "timeout_seconds": 30, "on_failure": "fail"}],
+   "post_restore": [{"command": "rm -rf .cache", "on_failure": "ignore"}]}`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		client := NewClient(apiURL)
+
+		hooks, err := readHooksFile(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		var ws WorkspaceRow
+		if err := client.Put("/v1/workspaces/"+wsid+"/hooks", hooks, &ws); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Hooks updated for workspace %s.\n", ws.WSID)
+	},
+}
+
+// readHooksFile reads a hooks JSON object from path, or stdin for "-".
+func readHooksFile(path string) (json.RawMessage, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("%s: not valid JSON", path)
+	}
+	return json.RawMessage(data), nil
+}
+
+var wsExcludesCmd = &cobra.Command{
+	Use:   "excludes <wsid> [pattern...]",
+	Short: "Replace the patterns snapshots leave out (no patterns clears them)",
+	Long: `Replace the workspace's exclude patterns. They apply on top of the
+.wvsignore in the live directory and use the same .gitignore-like syntax,
+for example: wvsctl ws excludes ws1 node_modules/ .venv/ '/build'`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		client := NewClient(apiURL)
+
+		patterns := args[1:]
+		var ws WorkspaceRow
+		if err := client.Put("/v1/workspaces/"+wsid+"/exclude-patterns", map[string][]string{"patterns": patterns}, &ws); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Workspace %s now excludes %d pattern(s) from snapshots.\n", ws.WSID, len(ws.ExcludePatterns))
+	},
+}
+
+var wsQuotaCmd = &cobra.Command{
+	Use:   "quota <wsid>",
+	Short: "Set workspace storage quotas (omitted limits are cleared)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		wsid := args[0]
+		client := NewClient(apiURL)
+
+		var quota WorkspaceQuota
+		if cmd.Flags().Changed("soft-bytes") {
+			quota.SoftBytes = &quotaSoftBytes
+		}
+		if cmd.Flags().Changed("hard-bytes") {
+			quota.HardBytes = &quotaHardBytes
+		}
+		if cmd.Flags().Changed("soft-snapshots") {
+			quota.SoftSnapshots = &quotaSoftSnapshots
+		}
+		if cmd.Flags().Changed("hard-snapshots") {
+			quota.HardSnapshots = &quotaHardSnapshots
+		}
+
+		var ws WorkspaceRow
+		if err := client.Put("/v1/workspaces/"+wsid+"/quota", quota, &ws); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Quota updated for workspace %s.\n", ws.WSID)
+	},
+}
+
 var wsListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List workspaces",
@@ -128,7 +379,7 @@ var wsRetryInitCmd = &cobra.Command{
 		client := NewClient(apiURL)
 
 		var resp TaskRef
-		if err := client.Post("/v1/workspaces/"+wsid+"/retry-init", nil, &resp); err != nil {
+		if err := client.Post(withPriority("/v1/workspaces/"+wsid+"/retry-init"), nil, &resp); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -138,7 +389,16 @@ var wsRetryInitCmd = &cobra.Command{
 }
 
 func init() {
-	workspaceCmd.AddCommand(wsCreateCmd, wsGetCmd, wsListCmd, wsDisableCmd, wsRetryInitCmd)
+	addMutationFlags(wsCreateCmd)
+	wsCreateCmd.Flags().StringVar(&createQuiescePolicy, "quiesce-policy", "", "Quiesce policy: require-guest, best-effort (default) or none")
+	wsCreateCmd.Flags().StringArrayVar(&createExcludes, "exclude", nil, "Pattern to leave out of snapshots, on top of .wvsignore (repeatable)")
+	wsCreateCmd.Flags().StringVar(&createHooksFile, "hooks-file", "", "JSON file with the workspace's hooks (see 'ws hooks')")
+	wsRetryInitCmd.Flags().StringVar(&priority, "priority", "", "Queue priority: interactive, normal or background")
+	wsQuotaCmd.Flags().Int64Var(&quotaSoftBytes, "soft-bytes", 0, "Warn once usage reaches this many bytes")
+	wsQuotaCmd.Flags().Int64Var(&quotaHardBytes, "hard-bytes", 0, "Reject snapshots and current switches at this many bytes")
+	wsQuotaCmd.Flags().Int32Var(&quotaSoftSnapshots, "soft-snapshots", 0, "Warn once the workspace holds this many snapshots")
+	wsQuotaCmd.Flags().Int32Var(&quotaHardSnapshots, "hard-snapshots", 0, "Reject new snapshots at this many snapshots")
+	workspaceCmd.AddCommand(wsCreateCmd, wsGetCmd, wsUsageCmd, wsQuotaCmd, wsQuiescePolicyCmd, wsHooksCmd, wsExcludesCmd, wsListCmd, wsDisableCmd, wsRetryInitCmd)
 	rootCmd.AddCommand(workspaceCmd)
 }
 
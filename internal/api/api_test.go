@@ -1,13 +1,19 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
 	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/store"
 )
 
 // Mock tests for API handlers without DB dependency
@@ -98,3 +104,209 @@ func TestIdempotencyKeyRequired(t *testing.T) {
 		t.Errorf("expected code WVS_BAD_REQUEST, got %s", resp.Code)
 	}
 }
+
+func TestWriteSSE(t *testing.T) {
+	var buf bytes.Buffer
+	taskID := "task-123"
+	err := writeSSE(&buf, core.AuditEvent{
+		EventID: 42,
+		Action:  "task.succeeded",
+		TaskID:  &taskID,
+		Actor:   json.RawMessage(`{"source":"worker"}`),
+		Payload: json.RawMessage(`{"status":"SUCCEEDED"}`),
+	})
+	if err != nil {
+		t.Fatalf("writeSSE failed: %s", err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "id: 42\nevent: task.succeeded\ndata: {") {
+		t.Errorf("unexpected SSE framing: %q", out)
+	}
+	if !strings.HasSuffix(out, "}\n\n") {
+		t.Errorf("expected event to end with a blank line, got %q", out)
+	}
+}
+
+func TestParseLastEventID(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v1/events", nil)
+	if _, ok, err := parseLastEventID(req); ok || err != nil {
+		t.Errorf("expected no cursor, got ok=%v err=%v", ok, err)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/events?last_event_id=7", nil)
+	req.Header.Set("Last-Event-ID", "12")
+	id, ok, err := parseLastEventID(req)
+	if err != nil || !ok || id != 12 {
+		t.Errorf("expected header to win with 12, got id=%d ok=%v err=%v", id, ok, err)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/events?last_event_id=abc", nil)
+	if _, _, err := parseLastEventID(req); err == nil {
+		t.Error("expected error for non-numeric cursor")
+	}
+}
+
+func TestEventRoutesMounted(t *testing.T) {
+	api := &API{log: zap.NewNop(), queries: store.New(nil)}
+	r := api.Router()
+
+	// Without a database the handlers fail, but they must be reached.
+	for _, path := range []string{"/v1/events", "/v1/events?wsid=ws1", "/v1/tasks/t1/events"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code == http.StatusNotFound || w.Code == http.StatusMethodNotAllowed || w.Code == http.StatusUnsupportedMediaType {
+			t.Errorf("GET %s: %d, route not mounted", path, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/v1/events", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /v1/events: %d, want 405", w.Code)
+	}
+}
+
+func TestEventCursorOverlap(t *testing.T) {
+	cur := newEventCursor(10)
+	if cur.mark(10) || cur.mark(3) {
+		t.Error("events at or below the resume point must not be resent")
+	}
+	for _, id := range []int64{11, 12, 14} {
+		if !cur.mark(id) {
+			t.Errorf("event %d not sent", id)
+		}
+	}
+	cur.advance()
+
+	// 13 commits after 14 was sent: the next read still covers it.
+	if from := cur.from(); from >= 13 {
+		t.Fatalf("next read starts after %d, missing 13", from)
+	}
+	if cur.mark(14) || cur.mark(12) {
+		t.Error("overlap re-read resent an event")
+	}
+	if !cur.mark(13) || cur.last != 14 {
+		t.Errorf("late event: last=%d", cur.last)
+	}
+
+	cur.mark(1000)
+	cur.advance()
+	if cur.from() != 1000-sseOverlap || len(cur.sent) != 1 {
+		t.Errorf("from=%d, %d remembered", cur.from(), len(cur.sent))
+	}
+}
+
+func TestIsTerminalTaskEvent(t *testing.T) {
+	for action, want := range map[string]bool{
+		"task.succeeded": true,
+		"task.dead":      true,
+		"task.canceled":  true,
+		"task.running":   false,
+		"task.failed":    false,
+		"task.cancel":    false,
+		"snapshot.drop":  false,
+	} {
+		if got := isTerminalTaskEvent(action); got != want {
+			t.Errorf("isTerminalTaskEvent(%q) = %v, want %v", action, got, want)
+		}
+	}
+}
+
+func TestParseWait(t *testing.T) {
+	cases := []struct {
+		name    string
+		url     string
+		prefer  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "none", url: "/x"},
+		{name: "query duration", url: "/x?wait=30s", want: 30 * time.Second},
+		{name: "query seconds", url: "/x?wait=5", want: 5 * time.Second},
+		{name: "prefer header", url: "/x", prefer: "respond-async, wait=10", want: 10 * time.Second},
+		{name: "capped", url: "/x?wait=10m", want: maxSyncWait},
+		{name: "invalid query", url: "/x?wait=soon", wantErr: true},
+		{name: "invalid prefer", url: "/x", prefer: "wait=-1", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tc.url, nil)
+			if tc.prefer != "" {
+				req.Header.Set("Prefer", tc.prefer)
+			}
+			got, err := parseWait(req)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		header         string
+		offset, length int64
+		partial, ok    bool
+	}{
+		{"", 0, 100, false, true},
+		{"bytes=0-9", 0, 10, true, true},
+		{"bytes=90-", 90, 10, true, true},
+		{"bytes=90-200", 90, 10, true, true},
+		{"bytes=-10", 90, 10, true, true},
+		{"bytes=-500", 0, 100, true, true},
+		{"bytes=100-", 0, 0, false, false},
+		{"bytes=-0", 0, 0, false, false},
+		{"bytes=0-1,5-6", 0, 100, false, true},
+		{"bytes=9-1", 0, 100, false, true},
+		{"items=0-1", 0, 100, false, true},
+	}
+	for _, tt := range tests {
+		offset, length, partial, ok := parseByteRange(tt.header, 100)
+		if offset != tt.offset || length != tt.length || partial != tt.partial || ok != tt.ok {
+			t.Errorf("parseByteRange(%q) = %d, %d, %v, %v; want %d, %d, %v, %v",
+				tt.header, offset, length, partial, ok, tt.offset, tt.length, tt.partial, tt.ok)
+		}
+	}
+}
+
+func TestArchiveContentTypeOnlyForImport(t *testing.T) {
+	api := &API{log: zap.NewNop(), queries: store.New(nil)}
+	r := api.Router()
+
+	// Any status but 415 means the upload got past the content-type check.
+	req := httptest.NewRequest("POST", "/v1/workspaces/ws1/snapshots:import", strings.NewReader("tar"))
+	req.Header.Set("Content-Type", "application/x-tar")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code == http.StatusUnsupportedMediaType {
+		t.Errorf("import rejected application/x-tar")
+	}
+
+	req = httptest.NewRequest("POST", "/v1/workspaces", strings.NewReader("tar"))
+	req.Header.Set("Content-Type", "application/x-tar")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("create workspace accepted application/x-tar: %d", w.Code)
+	}
+}
+
+func TestCreateWorkspaceHooksDisabled(t *testing.T) {
+	api := &API{log: zap.NewNop(), queries: store.New(nil)}
+	body := `{"wsid":"ws1","root_path":"/ws/ws1","owner":"me","hooks":{"pre_snapshot":[{"command":"sync"}]}}`
+	req := httptest.NewRequest("POST", "/v1/workspaces", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "k1")
+	w := httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("hooks with WVS_HOOKS_ENABLED unset: got %d, want 412", w.Code)
+	}
+}
@@ -0,0 +1,348 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+// maxBatchSteps bounds how many tasks one batch may enqueue.
+const maxBatchSteps = 20
+
+// BatchStep is one op in a batch. set_current and snapshot_drop name their
+// snapshot either directly or, via SnapshotStep, as the snapshot created by
+// an earlier snapshot_create step (0-based).
+type BatchStep struct {
+	Op           string `json:"op"`
+	Message      string `json:"message,omitempty"`
+	SnapshotID   string `json:"snapshot_id,omitempty"`
+	SnapshotStep *int   `json:"snapshot_step,omitempty"`
+}
+
+type CreateBatchRequest struct {
+	Steps []BatchStep `json:"steps"`
+}
+
+type BatchResponse struct {
+	BatchID   string         `json:"batch_id"`
+	WSID      string         `json:"wsid"`
+	Status    string         `json:"status"`
+	CreatedAt string         `json:"created_at"`
+	Tasks     []TaskResponse `json:"tasks"`
+}
+
+// batchStep is a validated step, ready to be enqueued.
+type batchStep struct {
+	op         core.TaskOp
+	snapshotID string
+	params     map[string]string
+}
+
+// CreateBatch enqueues an ordered list of ops as a chain: each step runs only
+// after the previous one succeeded, and a failed step cancels the rest.
+func (a *API) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+
+	ws, err := a.queries.GetWorkspace(ctx, wsid)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+	if ws.State == string(core.WorkspaceDisabled) {
+		WriteError(w, core.NewAppError(core.ErrGone, "workspace is disabled"))
+		return
+	}
+
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "Idempotency-Key header required"))
+		return
+	}
+	if !validateWait(w, r) {
+		return
+	}
+	// Without ?priority= each step takes its own op's default priority.
+	explicitPriority := r.URL.Query().Get("priority") != ""
+	priority, ok := requestPriority(w, r, "")
+	if !ok {
+		return
+	}
+
+	var req CreateBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid request body"))
+		return
+	}
+
+	body, _ := json.Marshal(req)
+	requestHash := core.ComputeRequestHash(body, "POST", "/v1/workspaces/"+wsid+"/batch")
+
+	existing, err := a.queries.GetBatchByIdempotencyKey(ctx, store.GetBatchByIdempotencyKeyParams{
+		Wsid:           wsid,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err == nil {
+		if existing.RequestHash == requestHash {
+			a.writeBatchResult(w, r, existing)
+			return
+		}
+		WriteError(w, core.NewAppError(core.ErrConflictIdempotent, "idempotency key mismatch"))
+		return
+	}
+
+	steps, appErr := a.planBatch(ctx, ws, req.Steps)
+	if appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	var newSnapshots int64
+	for _, s := range steps {
+		if s.op == core.OpSnapshotCreate {
+			newSnapshots++
+		}
+	}
+	if appErr := a.checkHardQuota(ctx, ws, false); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	tx, err := a.pool.Begin(ctx)
+	if err != nil {
+		a.log.Error("begin batch tx failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create batch"))
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.queries.WithTx(tx)
+
+	if appErr := a.reserveSnapshots(ctx, qtx, ws, newSnapshots); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	batch, err := qtx.CreateBatch(ctx, store.CreateBatchParams{
+		BatchID:        core.NewID(),
+		Wsid:           wsid,
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+	})
+	if err != nil {
+		a.log.Error("create batch failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create batch"))
+		return
+	}
+
+	var prev pgtype.Text
+	for i, s := range steps {
+		taskID := core.NewID()
+		if s.op == core.OpSnapshotCreate {
+			_, err = qtx.CreateSnapshot(ctx, store.CreateSnapshotParams{
+				SnapshotID: s.snapshotID,
+				Wsid:       wsid,
+				FsPath:     filepath.Join(ws.RootPath, "snapshots", s.snapshotID),
+				Message:    textFromString(s.params["message"]),
+				State:      string(core.SnapshotPending),
+				TaskID:     textFromString(taskID),
+			})
+			if err != nil {
+				a.log.Error("reserve batch snapshot failed", zap.Error(err))
+				WriteError(w, core.NewAppError(core.ErrInternal, "failed to create snapshot"))
+				return
+			}
+		}
+
+		stepPriority := priority
+		if !explicitPriority {
+			stepPriority = core.DefaultPriority(s.op)
+		}
+		params, _ := json.Marshal(s.params)
+		_, err = qtx.CreateTask(ctx, a.withRetryPolicy(store.CreateTaskParams{
+			TaskID:         taskID,
+			Wsid:           wsid,
+			Op:             string(s.op),
+			IdempotencyKey: idempotencyKey + "#" + strconv.Itoa(i),
+			RequestHash:    requestHash,
+			Params:         params,
+			TimeoutSeconds: 300,
+			Priority:       int32(stepPriority),
+			DependsOn:      prev,
+			BatchID:        textFromString(batch.BatchID),
+			BatchSeq:       pgtype.Int4{Int32: int32(i), Valid: true},
+		}))
+		if err != nil {
+			a.log.Error("create batch task failed", zap.Error(err))
+			WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+			return
+		}
+		prev = textFromString(taskID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		a.log.Error("commit batch tx failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create batch"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "batch.create", nil, map[string]interface{}{
+		"batch_id": batch.BatchID,
+		"steps":    req.Steps,
+	})
+
+	a.writeBatchResult(w, r, batch)
+}
+
+// planBatch validates the steps against the workspace as it will look when
+// each one runs, and builds their task params.
+func (a *API) planBatch(ctx context.Context, ws store.WvsWorkspace, req []BatchStep) ([]batchStep, *core.AppError) {
+	if len(req) == 0 {
+		return nil, core.NewAppError(core.ErrBadRequest, "steps required")
+	}
+	if len(req) > maxBatchSteps {
+		return nil, core.NewAppError(core.ErrBadRequest, fmt.Sprintf("at most %d steps per batch", maxBatchSteps))
+	}
+
+	current := ws.CurrentSnapshotID.String
+	steps := make([]batchStep, len(req))
+	for i, rs := range req {
+		op := core.TaskOp(rs.Op)
+		if !core.BatchOps[op] {
+			return nil, core.NewAppError(core.ErrBadRequest, fmt.Sprintf("step %d: unsupported op %q", i, rs.Op))
+		}
+		step := batchStep{op: op}
+
+		if op == core.OpSnapshotCreate {
+			step.snapshotID = core.NewID()
+			step.params = map[string]string{
+				"snapshot_id": step.snapshotID,
+				"message":     rs.Message,
+			}
+			if current != "" {
+				step.params["parent_snapshot_id"] = current
+			}
+			steps[i] = step
+			continue
+		}
+
+		switch {
+		case rs.SnapshotStep != nil && rs.SnapshotID != "":
+			return nil, core.NewAppError(core.ErrBadRequest, fmt.Sprintf("step %d: set snapshot_id or snapshot_step, not both", i))
+		case rs.SnapshotStep != nil:
+			j := *rs.SnapshotStep
+			if j < 0 || j >= i || steps[j].op != core.OpSnapshotCreate {
+				return nil, core.NewAppError(core.ErrBadRequest, fmt.Sprintf("step %d: snapshot_step must name an earlier snapshot_create step", i))
+			}
+			step.snapshotID = steps[j].snapshotID
+		case rs.SnapshotID != "":
+			snap, err := a.queries.GetWorkspaceSnapshot(ctx, store.GetWorkspaceSnapshotParams{
+				Wsid:       ws.Wsid,
+				SnapshotID: rs.SnapshotID,
+			})
+			if err != nil || snap.DeletedAt.Valid {
+				return nil, core.NewAppError(core.ErrNotFound, fmt.Sprintf("step %d: snapshot not found", i))
+			}
+			if snap.State != string(core.SnapshotReady) {
+				return nil, core.NewAppError(core.ErrPreconditionFailed, fmt.Sprintf("step %d: snapshot is not ready", i))
+			}
+			step.snapshotID = rs.SnapshotID
+		default:
+			return nil, core.NewAppError(core.ErrBadRequest, fmt.Sprintf("step %d: snapshot_id or snapshot_step required", i))
+		}
+
+		switch op {
+		case core.OpSetCurrent:
+			step.params = map[string]string{
+				"snapshot_id": step.snapshotID,
+				"new_live_id": uuid.New().String()[:8],
+			}
+			current = step.snapshotID
+		case core.OpSnapshotDrop:
+			if step.snapshotID == current {
+				return nil, core.NewAppError(core.ErrConflictSnapshotInUse, fmt.Sprintf("step %d: cannot drop current snapshot", i))
+			}
+			step.params = map[string]string{"snapshot_id": step.snapshotID}
+		}
+		steps[i] = step
+	}
+	return steps, nil
+}
+
+// GetBatch gets a batch and the status of each of its steps.
+func (a *API) GetBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	batch, err := a.queries.GetBatch(ctx, chi.URLParam(r, "batch_id"))
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "batch not found"))
+		return
+	}
+	resp, err := a.batchResponse(ctx, batch)
+	if err != nil {
+		a.log.Error("list batch tasks failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to get batch"))
+		return
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// writeBatchResult responds for a created (or replayed) batch, honoring a
+// wait preference by waiting on the last step: it finishes last, or is
+// canceled as soon as an earlier step fails.
+func (a *API) writeBatchResult(w http.ResponseWriter, r *http.Request, batch store.WvsBatch) {
+	ctx := r.Context()
+	resp, err := a.batchResponse(ctx, batch)
+	if err != nil {
+		a.log.Error("list batch tasks failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to get batch"))
+		return
+	}
+
+	if wait, err := parseWait(r); err == nil && wait > 0 && len(resp.Tasks) > 0 {
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Now().Add(wait + 10*time.Second))
+
+		last := resp.Tasks[len(resp.Tasks)-1].TaskID
+		if task, err := a.waitForTask(ctx, last, wait); err == nil && isTerminalStatus(task.Status) {
+			if resp, err = a.batchResponse(ctx, batch); err == nil {
+				w.Header().Set("Preference-Applied", fmt.Sprintf("wait=%d", int(wait.Seconds())))
+				WriteJSON(w, http.StatusOK, resp)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Location", "/v1/batches/"+batch.BatchID)
+	WriteJSON(w, http.StatusAccepted, resp)
+}
+
+func (a *API) batchResponse(ctx context.Context, batch store.WvsBatch) (BatchResponse, error) {
+	tasks, err := a.queries.ListBatchTasks(ctx, textFromString(batch.BatchID))
+	if err != nil {
+		return BatchResponse{}, err
+	}
+	statuses := make([]core.TaskStatus, len(tasks))
+	resp := BatchResponse{
+		BatchID:   batch.BatchID,
+		WSID:      batch.Wsid,
+		CreatedAt: batch.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		Tasks:     make([]TaskResponse, len(tasks)),
+	}
+	for i, t := range tasks {
+		statuses[i] = core.TaskStatus(t.Status)
+		resp.Tasks[i] = taskToResponse(t)
+	}
+	resp.Status = string(core.RollupBatchStatus(statuses))
+	return resp, nil
+}
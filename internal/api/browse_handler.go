@@ -0,0 +1,282 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/lzjever/mbos-wvs/gen/go/executor/v1"
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+type TreeEntryResponse struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Type       string `json:"type"`
+	Size       int64  `json:"size"`
+	Mode       string `json:"mode"`
+	ModTime    string `json:"mod_time"`
+	LinkTarget string `json:"link_target,omitempty"`
+}
+
+type TreeResponse struct {
+	SnapshotID string              `json:"snapshot_id"`
+	Path       string              `json:"path"`
+	Entries    []TreeEntryResponse `json:"entries"`
+}
+
+// GetSnapshotTree lists a directory inside a snapshot.
+func (a *API) GetSnapshotTree(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+	snapshotID := chi.URLParam(r, "snapshot_id")
+	p := r.URL.Query().Get("path")
+
+	if appErr := a.checkBrowsable(r, wsid, snapshotID); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	stream, err := a.exec.ListTree(ctx, &pb.BrowseRequest{Wsid: wsid, SnapshotId: snapshotID, Path: p})
+	if err != nil {
+		WriteError(w, a.browseError(err))
+		return
+	}
+	entries := []TreeEntryResponse{}
+	for {
+		e, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			WriteError(w, a.browseError(err))
+			return
+		}
+		entries = append(entries, treeEntryToResponse(e))
+	}
+
+	WriteJSON(w, http.StatusOK, TreeResponse{
+		SnapshotID: snapshotID,
+		Path:       cleanBrowsePath(p),
+		Entries:    entries,
+	})
+}
+
+// GetSnapshotFile streams a file from a snapshot. A single-range Range
+// header is honored; anything else gets the whole file.
+func (a *API) GetSnapshotFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+	snapshotID := chi.URLParam(r, "snapshot_id")
+	p := r.URL.Query().Get("path")
+
+	if appErr := a.checkBrowsable(r, wsid, snapshotID); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	info, err := a.exec.StatPath(ctx, &pb.BrowseRequest{Wsid: wsid, SnapshotId: snapshotID, Path: p})
+	if err != nil {
+		WriteError(w, a.browseError(err))
+		return
+	}
+	if info.Type != "file" {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "path is not a file"))
+		return
+	}
+
+	offset, length, partial, ok := parseByteRange(r.Header.Get("Range"), info.Size)
+	if !ok {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		WriteError(w, core.NewAppError(core.ErrRangeNotSatisfiable, "range not satisfiable"))
+		return
+	}
+
+	stream, err := a.exec.ReadFile(ctx, &pb.ReadFileRequest{
+		Wsid:       wsid,
+		SnapshotId: snapshotID,
+		Path:       p,
+		Offset:     offset,
+		Length:     length,
+	})
+	if err != nil {
+		WriteError(w, a.browseError(err))
+		return
+	}
+	// Pull the first chunk before committing to a status code.
+	first, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		WriteError(w, a.browseError(err))
+		return
+	}
+
+	h := w.Header()
+	contentType := mime.TypeByExtension(path.Ext(info.Name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h.Set("Content-Type", contentType)
+	// Agent output is untrusted: never let a browser run it on the API's origin.
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Security-Policy", "sandbox")
+	h.Set("Accept-Ranges", "bytes")
+	h.Set("Last-Modified", time.Unix(info.ModTimeUnix, 0).UTC().Format(http.TimeFormat))
+	h.Set("Content-Length", strconv.FormatInt(length, 10))
+	code := http.StatusOK
+	if partial {
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, info.Size))
+		code = http.StatusPartialContent
+	}
+	w.WriteHeader(code)
+
+	rc := http.NewResponseController(w)
+	for first != nil {
+		// Large files outlive the server's WriteTimeout; extend it per chunk.
+		_ = rc.SetWriteDeadline(time.Now().Add(30 * time.Second))
+		if _, err := w.Write(first.Data); err != nil {
+			return
+		}
+		first, err = stream.Recv()
+		if err != nil {
+			if err != io.EOF {
+				a.log.Warn("snapshot file stream failed", zap.String("wsid", wsid),
+					zap.String("snapshot_id", snapshotID), zap.String("path", p), zap.Error(err))
+			}
+			return
+		}
+	}
+}
+
+// checkBrowsable requires browsing to be configured and the snapshot to be a
+// live, READY snapshot of the workspace.
+func (a *API) checkBrowsable(r *http.Request, wsid, snapshotID string) *core.AppError {
+	if a.exec == nil {
+		return core.NewAppError(core.ErrExecutorError, "snapshot browsing is not configured")
+	}
+	snap, err := a.queries.GetWorkspaceSnapshot(r.Context(), store.GetWorkspaceSnapshotParams{
+		Wsid:       wsid,
+		SnapshotID: snapshotID,
+	})
+	if err != nil || snap.DeletedAt.Valid {
+		return core.NewAppError(core.ErrNotFound, "snapshot not found")
+	}
+	if snap.State != string(core.SnapshotReady) {
+		return core.NewAppError(core.ErrPreconditionFailed, "snapshot is not ready")
+	}
+	return nil
+}
+
+// browseError maps an executor browse RPC error onto an API error.
+func (a *API) browseError(err error) *core.AppError {
+	st, _ := status.FromError(err)
+	switch st.Code() {
+	case codes.NotFound:
+		return core.NewAppError(core.ErrNotFound, st.Message())
+	case codes.InvalidArgument, codes.PermissionDenied, codes.FailedPrecondition, codes.OutOfRange:
+		return core.NewAppError(core.ErrBadRequest, st.Message())
+	case codes.ResourceExhausted:
+		return core.NewAppError(core.ErrQuotaExceeded, st.Message())
+	case codes.DeadlineExceeded:
+		return core.NewAppError(core.ErrExecutorTimeout, "executor timed out")
+	default:
+		a.log.Warn("snapshot browse failed", zap.Error(err))
+		return core.NewAppError(core.ErrExecutorError, "executor error")
+	}
+}
+
+func treeEntryToResponse(e *pb.TreeEntry) TreeEntryResponse {
+	return TreeEntryResponse{
+		Name:       e.Name,
+		Path:       e.Path,
+		Type:       e.Type,
+		Size:       e.Size,
+		Mode:       fmt.Sprintf("%04o", e.Mode),
+		ModTime:    time.Unix(e.ModTimeUnix, 0).UTC().Format("2006-01-02T15:04:05Z"),
+		LinkTarget: e.LinkTarget,
+	}
+}
+
+// cleanBrowsePath normalizes a browse path the way the executor does.
+func cleanBrowsePath(p string) string {
+	p = path.Clean("/" + p)[1:]
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+var errBadRange = errors.New("bad range")
+
+// parseByteRange resolves a Range header against a file of size bytes. It
+// returns the span to send and whether the response is partial; ok is false
+// only for a well-formed range that cannot be satisfied. Multi-range and
+// malformed headers fall back to the whole file, as RFC 9110 allows.
+func parseByteRange(header string, size int64) (offset, length int64, partial, ok bool) {
+	if header == "" {
+		return 0, size, false, true
+	}
+	start, end, err := parseRangeSpec(header)
+	if err != nil {
+		return 0, size, false, true
+	}
+	switch {
+	case start < 0: // suffix: the last end bytes
+		if end == 0 || size == 0 {
+			return 0, 0, false, false
+		}
+		if end > size {
+			end = size
+		}
+		return size - end, end, true, true
+	case start >= size:
+		return 0, 0, false, false
+	case end < 0 || end >= size:
+		return start, size - start, true, true
+	default:
+		return start, end - start + 1, true, true
+	}
+}
+
+// parseRangeSpec parses "bytes=a-b", "bytes=a-" (end -1) and "bytes=-n"
+// (start -1, end n).
+func parseRangeSpec(header string) (start, end int64, err error) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, errBadRange
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, errBadRange
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, errBadRange
+		}
+		return -1, n, nil
+	}
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, errBadRange
+	}
+	if last == "" {
+		return start, -1, nil
+	}
+	end, err = strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, errBadRange
+	}
+	return start, end, nil
+}
@@ -1,6 +1,10 @@
 package api
 
-import "time"
+import (
+	"time"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
 
 type Config struct {
 	HTTPAddr        string        `envconfig:"WVS_HTTP_ADDR" default:"0.0.0.0:8080"`
@@ -8,4 +12,42 @@ type Config struct {
 	MetricsAddr     string        `envconfig:"WVS_METRICS_ADDR" default:"0.0.0.0:9090"`
 	LogLevel        string        `envconfig:"WVS_LOG_LEVEL" default:"info"`
 	ShutdownTimeout time.Duration `envconfig:"WVS_SHUTDOWN_TIMEOUT" default:"30s"`
+
+	// ExecutorAddr serves the snapshot browse endpoints; they are disabled when unset.
+	ExecutorAddr string `envconfig:"WVS_EXECUTOR_ADDR"`
+	// ImportMaxBytes caps an uploaded import archive; 0 leaves it to the executor.
+	ImportMaxBytes int64 `envconfig:"WVS_IMPORT_MAX_BYTES" default:"0"`
+	// HooksEnabled accepts workspace hooks; set it only with EXECUTOR_HOOKS_ENABLED.
+	HooksEnabled bool `envconfig:"WVS_HOOKS_ENABLED" default:"false"`
+
+	// Per-op retry policies, e.g. "max_attempts=8,base_delay=10s,multiplier=3,max_delay=10m,jitter=0.2".
+	// Unset keys fall back to core.DefaultRetryPolicy.
+	RetryInitWorkspace  core.RetryPolicy `envconfig:"WVS_RETRY_INIT_WORKSPACE"`
+	RetrySnapshotCreate core.RetryPolicy `envconfig:"WVS_RETRY_SNAPSHOT_CREATE"`
+	RetrySnapshotDrop   core.RetryPolicy `envconfig:"WVS_RETRY_SNAPSHOT_DROP"`
+	RetrySetCurrent     core.RetryPolicy `envconfig:"WVS_RETRY_SET_CURRENT"`
+
+	RetryCheckpointRestore core.RetryPolicy `envconfig:"WVS_RETRY_CHECKPOINT_RESTORE"`
+	RetryRestorePaths      core.RetryPolicy `envconfig:"WVS_RETRY_RESTORE_PATHS"`
+	RetryExport            core.RetryPolicy `envconfig:"WVS_RETRY_EXPORT"`
+	RetryImport            core.RetryPolicy `envconfig:"WVS_RETRY_IMPORT"`
+	RetryReplicate         core.RetryPolicy `envconfig:"WVS_RETRY_REPLICATE"`
+	RetryVerify            core.RetryPolicy `envconfig:"WVS_RETRY_VERIFY"`
+}
+
+// RetryPolicies returns the configured retry policy for each op.
+func (c Config) RetryPolicies() core.RetryPolicies {
+	return core.RetryPolicies{
+		core.OpInitWorkspace:  c.RetryInitWorkspace,
+		core.OpSnapshotCreate: c.RetrySnapshotCreate,
+		core.OpSnapshotDrop:   c.RetrySnapshotDrop,
+		core.OpSetCurrent:     c.RetrySetCurrent,
+
+		core.OpCheckpointRestore: c.RetryCheckpointRestore,
+		core.OpRestorePaths:      c.RetryRestorePaths,
+		core.OpExport:            c.RetryExport,
+		core.OpImport:            c.RetryImport,
+		core.OpReplicate:         c.RetryReplicate,
+		core.OpVerify:            c.RetryVerify,
+	}
 }
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"path/filepath"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -67,6 +68,13 @@ func (a *API) SetCurrent(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, core.NewAppError(core.ErrBadRequest, "Idempotency-Key header required"))
 		return
 	}
+	if !validateWait(w, r) {
+		return
+	}
+	priority, ok := requestPriority(w, r, core.OpSetCurrent)
+	if !ok {
+		return
+	}
 
 	var req SetCurrentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -79,12 +87,19 @@ func (a *API) SetCurrent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check snapshot exists
-	snap, err := a.queries.GetSnapshot(ctx, req.SnapshotID)
+	// Check snapshot exists in this workspace
+	snap, err := a.queries.GetWorkspaceSnapshot(ctx, store.GetWorkspaceSnapshotParams{
+		Wsid:       wsid,
+		SnapshotID: req.SnapshotID,
+	})
 	if err != nil || snap.DeletedAt.Valid {
 		WriteError(w, core.NewAppError(core.ErrNotFound, "snapshot not found"))
 		return
 	}
+	if snap.State != string(core.SnapshotReady) {
+		WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "snapshot is not ready"))
+		return
+	}
 
 	// Check if already current (noop)
 	if ws.CurrentSnapshotID.Valid && ws.CurrentSnapshotID.String == req.SnapshotID {
@@ -107,13 +122,18 @@ func (a *API) SetCurrent(w http.ResponseWriter, r *http.Request) {
 	})
 	if existingTask.TaskID != "" {
 		if existingTask.RequestHash == requestHash {
-			WriteAccepted(w, existingTask.TaskID, "/v1/tasks/")
+			a.writeTaskResult(w, r, existingTask.TaskID, nil)
 			return
 		}
 		WriteError(w, core.NewAppError(core.ErrConflictIdempotent, "idempotency key mismatch"))
 		return
 	}
 
+	if appErr := a.checkHardQuota(ctx, ws, false); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
 	// Create task
 	taskID := core.NewID()
 	newLiveID := uuid.New().String()[:8]
@@ -122,16 +142,16 @@ func (a *API) SetCurrent(w http.ResponseWriter, r *http.Request) {
 		"new_live_id": newLiveID,
 	})
 
-	_, err = a.queries.CreateTask(ctx, store.CreateTaskParams{
+	_, err = a.queries.CreateTask(ctx, a.withRetryPolicy(store.CreateTaskParams{
 		TaskID:         taskID,
 		Wsid:           wsid,
 		Op:             string(core.OpSetCurrent),
 		IdempotencyKey: idempotencyKey,
 		RequestHash:    requestHash,
 		Params:         params,
-		MaxAttempts:    5,
 		TimeoutSeconds: 300,
-	})
+		Priority:       int32(priority),
+	}))
 	if err != nil {
 		a.log.Error("create set_current task failed", zap.Error(err))
 		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
@@ -140,5 +160,178 @@ func (a *API) SetCurrent(w http.ResponseWriter, r *http.Request) {
 
 	_ = a.writeAudit(ctx, wsid, "current.set", &taskID, req)
 
-	WriteAccepted(w, taskID, "/v1/tasks/")
+	a.writeTaskResult(w, r, taskID, nil)
+}
+
+type RestoreCurrentRequest struct {
+	SnapshotID string `json:"snapshot_id"`
+	Message    string `json:"message,omitempty"`
+}
+
+// RestoreCurrent checkpoints current into a new snapshot and switches current
+// to an older snapshot in a single task (async).
+func (a *API) RestoreCurrent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+
+	// Check workspace exists and is active
+	ws, err := a.queries.GetWorkspace(ctx, wsid)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+	if ws.State == string(core.WorkspaceDisabled) {
+		WriteError(w, core.NewAppError(core.ErrGone, "workspace is disabled"))
+		return
+	}
+
+	// Check Idempotency-Key
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "Idempotency-Key header required"))
+		return
+	}
+	if !validateWait(w, r) {
+		return
+	}
+	priority, ok := requestPriority(w, r, core.OpCheckpointRestore)
+	if !ok {
+		return
+	}
+
+	var req RestoreCurrentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid request body"))
+		return
+	}
+	if req.SnapshotID == "" {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "snapshot_id required"))
+		return
+	}
+
+	body, _ := json.Marshal(req)
+	requestHash := core.ComputeRequestHash(body, "POST", "/v1/workspaces/"+wsid+"/current:restore")
+
+	// Check idempotency before the current check: a replay after the switch
+	// would otherwise look like a noop.
+	existingTask, _ := a.queries.GetTaskByIdempotencyKey(ctx, store.GetTaskByIdempotencyKeyParams{
+		Wsid:           wsid,
+		Op:             string(core.OpCheckpointRestore),
+		IdempotencyKey: idempotencyKey,
+	})
+	if existingTask.TaskID != "" {
+		if existingTask.RequestHash == requestHash {
+			var existingParams map[string]string
+			_ = json.Unmarshal(existingTask.Params, &existingParams)
+			a.writeTaskResult(w, r, existingTask.TaskID, checkpointRef(wsid, existingParams["checkpoint_id"]))
+			return
+		}
+		WriteError(w, core.NewAppError(core.ErrConflictIdempotent, "idempotency key mismatch"))
+		return
+	}
+
+	// Check snapshot exists in this workspace
+	snap, err := a.queries.GetWorkspaceSnapshot(ctx, store.GetWorkspaceSnapshotParams{
+		Wsid:       wsid,
+		SnapshotID: req.SnapshotID,
+	})
+	if err != nil || snap.DeletedAt.Valid {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "snapshot not found"))
+		return
+	}
+	if snap.State != string(core.SnapshotReady) {
+		WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "snapshot is not ready"))
+		return
+	}
+	if ws.CurrentSnapshotID.Valid && ws.CurrentSnapshotID.String == req.SnapshotID {
+		WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "snapshot is already current"))
+		return
+	}
+
+	if appErr := a.checkHardQuota(ctx, ws, true); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	// Reserve the checkpoint row and create the task atomically, like
+	// CreateSnapshot, so the checkpoint ID is known before the task runs.
+	taskID := core.NewID()
+	checkpointID := core.NewID()
+	taskParams := map[string]string{
+		"snapshot_id":   req.SnapshotID,
+		"checkpoint_id": checkpointID,
+		"new_live_id":   uuid.New().String()[:8],
+		"message":       req.Message,
+	}
+	if ws.CurrentSnapshotID.Valid {
+		taskParams["parent_snapshot_id"] = ws.CurrentSnapshotID.String
+	}
+	params, _ := json.Marshal(taskParams)
+
+	tx, err := a.pool.Begin(ctx)
+	if err != nil {
+		a.log.Error("begin restore tx failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.queries.WithTx(tx)
+
+	if appErr := a.reserveSnapshots(ctx, qtx, ws, 1); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	_, err = qtx.CreateSnapshot(ctx, store.CreateSnapshotParams{
+		SnapshotID: checkpointID,
+		Wsid:       wsid,
+		FsPath:     filepath.Join(ws.RootPath, "snapshots", checkpointID),
+		Message:    textFromString(req.Message),
+		State:      string(core.SnapshotPending),
+		TaskID:     textFromString(taskID),
+	})
+	if err != nil {
+		a.log.Error("reserve checkpoint failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create snapshot"))
+		return
+	}
+
+	_, err = qtx.CreateTask(ctx, a.withRetryPolicy(store.CreateTaskParams{
+		TaskID:         taskID,
+		Wsid:           wsid,
+		Op:             string(core.OpCheckpointRestore),
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+		Params:         params,
+		TimeoutSeconds: 600,
+		Priority:       int32(priority),
+	}))
+	if err != nil {
+		a.log.Error("create checkpoint_restore task failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		a.log.Error("commit restore tx failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "current.restore", &taskID, map[string]string{
+		"snapshot_id":   req.SnapshotID,
+		"checkpoint_id": checkpointID,
+	})
+
+	a.writeTaskResult(w, r, taskID, checkpointRef(wsid, checkpointID))
+}
+
+func checkpointRef(wsid, checkpointID string) map[string]interface{} {
+	if checkpointID == "" {
+		return nil
+	}
+	return map[string]interface{}{
+		"checkpoint_id":   checkpointID,
+		"checkpoint_href": "/v1/workspaces/" + wsid + "/snapshots/" + checkpointID,
+	}
 }
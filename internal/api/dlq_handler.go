@@ -0,0 +1,338 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+// errAlreadyResolved is returned when a DEAD task was retried or discarded concurrently.
+var errAlreadyResolved = errors.New("dead task already resolved")
+
+// DeadTaskFilter selects DEAD tasks for the bulk dead-letter endpoints.
+// Empty fields match everything; Since and Until bound ended_at (RFC 3339).
+type DeadTaskFilter struct {
+	WSID       string `json:"wsid,omitempty"`
+	Op         string `json:"op,omitempty"`
+	ErrorClass string `json:"error_class,omitempty"`
+	Since      string `json:"since,omitempty"`
+	Until      string `json:"until,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// DeadTaskOutcome reports what a bulk requeue or discard did to one task.
+type DeadTaskOutcome struct {
+	TaskID      string `json:"task_id"`
+	WSID        string `json:"wsid"`
+	Op          string `json:"op"`
+	RetryTaskID string `json:"retry_task_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// RetryTask re-enqueues a DEAD task as a new task linked to the original.
+func (a *API) RetryTask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	taskID := chi.URLParam(r, "task_id")
+
+	if !validateWait(w, r) {
+		return
+	}
+
+	task, err := a.queries.GetTask(ctx, taskID)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "task not found"))
+		return
+	}
+	if task.Status != string(core.TaskDead) {
+		WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "only DEAD tasks can be retried"))
+		return
+	}
+
+	// Replaying a retry returns the task it already produced.
+	if retry, err := a.queries.GetTaskRetry(ctx, textFromString(taskID)); err == nil {
+		a.writeTaskResult(w, r, retry.TaskID, map[string]interface{}{"retry_of": taskID})
+		return
+	}
+	if task.DlqResolution.String == string(core.DLQDiscarded) {
+		WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "task was discarded"))
+		return
+	}
+
+	ws, err := a.queries.GetWorkspace(ctx, task.Wsid)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+	if ws.State == string(core.WorkspaceDisabled) {
+		WriteError(w, core.NewAppError(core.ErrGone, "workspace is disabled"))
+		return
+	}
+
+	retry, err := a.retryDeadTask(ctx, task)
+	if errors.Is(err, errAlreadyResolved) {
+		if retry, err := a.queries.GetTaskRetry(ctx, textFromString(taskID)); err == nil {
+			a.writeTaskResult(w, r, retry.TaskID, map[string]interface{}{"retry_of": taskID})
+			return
+		}
+		WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "task was discarded"))
+		return
+	}
+	if err != nil {
+		a.log.Error("retry dead task failed", zap.String("task_id", taskID), zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+
+	a.writeTaskResult(w, r, retry.TaskID, map[string]interface{}{"retry_of": taskID})
+}
+
+// ListDeadTasks lists unresolved DEAD tasks; it doubles as a dry run for the bulk endpoints.
+func (a *API) ListDeadTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := DeadTaskFilter{
+		WSID:       q.Get("wsid"),
+		Op:         q.Get("op"),
+		ErrorClass: q.Get("error_class"),
+		Since:      q.Get("since"),
+		Until:      q.Get("until"),
+	}
+	tasks, appErr := a.findDeadTasks(r.Context(), filter, parseLimit(q.Get("limit"), 100, 1000))
+	if appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	resp := make([]TaskResponse, len(tasks))
+	for i, t := range tasks {
+		resp[i] = taskToResponse(t)
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"tasks": resp})
+}
+
+// RequeueDeadTasks retries every unresolved DEAD task matching the filter.
+func (a *API) RequeueDeadTasks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tasks, appErr := a.decodeDeadTaskFilter(r)
+	if appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	outcomes := make([]DeadTaskOutcome, 0, len(tasks))
+	requeued := 0
+	for _, t := range tasks {
+		out := DeadTaskOutcome{TaskID: t.TaskID, WSID: t.Wsid, Op: t.Op}
+		ws, err := a.queries.GetWorkspace(ctx, t.Wsid)
+		switch {
+		case err != nil:
+			out.Error = "workspace not found"
+		case ws.State == string(core.WorkspaceDisabled):
+			out.Error = "workspace is disabled"
+		default:
+			retry, err := a.retryDeadTask(ctx, t)
+			if err != nil {
+				if !errors.Is(err, errAlreadyResolved) {
+					a.log.Error("requeue dead task failed", zap.String("task_id", t.TaskID), zap.Error(err))
+				}
+				out.Error = err.Error()
+				break
+			}
+			out.RetryTaskID = retry.TaskID
+			requeued++
+		}
+		outcomes = append(outcomes, out)
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"requeued": requeued,
+		"tasks":    outcomes,
+	})
+}
+
+// DiscardDeadTasks removes every unresolved DEAD task matching the filter from the queue.
+func (a *API) DiscardDeadTasks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tasks, appErr := a.decodeDeadTaskFilter(r)
+	if appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	outcomes := make([]DeadTaskOutcome, 0, len(tasks))
+	discarded := 0
+	for _, t := range tasks {
+		out := DeadTaskOutcome{TaskID: t.TaskID, WSID: t.Wsid, Op: t.Op}
+		n, err := a.queries.ResolveDeadTask(ctx, store.ResolveDeadTaskParams{
+			TaskID:        t.TaskID,
+			DlqResolution: textFromString(string(core.DLQDiscarded)),
+		})
+		switch {
+		case err != nil:
+			a.log.Error("discard dead task failed", zap.String("task_id", t.TaskID), zap.Error(err))
+			out.Error = "failed to discard task"
+		case n == 0:
+			out.Error = errAlreadyResolved.Error()
+		default:
+			taskID := t.TaskID
+			_ = a.writeAudit(ctx, t.Wsid, "task.discard", &taskID, nil)
+			discarded++
+		}
+		outcomes = append(outcomes, out)
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"discarded": discarded,
+		"tasks":     outcomes,
+	})
+}
+
+// decodeDeadTaskFilter reads a DeadTaskFilter body and returns the matching tasks.
+func (a *API) decodeDeadTaskFilter(r *http.Request) ([]store.WvsTask, *core.AppError) {
+	var filter DeadTaskFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		return nil, core.NewAppError(core.ErrBadRequest, "invalid request body")
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	return a.findDeadTasks(r.Context(), filter, limit)
+}
+
+func (a *API) findDeadTasks(ctx context.Context, filter DeadTaskFilter, limit int) ([]store.WvsTask, *core.AppError) {
+	since, err := parseFilterTime(filter.Since)
+	if err != nil {
+		return nil, core.NewAppError(core.ErrBadRequest, "since must be an RFC 3339 timestamp")
+	}
+	until, err := parseFilterTime(filter.Until)
+	if err != nil {
+		return nil, core.NewAppError(core.ErrBadRequest, "until must be an RFC 3339 timestamp")
+	}
+
+	tasks, err := a.queries.ListDeadTasks(ctx, store.ListDeadTasksParams{
+		Limit:     int32(limit),
+		Wsid:      textFromString(filter.WSID),
+		Op:        textFromString(filter.Op),
+		ErrorCode: textFromString(filter.ErrorClass),
+		Since:     since,
+		Until:     until,
+	})
+	if err != nil {
+		a.log.Error("list dead tasks failed", zap.Error(err))
+		return nil, core.NewAppError(core.ErrInternal, "failed to list dead tasks")
+	}
+	return tasks, nil
+}
+
+// retryDeadTask marks orig RETRIED and enqueues a fresh task with the same
+// params. The new task starts a new idempotency lineage derived from the
+// original, so a dead task is retried at most once.
+func (a *API) retryDeadTask(ctx context.Context, orig store.WvsTask) (store.WvsTask, error) {
+	tx, err := a.pool.Begin(ctx)
+	if err != nil {
+		return store.WvsTask{}, err
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.queries.WithTx(tx)
+
+	n, err := qtx.ResolveDeadTask(ctx, store.ResolveDeadTaskParams{
+		TaskID:        orig.TaskID,
+		DlqResolution: textFromString(string(core.DLQRetried)),
+	})
+	if err != nil {
+		return store.WvsTask{}, err
+	}
+	if n == 0 {
+		return store.WvsTask{}, errAlreadyResolved
+	}
+
+	// The retry runs under the op's current policy, not the one the dead task had.
+	p := a.withRetryPolicy(store.CreateTaskParams{Op: orig.Op})
+	taskID := core.NewID()
+	retry, err := qtx.CreateRetryTask(ctx, store.CreateRetryTaskParams{
+		TaskID:          taskID,
+		Wsid:            orig.Wsid,
+		Op:              orig.Op,
+		IdempotencyKey:  "retry:" + orig.TaskID,
+		RequestHash:     core.ComputeRequestHash(orig.Params, "POST", "/v1/tasks/"+orig.TaskID+":retry"),
+		Params:          orig.Params,
+		MaxAttempts:     p.MaxAttempts,
+		TimeoutSeconds:  orig.TimeoutSeconds,
+		RetryBaseMs:     p.RetryBaseMs,
+		RetryMultiplier: p.RetryMultiplier,
+		RetryMaxDelayMs: p.RetryMaxDelayMs,
+		RetryJitter:     p.RetryJitter,
+		Priority:        orig.Priority,
+		RetryOf:         textFromString(orig.TaskID),
+	})
+	if err != nil {
+		return store.WvsTask{}, err
+	}
+
+	// Undo the side effects the dead task left behind so the retry starts clean.
+	switch core.TaskOp(orig.Op) {
+	case core.OpSnapshotCreate, core.OpCheckpointRestore, core.OpImport:
+		var params map[string]string
+		_ = json.Unmarshal(orig.Params, &params)
+		if snapshotID := core.ReservedSnapshotID(core.TaskOp(orig.Op), params); snapshotID != "" {
+			if _, err := qtx.ResetSnapshotForRetry(ctx, store.ResetSnapshotForRetryParams{
+				SnapshotID: snapshotID,
+				TaskID:     textFromString(taskID),
+			}); err != nil {
+				return store.WvsTask{}, err
+			}
+		}
+	case core.OpReplicate:
+		var params map[string]string
+		_ = json.Unmarshal(orig.Params, &params)
+		if err := qtx.RepointSnapshotReplica(ctx, store.RepointSnapshotReplicaParams{
+			SnapshotID: params["snapshot_id"],
+			TaskID:     taskID,
+		}); err != nil {
+			return store.WvsTask{}, err
+		}
+	case core.OpInitWorkspace:
+		ws, err := qtx.GetWorkspace(ctx, orig.Wsid)
+		if err != nil {
+			return store.WvsTask{}, err
+		}
+		if ws.State == string(core.WorkspaceInitFailed) {
+			if err := qtx.UpdateWorkspaceState(ctx, store.UpdateWorkspaceStateParams{
+				Wsid:  orig.Wsid,
+				State: string(core.WorkspaceProvisioning),
+			}); err != nil {
+				return store.WvsTask{}, err
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return store.WvsTask{}, err
+	}
+
+	_ = a.writeAudit(ctx, orig.Wsid, "task.retry", &taskID, map[string]string{"retry_of": orig.TaskID})
+	return retry, nil
+}
+
+func parseFilterTime(s string) (pgtype.Timestamptz, error) {
+	if s == "" {
+		return pgtype.Timestamptz{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return pgtype.Timestamptz{}, err
+	}
+	return pgtype.Timestamptz{Time: t, Valid: true}, nil
+}
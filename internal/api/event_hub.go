@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// eventsChannel is the Postgres NOTIFY channel fed by the wvs.audit insert trigger.
+const eventsChannel = "wvs_events"
+
+// eventNotification is the NOTIFY payload emitted by wvs.notify_audit_event().
+type eventNotification struct {
+	EventID int64   `json:"event_id"`
+	WSID    *string `json:"wsid"`
+	TaskID  *string `json:"task_id"`
+}
+
+type eventSubscriber struct {
+	wsid   string
+	taskID string
+	ch     chan struct{}
+}
+
+// matches reports whether a notification is relevant to the subscriber.
+// A nil notification (e.g. after a listener reconnect) matches everyone.
+func (s *eventSubscriber) matches(n *eventNotification) bool {
+	if n == nil {
+		return true
+	}
+	if s.wsid != "" && (n.WSID == nil || *n.WSID != s.wsid) {
+		return false
+	}
+	if s.taskID != "" && (n.TaskID == nil || *n.TaskID != s.taskID) {
+		return false
+	}
+	return true
+}
+
+// EventHub holds a single LISTEN connection and wakes SSE subscribers when
+// matching audit events are inserted. Subscribers re-read wvs.audit from their
+// last delivered event_id, so a dropped wake-up never loses events.
+type EventHub struct {
+	pool *pgxpool.Pool
+	log  *zap.Logger
+
+	mu   sync.Mutex
+	subs map[*eventSubscriber]struct{}
+}
+
+func NewEventHub(pool *pgxpool.Pool, log *zap.Logger) *EventHub {
+	return &EventHub{
+		pool: pool,
+		log:  log,
+		subs: make(map[*eventSubscriber]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber filtered by wsid and/or task ID (empty means any).
+// The returned channel receives a signal whenever new matching events may exist.
+func (h *EventHub) Subscribe(wsid, taskID string) (<-chan struct{}, func()) {
+	sub := &eventSubscriber{wsid: wsid, taskID: taskID, ch: make(chan struct{}, 1)}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub.ch, func() {
+		h.mu.Lock()
+		delete(h.subs, sub)
+		h.mu.Unlock()
+	}
+}
+
+func (h *EventHub) broadcast(n *eventNotification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if !sub.matches(n) {
+			continue
+		}
+		select {
+		case sub.ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run listens for audit notifications until ctx is canceled, reconnecting on error.
+func (h *EventHub) Run(ctx context.Context) {
+	for {
+		if err := h.listen(ctx); err != nil && ctx.Err() == nil {
+			h.log.Warn("event hub: listen failed, reconnecting", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (h *EventHub) listen(ctx context.Context) error {
+	conn, err := h.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = conn.Exec(context.Background(), "UNLISTEN *")
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+eventsChannel); err != nil {
+		return err
+	}
+	h.log.Info("event hub: listening", zap.String("channel", eventsChannel))
+
+	// Events may have been inserted while we were disconnected.
+	h.broadcast(nil)
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var n eventNotification
+		if err := json.Unmarshal([]byte(notification.Payload), &n); err != nil {
+			h.broadcast(nil)
+			continue
+		}
+		h.broadcast(&n)
+	}
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+const (
+	sseHeartbeatInterval = 15 * time.Second
+	sseBatchSize         = 100
+	// sseOverlap is how many event IDs below the newest one sent each read
+	// goes back over. Event IDs come from a sequence, so a transaction that
+	// commits late can insert an event below IDs a stream has already sent.
+	sseOverlap = 256
+	// sseTerminalGrace is how long a task stream waits for the terminal
+	// event once the task row is terminal: the worker completes a task
+	// before it records the event.
+	sseTerminalGrace = 5 * time.Second
+)
+
+// StreamEvents streams audit events as Server-Sent Events, optionally filtered by wsid.
+// Without Last-Event-ID the stream starts at the newest event.
+//
+// An open stream also delivers events that commit out of ID order, as long
+// as they land within sseOverlap IDs of the newest one sent. Resuming with
+// Last-Event-ID starts strictly after that ID, so an event below it that
+// committed while the client was away is not replayed.
+func (a *API) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := r.URL.Query().Get("wsid")
+
+	if wsid != "" {
+		if _, err := a.queries.GetWorkspace(ctx, wsid); err != nil {
+			WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+			return
+		}
+	}
+
+	lastID, ok, err := parseLastEventID(r)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid Last-Event-ID"))
+		return
+	}
+	if !ok {
+		lastID, err = a.queries.GetLatestAuditEventID(ctx)
+		if err != nil {
+			a.log.Error("get latest event id failed", zap.Error(err))
+			WriteError(w, core.NewAppError(core.ErrInternal, "failed to open event stream"))
+			return
+		}
+	}
+
+	a.streamEvents(w, r, wsid, "", lastID)
+}
+
+// StreamTaskEvents streams the audit events of a single task as Server-Sent Events.
+// Without Last-Event-ID the task's full history is replayed first. The stream
+// ends after the task's terminal event (task.succeeded, task.dead or
+// task.canceled), or shortly after the task turns terminal if that event
+// never arrives.
+func (a *API) StreamTaskEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	taskID := chi.URLParam(r, "task_id")
+
+	if _, err := a.queries.GetTask(ctx, taskID); err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "task not found"))
+		return
+	}
+
+	lastID, _, err := parseLastEventID(r)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid Last-Event-ID"))
+		return
+	}
+
+	a.streamEvents(w, r, "", taskID, lastID)
+}
+
+func (a *API) streamEvents(w http.ResponseWriter, r *http.Request, wsid, taskID string, lastID int64) {
+	ctx := r.Context()
+
+	rc := http.NewResponseController(w)
+	// Streams outlive the server's WriteTimeout.
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	wake, unsubscribe := a.events.Subscribe(wsid, taskID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		a.log.Error("event stream: flush unsupported", zap.Error(err))
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	cur := newEventCursor(lastID)
+	var grace <-chan time.Time
+	final := false
+	for {
+		done, err := a.flushEvents(ctx, w, wsid, taskID, cur)
+		if err != nil {
+			if ctx.Err() == nil {
+				a.log.Warn("event stream: write failed", zap.Error(err))
+			}
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		if done || final {
+			return
+		}
+
+		if taskID != "" && grace == nil && a.taskTerminal(ctx, taskID) {
+			grace = time.After(sseTerminalGrace)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-grace:
+			final = true
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// eventCursor tracks what a stream has sent. Each read starts sseOverlap IDs
+// below the newest event sent and skips the ones already sent, so events
+// that commit out of ID order are not lost.
+type eventCursor struct {
+	floor int64              // events at or below floor count as sent
+	last  int64              // newest event sent
+	sent  map[int64]struct{} // events above floor that were sent
+}
+
+func newEventCursor(lastID int64) *eventCursor {
+	return &eventCursor{floor: lastID, last: lastID, sent: map[int64]struct{}{}}
+}
+
+// from is where the next read starts.
+func (c *eventCursor) from() int64 {
+	return max(c.floor, c.last-sseOverlap)
+}
+
+// mark records that id was sent and reports whether it is new.
+func (c *eventCursor) mark(id int64) bool {
+	if id <= c.floor {
+		return false
+	}
+	if _, ok := c.sent[id]; ok {
+		return false
+	}
+	c.sent[id] = struct{}{}
+	c.last = max(c.last, id)
+	return true
+}
+
+// advance forgets sent events that have fallen out of the overlap window.
+func (c *eventCursor) advance() {
+	c.floor = c.from()
+	for id := range c.sent {
+		if id <= c.floor {
+			delete(c.sent, id)
+		}
+	}
+}
+
+// flushEvents writes all matching events the stream has not sent yet. It
+// reports whether one of them was the terminal event of the streamed task.
+func (a *API) flushEvents(ctx context.Context, w io.Writer, wsid, taskID string, cur *eventCursor) (bool, error) {
+	done := false
+	after := cur.from()
+	for {
+		events, err := a.queries.ListAuditEventsAfter(ctx, store.ListAuditEventsAfterParams{
+			EventID: after,
+			Limit:   sseBatchSize,
+			Wsid:    textFromString(wsid),
+			TaskID:  textFromString(taskID),
+		})
+		if err != nil {
+			return done, fmt.Errorf("list events: %w", err)
+		}
+		for _, ev := range events {
+			after = ev.EventID
+			if !cur.mark(ev.EventID) {
+				continue
+			}
+			// A late event goes out with the stream's newest ID, so that
+			// Last-Event-ID always resumes after everything sent.
+			if err := writeSSEWithID(w, cur.last, auditToEvent(ev)); err != nil {
+				return done, err
+			}
+			if taskID != "" && isTerminalTaskEvent(ev.Action) {
+				done = true
+			}
+		}
+		if len(events) < sseBatchSize {
+			cur.advance()
+			return done, nil
+		}
+	}
+}
+
+// isTerminalTaskEvent reports whether action records a task reaching a
+// terminal status.
+func isTerminalTaskEvent(action string) bool {
+	status, ok := strings.CutPrefix(action, "task.")
+	return ok && isTerminalStatus(strings.ToUpper(status))
+}
+
+func (a *API) taskTerminal(ctx context.Context, taskID string) bool {
+	task, err := a.queries.GetTask(ctx, taskID)
+	if err != nil {
+		return false
+	}
+	return isTerminalStatus(task.Status)
+}
+
+// writeSSE writes a single event in text/event-stream framing.
+func writeSSE(w io.Writer, ev core.AuditEvent) error {
+	return writeSSEWithID(w, ev.EventID, ev)
+}
+
+// writeSSEWithID writes ev with id as its SSE event ID, the value a client
+// sends back as Last-Event-ID.
+func writeSSEWithID(w io.Writer, id int64, ev core.AuditEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, ev.Action, data)
+	return err
+}
+
+// parseLastEventID reads the resume cursor from the Last-Event-ID header, or
+// the last_event_id query parameter for clients that cannot set headers.
+func parseLastEventID(r *http.Request) (int64, bool, error) {
+	s := r.Header.Get("Last-Event-ID")
+	if s == "" {
+		s = r.URL.Query().Get("last_event_id")
+	}
+	if s == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("invalid event id %q", s)
+	}
+	return id, true, nil
+}
+
+func auditToEvent(e store.WvsAudit) core.AuditEvent {
+	ev := core.AuditEvent{
+		EventID: e.EventID,
+		Ts:      e.Ts.Time,
+		Actor:   json.RawMessage(e.Actor),
+		Action:  e.Action,
+		Payload: json.RawMessage(e.Payload),
+	}
+	if e.Wsid.Valid {
+		ev.WSID = &e.Wsid.String
+	}
+	if e.RequestID.Valid {
+		ev.RequestID = &e.RequestID.String
+	}
+	if e.TaskID.Valid {
+		ev.TaskID = &e.TaskID.String
+	}
+	return ev
+}
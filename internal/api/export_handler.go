@@ -0,0 +1,317 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	pb "github.com/lzjever/mbos-wvs/gen/go/executor/v1"
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+type ExportSnapshotRequest struct {
+	Compression string `json:"compression,omitempty"`
+	Destination string `json:"destination,omitempty"`
+}
+
+type ExportResponse struct {
+	ExportID         string `json:"export_id"`
+	WSID             string `json:"wsid"`
+	SnapshotID       string `json:"snapshot_id"`
+	TaskID           string `json:"task_id"`
+	Status           string `json:"status"`
+	Compression      string `json:"compression"`
+	Destination      string `json:"destination"`
+	Location         string `json:"location,omitempty"`
+	Digest           string `json:"digest,omitempty"`
+	SizeBytes        *int64 `json:"size_bytes,omitempty"`
+	FileCount        *int64 `json:"file_count,omitempty"`
+	ManifestLocation string `json:"manifest_location,omitempty"`
+	ManifestDigest   string `json:"manifest_digest,omitempty"`
+	CreatedAt        string `json:"created_at"`
+	CompletedAt      string `json:"completed_at,omitempty"`
+}
+
+// ExportSnapshot exports a snapshot as a tar archive (async).
+func (a *API) ExportSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+	snapshotID := chi.URLParam(r, "snapshot_id")
+
+	// Check workspace exists and is active
+	ws, err := a.queries.GetWorkspace(ctx, wsid)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+	if ws.State == string(core.WorkspaceDisabled) {
+		WriteError(w, core.NewAppError(core.ErrGone, "workspace is disabled"))
+		return
+	}
+
+	// Check Idempotency-Key
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "Idempotency-Key header required"))
+		return
+	}
+	if !validateWait(w, r) {
+		return
+	}
+	priority, ok := requestPriority(w, r, core.OpExport)
+	if !ok {
+		return
+	}
+
+	var req ExportSnapshotRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid request body"))
+			return
+		}
+	}
+	compression, err := core.ParseExportCompression(req.Compression)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return
+	}
+	destination, err := core.ParseExportDestination(req.Destination)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return
+	}
+
+	// Check snapshot exists in this workspace
+	snap, err := a.queries.GetWorkspaceSnapshot(ctx, store.GetWorkspaceSnapshotParams{
+		Wsid:       wsid,
+		SnapshotID: snapshotID,
+	})
+	if err != nil || snap.DeletedAt.Valid {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "snapshot not found"))
+		return
+	}
+	if snap.State != string(core.SnapshotReady) {
+		WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "snapshot is not ready"))
+		return
+	}
+
+	body, _ := json.Marshal(ExportSnapshotRequest{Compression: string(compression), Destination: string(destination)})
+	requestHash := core.ComputeRequestHash(body, "POST", "/v1/workspaces/"+wsid+"/snapshots/"+snapshotID+":export")
+
+	// Check idempotency
+	existingTask, _ := a.queries.GetTaskByIdempotencyKey(ctx, store.GetTaskByIdempotencyKeyParams{
+		Wsid:           wsid,
+		Op:             string(core.OpExport),
+		IdempotencyKey: idempotencyKey,
+	})
+	if existingTask.TaskID != "" {
+		if existingTask.RequestHash == requestHash {
+			var existingParams map[string]string
+			_ = json.Unmarshal(existingTask.Params, &existingParams)
+			a.writeTaskResult(w, r, existingTask.TaskID, exportRef(existingParams["export_id"]))
+			return
+		}
+		WriteError(w, core.NewAppError(core.ErrConflictIdempotent, "idempotency key mismatch"))
+		return
+	}
+
+	// Reserve the export row and create the task atomically, so the export
+	// can be polled by ID before it runs.
+	taskID := core.NewID()
+	exportID := core.NewID()
+	params, _ := json.Marshal(map[string]string{
+		"snapshot_id": snapshotID,
+		"export_id":   exportID,
+		"compression": string(compression),
+		"destination": string(destination),
+	})
+
+	tx, err := a.pool.Begin(ctx)
+	if err != nil {
+		a.log.Error("begin export tx failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.queries.WithTx(tx)
+
+	_, err = qtx.CreateTask(ctx, a.withRetryPolicy(store.CreateTaskParams{
+		TaskID:         taskID,
+		Wsid:           wsid,
+		Op:             string(core.OpExport),
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+		Params:         params,
+		TimeoutSeconds: 3600,
+		Priority:       int32(priority),
+	}))
+	if err != nil {
+		a.log.Error("create export task failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+
+	_, err = qtx.CreateExport(ctx, store.CreateExportParams{
+		ExportID:    exportID,
+		Wsid:        wsid,
+		SnapshotID:  snapshotID,
+		TaskID:      taskID,
+		Compression: string(compression),
+		Destination: string(destination),
+	})
+	if err != nil {
+		a.log.Error("reserve export failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create export"))
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		a.log.Error("commit export tx failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "snapshot.export", &taskID, map[string]string{
+		"snapshot_id": snapshotID,
+		"export_id":   exportID,
+		"destination": string(destination),
+	})
+
+	a.writeTaskResult(w, r, taskID, exportRef(exportID))
+}
+
+// ListSnapshotExports lists the exports of a snapshot, newest first.
+func (a *API) ListSnapshotExports(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+	snapshotID := chi.URLParam(r, "snapshot_id")
+
+	exports, err := a.queries.ListSnapshotExports(ctx, store.ListSnapshotExportsParams{
+		Wsid:       wsid,
+		SnapshotID: snapshotID,
+	})
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to list exports"))
+		return
+	}
+
+	resp := make([]ExportResponse, len(exports))
+	for i, e := range exports {
+		resp[i] = a.exportToResponse(r, e)
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"exports": resp})
+}
+
+// GetExport gets an export, including one whose task is still running.
+func (a *API) GetExport(w http.ResponseWriter, r *http.Request) {
+	e, err := a.queries.GetExport(r.Context(), chi.URLParam(r, "export_id"))
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "export not found"))
+		return
+	}
+	WriteJSON(w, http.StatusOK, a.exportToResponse(r, e))
+}
+
+// DownloadExport streams a finished export archive through the executor.
+func (a *API) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	e, err := a.queries.GetExport(ctx, chi.URLParam(r, "export_id"))
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "export not found"))
+		return
+	}
+	if !e.Location.Valid {
+		WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "export is not finished"))
+		return
+	}
+	if a.exec == nil {
+		WriteError(w, core.NewAppError(core.ErrExecutorError, "export download is not configured"))
+		return
+	}
+
+	stream, err := a.exec.ReadExport(ctx, &pb.ReadExportRequest{Wsid: e.Wsid, Location: e.Location.String})
+	if err != nil {
+		WriteError(w, a.browseError(err))
+		return
+	}
+	// Pull the first chunk before committing to a status code.
+	chunk, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		WriteError(w, a.browseError(err))
+		return
+	}
+
+	h := w.Header()
+	contentType := "application/x-tar"
+	if e.Compression == string(core.CompressionZstd) {
+		contentType = "application/zstd"
+	}
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", `attachment; filename="`+e.ExportID+core.ExportCompression(e.Compression).ArchiveExt()+`"`)
+	if e.SizeBytes.Valid {
+		h.Set("Content-Length", strconv.FormatInt(e.SizeBytes.Int64, 10))
+	}
+	if e.Digest.Valid {
+		h.Set("ETag", strconv.Quote(e.Digest.String))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	for chunk != nil {
+		// Archives outlive the server's WriteTimeout; extend it per chunk.
+		_ = rc.SetWriteDeadline(time.Now().Add(30 * time.Second))
+		if _, err := w.Write(chunk.Data); err != nil {
+			return
+		}
+		chunk, err = stream.Recv()
+		if err != nil {
+			if err != io.EOF {
+				a.log.Warn("export download failed", zap.String("export_id", e.ExportID), zap.Error(err))
+			}
+			return
+		}
+	}
+}
+
+// exportToResponse renders an export, taking its status from its task.
+func (a *API) exportToResponse(r *http.Request, e store.WvsExport) ExportResponse {
+	resp := ExportResponse{
+		ExportID:    e.ExportID,
+		WSID:        e.Wsid,
+		SnapshotID:  e.SnapshotID,
+		TaskID:      e.TaskID,
+		Compression: e.Compression,
+		Destination: e.Destination,
+		Location:    e.Location.String,
+		Digest:      e.Digest.String,
+		SizeBytes:   int64Ptr(e.SizeBytes),
+		FileCount:   int64Ptr(e.FileCount),
+		CreatedAt:   e.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		CompletedAt: formatTime(e.CompletedAt),
+
+		ManifestLocation: e.ManifestLocation.String,
+		ManifestDigest:   e.ManifestDigest.String,
+	}
+	if task, err := a.queries.GetTask(r.Context(), e.TaskID); err == nil {
+		resp.Status = task.Status
+	}
+	return resp
+}
+
+// exportRef returns the accepted-response fields pointing at a reserved export.
+func exportRef(exportID string) map[string]interface{} {
+	if exportID == "" {
+		return nil
+	}
+	return map[string]interface{}{
+		"export_id":   exportID,
+		"export_href": "/v1/exports/" + exportID,
+	}
+}
@@ -0,0 +1,343 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	pb "github.com/lzjever/mbos-wvs/gen/go/executor/v1"
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+// uploadChunkSize is the payload size of each UploadImportChunk.
+const uploadChunkSize = 64 << 10
+
+type ImportSnapshotRequest struct {
+	Source  string `json:"source"`
+	Message string `json:"message,omitempty"`
+
+	// SnapshotID picks the new snapshot's ID instead of generating one, as
+	// replication does to keep IDs equal on both sides.
+	SnapshotID string `json:"snapshot_id,omitempty"`
+	// BaseSnapshotID makes the archive a delta against this READY snapshot.
+	BaseSnapshotID string `json:"base_snapshot_id,omitempty"`
+}
+
+// ImportSnapshot creates a snapshot from a tar archive (async). A JSON body
+// names an s3:// or http(s):// source to fetch; any other body is the
+// archive itself, with the message, snapshot_id and base_snapshot_id in
+// query parameters.
+func (a *API) ImportSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+
+	// Check workspace exists and is active
+	ws, err := a.queries.GetWorkspace(ctx, wsid)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+	if ws.State == string(core.WorkspaceDisabled) {
+		WriteError(w, core.NewAppError(core.ErrGone, "workspace is disabled"))
+		return
+	}
+
+	// Check Idempotency-Key
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "Idempotency-Key header required"))
+		return
+	}
+	if !validateWait(w, r) {
+		return
+	}
+	priority, ok := requestPriority(w, r, core.OpImport)
+	if !ok {
+		return
+	}
+
+	var req ImportSnapshotRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid request body"))
+			return
+		}
+		if req.Source == core.ImportSourceUpload {
+			WriteError(w, core.NewAppError(core.ErrBadRequest, "upload the archive as the request body instead"))
+			return
+		}
+	} else {
+		q := r.URL.Query()
+		req = ImportSnapshotRequest{
+			Source:         core.ImportSourceUpload,
+			Message:        q.Get("message"),
+			SnapshotID:     q.Get("snapshot_id"),
+			BaseSnapshotID: q.Get("base_snapshot_id"),
+		}
+		if r.ContentLength == 0 {
+			WriteError(w, core.NewAppError(core.ErrBadRequest, "archive upload is empty"))
+			return
+		}
+		if a.exec == nil {
+			WriteError(w, core.NewAppError(core.ErrExecutorError, "archive upload is not configured"))
+			return
+		}
+	}
+	if err := core.ValidateImportSource(req.Source); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return
+	}
+	if req.SnapshotID != "" && !core.ValidID(req.SnapshotID) {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid snapshot_id"))
+		return
+	}
+	if req.BaseSnapshotID != "" {
+		base, err := a.queries.GetWorkspaceSnapshot(ctx, store.GetWorkspaceSnapshotParams{
+			Wsid:       wsid,
+			SnapshotID: req.BaseSnapshotID,
+		})
+		if err != nil || base.DeletedAt.Valid {
+			WriteError(w, core.NewAppError(core.ErrNotFound, "base snapshot not found"))
+			return
+		}
+		if base.State != string(core.SnapshotReady) {
+			WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "base snapshot is not ready"))
+			return
+		}
+	}
+
+	// An upload's hash covers its message but not its bytes, so a replay is
+	// answered before the archive is read again.
+	body, _ := json.Marshal(req)
+	requestHash := core.ComputeRequestHash(body, "POST", "/v1/workspaces/"+wsid+"/snapshots:import")
+
+	// Check idempotency
+	existingTask, _ := a.queries.GetTaskByIdempotencyKey(ctx, store.GetTaskByIdempotencyKeyParams{
+		Wsid:           wsid,
+		Op:             string(core.OpImport),
+		IdempotencyKey: idempotencyKey,
+	})
+	if existingTask.TaskID != "" {
+		if existingTask.RequestHash == requestHash {
+			var existingParams map[string]string
+			_ = json.Unmarshal(existingTask.Params, &existingParams)
+			a.writeTaskResult(w, r, existingTask.TaskID, snapshotRef(wsid, existingParams["snapshot_id"]))
+			return
+		}
+		WriteError(w, core.NewAppError(core.ErrConflictIdempotent, "idempotency key mismatch"))
+		return
+	}
+
+	if appErr := a.checkHardQuota(ctx, ws, true); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	// A chosen ID may only be reused after an import under it failed.
+	snapshotID := req.SnapshotID
+	var reuse bool
+	if snapshotID != "" {
+		if existing, err := a.queries.GetSnapshot(ctx, snapshotID); err == nil {
+			if existing.Wsid != wsid || existing.State != string(core.SnapshotFailed) || existing.DeletedAt.Valid {
+				WriteError(w, core.NewAppError(core.ErrConflictExists, "snapshot already exists"))
+				return
+			}
+			reuse = true
+		}
+	} else {
+		snapshotID = core.NewID()
+	}
+
+	taskID := core.NewID()
+	taskParams := map[string]string{
+		"snapshot_id": snapshotID,
+		"source":      req.Source,
+		"message":     req.Message,
+	}
+	if req.BaseSnapshotID != "" {
+		taskParams["base_snapshot_id"] = req.BaseSnapshotID
+	}
+
+	// Reserve the snapshot row before reading an upload, so a concurrent
+	// import cannot claim the same ID; the task follows once the archive is
+	// staged.
+	if appErr := a.reserveImport(ctx, ws, snapshotID, taskID, req.Message, reuse); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	extra := snapshotRef(wsid, snapshotID)
+	if req.Source == core.ImportSourceUpload {
+		staged, appErr := a.stageImport(w, r, wsid, taskID)
+		if appErr != nil {
+			a.releaseImport(ctx, wsid, snapshotID, "")
+			WriteError(w, appErr)
+			return
+		}
+		taskParams["upload_task_id"] = taskID
+		extra["upload_size_bytes"] = staged.SizeBytes
+		extra["upload_digest"] = staged.Digest
+	}
+	params, _ := json.Marshal(taskParams)
+
+	_, err = a.queries.CreateTask(ctx, a.withRetryPolicy(store.CreateTaskParams{
+		TaskID:         taskID,
+		Wsid:           wsid,
+		Op:             string(core.OpImport),
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+		Params:         params,
+		TimeoutSeconds: 3600,
+		Priority:       int32(priority),
+	}))
+	if err != nil {
+		a.log.Error("create import task failed", zap.Error(err))
+		a.releaseImport(ctx, wsid, snapshotID, taskParams["upload_task_id"])
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "snapshot.import", &taskID, taskParams)
+
+	a.writeTaskResult(w, r, taskID, extra)
+}
+
+// reserveImport claims snapshotID for the import task taskID: a new PENDING
+// row, or with reuse the FAILED row of an earlier import under that ID.
+func (a *API) reserveImport(ctx context.Context, ws store.WvsWorkspace, snapshotID, taskID, message string, reuse bool) *core.AppError {
+	tx, err := a.pool.Begin(ctx)
+	if err != nil {
+		a.log.Error("begin import tx failed", zap.Error(err))
+		return core.NewAppError(core.ErrInternal, "failed to create snapshot")
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.queries.WithTx(tx)
+
+	if appErr := a.reserveSnapshots(ctx, qtx, ws, 1); appErr != nil {
+		return appErr
+	}
+
+	if reuse {
+		var n int64
+		n, err = qtx.ResetSnapshotForRetry(ctx, store.ResetSnapshotForRetryParams{
+			SnapshotID: snapshotID,
+			TaskID:     textFromString(taskID),
+		})
+		if err == nil && n == 0 {
+			return core.NewAppError(core.ErrConflictExists, "snapshot already exists")
+		}
+	} else {
+		_, err = qtx.CreateSnapshot(ctx, store.CreateSnapshotParams{
+			SnapshotID: snapshotID,
+			Wsid:       ws.Wsid,
+			FsPath:     filepath.Join(ws.RootPath, "snapshots", snapshotID),
+			Message:    textFromString(message),
+			State:      string(core.SnapshotPending),
+			TaskID:     textFromString(taskID),
+		})
+	}
+	if err != nil {
+		a.log.Error("reserve snapshot failed", zap.Error(err))
+		return core.NewAppError(core.ErrInternal, "failed to create snapshot")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		a.log.Error("commit import tx failed", zap.Error(err))
+		return core.NewAppError(core.ErrInternal, "failed to create snapshot")
+	}
+	return nil
+}
+
+// releaseImport fails a snapshot reserved by reserveImport whose task was
+// never created, leaving the ID free for another import, and discards the
+// archive staged for uploadTaskID if set. It runs even if the client went
+// away.
+func (a *API) releaseImport(ctx context.Context, wsid, snapshotID, uploadTaskID string) {
+	ctx = context.WithoutCancel(ctx)
+	if err := a.queries.UpdateSnapshotState(ctx, store.UpdateSnapshotStateParams{
+		SnapshotID: snapshotID,
+		State:      string(core.SnapshotFailed),
+	}); err != nil {
+		a.log.Error("release import snapshot failed", zap.String("snapshot_id", snapshotID), zap.Error(err))
+	}
+	if uploadTaskID == "" {
+		return
+	}
+	if _, err := a.exec.DiscardImport(ctx, &pb.DiscardImportRequest{Wsid: wsid, TaskId: uploadTaskID}); err != nil {
+		a.log.Warn("discard staged import failed", zap.String("task_id", uploadTaskID), zap.Error(err))
+	}
+}
+
+// stageImport streams the request body to the executor, which holds it
+// until the import task taskID runs. The body is cut off past
+// WVS_IMPORT_MAX_BYTES; the executor enforces its own limits as it writes.
+func (a *API) stageImport(w http.ResponseWriter, r *http.Request, wsid, taskID string) (*pb.UploadImportResponse, *core.AppError) {
+	if a.importMaxBytes > 0 {
+		if r.ContentLength > a.importMaxBytes {
+			return nil, a.uploadTooLarge()
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, a.importMaxBytes)
+	}
+	// Canceling the stream on any early return leaves nothing staged.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stream, err := a.exec.UploadImport(ctx)
+	if err != nil {
+		return nil, a.browseError(err)
+	}
+
+	rc := http.NewResponseController(w)
+	buf := make([]byte, uploadChunkSize)
+	first := true
+	for {
+		// Archives outlive the server's ReadTimeout; extend it per chunk.
+		_ = rc.SetReadDeadline(time.Now().Add(30 * time.Second))
+		n, readErr := io.ReadFull(r.Body, buf)
+		if n > 0 || first {
+			chunk := &pb.UploadImportChunk{Data: buf[:n]}
+			if first {
+				chunk.Wsid, chunk.TaskId = wsid, taskID
+				first = false
+			}
+			if err := stream.Send(chunk); err != nil {
+				// The executor rejected the stream; CloseAndRecv carries why.
+				break
+			}
+		}
+		if readErr == io.EOF || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(readErr, &tooLarge) {
+			return nil, a.uploadTooLarge()
+		}
+		if readErr != nil {
+			a.log.Warn("read import upload failed", zap.String("wsid", wsid), zap.Error(readErr))
+			return nil, core.NewAppError(core.ErrBadRequest, "failed to read archive upload")
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, a.browseError(err)
+	}
+	if resp.SizeBytes == 0 {
+		return nil, core.NewAppError(core.ErrBadRequest, "archive upload is empty")
+	}
+	return resp, nil
+}
+
+func (a *API) uploadTooLarge() *core.AppError {
+	return core.NewAppError(core.ErrBadRequest, fmt.Sprintf("archive upload exceeds %d bytes", a.importMaxBytes))
+}
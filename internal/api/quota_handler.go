@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/observability"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+// SetQuota replaces a workspace's storage quota. Omitted limits are cleared.
+func (a *API) SetQuota(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+
+	if _, err := a.queries.GetWorkspace(ctx, wsid); err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+
+	var req core.Quota
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid request body"))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return
+	}
+
+	ws, err := a.queries.SetWorkspaceQuota(ctx, store.SetWorkspaceQuotaParams{
+		Wsid:               wsid,
+		QuotaSoftBytes:     int8FromPtr(req.SoftBytes),
+		QuotaHardBytes:     int8FromPtr(req.HardBytes),
+		QuotaSoftSnapshots: int4FromPtr(req.SoftSnapshots),
+		QuotaHardSnapshots: int4FromPtr(req.HardSnapshots),
+	})
+	if err != nil {
+		a.log.Error("set quota failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to set quota"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "workspace.quota_set", nil, req)
+
+	WriteJSON(w, http.StatusOK, workspaceToResponse(ws))
+}
+
+// checkHardQuota rejects work that would grow a workspace past its hard
+// limits. newSnapshot additionally checks the snapshot count limit; that is
+// only an early answer, and reserveSnapshots enforces it.
+func (a *API) checkHardQuota(ctx context.Context, ws store.WvsWorkspace, newSnapshot bool) *core.AppError {
+	if ws.QuotaHardBytes.Valid && ws.UsageBytes >= ws.QuotaHardBytes.Int64 {
+		return a.quotaExceeded(ctx, ws.Wsid, core.QuotaBytes, ws.UsageBytes, ws.QuotaHardBytes.Int64)
+	}
+	if newSnapshot {
+		return a.checkSnapshotCount(ctx, a.queries, ws, 1)
+	}
+	return nil
+}
+
+// reserveSnapshots enforces the hard snapshot limit for n new snapshots
+// inside qtx, the transaction that reserves their rows. The workspace lock
+// makes concurrent reservations count one after the other.
+func (a *API) reserveSnapshots(ctx context.Context, qtx *store.Queries, ws store.WvsWorkspace, n int64) *core.AppError {
+	if !ws.QuotaHardSnapshots.Valid || n == 0 {
+		return nil
+	}
+	if err := qtx.AcquireWorkspaceLock(ctx, ws.Wsid); err != nil {
+		a.log.Error("acquire workspace lock failed", zap.Error(err))
+		return core.NewAppError(core.ErrInternal, "failed to check quota")
+	}
+	return a.checkSnapshotCount(ctx, qtx, ws, n)
+}
+
+// checkSnapshotCount rejects n new snapshots if they would take the
+// workspace past its hard snapshot limit, counting snapshots that are still
+// being created.
+func (a *API) checkSnapshotCount(ctx context.Context, q *store.Queries, ws store.WvsWorkspace, n int64) *core.AppError {
+	if !ws.QuotaHardSnapshots.Valid {
+		return nil
+	}
+	count, err := q.CountActiveSnapshots(ctx, ws.Wsid)
+	if err != nil {
+		a.log.Error("count snapshots failed", zap.Error(err))
+		return core.NewAppError(core.ErrInternal, "failed to check quota")
+	}
+	if limit := int64(ws.QuotaHardSnapshots.Int32); count+n > limit {
+		return a.quotaExceeded(ctx, ws.Wsid, core.QuotaSnapshots, count, limit)
+	}
+	return nil
+}
+
+func (a *API) quotaExceeded(ctx context.Context, wsid string, kind core.QuotaKind, usage, limit int64) *core.AppError {
+	observability.QuotaExceededTotal.WithLabelValues(string(kind)).Inc()
+	_ = a.writeAudit(ctx, wsid, "workspace.quota_exceeded", nil, map[string]interface{}{
+		"kind":       kind,
+		"usage":      usage,
+		"hard_limit": limit,
+	})
+	return core.NewAppError(core.ErrQuotaExceeded,
+		fmt.Sprintf("workspace %s quota exceeded (%d of %d)", kind, usage, limit))
+}
+
+// quotaFromWorkspace returns the workspace's limits, or nil if it has none.
+func quotaFromWorkspace(ws store.WvsWorkspace) *core.Quota {
+	q := core.Quota{
+		SoftBytes:     int64Ptr(ws.QuotaSoftBytes),
+		HardBytes:     int64Ptr(ws.QuotaHardBytes),
+		SoftSnapshots: int32Ptr(ws.QuotaSoftSnapshots),
+		HardSnapshots: int32Ptr(ws.QuotaHardSnapshots),
+	}
+	if q == (core.Quota{}) {
+		return nil
+	}
+	return &q
+}
+
+func int32Ptr(v pgtype.Int4) *int32 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int32
+}
+
+func int8FromPtr(v *int64) pgtype.Int8 {
+	if v == nil {
+		return pgtype.Int8{Valid: false}
+	}
+	return pgtype.Int8{Int64: *v, Valid: true}
+}
+
+func int4FromPtr(v *int32) pgtype.Int4 {
+	if v == nil {
+		return pgtype.Int4{Valid: false}
+	}
+	return pgtype.Int4{Int32: *v, Valid: true}
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+type SetReplicationRequest struct {
+	PeerURL string `json:"peer_url"`
+	// PeerWSID defaults to the workspace's own ID.
+	PeerWSID string `json:"peer_wsid,omitempty"`
+}
+
+type ReplicationResponse struct {
+	WSID             string            `json:"wsid"`
+	PeerURL          string            `json:"peer_url"`
+	PeerWSID         string            `json:"peer_wsid"`
+	Enabled          bool              `json:"enabled"`
+	PendingSnapshots int64             `json:"pending_snapshots"`
+	LagSeconds       float64           `json:"lag_seconds"`
+	Replicas         []ReplicaResponse `json:"replicas"`
+	CreatedAt        string            `json:"created_at"`
+	UpdatedAt        string            `json:"updated_at"`
+}
+
+type ReplicaResponse struct {
+	SnapshotID     string `json:"snapshot_id"`
+	State          string `json:"state"`
+	BaseSnapshotID string `json:"base_snapshot_id,omitempty"`
+	TaskID         string `json:"task_id"`
+	BytesShipped   *int64 `json:"bytes_shipped,omitempty"`
+	FilesChanged   *int64 `json:"files_changed,omitempty"`
+	FilesDeleted   *int64 `json:"files_deleted,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	ReplicatedAt   string `json:"replicated_at,omitempty"`
+}
+
+// SetReplication points a workspace's replication at a peer WVS deployment
+// and enables it. Pointing it at a different peer forgets what the old one
+// holds, so the next snapshot is shipped in full.
+func (a *API) SetReplication(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+
+	ws, err := a.queries.GetWorkspace(ctx, wsid)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+	if ws.State == string(core.WorkspaceDisabled) {
+		WriteError(w, core.NewAppError(core.ErrGone, "workspace is disabled"))
+		return
+	}
+
+	var req SetReplicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid request body"))
+		return
+	}
+	if req.PeerWSID == "" {
+		req.PeerWSID = wsid
+	}
+	if req.PeerURL, err = core.ValidateReplicationTarget(req.PeerURL, req.PeerWSID); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return
+	}
+
+	tx, err := a.pool.Begin(ctx)
+	if err != nil {
+		a.log.Error("begin replication tx failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to set replication"))
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.queries.WithTx(tx)
+
+	if prev, err := qtx.GetReplicationTarget(ctx, wsid); err == nil &&
+		(prev.PeerUrl != req.PeerURL || prev.PeerWsid != req.PeerWSID) {
+		if err := qtx.DeleteSnapshotReplicas(ctx, wsid); err != nil {
+			a.log.Error("reset snapshot replicas failed", zap.Error(err))
+			WriteError(w, core.NewAppError(core.ErrInternal, "failed to set replication"))
+			return
+		}
+	}
+	target, err := qtx.UpsertReplicationTarget(ctx, store.UpsertReplicationTargetParams{
+		Wsid:     wsid,
+		PeerUrl:  req.PeerURL,
+		PeerWsid: req.PeerWSID,
+	})
+	if err != nil {
+		a.log.Error("set replication target failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to set replication"))
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		a.log.Error("commit replication tx failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to set replication"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "replication.set", nil, req)
+
+	a.writeReplication(w, r, target)
+}
+
+// GetReplication gets a workspace's replication target, its lag and the
+// state of its most recent replicas.
+func (a *API) GetReplication(w http.ResponseWriter, r *http.Request) {
+	target, err := a.queries.GetReplicationTarget(r.Context(), chi.URLParam(r, "wsid"))
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "replication not configured"))
+		return
+	}
+	a.writeReplication(w, r, target)
+}
+
+// DisableReplication stops shipping a workspace's snapshots. Replicas in
+// flight finish; the history is kept for when it is enabled again.
+func (a *API) DisableReplication(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+
+	n, err := a.queries.DisableReplicationTarget(ctx, wsid)
+	if err != nil {
+		a.log.Error("disable replication failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to disable replication"))
+		return
+	}
+	if n == 0 {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "replication not enabled"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "replication.disable", nil, nil)
+
+	target, err := a.queries.GetReplicationTarget(ctx, wsid)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to get replication"))
+		return
+	}
+	a.writeReplication(w, r, target)
+}
+
+func (a *API) writeReplication(w http.ResponseWriter, r *http.Request, t store.WvsReplicationTarget) {
+	ctx := r.Context()
+	resp := ReplicationResponse{
+		WSID:      t.Wsid,
+		PeerURL:   t.PeerUrl,
+		PeerWSID:  t.PeerWsid,
+		Enabled:   t.Enabled,
+		Replicas:  []ReplicaResponse{},
+		CreatedAt: t.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt: t.UpdatedAt.Time.Format("2006-01-02T15:04:05Z"),
+	}
+
+	if lag, err := a.queries.GetReplicationLag(ctx, t.Wsid); err == nil {
+		resp.PendingSnapshots = lag.PendingSnapshots
+		if lag.OldestPendingAt.Valid {
+			resp.LagSeconds = time.Since(lag.OldestPendingAt.Time).Seconds()
+		}
+	}
+
+	replicas, err := a.queries.ListSnapshotReplicas(ctx, store.ListSnapshotReplicasParams{
+		Wsid:  t.Wsid,
+		Limit: int32(parseLimit(r.URL.Query().Get("limit"), 20, 100)),
+	})
+	if err != nil {
+		a.log.Error("list snapshot replicas failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to list replicas"))
+		return
+	}
+	for _, rep := range replicas {
+		var status core.TaskStatus
+		if task, err := a.queries.GetTask(ctx, rep.TaskID); err == nil {
+			status = core.TaskStatus(task.Status)
+		}
+		resp.Replicas = append(resp.Replicas, ReplicaResponse{
+			SnapshotID:     rep.SnapshotID,
+			State:          string(core.ReplicaStateOf(rep.ReplicatedAt.Valid, status)),
+			BaseSnapshotID: rep.BaseSnapshotID.String,
+			TaskID:         rep.TaskID,
+			BytesShipped:   int64Ptr(rep.BytesShipped),
+			FilesChanged:   int64Ptr(rep.FilesChanged),
+			FilesDeleted:   int64Ptr(rep.FilesDeleted),
+			CreatedAt:      rep.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+			ReplicatedAt:   formatTime(rep.ReplicatedAt),
+		})
+	}
+
+	WriteJSON(w, http.StatusOK, resp)
+}
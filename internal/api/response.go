@@ -32,9 +32,19 @@ func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
 
 // WriteAccepted writes a 202 Accepted response with a task reference.
 func WriteAccepted(w http.ResponseWriter, taskID string, path string) {
-	WriteJSON(w, http.StatusAccepted, map[string]interface{}{
+	WriteAcceptedWith(w, taskID, path, nil)
+}
+
+// WriteAcceptedWith writes a 202 Accepted response with a task reference and
+// extra fields describing resources reserved by the task.
+func WriteAcceptedWith(w http.ResponseWriter, taskID string, path string, extra map[string]interface{}) {
+	body := map[string]interface{}{
 		"task_id":     taskID,
 		"status":      "PENDING",
 		"status_href": path + taskID,
-	})
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+	WriteJSON(w, http.StatusAccepted, body)
 }
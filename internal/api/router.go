@@ -13,23 +13,51 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/lzjever/mbos-wvs/internal/api/middleware"
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/executorclient"
 	"github.com/lzjever/mbos-wvs/internal/store"
 )
 
 type API struct {
-	pool    *pgxpool.Pool
-	queries *store.Queries
-	log     *zap.Logger
+	pool           *pgxpool.Pool
+	queries        *store.Queries
+	events         *EventHub
+	retry          core.RetryPolicies
+	exec           *executorclient.Client // nil disables snapshot browsing
+	importMaxBytes int64
+	hooksEnabled   bool
+	log            *zap.Logger
 }
 
-func NewAPI(pool *pgxpool.Pool, log *zap.Logger) *API {
+func NewAPI(pool *pgxpool.Pool, cfg Config, exec *executorclient.Client, log *zap.Logger) *API {
 	return &API{
-		pool:    pool,
-		queries: store.New(pool),
-		log:     log,
+		pool:           pool,
+		queries:        store.New(pool),
+		events:         NewEventHub(pool, log),
+		retry:          cfg.RetryPolicies(),
+		exec:           exec,
+		importMaxBytes: cfg.ImportMaxBytes,
+		hooksEnabled:   cfg.HooksEnabled,
+		log:            log,
 	}
 }
 
+// withRetryPolicy stamps the op's retry policy onto a new task.
+func (a *API) withRetryPolicy(p store.CreateTaskParams) store.CreateTaskParams {
+	pol := a.retry.For(core.TaskOp(p.Op))
+	p.MaxAttempts = pol.MaxAttempts
+	p.RetryBaseMs = int32(pol.BaseDelay.Milliseconds())
+	p.RetryMultiplier = pol.Multiplier
+	p.RetryMaxDelayMs = int32(pol.MaxDelay.Milliseconds())
+	p.RetryJitter = pol.Jitter
+	return p
+}
+
+// RunEvents runs the LISTEN loop backing the SSE endpoints until ctx is canceled.
+func (a *API) RunEvents(ctx context.Context) {
+	a.events.Run(ctx)
+}
+
 func (a *API) Router() chi.Router {
 	r := chi.NewRouter()
 
@@ -37,7 +65,6 @@ func (a *API) Router() chi.Router {
 	r.Use(middleware.Metrics)
 	r.Use(middleware.Recoverer(a.log))
 	r.Use(middleware.Logger)
-	r.Use(chiMiddleware.AllowContentType("application/json"))
 
 	// Health endpoints
 	r.Get("/healthz", a.HealthHandler)
@@ -45,26 +72,64 @@ func (a *API) Router() chi.Router {
 
 	// API v1 routes
 	r.Route("/v1", func(r chi.Router) {
-		// Workspaces
-		r.Get("/workspaces", a.ListWorkspaces)
-		r.Post("/workspaces", a.CreateWorkspace)
-		r.Get("/workspaces/{wsid}", a.GetWorkspace)
-		r.Delete("/workspaces/{wsid}", a.DisableWorkspace)
-		r.Post("/workspaces/{wsid}/retry-init", a.RetryInit)
-
-		// Snapshots
-		r.Get("/workspaces/{wsid}/snapshots", a.ListSnapshots)
-		r.Post("/workspaces/{wsid}/snapshots", a.CreateSnapshot)
-		r.Delete("/workspaces/{wsid}/snapshots/{snapshot_id}", a.DropSnapshot)
-
-		// Current
-		r.Get("/workspaces/{wsid}/current", a.GetCurrent)
-		r.Post("/workspaces/{wsid}/current:set", a.SetCurrent)
-
-		// Tasks
-		r.Get("/tasks", a.ListTasks)
-		r.Get("/tasks/{task_id}", a.GetTask)
-		r.Post("/tasks/{task_id}:cancel", a.CancelTask)
+		// Archive uploads are the only non-JSON bodies.
+		r.With(chiMiddleware.AllowContentType("application/json", "application/x-tar", "application/zstd", "application/octet-stream")).
+			Post("/workspaces/{wsid}/snapshots:import", a.ImportSnapshot)
+
+		// Event streams (SSE)
+		r.Get("/events", a.StreamEvents)
+		r.Get("/tasks/{task_id}/events", a.StreamTaskEvents)
+
+		r.Group(func(r chi.Router) {
+			r.Use(chiMiddleware.AllowContentType("application/json"))
+
+			// Workspaces
+			r.Get("/workspaces", a.ListWorkspaces)
+			r.Post("/workspaces", a.CreateWorkspace)
+			r.Get("/workspaces/{wsid}", a.GetWorkspace)
+			r.Get("/workspaces/{wsid}/usage", a.GetWorkspaceUsage)
+			r.Put("/workspaces/{wsid}/quota", a.SetQuota)
+			r.Put("/workspaces/{wsid}/quiesce-policy", a.SetQuiescePolicy)
+			r.Put("/workspaces/{wsid}/hooks", a.SetHooks)
+			r.Put("/workspaces/{wsid}/exclude-patterns", a.SetExcludePatterns)
+			r.Delete("/workspaces/{wsid}", a.DisableWorkspace)
+			r.Post("/workspaces/{wsid}/retry-init", a.RetryInit)
+			r.Put("/workspaces/{wsid}/replication", a.SetReplication)
+			r.Get("/workspaces/{wsid}/replication", a.GetReplication)
+			r.Delete("/workspaces/{wsid}/replication", a.DisableReplication)
+
+			// Snapshots
+			r.Get("/workspaces/{wsid}/snapshots", a.ListSnapshots)
+			r.Post("/workspaces/{wsid}/snapshots", a.CreateSnapshot)
+			r.Get("/workspaces/{wsid}/snapshots/{snapshot_id}", a.GetSnapshot)
+			r.Delete("/workspaces/{wsid}/snapshots/{snapshot_id}", a.DropSnapshot)
+			r.Post("/workspaces/{wsid}/snapshots/{snapshot_id}:restore", a.RestorePaths)
+			r.Post("/workspaces/{wsid}/snapshots/{snapshot_id}:verify", a.VerifySnapshot)
+			r.Get("/workspaces/{wsid}/snapshots/{snapshot_id}/tree", a.GetSnapshotTree)
+			r.Get("/workspaces/{wsid}/snapshots/{snapshot_id}/files", a.GetSnapshotFile)
+			r.Post("/workspaces/{wsid}/snapshots/{snapshot_id}:export", a.ExportSnapshot)
+			r.Get("/workspaces/{wsid}/snapshots/{snapshot_id}/exports", a.ListSnapshotExports)
+			r.Get("/exports/{export_id}", a.GetExport)
+			r.Get("/exports/{export_id}/download", a.DownloadExport)
+
+			// Current
+			r.Get("/workspaces/{wsid}/current", a.GetCurrent)
+			r.Post("/workspaces/{wsid}/current:set", a.SetCurrent)
+			r.Post("/workspaces/{wsid}/current:restore", a.RestoreCurrent)
+
+			// Batches
+			r.Post("/workspaces/{wsid}/batch", a.CreateBatch)
+			r.Get("/batches/{batch_id}", a.GetBatch)
+
+			// Tasks
+			r.Get("/tasks", a.ListTasks)
+			r.Get("/tasks/dead", a.ListDeadTasks)
+			r.Post("/tasks/dead:requeue", a.RequeueDeadTasks)
+			r.Post("/tasks/dead:discard", a.DiscardDeadTasks)
+			r.Get("/tasks/{task_id}", a.GetTask)
+			r.Post("/tasks/{task_id}:cancel", a.CancelTask)
+			r.Post("/tasks/{task_id}:retry", a.RetryTask)
+		})
 	})
 
 	return r
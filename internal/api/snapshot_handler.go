@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"path/filepath"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -17,11 +18,22 @@ type CreateSnapshotRequest struct {
 }
 
 type SnapshotResponse struct {
-	SnapshotID string `json:"snapshot_id"`
-	WSID       string `json:"wsid"`
-	FSPath     string `json:"fs_path"`
-	Message    string `json:"message,omitempty"`
-	CreatedAt  string `json:"created_at"`
+	SnapshotID  string          `json:"snapshot_id"`
+	WSID        string          `json:"wsid"`
+	FSPath      string          `json:"fs_path"`
+	Message     string          `json:"message,omitempty"`
+	State       string          `json:"state"`
+	TaskID      string          `json:"task_id,omitempty"`
+	SizeBytes   *int64          `json:"size_bytes,omitempty"`
+	FileCount   *int64          `json:"file_count,omitempty"`
+	UniqueBytes *int64          `json:"unique_bytes,omitempty"`
+	SharedBytes *int64          `json:"shared_bytes,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	TreeDigest  string          `json:"tree_digest,omitempty"`
+	VerifyState string          `json:"verify_state,omitempty"`
+	VerifiedAt  string          `json:"verified_at,omitempty"`
+	CreatedAt   string          `json:"created_at"`
+	DeletedAt   string          `json:"deleted_at,omitempty"`
 }
 
 // ListSnapshots lists snapshots for a workspace.
@@ -88,6 +100,13 @@ func (a *API) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, core.NewAppError(core.ErrBadRequest, "Idempotency-Key header required"))
 		return
 	}
+	if !validateWait(w, r) {
+		return
+	}
+	priority, ok := requestPriority(w, r, core.OpSnapshotCreate)
+	if !ok {
+		return
+	}
 
 	var req CreateSnapshotRequest
 	json.NewDecoder(r.Body).Decode(&req)
@@ -103,40 +122,108 @@ func (a *API) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
 	})
 	if existingTask.TaskID != "" {
 		if existingTask.RequestHash == requestHash {
-			WriteAccepted(w, existingTask.TaskID, "/v1/tasks/")
+			var existingParams map[string]string
+			_ = json.Unmarshal(existingTask.Params, &existingParams)
+			a.writeTaskResult(w, r, existingTask.TaskID, snapshotRef(wsid, existingParams["snapshot_id"]))
 			return
 		}
 		WriteError(w, core.NewAppError(core.ErrConflictIdempotent, "idempotency key mismatch"))
 		return
 	}
 
-	// Create task
+	if appErr := a.checkHardQuota(ctx, ws, true); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	// Reserve the snapshot row and create the task atomically, so the
+	// snapshot ID can be returned (and polled) before the clone runs.
 	taskID := core.NewID()
 	snapshotID := core.NewID()
-	params, _ := json.Marshal(map[string]string{
+	taskParams := map[string]string{
 		"snapshot_id": snapshotID,
 		"message":     req.Message,
+	}
+	if ws.CurrentSnapshotID.Valid {
+		// Lets the executor tell data shared with the parent from new data.
+		taskParams["parent_snapshot_id"] = ws.CurrentSnapshotID.String
+	}
+	params, _ := json.Marshal(taskParams)
+
+	tx, err := a.pool.Begin(ctx)
+	if err != nil {
+		a.log.Error("begin snapshot tx failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.queries.WithTx(tx)
+
+	if appErr := a.reserveSnapshots(ctx, qtx, ws, 1); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	_, err = qtx.CreateSnapshot(ctx, store.CreateSnapshotParams{
+		SnapshotID: snapshotID,
+		Wsid:       wsid,
+		FsPath:     filepath.Join(ws.RootPath, "snapshots", snapshotID),
+		Message:    textFromString(req.Message),
+		State:      string(core.SnapshotPending),
+		TaskID:     textFromString(taskID),
 	})
+	if err != nil {
+		a.log.Error("reserve snapshot failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create snapshot"))
+		return
+	}
 
-	_, err = a.queries.CreateTask(ctx, store.CreateTaskParams{
+	_, err = qtx.CreateTask(ctx, a.withRetryPolicy(store.CreateTaskParams{
 		TaskID:         taskID,
 		Wsid:           wsid,
 		Op:             string(core.OpSnapshotCreate),
 		IdempotencyKey: idempotencyKey,
 		RequestHash:    requestHash,
 		Params:         params,
-		MaxAttempts:    5,
 		TimeoutSeconds: 300,
-	})
+		Priority:       int32(priority),
+	}))
 	if err != nil {
 		a.log.Error("create snapshot task failed", zap.Error(err))
 		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
 		return
 	}
 
-	_ = a.writeAudit(ctx, wsid, "snapshot.create", &taskID, req)
+	if err := tx.Commit(ctx); err != nil {
+		a.log.Error("commit snapshot tx failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "snapshot.create", &taskID, map[string]string{
+		"snapshot_id": snapshotID,
+		"message":     req.Message,
+	})
+
+	a.writeTaskResult(w, r, taskID, snapshotRef(wsid, snapshotID))
+}
+
+// GetSnapshot gets a single snapshot, including one that is still being created.
+func (a *API) GetSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+	snapshotID := chi.URLParam(r, "snapshot_id")
+
+	snap, err := a.queries.GetWorkspaceSnapshot(ctx, store.GetWorkspaceSnapshotParams{
+		Wsid:       wsid,
+		SnapshotID: snapshotID,
+	})
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "snapshot not found"))
+		return
+	}
 
-	WriteAccepted(w, taskID, "/v1/tasks/")
+	WriteJSON(w, http.StatusOK, snapshotToResponse(snap))
 }
 
 // DropSnapshot drops a snapshot (async).
@@ -156,8 +243,11 @@ func (a *API) DropSnapshot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check snapshot exists
-	snap, err := a.queries.GetSnapshot(ctx, snapshotID)
+	// Check snapshot exists in this workspace
+	snap, err := a.queries.GetWorkspaceSnapshot(ctx, store.GetWorkspaceSnapshotParams{
+		Wsid:       wsid,
+		SnapshotID: snapshotID,
+	})
 	if err != nil {
 		WriteError(w, core.NewAppError(core.ErrNotFound, "snapshot not found"))
 		return
@@ -167,6 +257,10 @@ func (a *API) DropSnapshot(w http.ResponseWriter, r *http.Request) {
 		WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 		return
 	}
+	if snap.State == string(core.SnapshotPending) || snap.State == string(core.SnapshotCreating) {
+		WriteError(w, core.NewAppError(core.ErrConflictSnapshotInUse, "snapshot is still being created"))
+		return
+	}
 
 	// Can't drop current snapshot
 	if ws.CurrentSnapshotID.Valid && ws.CurrentSnapshotID.String == snapshotID {
@@ -180,6 +274,13 @@ func (a *API) DropSnapshot(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, core.NewAppError(core.ErrBadRequest, "Idempotency-Key header required"))
 		return
 	}
+	if !validateWait(w, r) {
+		return
+	}
+	priority, ok := requestPriority(w, r, core.OpSnapshotDrop)
+	if !ok {
+		return
+	}
 
 	body, _ := json.Marshal(map[string]string{"snapshot_id": snapshotID})
 	requestHash := core.ComputeRequestHash(body, "DELETE", "/v1/workspaces/"+wsid+"/snapshots/"+snapshotID)
@@ -192,7 +293,7 @@ func (a *API) DropSnapshot(w http.ResponseWriter, r *http.Request) {
 	})
 	if existingTask.TaskID != "" {
 		if existingTask.RequestHash == requestHash {
-			WriteAccepted(w, existingTask.TaskID, "/v1/tasks/")
+			a.writeTaskResult(w, r, existingTask.TaskID, nil)
 			return
 		}
 		WriteError(w, core.NewAppError(core.ErrConflictIdempotent, "idempotency key mismatch"))
@@ -203,16 +304,16 @@ func (a *API) DropSnapshot(w http.ResponseWriter, r *http.Request) {
 	taskID := core.NewID()
 	params, _ := json.Marshal(map[string]string{"snapshot_id": snapshotID})
 
-	_, err = a.queries.CreateTask(ctx, store.CreateTaskParams{
+	_, err = a.queries.CreateTask(ctx, a.withRetryPolicy(store.CreateTaskParams{
 		TaskID:         taskID,
 		Wsid:           wsid,
 		Op:             string(core.OpSnapshotDrop),
 		IdempotencyKey: idempotencyKey,
 		RequestHash:    requestHash,
 		Params:         params,
-		MaxAttempts:    5,
 		TimeoutSeconds: 300,
-	})
+		Priority:       int32(priority),
+	}))
 	if err != nil {
 		a.log.Error("create drop task failed", zap.Error(err))
 		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
@@ -221,7 +322,121 @@ func (a *API) DropSnapshot(w http.ResponseWriter, r *http.Request) {
 
 	_ = a.writeAudit(ctx, wsid, "snapshot.drop", &taskID, map[string]string{"snapshot_id": snapshotID})
 
-	WriteAccepted(w, taskID, "/v1/tasks/")
+	a.writeTaskResult(w, r, taskID, nil)
+}
+
+type RestorePathsRequest struct {
+	Paths []string `json:"paths"`
+}
+
+// RestorePaths copies files or subtrees from a snapshot into current (async).
+func (a *API) RestorePaths(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+	snapshotID := chi.URLParam(r, "snapshot_id")
+
+	// Check workspace exists and is active
+	ws, err := a.queries.GetWorkspace(ctx, wsid)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+	if ws.State == string(core.WorkspaceDisabled) {
+		WriteError(w, core.NewAppError(core.ErrGone, "workspace is disabled"))
+		return
+	}
+
+	// Check Idempotency-Key
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "Idempotency-Key header required"))
+		return
+	}
+	if !validateWait(w, r) {
+		return
+	}
+	priority, ok := requestPriority(w, r, core.OpRestorePaths)
+	if !ok {
+		return
+	}
+
+	var req RestorePathsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid request body"))
+		return
+	}
+	paths, err := core.CleanRestorePaths(req.Paths)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return
+	}
+
+	// Check snapshot exists in this workspace
+	snap, err := a.queries.GetWorkspaceSnapshot(ctx, store.GetWorkspaceSnapshotParams{
+		Wsid:       wsid,
+		SnapshotID: snapshotID,
+	})
+	if err != nil || snap.DeletedAt.Valid {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "snapshot not found"))
+		return
+	}
+	if snap.State != string(core.SnapshotReady) {
+		WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "snapshot is not ready"))
+		return
+	}
+
+	body, _ := json.Marshal(RestorePathsRequest{Paths: paths})
+	requestHash := core.ComputeRequestHash(body, "POST", "/v1/workspaces/"+wsid+"/snapshots/"+snapshotID+":restore")
+
+	// Check idempotency
+	existingTask, _ := a.queries.GetTaskByIdempotencyKey(ctx, store.GetTaskByIdempotencyKeyParams{
+		Wsid:           wsid,
+		Op:             string(core.OpRestorePaths),
+		IdempotencyKey: idempotencyKey,
+	})
+	if existingTask.TaskID != "" {
+		if existingTask.RequestHash == requestHash {
+			a.writeTaskResult(w, r, existingTask.TaskID, nil)
+			return
+		}
+		WriteError(w, core.NewAppError(core.ErrConflictIdempotent, "idempotency key mismatch"))
+		return
+	}
+
+	if appErr := a.checkHardQuota(ctx, ws, false); appErr != nil {
+		WriteError(w, appErr)
+		return
+	}
+
+	// Create task
+	taskID := core.NewID()
+	params, _ := json.Marshal(map[string]string{
+		"snapshot_id": snapshotID,
+		"paths":       core.EncodeRestorePaths(paths),
+	})
+
+	_, err = a.queries.CreateTask(ctx, a.withRetryPolicy(store.CreateTaskParams{
+		TaskID:         taskID,
+		Wsid:           wsid,
+		Op:             string(core.OpRestorePaths),
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+		Params:         params,
+		TimeoutSeconds: 300,
+		Priority:       int32(priority),
+	}))
+	if err != nil {
+		a.log.Error("create restore_paths task failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "snapshot.restore_paths", &taskID, map[string]interface{}{
+		"snapshot_id": snapshotID,
+		"paths":       paths,
+	})
+
+	a.writeTaskResult(w, r, taskID, nil)
 }
 
 func snapshotToResponse(s store.WvsSnapshot) SnapshotResponse {
@@ -229,12 +444,45 @@ func snapshotToResponse(s store.WvsSnapshot) SnapshotResponse {
 	if s.Message.Valid {
 		msg = s.Message.String
 	}
+	var taskID string
+	if s.TaskID.Valid {
+		taskID = s.TaskID.String
+	}
 	return SnapshotResponse{
-		SnapshotID: s.SnapshotID,
-		WSID:       s.Wsid,
-		FSPath:     s.FsPath,
-		Message:    msg,
-		CreatedAt:  s.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		SnapshotID:  s.SnapshotID,
+		WSID:        s.Wsid,
+		FSPath:      s.FsPath,
+		Message:     msg,
+		State:       s.State,
+		TaskID:      taskID,
+		SizeBytes:   int64Ptr(s.SizeBytes),
+		FileCount:   int64Ptr(s.FileCount),
+		UniqueBytes: int64Ptr(s.UniqueBytes),
+		SharedBytes: int64Ptr(s.SharedBytes),
+		Metadata:    json.RawMessage(s.Metadata),
+		TreeDigest:  s.TreeDigest.String,
+		VerifyState: s.VerifyState.String,
+		VerifiedAt:  formatTime(s.VerifiedAt),
+		CreatedAt:   s.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
+		DeletedAt:   formatTime(s.DeletedAt),
+	}
+}
+
+func int64Ptr(v pgtype.Int8) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
+
+// snapshotRef returns the accepted-response fields pointing at a reserved snapshot.
+func snapshotRef(wsid, snapshotID string) map[string]interface{} {
+	if snapshotID == "" {
+		return nil
+	}
+	return map[string]interface{}{
+		"snapshot_id":   snapshotID,
+		"snapshot_href": "/v1/workspaces/" + wsid + "/snapshots/" + snapshotID,
 	}
 }
 
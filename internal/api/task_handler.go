@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
@@ -17,6 +18,7 @@ type TaskResponse struct {
 	WSID            string                 `json:"wsid"`
 	Op              string                 `json:"op"`
 	Status          string                 `json:"status"`
+	Priority        string                 `json:"priority"`
 	IdempotencyKey  string                 `json:"idempotency_key"`
 	RequestHash     string                 `json:"request_hash"`
 	CreatedAt       string                 `json:"created_at"`
@@ -30,6 +32,10 @@ type TaskResponse struct {
 	Params          map[string]interface{} `json:"params"`
 	Result          map[string]interface{} `json:"result,omitempty"`
 	Error           map[string]interface{} `json:"error,omitempty"`
+	RetryOf         string                 `json:"retry_of,omitempty"`
+	DLQResolution   string                 `json:"dlq_resolution,omitempty"`
+	DependsOn       string                 `json:"depends_on,omitempty"`
+	BatchID         string                 `json:"batch_id,omitempty"`
 }
 
 // ListTasks lists tasks with filters.
@@ -110,6 +116,7 @@ func (a *API) CancelTask(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		task.Status = string(core.TaskCanceled)
+		a.abandonTask(ctx, task)
 	} else {
 		// If RUNNING, request cancel
 		_, err = a.queries.RequestCancelRunningTask(ctx, taskID)
@@ -125,6 +132,23 @@ func (a *API) CancelTask(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, taskToResponse(task))
 }
 
+// abandonTask cleans up after a task canceled before it ran: its reserved
+// snapshot fails and its dependents are canceled.
+func (a *API) abandonTask(ctx context.Context, task store.WvsTask) {
+	canceled, err := a.queries.AbandonTask(ctx, task)
+	if err != nil {
+		a.log.Warn("abandon task cleanup failed", zap.String("task_id", task.TaskID), zap.Error(err))
+	}
+	for _, dep := range canceled {
+		taskID := dep.TaskID
+		_ = a.writeAudit(ctx, dep.Wsid, "task.canceled", &taskID, map[string]string{
+			"status":     string(core.TaskCanceled),
+			"op":         dep.Op,
+			"depends_on": dep.DependsOn.String,
+		})
+	}
+}
+
 func taskToResponse(t store.WvsTask) TaskResponse {
 	var params, result, errMsg map[string]interface{}
 	json.Unmarshal(t.Params, &params)
@@ -140,6 +164,7 @@ func taskToResponse(t store.WvsTask) TaskResponse {
 		WSID:            t.Wsid,
 		Op:              t.Op,
 		Status:          t.Status,
+		Priority:        core.TaskPriority(t.Priority).String(),
 		IdempotencyKey:  t.IdempotencyKey,
 		RequestHash:     t.RequestHash,
 		CreatedAt:       t.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
@@ -153,6 +178,10 @@ func taskToResponse(t store.WvsTask) TaskResponse {
 		Params:          params,
 		Result:          result,
 		Error:           errMsg,
+		RetryOf:         t.RetryOf.String,
+		DLQResolution:   t.DlqResolution.String,
+		DependsOn:       t.DependsOn.String,
+		BatchID:         t.BatchID.String,
 	}
 }
 
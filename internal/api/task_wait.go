@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+// maxSyncWait caps how long a mutating request may block on its task.
+const maxSyncWait = 60 * time.Second
+
+// writeTaskResult responds for a freshly created (or replayed) task. If the
+// client asked to wait via `Prefer: wait=N` or `?wait=`, it blocks until the
+// task is terminal and returns the final task body; otherwise, or on
+// timeout, it falls back to 202 Accepted carrying extra.
+func (a *API) writeTaskResult(w http.ResponseWriter, r *http.Request, taskID string, extra map[string]interface{}) {
+	wait, err := parseWait(r)
+	if err != nil {
+		// The task is already enqueued; an unparseable wait degrades to async.
+		wait = 0
+	}
+	if wait > 0 {
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Now().Add(wait + 10*time.Second))
+
+		task, err := a.waitForTask(r.Context(), taskID, wait)
+		if err == nil && isTerminalStatus(task.Status) {
+			w.Header().Set("Preference-Applied", fmt.Sprintf("wait=%d", int(wait.Seconds())))
+			WriteJSON(w, http.StatusOK, taskToResponse(task))
+			return
+		}
+	}
+	WriteAcceptedWith(w, taskID, "/v1/tasks/", extra)
+}
+
+// waitForTask blocks until the task is terminal, the timeout elapses or ctx is done,
+// and returns the last observed task row.
+func (a *API) waitForTask(ctx context.Context, taskID string, timeout time.Duration) (store.WvsTask, error) {
+	wake, unsubscribe := a.events.Subscribe("", taskID)
+	defer unsubscribe()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		task, err := a.queries.GetTask(ctx, taskID)
+		if err != nil {
+			return task, err
+		}
+		if isTerminalStatus(task.Status) {
+			return task, nil
+		}
+		select {
+		case <-ctx.Done():
+			return task, ctx.Err()
+		case <-timer.C:
+			return task, nil
+		case <-wake:
+		}
+	}
+}
+
+// parseWait returns the requested synchronous wait, capped at maxSyncWait.
+// `?wait=` accepts a Go duration ("30s") or plain seconds; `Prefer: wait=N`
+// follows RFC 7240 and is in seconds.
+func parseWait(r *http.Request) (time.Duration, error) {
+	if s := r.URL.Query().Get("wait"); s != "" {
+		d, err := parseWaitValue(s)
+		if err != nil {
+			return 0, err
+		}
+		return capWait(d), nil
+	}
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pref), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), "wait") {
+				continue
+			}
+			secs, err := strconv.Atoi(strings.Trim(strings.TrimSpace(value), `"`))
+			if err != nil || secs < 0 {
+				return 0, fmt.Errorf("invalid Prefer wait %q", value)
+			}
+			return capWait(time.Duration(secs) * time.Second), nil
+		}
+	}
+	return 0, nil
+}
+
+func parseWaitValue(s string) (time.Duration, error) {
+	if secs, err := strconv.Atoi(s); err == nil {
+		if secs < 0 {
+			return 0, fmt.Errorf("invalid wait %q", s)
+		}
+		return time.Duration(secs) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid wait %q", s)
+	}
+	return d, nil
+}
+
+func capWait(d time.Duration) time.Duration {
+	if d > maxSyncWait {
+		return maxSyncWait
+	}
+	return d
+}
+
+// validateWait rejects malformed wait preferences before any task is created.
+func validateWait(w http.ResponseWriter, r *http.Request) bool {
+	if _, err := parseWait(r); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return false
+	}
+	return true
+}
+
+// requestPriority reads the optional `?priority=` query parameter, falling back
+// to the op's default. It writes a 400 and returns false if the value is unknown.
+func requestPriority(w http.ResponseWriter, r *http.Request, op core.TaskOp) (core.TaskPriority, bool) {
+	s := r.URL.Query().Get("priority")
+	if s == "" {
+		return core.DefaultPriority(op), true
+	}
+	p, err := core.ParsePriority(s)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return 0, false
+	}
+	return p, true
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+// VerifySnapshot recomputes a snapshot's tree digest and compares it with
+// the one recorded when it was taken (async). The outcome lands in the
+// task result and on the snapshot as verify_state.
+func (a *API) VerifySnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+	snapshotID := chi.URLParam(r, "snapshot_id")
+
+	// Check workspace exists and is active
+	ws, err := a.queries.GetWorkspace(ctx, wsid)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+	if ws.State == string(core.WorkspaceDisabled) {
+		WriteError(w, core.NewAppError(core.ErrGone, "workspace is disabled"))
+		return
+	}
+
+	// Check Idempotency-Key
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "Idempotency-Key header required"))
+		return
+	}
+	if !validateWait(w, r) {
+		return
+	}
+	priority, ok := requestPriority(w, r, core.OpVerify)
+	if !ok {
+		return
+	}
+
+	// Check snapshot exists in this workspace
+	snap, err := a.queries.GetWorkspaceSnapshot(ctx, store.GetWorkspaceSnapshotParams{
+		Wsid:       wsid,
+		SnapshotID: snapshotID,
+	})
+	if err != nil || snap.DeletedAt.Valid {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "snapshot not found"))
+		return
+	}
+	if snap.State != string(core.SnapshotReady) {
+		WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "snapshot is not ready"))
+		return
+	}
+
+	requestHash := core.ComputeRequestHash(nil, "POST", "/v1/workspaces/"+wsid+"/snapshots/"+snapshotID+":verify")
+
+	// Check idempotency
+	existingTask, _ := a.queries.GetTaskByIdempotencyKey(ctx, store.GetTaskByIdempotencyKeyParams{
+		Wsid:           wsid,
+		Op:             string(core.OpVerify),
+		IdempotencyKey: idempotencyKey,
+	})
+	if existingTask.TaskID != "" {
+		if existingTask.RequestHash == requestHash {
+			a.writeTaskResult(w, r, existingTask.TaskID, snapshotRef(wsid, snapshotID))
+			return
+		}
+		WriteError(w, core.NewAppError(core.ErrConflictIdempotent, "idempotency key mismatch"))
+		return
+	}
+
+	// Verify against the catalog's digest; the snapshot's own copy could
+	// have been overwritten along with its files.
+	taskParams := map[string]string{"snapshot_id": snapshotID}
+	if snap.TreeDigest.Valid {
+		taskParams["tree_digest"] = snap.TreeDigest.String
+	}
+	params, _ := json.Marshal(taskParams)
+
+	taskID := core.NewID()
+	_, err = a.queries.CreateTask(ctx, a.withRetryPolicy(store.CreateTaskParams{
+		TaskID:         taskID,
+		Wsid:           wsid,
+		Op:             string(core.OpVerify),
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+		Params:         params,
+		TimeoutSeconds: 3600,
+		Priority:       int32(priority),
+	}))
+	if err != nil {
+		a.log.Error("create verify task failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "snapshot.verify", &taskID, map[string]string{"snapshot_id": snapshotID})
+
+	a.writeTaskResult(w, r, taskID, snapshotRef(wsid, snapshotID))
+}
@@ -14,20 +14,29 @@ import (
 )
 
 type CreateWorkspaceRequest struct {
-	WSID     string `json:"wsid"`
-	RootPath string `json:"root_path"`
-	Owner    string `json:"owner"`
+	WSID            string               `json:"wsid"`
+	RootPath        string               `json:"root_path"`
+	Owner           string               `json:"owner"`
+	QuiescePolicy   string               `json:"quiesce_policy,omitempty"`
+	Hooks           *core.WorkspaceHooks `json:"hooks,omitempty"`
+	ExcludePatterns []string             `json:"exclude_patterns,omitempty"`
 }
 
 type WorkspaceResponse struct {
-	WSID              string `json:"wsid"`
-	RootPath          string `json:"root_path"`
-	Owner             string `json:"owner"`
-	State             string `json:"state"`
-	CurrentSnapshotID string `json:"current_snapshot_id,omitempty"`
-	CurrentPath       string `json:"current_path"`
-	CreatedAt         string `json:"created_at"`
-	UpdatedAt         string `json:"updated_at"`
+	WSID              string               `json:"wsid"`
+	RootPath          string               `json:"root_path"`
+	Owner             string               `json:"owner"`
+	State             string               `json:"state"`
+	CurrentSnapshotID string               `json:"current_snapshot_id,omitempty"`
+	CurrentPath       string               `json:"current_path"`
+	Quota             *core.Quota          `json:"quota,omitempty"`
+	QuiescePolicy     string               `json:"quiesce_policy"`
+	Hooks             *core.WorkspaceHooks `json:"hooks,omitempty"`
+	ExcludePatterns   []string             `json:"exclude_patterns,omitempty"`
+	UsageBytes        int64                `json:"usage_bytes"`
+	UsageSnapshots    int32                `json:"usage_snapshots"`
+	CreatedAt         string               `json:"created_at"`
+	UpdatedAt         string               `json:"updated_at"`
 }
 
 // ListWorkspaces lists all workspaces with pagination.
@@ -78,6 +87,52 @@ func (a *API) GetWorkspace(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, workspaceToResponse(ws))
 }
 
+// WorkspaceUsageResponse rolls up storage held by a workspace's live snapshots.
+// UniqueBytes counts data not shared with a parent snapshot; UsageBytes adds
+// the live directory's unique data and is the figure quotas and chargeback use.
+type WorkspaceUsageResponse struct {
+	WSID            string      `json:"wsid"`
+	SnapshotCount   int64       `json:"snapshot_count"`
+	FileCount       int64       `json:"file_count"`
+	LogicalBytes    int64       `json:"logical_bytes"`
+	UniqueBytes     int64       `json:"unique_bytes"`
+	LiveUniqueBytes int64       `json:"live_unique_bytes"`
+	UsageBytes      int64       `json:"usage_bytes"`
+	UsageUpdatedAt  string      `json:"usage_updated_at,omitempty"`
+	Quota           *core.Quota `json:"quota,omitempty"`
+}
+
+// GetWorkspaceUsage reports storage usage for a workspace.
+func (a *API) GetWorkspaceUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+
+	ws, err := a.queries.GetWorkspace(ctx, wsid)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+
+	usage, err := a.queries.GetWorkspaceUsage(ctx, wsid)
+	if err != nil {
+		a.log.Error("get workspace usage failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to get workspace usage"))
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, WorkspaceUsageResponse{
+		WSID:            wsid,
+		SnapshotCount:   usage.SnapshotCount,
+		FileCount:       usage.FileCount,
+		LogicalBytes:    usage.LogicalBytes,
+		UniqueBytes:     usage.UniqueBytes,
+		LiveUniqueBytes: ws.LiveUniqueBytes,
+		UsageBytes:      ws.UsageBytes,
+		UsageUpdatedAt:  formatTime(ws.UsageUpdatedAt),
+		Quota:           quotaFromWorkspace(ws),
+	})
+}
+
 // CreateWorkspace creates a new workspace (async).
 func (a *API) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -88,6 +143,13 @@ func (a *API) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, core.NewAppError(core.ErrBadRequest, "Idempotency-Key header required"))
 		return
 	}
+	if !validateWait(w, r) {
+		return
+	}
+	priority, ok := requestPriority(w, r, core.OpInitWorkspace)
+	if !ok {
+		return
+	}
 
 	var req CreateWorkspaceRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -100,6 +162,27 @@ func (a *API) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, core.NewAppError(core.ErrBadRequest, "wsid, root_path, and owner are required"))
 		return
 	}
+	quiescePolicy, err := core.ParseQuiescePolicy(req.QuiescePolicy)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return
+	}
+	var hooks core.WorkspaceHooks
+	if req.Hooks != nil {
+		hooks = *req.Hooks
+		if err := hooks.Normalize(); err != nil {
+			WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+			return
+		}
+		if !hooks.Empty() && !a.hooksEnabled {
+			WriteError(w, errHooksDisabled)
+			return
+		}
+	}
+	if err := core.ValidateExcludePatterns(req.ExcludePatterns); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return
+	}
 
 	// Compute request hash
 	body, _ := json.Marshal(req)
@@ -113,7 +196,7 @@ func (a *API) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 	})
 	if existingTask.TaskID != "" {
 		if existingTask.RequestHash == requestHash {
-			WriteAccepted(w, existingTask.TaskID, "/v1/tasks/")
+			a.writeTaskResult(w, r, existingTask.TaskID, nil)
 			return
 		}
 		WriteError(w, core.NewAppError(core.ErrConflictIdempotent, "idempotency key mismatch"))
@@ -121,11 +204,15 @@ func (a *API) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create workspace record (PROVISIONING state)
-	_, err := a.queries.CreateWorkspace(ctx, store.CreateWorkspaceParams{
-		Wsid:        req.WSID,
-		RootPath:    req.RootPath,
-		Owner:       req.Owner,
-		CurrentPath: req.RootPath, // Initial current_path
+	hooksJSON, _ := json.Marshal(hooks)
+	_, err = a.queries.CreateWorkspace(ctx, store.CreateWorkspaceParams{
+		Wsid:            req.WSID,
+		RootPath:        req.RootPath,
+		Owner:           req.Owner,
+		CurrentPath:     req.RootPath, // Initial current_path
+		QuiescePolicy:   string(quiescePolicy),
+		Hooks:           hooksJSON,
+		ExcludePatterns: nonNilStrings(req.ExcludePatterns),
 	})
 	if err != nil {
 		a.log.Error("create workspace failed", zap.Error(err))
@@ -136,16 +223,16 @@ func (a *API) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 	// Create init_workspace task
 	taskID := core.NewID()
 	params, _ := json.Marshal(map[string]string{"owner": req.Owner})
-	_, err = a.queries.CreateTask(ctx, store.CreateTaskParams{
+	_, err = a.queries.CreateTask(ctx, a.withRetryPolicy(store.CreateTaskParams{
 		TaskID:         taskID,
 		Wsid:           req.WSID,
 		Op:             string(core.OpInitWorkspace),
 		IdempotencyKey: idempotencyKey,
 		RequestHash:    requestHash,
 		Params:         params,
-		MaxAttempts:    5,
 		TimeoutSeconds: 300,
-	})
+		Priority:       int32(priority),
+	}))
 	if err != nil {
 		a.log.Error("create task failed", zap.Error(err))
 		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
@@ -155,7 +242,7 @@ func (a *API) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 	// Write audit log
 	_ = a.writeAudit(ctx, req.WSID, "workspace.create", &taskID, req)
 
-	WriteAccepted(w, taskID, "/v1/tasks/")
+	a.writeTaskResult(w, r, taskID, nil)
 }
 
 // RetryInit retries a failed workspace initialization.
@@ -173,6 +260,10 @@ func (a *API) RetryInit(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, core.NewAppError(core.ErrPreconditionFailed, "workspace is not in INIT_FAILED state"))
 		return
 	}
+	priority, ok := requestPriority(w, r, core.OpInitWorkspace)
+	if !ok {
+		return
+	}
 
 	// Reset workspace to PROVISIONING
 	_ = a.queries.UpdateWorkspaceState(ctx, store.UpdateWorkspaceStateParams{
@@ -186,23 +277,23 @@ func (a *API) RetryInit(w http.ResponseWriter, r *http.Request) {
 	params, _ := json.Marshal(map[string]string{"owner": ws.Owner})
 	requestHash := core.ComputeRequestHash(params, "POST", "/v1/workspaces/"+wsid+"/retry-init")
 
-	_, err = a.queries.CreateTask(ctx, store.CreateTaskParams{
+	_, err = a.queries.CreateTask(ctx, a.withRetryPolicy(store.CreateTaskParams{
 		TaskID:         taskID,
 		Wsid:           wsid,
 		Op:             string(core.OpInitWorkspace),
 		IdempotencyKey: idempotencyKey,
 		RequestHash:    requestHash,
 		Params:         params,
-		MaxAttempts:    5,
 		TimeoutSeconds: 300,
-	})
+		Priority:       int32(priority),
+	}))
 	if err != nil {
 		a.log.Error("create retry task failed", zap.Error(err))
 		WriteError(w, core.NewAppError(core.ErrInternal, "failed to create task"))
 		return
 	}
 
-	WriteAccepted(w, taskID, "/v1/tasks/")
+	a.writeTaskResult(w, r, taskID, nil)
 }
 
 // DisableWorkspace disables a workspace (sync).
@@ -241,6 +332,138 @@ func (a *API) DisableWorkspace(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, workspaceToResponse(ws))
 }
 
+type SetQuiescePolicyRequest struct {
+	Policy string `json:"policy"`
+}
+
+// SetQuiescePolicy sets how the executor quiesces a workspace's guest before
+// snapshots and switches. It applies to tasks dispatched from now on.
+func (a *API) SetQuiescePolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+
+	if _, err := a.queries.GetWorkspace(ctx, wsid); err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+
+	var req SetQuiescePolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid request body"))
+		return
+	}
+	if req.Policy == "" {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "policy is required"))
+		return
+	}
+	policy, err := core.ParseQuiescePolicy(req.Policy)
+	if err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return
+	}
+
+	ws, err := a.queries.SetWorkspaceQuiescePolicy(ctx, store.SetWorkspaceQuiescePolicyParams{
+		Wsid:          wsid,
+		QuiescePolicy: string(policy),
+	})
+	if err != nil {
+		a.log.Error("set quiesce policy failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to set quiesce policy"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "workspace.quiesce_policy_set", nil, req)
+
+	WriteJSON(w, http.StatusOK, workspaceToResponse(ws))
+}
+
+// errHooksDisabled rejects hooks on a deployment that does not run them.
+var errHooksDisabled = core.NewAppError(core.ErrPreconditionFailed,
+	"workspace hooks are disabled; they need WVS_HOOKS_ENABLED and EXECUTOR_HOOKS_ENABLED")
+
+// SetHooks replaces a workspace's hooks; an empty body object removes them.
+// They apply to tasks dispatched from now on. A hook is a shell command on
+// the executor, so setting one is refused unless WVS_HOOKS_ENABLED is set.
+func (a *API) SetHooks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+
+	if _, err := a.queries.GetWorkspace(ctx, wsid); err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+
+	var req core.WorkspaceHooks
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid request body"))
+		return
+	}
+	if err := req.Normalize(); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return
+	}
+	if !req.Empty() && !a.hooksEnabled {
+		WriteError(w, errHooksDisabled)
+		return
+	}
+
+	hooksJSON, _ := json.Marshal(req)
+	ws, err := a.queries.SetWorkspaceHooks(ctx, store.SetWorkspaceHooksParams{
+		Wsid:  wsid,
+		Hooks: hooksJSON,
+	})
+	if err != nil {
+		a.log.Error("set hooks failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to set hooks"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "workspace.hooks_set", nil, req)
+
+	WriteJSON(w, http.StatusOK, workspaceToResponse(ws))
+}
+
+type SetExcludePatternsRequest struct {
+	Patterns []string `json:"patterns"`
+}
+
+// SetExcludePatterns replaces the patterns a workspace's snapshots leave
+// out, on top of its .wvsignore; an empty list removes them. They apply to
+// snapshots taken from now on.
+func (a *API) SetExcludePatterns(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	wsid := chi.URLParam(r, "wsid")
+
+	if _, err := a.queries.GetWorkspace(ctx, wsid); err != nil {
+		WriteError(w, core.NewAppError(core.ErrNotFound, "workspace not found"))
+		return
+	}
+
+	var req SetExcludePatternsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, "invalid request body"))
+		return
+	}
+	if err := core.ValidateExcludePatterns(req.Patterns); err != nil {
+		WriteError(w, core.NewAppError(core.ErrBadRequest, err.Error()))
+		return
+	}
+
+	ws, err := a.queries.SetWorkspaceExcludePatterns(ctx, store.SetWorkspaceExcludePatternsParams{
+		Wsid:            wsid,
+		ExcludePatterns: nonNilStrings(req.Patterns),
+	})
+	if err != nil {
+		a.log.Error("set exclude patterns failed", zap.Error(err))
+		WriteError(w, core.NewAppError(core.ErrInternal, "failed to set exclude patterns"))
+		return
+	}
+
+	_ = a.writeAudit(ctx, wsid, "workspace.exclude_patterns_set", nil, req)
+
+	WriteJSON(w, http.StatusOK, workspaceToResponse(ws))
+}
+
 func workspaceToResponse(ws store.WvsWorkspace) WorkspaceResponse {
 	var snapshotID string
 	if ws.CurrentSnapshotID.Valid {
@@ -253,11 +476,35 @@ func workspaceToResponse(ws store.WvsWorkspace) WorkspaceResponse {
 		State:             ws.State,
 		CurrentSnapshotID: snapshotID,
 		CurrentPath:       ws.CurrentPath,
+		Quota:             quotaFromWorkspace(ws),
+		QuiescePolicy:     ws.QuiescePolicy,
+		Hooks:             hooksFromWorkspace(ws),
+		ExcludePatterns:   ws.ExcludePatterns,
+		UsageBytes:        ws.UsageBytes,
+		UsageSnapshots:    ws.UsageSnapshots,
 		CreatedAt:         ws.CreatedAt.Time.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:         ws.UpdatedAt.Time.Format("2006-01-02T15:04:05Z"),
 	}
 }
 
+// hooksFromWorkspace returns ws's hooks, or nil if it has none.
+func hooksFromWorkspace(ws store.WvsWorkspace) *core.WorkspaceHooks {
+	hooks, err := core.ParseWorkspaceHooks(ws.Hooks)
+	if err != nil || hooks.Empty() {
+		return nil
+	}
+	return &hooks
+}
+
+// nonNilStrings returns s, or an empty slice for nil: a nil slice would be
+// stored as NULL.
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
 func parseLimit(s string, defaultVal, maxVal int) int {
 	if s == "" {
 		return defaultVal
@@ -0,0 +1,48 @@
+package core
+
+// BatchStatus summarizes the status of a batch from its steps, in order.
+type BatchStatus string
+
+const (
+	BatchPending   BatchStatus = "PENDING"
+	BatchRunning   BatchStatus = "RUNNING"
+	BatchSucceeded BatchStatus = "SUCCEEDED"
+	BatchFailed    BatchStatus = "FAILED"
+	BatchCanceled  BatchStatus = "CANCELED"
+)
+
+// BatchOps are the ops a batch step may run.
+var BatchOps = map[TaskOp]bool{
+	OpSnapshotCreate: true,
+	OpSnapshotDrop:   true,
+	OpSetCurrent:     true,
+}
+
+// RollupBatchStatus derives a batch's status from its steps' statuses. A
+// DEAD step fails the batch; otherwise any CANCELED step cancels it.
+func RollupBatchStatus(steps []TaskStatus) BatchStatus {
+	succeeded, started := 0, false
+	canceled := false
+	for _, s := range steps {
+		switch s {
+		case TaskDead:
+			return BatchFailed
+		case TaskCanceled:
+			canceled = true
+		case TaskSucceeded:
+			succeeded++
+			started = true
+		case TaskRunning, TaskFailed:
+			started = true
+		}
+	}
+	switch {
+	case canceled:
+		return BatchCanceled
+	case succeeded == len(steps):
+		return BatchSucceeded
+	case started:
+		return BatchRunning
+	}
+	return BatchPending
+}
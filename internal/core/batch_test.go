@@ -0,0 +1,26 @@
+package core
+
+import "testing"
+
+func TestRollupBatchStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []TaskStatus
+		want  BatchStatus
+	}{
+		{"not started", []TaskStatus{TaskPending, TaskPending}, BatchPending},
+		{"first running", []TaskStatus{TaskRunning, TaskPending}, BatchRunning},
+		{"midway", []TaskStatus{TaskSucceeded, TaskPending}, BatchRunning},
+		{"retrying", []TaskStatus{TaskFailed, TaskPending}, BatchRunning},
+		{"done", []TaskStatus{TaskSucceeded, TaskSucceeded}, BatchSucceeded},
+		{"step dead", []TaskStatus{TaskSucceeded, TaskDead, TaskCanceled}, BatchFailed},
+		{"step canceled", []TaskStatus{TaskCanceled, TaskCanceled}, BatchCanceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RollupBatchStatus(tt.steps); got != tt.want {
+				t.Errorf("RollupBatchStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package core
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type ErrorCode string
 
@@ -13,6 +16,8 @@ const (
 	ErrConflictSnapshotInUse ErrorCode = "WVS_CONFLICT_SNAPSHOT_IN_USE"
 	ErrGone                  ErrorCode = "WVS_GONE"
 	ErrPreconditionFailed    ErrorCode = "WVS_PRECONDITION_FAILED"
+	ErrQuotaExceeded         ErrorCode = "WVS_QUOTA_EXCEEDED"
+	ErrRangeNotSatisfiable   ErrorCode = "WVS_RANGE_NOT_SATISFIABLE"
 	ErrInternal              ErrorCode = "WVS_INTERNAL"
 	ErrExecutorError         ErrorCode = "WVS_EXECUTOR_ERROR"
 	ErrExecutorTimeout       ErrorCode = "WVS_EXECUTOR_TIMEOUT"
@@ -31,6 +36,10 @@ func (e ErrorCode) HTTPStatus() int {
 		return 410
 	case ErrPreconditionFailed:
 		return 412
+	case ErrRangeNotSatisfiable:
+		return 416
+	case ErrQuotaExceeded:
+		return 507
 	case ErrExecutorError:
 		return 502
 	case ErrExecutorTimeout:
@@ -52,3 +61,52 @@ func (e *AppError) Error() string {
 func NewAppError(code ErrorCode, msg string) *AppError {
 	return &AppError{Code: code, Message: msg}
 }
+
+// ErrorClass classifies a task failure. It mirrors the executor proto's
+// ErrorClass enum and is stored as error.code on the task.
+type ErrorClass string
+
+const (
+	ErrClassInternal         ErrorClass = "INTERNAL"
+	ErrClassQuiesceTimeout   ErrorClass = "QUIESCE_TIMEOUT"
+	ErrClassSnapshotNotFound ErrorClass = "SNAPSHOT_NOT_FOUND"
+	ErrClassNoSpace          ErrorClass = "NO_SPACE"
+	ErrClassPermission       ErrorClass = "PERMISSION"
+	ErrClassCloneFailed      ErrorClass = "CLONE_FAILED"
+	ErrClassCanceled         ErrorClass = "CANCELED"
+	ErrClassInvalidParams    ErrorClass = "INVALID_PARAMS"
+	ErrClassHookFailed       ErrorClass = "HOOK_FAILED"
+)
+
+// Retryable reports whether a failure of this class may succeed on retry.
+func (c ErrorClass) Retryable() bool {
+	switch c {
+	case ErrClassInternal, ErrClassQuiesceTimeout, ErrClassCloneFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// TaskError is a classified task failure.
+type TaskError struct {
+	Class   ErrorClass
+	Message string
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Class, e.Message)
+}
+
+func NewTaskError(class ErrorClass, msg string) *TaskError {
+	return &TaskError{Class: class, Message: msg}
+}
+
+// ErrorClassOf returns the class of err, or ErrClassInternal if it is unclassified.
+func ErrorClassOf(err error) ErrorClass {
+	var te *TaskError
+	if errors.As(err, &te) {
+		return te.Class
+	}
+	return ErrClassInternal
+}
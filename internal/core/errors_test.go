@@ -0,0 +1,32 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorClassOf(t *testing.T) {
+	wrapped := fmt.Errorf("dispatch: %w", NewTaskError(ErrClassNoSpace, "disk full"))
+	if got := ErrorClassOf(wrapped); got != ErrClassNoSpace {
+		t.Fatalf("ErrorClassOf(wrapped) = %s, want %s", got, ErrClassNoSpace)
+	}
+	if got := ErrorClassOf(errors.New("boom")); got != ErrClassInternal {
+		t.Fatalf("ErrorClassOf(plain) = %s, want %s", got, ErrClassInternal)
+	}
+}
+
+func TestErrorClassRetryable(t *testing.T) {
+	retryable := []ErrorClass{ErrClassInternal, ErrClassQuiesceTimeout, ErrClassCloneFailed}
+	permanent := []ErrorClass{ErrClassSnapshotNotFound, ErrClassNoSpace, ErrClassPermission, ErrClassCanceled, ErrClassInvalidParams, ErrClassHookFailed}
+	for _, c := range retryable {
+		if !c.Retryable() {
+			t.Errorf("%s should be retryable", c)
+		}
+	}
+	for _, c := range permanent {
+		if c.Retryable() {
+			t.Errorf("%s should be permanent", c)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ExcludeFile is the file in a workspace's live root listing paths to leave
+// out of its snapshots, one pattern per line.
+const ExcludeFile = ".wvsignore"
+
+// MaxExcludePatterns bounds a workspace's own exclude list.
+const MaxExcludePatterns = 256
+
+// Excluder decides which paths of a tree a snapshot leaves out. Patterns
+// follow a subset of .gitignore:
+//
+//   - blank lines and lines starting with # are skipped;
+//   - a pattern without a slash matches a name at any depth, one with a
+//     slash (a leading one included) matches the path from the root;
+//   - *, ? and [...] match within a name and ** matches any number of
+//     directories;
+//   - a trailing slash matches directories only;
+//   - a leading ! re-includes what an earlier pattern excluded, except
+//     below an excluded directory.
+//
+// The last matching pattern wins. The root's .wvs directory, which holds
+// the executor's own metadata, is never excluded.
+type Excluder struct {
+	rules []excludeRule
+}
+
+type excludeRule struct {
+	segs     []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// CompileExcludes compiles patterns into an Excluder, or returns nil if
+// there are none.
+func CompileExcludes(patterns []string) (*Excluder, error) {
+	var e Excluder
+	for _, p := range patterns {
+		r, ok, err := compileExclude(p)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			e.rules = append(e.rules, r)
+		}
+	}
+	if len(e.rules) == 0 {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+// ValidateExcludePatterns checks a workspace's own exclude list.
+func ValidateExcludePatterns(patterns []string) error {
+	if len(patterns) > MaxExcludePatterns {
+		return fmt.Errorf("at most %d exclude patterns", MaxExcludePatterns)
+	}
+	for _, p := range patterns {
+		if _, ok, err := compileExclude(p); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("empty exclude pattern %q", p)
+		}
+	}
+	return nil
+}
+
+// ParseExcludeFile splits the contents of an ExcludeFile into patterns.
+// Lines that do not compile are returned separately rather than failing the
+// whole file, as git does with a .gitignore.
+func ParseExcludeFile(data []byte) (patterns, invalid []string) {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if _, ok, err := compileExclude(line); err != nil {
+			invalid = append(invalid, line)
+		} else if ok {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, invalid
+}
+
+func compileExclude(p string) (excludeRule, bool, error) {
+	var r excludeRule
+	if s := strings.TrimSpace(p); s == "" || strings.HasPrefix(s, "#") {
+		return r, false, nil
+	}
+	orig := p
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if strings.HasPrefix(p, "/") {
+		r.anchored = true
+		p = strings.TrimLeft(p, "/")
+	}
+	if p == "" {
+		return r, false, fmt.Errorf("invalid exclude pattern %q", orig)
+	}
+	if strings.Contains(p, "/") {
+		r.anchored = true
+	}
+	r.segs = strings.Split(p, "/")
+	for _, seg := range r.segs {
+		if seg == "" || seg == "." || seg == ".." {
+			return r, false, fmt.Errorf("invalid exclude pattern %q", orig)
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return r, false, fmt.Errorf("invalid exclude pattern %q: %v", orig, err)
+		}
+	}
+	return r, true, nil
+}
+
+// Excluded reports whether the entry at rel, a slash-separated path relative
+// to the root, is left out. Callers walking a tree skip the contents of an
+// excluded directory without asking about them. A nil Excluder excludes
+// nothing.
+func (e *Excluder) Excluded(rel string, isDir bool) bool {
+	if e == nil || rel == "" || rel == "." || rel == ".wvs" || strings.HasPrefix(rel, ".wvs/") {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	excluded := false
+	for _, r := range e.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		target := parts
+		if !r.anchored {
+			target = parts[len(parts)-1:]
+		}
+		if matchSegs(r.segs, target) {
+			excluded = !r.negate
+		}
+	}
+	return excluded
+}
+
+// matchSegs matches path segments against pattern segments, where a "**"
+// segment matches any number of path segments.
+func matchSegs(pat, parts []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegs(pat[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], parts[0]); !ok {
+			return false
+		}
+		pat, parts = pat[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
@@ -0,0 +1,82 @@
+package core
+
+import "testing"
+
+func TestExcluder(t *testing.T) {
+	e, err := CompileExcludes([]string{
+		"# caches",
+		"node_modules/",
+		"*.pyc",
+		"/build",
+		"docs/**/*.tmp",
+		"logs/*",
+		"!logs/keep.log",
+		"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"node_modules", true, true},
+		{"web/node_modules", true, true},
+		{"node_modules", false, false}, // dirs only
+		{"a.pyc", false, true},
+		{"src/pkg/a.pyc", false, true},
+		{"a.py", false, false},
+		{"build", true, true},
+		{"src/build", true, false}, // anchored
+		{"docs/a.tmp", false, true},
+		{"docs/x/y/a.tmp", false, true},
+		{"a.tmp", false, false},
+		{"logs/app.log", false, true},
+		{"logs/keep.log", false, false},
+		{"logs", true, false},
+		{".wvs", true, false},
+		{".wvs/control.json", false, false},
+	}
+	for _, c := range cases {
+		if got := e.Excluded(c.rel, c.isDir); got != c.want {
+			t.Errorf("Excluded(%q, dir=%v) = %v, want %v", c.rel, c.isDir, got, c.want)
+		}
+	}
+
+	if e, err := CompileExcludes([]string{"# nothing", " "}); e != nil || err != nil {
+		t.Errorf("no patterns: %v, %v", e, err)
+	}
+	var none *Excluder
+	if none.Excluded("a", false) {
+		t.Error("nil Excluder excluded a path")
+	}
+}
+
+func TestValidateExcludePatterns(t *testing.T) {
+	if err := ValidateExcludePatterns([]string{"node_modules/", "/dist", "**/*.o"}); err != nil {
+		t.Error(err)
+	}
+	for _, bad := range [][]string{{""}, {"# comment"}, {"/"}, {"!"}, {"a/../b"}, {"[z-a"}} {
+		if err := ValidateExcludePatterns(bad); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+	too := make([]string, MaxExcludePatterns+1)
+	for i := range too {
+		too[i] = "x"
+	}
+	if err := ValidateExcludePatterns(too); err == nil {
+		t.Error("accepted too many patterns")
+	}
+}
+
+func TestParseExcludeFile(t *testing.T) {
+	patterns, invalid := ParseExcludeFile([]byte("# comment\r\nnode_modules/\r\n\n[oops\n.venv  \n"))
+	if len(patterns) != 2 || patterns[0] != "node_modules/" || patterns[1] != ".venv" {
+		t.Errorf("patterns %q", patterns)
+	}
+	if len(invalid) != 1 || invalid[0] != "[oops" {
+		t.Errorf("invalid %q", invalid)
+	}
+}
@@ -0,0 +1,53 @@
+package core
+
+import "fmt"
+
+// ExportCompression is how an export archive is compressed.
+type ExportCompression string
+
+const (
+	CompressionZstd ExportCompression = "zstd"
+	CompressionNone ExportCompression = "none"
+)
+
+// ExportDestination is where an export archive is written.
+type ExportDestination string
+
+const (
+	DestinationLocal ExportDestination = "local"
+	DestinationS3    ExportDestination = "s3"
+)
+
+// ExportManifestPath is the archive entry listing the SHA-256 of every
+// regular file, in sha256sum format. It is always the last entry.
+const ExportManifestPath = ".wvs/MANIFEST.sha256"
+
+// ParseExportCompression accepts a compression name; "" means zstd.
+func ParseExportCompression(s string) (ExportCompression, error) {
+	switch c := ExportCompression(s); c {
+	case "":
+		return CompressionZstd, nil
+	case CompressionZstd, CompressionNone:
+		return c, nil
+	}
+	return "", fmt.Errorf("invalid compression %q (want zstd or none)", s)
+}
+
+// ParseExportDestination accepts a destination name; "" means local.
+func ParseExportDestination(s string) (ExportDestination, error) {
+	switch d := ExportDestination(s); d {
+	case "":
+		return DestinationLocal, nil
+	case DestinationLocal, DestinationS3:
+		return d, nil
+	}
+	return "", fmt.Errorf("invalid destination %q (want local or s3)", s)
+}
+
+// ArchiveExt is the file extension of an archive with this compression.
+func (c ExportCompression) ArchiveExt() string {
+	if c == CompressionZstd {
+		return ".tar.zst"
+	}
+	return ".tar"
+}
@@ -0,0 +1,21 @@
+package core
+
+import "testing"
+
+func TestParseExportOptions(t *testing.T) {
+	if c, err := ParseExportCompression(""); err != nil || c != CompressionZstd {
+		t.Errorf("default compression = %q, %v", c, err)
+	}
+	if c, err := ParseExportCompression("none"); err != nil || c.ArchiveExt() != ".tar" {
+		t.Errorf("none compression = %q, %v", c, err)
+	}
+	if _, err := ParseExportCompression("gzip"); err == nil {
+		t.Error("expected error for gzip")
+	}
+	if d, err := ParseExportDestination(""); err != nil || d != DestinationLocal {
+		t.Errorf("default destination = %q, %v", d, err)
+	}
+	if _, err := ParseExportDestination("ftp"); err == nil {
+		t.Error("expected error for ftp")
+	}
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HookPoint is where in a task the executor runs a workspace's hooks.
+type HookPoint string
+
+const (
+	// HookPreSnapshot runs in the live directory before the guest is
+	// quiesced for snapshot_create or checkpoint_restore's checkpoint, while
+	// the workload can still write: flush a WAL, dump a database.
+	HookPreSnapshot HookPoint = "pre_snapshot"
+	// HookPostRestore runs in the new live directory after set_current or
+	// checkpoint_restore switched to it and the guest was resumed.
+	HookPostRestore HookPoint = "post_restore"
+)
+
+// HookFailure decides what a failing hook does to its task.
+type HookFailure string
+
+const (
+	// HookFailTask fails a pre_snapshot hook's task with HOOK_FAILED, so no
+	// snapshot is taken. A restore has already switched by the time its
+	// post_restore hooks run, so there it only stops the hooks after it and
+	// flags the task result with post_restore_failed.
+	HookFailTask HookFailure = "fail"
+	// HookIgnore records the failure in the task result and carries on.
+	HookIgnore HookFailure = "ignore"
+)
+
+const (
+	DefaultHookTimeout = 60 * time.Second
+	MaxHookTimeout     = 30 * time.Minute
+	// MaxHooksPerPoint bounds how many hooks a workspace may define per point.
+	MaxHooksPerPoint = 8
+)
+
+// Hook is a command the executor runs with sh -c.
+type Hook struct {
+	// Name identifies the hook in results and logs; defaults to its position.
+	Name           string      `json:"name,omitempty"`
+	Command        string      `json:"command"`
+	TimeoutSeconds int         `json:"timeout_seconds,omitempty"`
+	OnFailure      HookFailure `json:"on_failure,omitempty"`
+}
+
+// Timeout returns the hook's timeout, DefaultHookTimeout if it has none.
+func (h Hook) Timeout() time.Duration {
+	if h.TimeoutSeconds <= 0 {
+		return DefaultHookTimeout
+	}
+	return time.Duration(h.TimeoutSeconds) * time.Second
+}
+
+// WorkspaceHooks are a workspace's hooks by the point they run at, each
+// list in order.
+type WorkspaceHooks struct {
+	PreSnapshot []Hook `json:"pre_snapshot,omitempty"`
+	PostRestore []Hook `json:"post_restore,omitempty"`
+}
+
+// At returns the hooks that run at point.
+func (h WorkspaceHooks) At(point HookPoint) []Hook {
+	switch point {
+	case HookPreSnapshot:
+		return h.PreSnapshot
+	case HookPostRestore:
+		return h.PostRestore
+	}
+	return nil
+}
+
+// Empty reports whether no hooks are defined.
+func (h WorkspaceHooks) Empty() bool {
+	return len(h.PreSnapshot) == 0 && len(h.PostRestore) == 0
+}
+
+// Normalize validates h and fills in defaults: names from positions and
+// on_failure "fail".
+func (h *WorkspaceHooks) Normalize() error {
+	for _, p := range []struct {
+		point HookPoint
+		hooks []Hook
+	}{{HookPreSnapshot, h.PreSnapshot}, {HookPostRestore, h.PostRestore}} {
+		if len(p.hooks) > MaxHooksPerPoint {
+			return fmt.Errorf("%s: at most %d hooks", p.point, MaxHooksPerPoint)
+		}
+		names := map[string]bool{}
+		for i := range p.hooks {
+			hk := &p.hooks[i]
+			if hk.Name == "" {
+				hk.Name = fmt.Sprintf("%s-%d", p.point, i+1)
+			}
+			if names[hk.Name] {
+				return fmt.Errorf("%s: duplicate hook name %q", p.point, hk.Name)
+			}
+			names[hk.Name] = true
+			if strings.TrimSpace(hk.Command) == "" {
+				return fmt.Errorf("%s: hook %q has no command", p.point, hk.Name)
+			}
+			if hk.TimeoutSeconds < 0 || time.Duration(hk.TimeoutSeconds)*time.Second > MaxHookTimeout {
+				return fmt.Errorf("%s: hook %q: timeout_seconds must be between 0 and %d", p.point, hk.Name, int(MaxHookTimeout/time.Second))
+			}
+			switch hk.OnFailure {
+			case "":
+				hk.OnFailure = HookFailTask
+			case HookFailTask, HookIgnore:
+			default:
+				return fmt.Errorf("%s: hook %q: invalid on_failure %q (want %s or %s)", p.point, hk.Name, hk.OnFailure, HookFailTask, HookIgnore)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseWorkspaceHooks decodes and normalizes hooks as stored on the
+// workspace and passed to the executor. Empty input means no hooks.
+func ParseWorkspaceHooks(data []byte) (WorkspaceHooks, error) {
+	var h WorkspaceHooks
+	if len(data) == 0 {
+		return h, nil
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return h, fmt.Errorf("invalid hooks: %w", err)
+	}
+	if err := h.Normalize(); err != nil {
+		return h, err
+	}
+	return h, nil
+}
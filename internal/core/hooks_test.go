@@ -0,0 +1,44 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWorkspaceHooks(t *testing.T) {
+	h, err := ParseWorkspaceHooks([]byte(`{"pre_snapshot": [{"command": "sync"}, {"name": "dump", "command": "pg_dump", "on_failure": "ignore", "timeout_seconds": 5}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pre := h.At(HookPreSnapshot)
+	if len(pre) != 2 || len(h.At(HookPostRestore)) != 0 {
+		t.Fatalf("hooks %+v", h)
+	}
+	if pre[0].Name != "pre_snapshot-1" || pre[0].OnFailure != HookFailTask || pre[0].Timeout() != DefaultHookTimeout {
+		t.Errorf("defaults not filled in: %+v", pre[0])
+	}
+	if pre[1].OnFailure != HookIgnore || pre[1].Timeout().Seconds() != 5 {
+		t.Errorf("explicit settings lost: %+v", pre[1])
+	}
+
+	if h, err := ParseWorkspaceHooks(nil); err != nil || !h.Empty() {
+		t.Errorf("no hooks: %+v, %v", h, err)
+	}
+	if h, err := ParseWorkspaceHooks([]byte(`{}`)); err != nil || !h.Empty() {
+		t.Errorf("empty object: %+v, %v", h, err)
+	}
+
+	for _, bad := range []string{
+		`{"pre_snapshot": [{"command": " "}]}`,
+		`{"pre_snapshot": [{"command": "x", "on_failure": "retry"}]}`,
+		`{"post_restore": [{"command": "x", "timeout_seconds": -1}]}`,
+		`{"post_restore": [{"command": "x", "timeout_seconds": 86400}]}`,
+		`{"post_restore": [{"name": "a", "command": "x"}, {"name": "a", "command": "y"}]}`,
+		`{"pre_snapshot": [` + strings.Repeat(`{"command": "x"},`, MaxHooksPerPoint) + `{"command": "x"}]}`,
+		`{"pre_snapshot": "sync"}`,
+	} {
+		if _, err := ParseWorkspaceHooks([]byte(bad)); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
@@ -11,3 +11,9 @@ func NewID() string {
 	}
 	return id.String()
 }
+
+// ValidID reports whether s is an ID in the canonical form NewID produces.
+func ValidID(s string) bool {
+	_, err := uuid.Parse(s)
+	return err == nil && len(s) == 36
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ImportSourceUpload is the import source of an archive uploaded through the
+// API and staged on the executor under <wsid>/imports/<task_id>.
+const ImportSourceUpload = "upload"
+
+// ValidateImportSource accepts ImportSourceUpload or an absolute s3://,
+// http:// or https:// URL to fetch the archive from. This only checks the
+// form; the executor decides which buckets and hosts it will fetch from.
+func ValidateImportSource(s string) error {
+	if s == ImportSourceUpload {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid source %q (want an s3:// or http(s):// URL)", s)
+	}
+	switch u.Scheme {
+	case "s3":
+		if strings.Trim(u.Path, "/") == "" {
+			return fmt.Errorf("source %q names no object", s)
+		}
+		return nil
+	case "http", "https":
+		return nil
+	}
+	return fmt.Errorf("unsupported source scheme %q (want s3, http or https)", u.Scheme)
+}
+
+// ImportHostAllowed reports whether host, the host name of an http(s)
+// import source, is on allowlist. An entry matches the host exactly or, with
+// a leading dot, any host below it: ".example.com" allows "cdn.example.com"
+// but not "example.com".
+func ImportHostAllowed(host string, allowlist []string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return false
+	}
+	for _, entry := range allowlist {
+		entry = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(entry)), ".")
+		switch {
+		case entry == "" || entry == ".":
+		case strings.HasPrefix(entry, "."):
+			if strings.HasSuffix(host, entry) {
+				return true
+			}
+		case host == entry:
+			return true
+		}
+	}
+	return false
+}
+
+// CleanArchivePath validates the name of an archive entry and returns it
+// cleaned, "." for the archive root. Absolute names and names that climb out
+// with ".." are rejected rather than rewritten.
+func CleanArchivePath(name string) (string, error) {
+	if name == "" || strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("invalid entry name %q", name)
+	}
+	if path.IsAbs(name) || strings.HasPrefix(name, `\`) {
+		return "", fmt.Errorf("entry %q is absolute", name)
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("entry %q contains ..", name)
+		}
+	}
+	return path.Clean(name), nil
+}
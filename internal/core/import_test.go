@@ -0,0 +1,57 @@
+package core
+
+import "testing"
+
+func TestValidateImportSource(t *testing.T) {
+	for _, s := range []string{"upload", "s3://bucket/golden/base.tar.zst", "https://example.com/a.tar"} {
+		if err := ValidateImportSource(s); err != nil {
+			t.Errorf("ValidateImportSource(%q) = %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "s3://bucket/", "file:///etc/passwd", "/tmp/a.tar", "ftp://host/a.tar"} {
+		if err := ValidateImportSource(s); err == nil {
+			t.Errorf("ValidateImportSource(%q): expected error", s)
+		}
+	}
+}
+
+func TestImportHostAllowed(t *testing.T) {
+	allow := []string{"artifacts.example.com", ".cdn.example.net", " Mirror.Example.org. "}
+	for host, want := range map[string]bool{
+		"artifacts.example.com":  true,
+		"ARTIFACTS.example.com.": true,
+		"eu.cdn.example.net":     true,
+		"cdn.example.net":        false,
+		"evilcdn.example.net":    false,
+		"mirror.example.org":     true,
+		"example.com":            false,
+		"169.254.169.254":        false,
+		"":                       false,
+	} {
+		if got := ImportHostAllowed(host, allow); got != want {
+			t.Errorf("ImportHostAllowed(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if ImportHostAllowed("example.com", nil) {
+		t.Error("empty allowlist allowed a host")
+	}
+}
+
+func TestCleanArchivePath(t *testing.T) {
+	for name, want := range map[string]string{
+		"a/b.txt":  "a/b.txt",
+		"./a/b":    "a/b",
+		"dir/":     "dir",
+		"./":       ".",
+		"a//b/./c": "a/b/c",
+	} {
+		if got, err := CleanArchivePath(name); err != nil || got != want {
+			t.Errorf("CleanArchivePath(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"", "/etc/passwd", "../x", "a/../../x", "a/..", `\x`} {
+		if _, err := CleanArchivePath(name); err == nil {
+			t.Errorf("CleanArchivePath(%q): expected error", name)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package core
+
+// VerifyState is the outcome of a snapshot's last integrity check.
+type VerifyState string
+
+const (
+	VerifyOK      VerifyState = "OK"
+	VerifyCorrupt VerifyState = "CORRUPT"
+)
+
+// VerifyStateOf compares a snapshot's recorded tree digest with a freshly
+// computed one. A snapshot taken before digests were recorded has none to
+// compare against; the fresh digest becomes its baseline.
+func VerifyStateOf(recorded, actual string) VerifyState {
+	if recorded == "" || recorded == actual {
+		return VerifyOK
+	}
+	return VerifyCorrupt
+}
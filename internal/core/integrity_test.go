@@ -0,0 +1,19 @@
+package core
+
+import "testing"
+
+func TestVerifyStateOf(t *testing.T) {
+	cases := []struct {
+		recorded, actual string
+		want             VerifyState
+	}{
+		{"sha256:aa", "sha256:aa", VerifyOK},
+		{"", "sha256:aa", VerifyOK},
+		{"sha256:aa", "sha256:bb", VerifyCorrupt},
+	}
+	for _, c := range cases {
+		if got := VerifyStateOf(c.recorded, c.actual); got != c.want {
+			t.Errorf("VerifyStateOf(%q, %q) = %s, want %s", c.recorded, c.actual, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package core
+
+import "fmt"
+
+// QuiescePolicy decides how a workspace is quiesced before the executor
+// snapshots or switches its live directory.
+type QuiescePolicy string
+
+const (
+	// QuiesceRequireGuest fails the task unless a registered guest agent
+	// acknowledges the freeze in time.
+	QuiesceRequireGuest QuiescePolicy = "require-guest"
+	// QuiesceBestEffort freezes a registered guest agent if there is one and
+	// carries on without it otherwise, or if it does not answer in time.
+	QuiesceBestEffort QuiescePolicy = "best-effort"
+	// QuiesceNone never asks the guest; for workspaces with nothing running.
+	QuiesceNone QuiescePolicy = "none"
+)
+
+// DefaultQuiescePolicy applies to workspaces that were not given one.
+const DefaultQuiescePolicy = QuiesceBestEffort
+
+// ParseQuiescePolicy validates a quiesce policy name. The empty string
+// selects DefaultQuiescePolicy.
+func ParseQuiescePolicy(s string) (QuiescePolicy, error) {
+	switch p := QuiescePolicy(s); p {
+	case "":
+		return DefaultQuiescePolicy, nil
+	case QuiesceRequireGuest, QuiesceBestEffort, QuiesceNone:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid quiesce policy %q (want %s, %s or %s)", s, QuiesceRequireGuest, QuiesceBestEffort, QuiesceNone)
+}
@@ -0,0 +1,24 @@
+package core
+
+import "testing"
+
+func TestParseQuiescePolicy(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    QuiescePolicy
+		wantErr bool
+	}{
+		{"", DefaultQuiescePolicy, false},
+		{"require-guest", QuiesceRequireGuest, false},
+		{"best-effort", QuiesceBestEffort, false},
+		{"none", QuiesceNone, false},
+		{"always", "", true},
+		{"NONE", "", true},
+	}
+	for _, c := range cases {
+		got, err := ParseQuiescePolicy(c.in)
+		if (err != nil) != c.wantErr || got != c.want {
+			t.Errorf("ParseQuiescePolicy(%q) = %q, %v", c.in, got, err)
+		}
+	}
+}
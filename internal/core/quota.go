@@ -0,0 +1,46 @@
+package core
+
+import "fmt"
+
+type QuotaKind string
+
+const (
+	QuotaBytes     QuotaKind = "bytes"
+	QuotaSnapshots QuotaKind = "snapshots"
+)
+
+// Quota holds per-workspace storage limits. A nil field means unlimited.
+// Crossing a soft limit only warns; reaching a hard limit rejects new
+// snapshots and current switches with ErrQuotaExceeded.
+type Quota struct {
+	SoftBytes     *int64 `json:"soft_bytes,omitempty"`
+	HardBytes     *int64 `json:"hard_bytes,omitempty"`
+	SoftSnapshots *int32 `json:"soft_snapshots,omitempty"`
+	HardSnapshots *int32 `json:"hard_snapshots,omitempty"`
+}
+
+// Validate checks that limits are non-negative and soft limits do not exceed hard ones.
+func (q Quota) Validate() error {
+	for name, v := range map[string]*int64{"soft_bytes": q.SoftBytes, "hard_bytes": q.HardBytes} {
+		if v != nil && *v < 0 {
+			return fmt.Errorf("%s must be non-negative", name)
+		}
+	}
+	for name, v := range map[string]*int32{"soft_snapshots": q.SoftSnapshots, "hard_snapshots": q.HardSnapshots} {
+		if v != nil && *v < 0 {
+			return fmt.Errorf("%s must be non-negative", name)
+		}
+	}
+	if q.SoftBytes != nil && q.HardBytes != nil && *q.SoftBytes > *q.HardBytes {
+		return fmt.Errorf("soft_bytes exceeds hard_bytes")
+	}
+	if q.SoftSnapshots != nil && q.HardSnapshots != nil && *q.SoftSnapshots > *q.HardSnapshots {
+		return fmt.Errorf("soft_snapshots exceeds hard_snapshots")
+	}
+	return nil
+}
+
+// SoftLimitCrossed reports whether usage moved from below limit to at or above it.
+func SoftLimitCrossed(before, after, limit int64) bool {
+	return before < limit && after >= limit
+}
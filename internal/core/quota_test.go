@@ -0,0 +1,41 @@
+package core
+
+import "testing"
+
+func TestQuotaValidate(t *testing.T) {
+	i64 := func(v int64) *int64 { return &v }
+	i32 := func(v int32) *int32 { return &v }
+
+	tests := []struct {
+		name    string
+		quota   Quota
+		wantErr bool
+	}{
+		{"unlimited", Quota{}, false},
+		{"soft below hard", Quota{SoftBytes: i64(10), HardBytes: i64(20)}, false},
+		{"hard only", Quota{HardSnapshots: i32(5)}, false},
+		{"soft above hard bytes", Quota{SoftBytes: i64(30), HardBytes: i64(20)}, true},
+		{"soft above hard snapshots", Quota{SoftSnapshots: i32(6), HardSnapshots: i32(5)}, true},
+		{"negative", Quota{HardBytes: i64(-1)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.quota.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSoftLimitCrossed(t *testing.T) {
+	if !SoftLimitCrossed(9, 10, 10) {
+		t.Error("expected crossing at the limit")
+	}
+	if SoftLimitCrossed(10, 12, 10) {
+		t.Error("already over the limit must not warn again")
+	}
+	if SoftLimitCrossed(5, 8, 10) {
+		t.Error("below the limit must not warn")
+	}
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ArchiveDeletedPath is the archive entry of a delta archive listing, one per
+// line, the paths of the base snapshot that the new snapshot no longer has.
+// It precedes the manifest.
+const ArchiveDeletedPath = ".wvs/DELETED"
+
+// ReplicaState is how far a snapshot has got towards its workspace's peer.
+type ReplicaState string
+
+const (
+	ReplicaPending    ReplicaState = "PENDING"
+	ReplicaReplicated ReplicaState = "REPLICATED"
+	ReplicaFailed     ReplicaState = "FAILED"
+)
+
+// ReplicaStateOf derives a replica's state from whether it completed and
+// the status of the replicate task shipping it.
+func ReplicaStateOf(replicated bool, task TaskStatus) ReplicaState {
+	switch {
+	case replicated:
+		return ReplicaReplicated
+	case task == TaskDead || task == TaskCanceled:
+		return ReplicaFailed
+	}
+	return ReplicaPending
+}
+
+// wsidPattern matches the workspace IDs the catalog accepts.
+var wsidPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
+
+// ValidateReplicationTarget checks a peer URL and workspace ID and returns
+// the URL normalized.
+func ValidateReplicationTarget(peerURL, peerWSID string) (string, error) {
+	if !wsidPattern.MatchString(peerWSID) {
+		return "", fmt.Errorf("invalid peer_wsid %q", peerWSID)
+	}
+	return NormalizePeerURL(peerURL)
+}
+
+// NormalizePeerURL validates the base URL of a peer WVS API and returns it
+// without a trailing slash.
+func NormalizePeerURL(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid peer_url %q (want an http(s):// URL)", s)
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", fmt.Errorf("peer_url %q must not carry a query, fragment or credentials", s)
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
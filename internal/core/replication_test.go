@@ -0,0 +1,56 @@
+package core
+
+import "testing"
+
+func TestReplicaStateOf(t *testing.T) {
+	cases := []struct {
+		replicated bool
+		task       TaskStatus
+		want       ReplicaState
+	}{
+		{true, TaskSucceeded, ReplicaReplicated},
+		{false, TaskPending, ReplicaPending},
+		{false, TaskFailed, ReplicaPending}, // still retrying
+		{false, TaskDead, ReplicaFailed},
+		{false, TaskCanceled, ReplicaFailed},
+	}
+	for _, c := range cases {
+		if got := ReplicaStateOf(c.replicated, c.task); got != c.want {
+			t.Errorf("ReplicaStateOf(%v, %s) = %s, want %s", c.replicated, c.task, got, c.want)
+		}
+	}
+}
+
+func TestNormalizePeerURL(t *testing.T) {
+	got, err := NormalizePeerURL("https://wvs-dr.example.com:8080/")
+	if err != nil || got != "https://wvs-dr.example.com:8080" {
+		t.Errorf("NormalizePeerURL = %q, %v", got, err)
+	}
+	for _, bad := range []string{"", "wvs-dr:8080", "ftp://host", "https://host/?token=x", "https://user:pw@host"} {
+		if _, err := NormalizePeerURL(bad); err == nil {
+			t.Errorf("NormalizePeerURL(%q) accepted", bad)
+		}
+	}
+}
+
+func TestValidateReplicationTarget(t *testing.T) {
+	if _, err := ValidateReplicationTarget("http://wvs-dr:8080", "ws-dr-1"); err != nil {
+		t.Errorf("valid target rejected: %v", err)
+	}
+	for _, bad := range []string{"", "Ws", "../x", "-ws"} {
+		if _, err := ValidateReplicationTarget("http://wvs-dr:8080", bad); err == nil {
+			t.Errorf("peer_wsid %q accepted", bad)
+		}
+	}
+}
+
+func TestValidID(t *testing.T) {
+	if !ValidID(NewID()) {
+		t.Error("NewID rejected")
+	}
+	for _, bad := range []string{"", "abc", "../etc", "{" + NewID() + "}"} {
+		if ValidID(bad) {
+			t.Errorf("ValidID(%q) accepted", bad)
+		}
+	}
+}
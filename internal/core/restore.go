@@ -0,0 +1,61 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// MaxRestorePaths caps the paths a single restore_paths task may copy.
+const MaxRestorePaths = 1000
+
+// CleanRestorePaths validates paths relative to the workspace tree and
+// returns them cleaned and de-duplicated. Absolute paths, paths that
+// climb out with "..", and the tree root itself are rejected.
+func CleanRestorePaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("at least one path required")
+	}
+	if len(paths) > MaxRestorePaths {
+		return nil, fmt.Errorf("at most %d paths per restore", MaxRestorePaths)
+	}
+	seen := make(map[string]bool, len(paths))
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if p == "" || strings.ContainsRune(p, 0) {
+			return nil, fmt.Errorf("invalid path %q", p)
+		}
+		if path.IsAbs(p) {
+			return nil, fmt.Errorf("path %q must be relative", p)
+		}
+		c := path.Clean(p)
+		if c == "." {
+			return nil, fmt.Errorf("path %q names the whole tree; use set_current", p)
+		}
+		if c == ".." || strings.HasPrefix(c, "../") {
+			return nil, fmt.Errorf("path %q escapes the workspace", p)
+		}
+		if !seen[c] {
+			seen[c] = true
+			cleaned = append(cleaned, c)
+		}
+	}
+	return cleaned, nil
+}
+
+// EncodeRestorePaths packs paths into the single string task param the
+// executor protocol carries.
+func EncodeRestorePaths(paths []string) string {
+	b, _ := json.Marshal(paths)
+	return string(b)
+}
+
+// DecodeRestorePaths unpacks and re-validates a paths task param.
+func DecodeRestorePaths(s string) ([]string, error) {
+	var paths []string
+	if err := json.Unmarshal([]byte(s), &paths); err != nil {
+		return nil, fmt.Errorf("paths: %w", err)
+	}
+	return CleanRestorePaths(paths)
+}
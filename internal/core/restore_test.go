@@ -0,0 +1,47 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCleanRestorePaths(t *testing.T) {
+	got, err := CleanRestorePaths([]string{"src/main.go", "./docs/", "src//main.go", "a/b/../c"})
+	if err != nil {
+		t.Fatalf("CleanRestorePaths() error = %v", err)
+	}
+	want := []string{"src/main.go", "docs", "a/c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CleanRestorePaths() = %v, want %v", got, want)
+	}
+
+	for _, bad := range [][]string{
+		nil,
+		{""},
+		{"/etc/passwd"},
+		{"."},
+		{"a/.."},
+		{".."},
+		{"../other-ws/file"},
+		{"a/../../b"},
+		{"a\x00b"},
+	} {
+		if _, err := CleanRestorePaths(bad); err == nil {
+			t.Errorf("CleanRestorePaths(%q) expected error", bad)
+		}
+	}
+}
+
+func TestRestorePathsRoundTrip(t *testing.T) {
+	paths := []string{"a.txt", "dir/b"}
+	got, err := DecodeRestorePaths(EncodeRestorePaths(paths))
+	if err != nil || !reflect.DeepEqual(got, paths) {
+		t.Errorf("round trip = %v, %v", got, err)
+	}
+	if _, err := DecodeRestorePaths(`["../x"]`); err == nil {
+		t.Error("DecodeRestorePaths should re-validate")
+	}
+	if _, err := DecodeRestorePaths("not json"); err == nil {
+		t.Error("DecodeRestorePaths should reject bad JSON")
+	}
+}
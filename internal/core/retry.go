@@ -0,0 +1,123 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how often and how quickly a failed task is retried.
+// The delay before attempt n+1 is BaseDelay * Multiplier^(n-1), capped at
+// MaxDelay and then spread by ±Jitter (a fraction of the delay).
+type RetryPolicy struct {
+	MaxAttempts int32
+	BaseDelay   time.Duration
+	Multiplier  float64
+	MaxDelay    time.Duration
+	Jitter      float64
+}
+
+// DefaultRetryPolicy is the backoff every op used before policies were configurable.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   5 * time.Second,
+	Multiplier:  2,
+	MaxDelay:    300 * time.Second,
+}
+
+// Decode parses a policy spec such as
+// "max_attempts=8,base_delay=10s,multiplier=3,max_delay=10m,jitter=0.2".
+// Keys left out stay zero and are filled in by WithDefaults.
+func (p *RetryPolicy) Decode(value string) error {
+	*p = RetryPolicy{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(field, "=")
+		if !ok {
+			return fmt.Errorf("retry policy: %q is not key=value", field)
+		}
+		var err error
+		switch strings.TrimSpace(key) {
+		case "max_attempts":
+			var n int64
+			n, err = strconv.ParseInt(val, 10, 32)
+			p.MaxAttempts = int32(n)
+		case "base_delay":
+			p.BaseDelay, err = time.ParseDuration(val)
+		case "multiplier":
+			p.Multiplier, err = strconv.ParseFloat(val, 64)
+		case "max_delay":
+			p.MaxDelay, err = time.ParseDuration(val)
+		case "jitter":
+			p.Jitter, err = strconv.ParseFloat(val, 64)
+		default:
+			return fmt.Errorf("retry policy: unknown key %q", key)
+		}
+		if err != nil {
+			return fmt.Errorf("retry policy: %s: %w", key, err)
+		}
+	}
+	return p.Validate()
+}
+
+// WithDefaults fills every unset field of p from def.
+func (p RetryPolicy) WithDefaults(def RetryPolicy) RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.BaseDelay == 0 {
+		p.BaseDelay = def.BaseDelay
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = def.Multiplier
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+	if p.Jitter == 0 {
+		p.Jitter = def.Jitter
+	}
+	return p
+}
+
+// Validate rejects policies that could never retry sensibly.
+func (p RetryPolicy) Validate() error {
+	switch {
+	case p.MaxAttempts < 0:
+		return fmt.Errorf("retry policy: max_attempts must not be negative")
+	case p.BaseDelay < 0 || p.MaxDelay < 0:
+		return fmt.Errorf("retry policy: delays must not be negative")
+	case p.Multiplier != 0 && p.Multiplier < 1:
+		return fmt.Errorf("retry policy: multiplier must be at least 1")
+	case p.Jitter < 0 || p.Jitter > 1:
+		return fmt.Errorf("retry policy: jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// Delay returns the backoff after the given (1-based) failed attempt. r is a
+// uniform random number in [0, 1) that places the delay within the jitter band.
+func (p RetryPolicy) Delay(attempt int32, r float64) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := float64(p.BaseDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	d *= 1 + p.Jitter*(2*r-1)
+	return time.Duration(d)
+}
+
+// RetryPolicies maps each op to its retry policy.
+type RetryPolicies map[TaskOp]RetryPolicy
+
+// For returns the policy for op, falling back to DefaultRetryPolicy.
+func (rp RetryPolicies) For(op TaskOp) RetryPolicy {
+	return rp[op].WithDefaults(DefaultRetryPolicy)
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDecode(t *testing.T) {
+	var p RetryPolicy
+	if err := p.Decode("max_attempts=8, base_delay=10s,multiplier=3,max_delay=10m,jitter=0.2"); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	want := RetryPolicy{MaxAttempts: 8, BaseDelay: 10 * time.Second, Multiplier: 3, MaxDelay: 10 * time.Minute, Jitter: 0.2}
+	if p != want {
+		t.Fatalf("Decode() = %+v, want %+v", p, want)
+	}
+
+	for _, bad := range []string{"max_attempts", "retries=3", "base_delay=soon", "jitter=2", "multiplier=0.5"} {
+		if err := p.Decode(bad); err == nil {
+			t.Errorf("Decode(%q) expected error", bad)
+		}
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := DefaultRetryPolicy
+	tests := []struct {
+		attempt int32
+		want    time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{4, 40 * time.Second},
+		{10, 300 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.Delay(tt.attempt, 0.5); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+
+	p.Jitter = 0.5
+	if got := p.Delay(1, 0); got != 2500*time.Millisecond {
+		t.Errorf("low jitter edge = %v", got)
+	}
+	if got := p.Delay(1, 0.999); got < 7*time.Second {
+		t.Errorf("high jitter edge = %v", got)
+	}
+}
+
+func TestRetryPoliciesFor(t *testing.T) {
+	rp := RetryPolicies{OpInitWorkspace: {MaxAttempts: 10}}
+	p := rp.For(OpInitWorkspace)
+	if p.MaxAttempts != 10 || p.BaseDelay != DefaultRetryPolicy.BaseDelay {
+		t.Errorf("For(init_workspace) = %+v", p)
+	}
+	if rp.For(OpSetCurrent) != DefaultRetryPolicy {
+		t.Errorf("unconfigured op should use the default policy")
+	}
+}
@@ -2,11 +2,24 @@ package core
 
 import "time"
 
+type SnapshotState string
+
+const (
+	SnapshotPending  SnapshotState = "PENDING"
+	SnapshotCreating SnapshotState = "CREATING"
+	SnapshotReady    SnapshotState = "READY"
+	SnapshotFailed   SnapshotState = "FAILED"
+)
+
 type Snapshot struct {
-	SnapshotID string     `json:"snapshot_id"`
-	WSID       string     `json:"wsid"`
-	FSPath     string     `json:"fs_path"`
-	Message    *string    `json:"message"`
-	CreatedAt  time.Time  `json:"created_at"`
-	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	SnapshotID string        `json:"snapshot_id"`
+	WSID       string        `json:"wsid"`
+	FSPath     string        `json:"fs_path"`
+	Message    *string       `json:"message"`
+	State      SnapshotState `json:"state"`
+	TaskID     *string       `json:"task_id,omitempty"`
+	SizeBytes  *int64        `json:"size_bytes,omitempty"`
+	FileCount  *int64        `json:"file_count,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	DeletedAt  *time.Time    `json:"deleted_at,omitempty"`
 }
@@ -2,6 +2,7 @@ package core
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -12,8 +13,41 @@ const (
 	OpSnapshotCreate TaskOp = "snapshot_create"
 	OpSnapshotDrop   TaskOp = "snapshot_drop"
 	OpSetCurrent     TaskOp = "set_current"
+
+	// OpCheckpointRestore snapshots current and then switches current to an
+	// older snapshot, under a single quiesce.
+	OpCheckpointRestore TaskOp = "checkpoint_restore"
+
+	// OpRestorePaths copies selected files or subtrees from a snapshot into
+	// the live tree, leaving everything else in place.
+	OpRestorePaths TaskOp = "restore_paths"
+
+	// OpExport writes a snapshot out as a tar archive.
+	OpExport TaskOp = "export"
+
+	// OpImport unpacks a tar archive into a new snapshot.
+	OpImport TaskOp = "import"
+
+	// OpReplicate ships a snapshot to the workspace's peer deployment.
+	OpReplicate TaskOp = "replicate"
+
+	// OpVerify recomputes a snapshot's tree digest and compares it with the
+	// one recorded when the snapshot was taken.
+	OpVerify TaskOp = "verify"
 )
 
+// ReservedSnapshotID returns the snapshot an op reserved at enqueue time and
+// will create, or "" if the op creates none.
+func ReservedSnapshotID(op TaskOp, params map[string]string) string {
+	switch op {
+	case OpSnapshotCreate, OpImport:
+		return params["snapshot_id"]
+	case OpCheckpointRestore:
+		return params["checkpoint_id"]
+	}
+	return ""
+}
+
 type TaskStatus string
 
 const (
@@ -25,6 +59,61 @@ const (
 	TaskDead      TaskStatus = "DEAD"
 )
 
+// TaskPriority orders the queue: higher priorities are dequeued first, and
+// workspaces take turns within a priority.
+type TaskPriority int32
+
+const (
+	PriorityBackground  TaskPriority = 0
+	PriorityNormal      TaskPriority = 50
+	PriorityInteractive TaskPriority = 100
+)
+
+var priorityNames = map[TaskPriority]string{
+	PriorityBackground:  "background",
+	PriorityNormal:      "normal",
+	PriorityInteractive: "interactive",
+}
+
+// ParsePriority accepts a priority name.
+func ParsePriority(s string) (TaskPriority, error) {
+	for p, name := range priorityNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid priority %q (want interactive, normal or background)", s)
+}
+
+// String returns the priority's name, or its number if it has none.
+func (p TaskPriority) String() string {
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", int32(p))
+}
+
+// DefaultPriority is the priority of a request that does not ask for one.
+// Switching current or restoring files is what a user sits waiting on, so
+// it jumps the queue; replication waits for everything else.
+func DefaultPriority(op TaskOp) TaskPriority {
+	switch op {
+	case OpSetCurrent, OpCheckpointRestore, OpRestorePaths:
+		return PriorityInteractive
+	case OpReplicate:
+		return PriorityBackground
+	}
+	return PriorityNormal
+}
+
+// DLQResolution records how a DEAD task left the dead-letter queue.
+type DLQResolution string
+
+const (
+	DLQRetried   DLQResolution = "RETRIED"
+	DLQDiscarded DLQResolution = "DISCARDED"
+)
+
 type Task struct {
 	TaskID          string          `json:"task_id"`
 	WSID            string          `json:"wsid"`
@@ -0,0 +1,35 @@
+package core
+
+import "testing"
+
+func TestParsePriority(t *testing.T) {
+	for _, p := range []TaskPriority{PriorityBackground, PriorityNormal, PriorityInteractive} {
+		got, err := ParsePriority(p.String())
+		if err != nil || got != p {
+			t.Errorf("ParsePriority(%q) = %v, %v", p.String(), got, err)
+		}
+	}
+	if _, err := ParsePriority("urgent"); err == nil {
+		t.Error("expected error for unknown priority")
+	}
+	if DefaultPriority(OpSetCurrent) != PriorityInteractive || DefaultPriority(OpRestorePaths) != PriorityInteractive ||
+		DefaultPriority(OpSnapshotCreate) != PriorityNormal || DefaultPriority(OpReplicate) != PriorityBackground {
+		t.Error("unexpected default priorities")
+	}
+}
+
+func TestReservedSnapshotID(t *testing.T) {
+	params := map[string]string{"snapshot_id": "target", "checkpoint_id": "safety"}
+	if got := ReservedSnapshotID(OpSnapshotCreate, params); got != "target" {
+		t.Errorf("snapshot_create reserved %q", got)
+	}
+	if got := ReservedSnapshotID(OpImport, params); got != "target" {
+		t.Errorf("import reserved %q", got)
+	}
+	if got := ReservedSnapshotID(OpCheckpointRestore, params); got != "safety" {
+		t.Errorf("checkpoint_restore reserved %q", got)
+	}
+	if got := ReservedSnapshotID(OpSetCurrent, params); got != "" {
+		t.Errorf("set_current reserved %q", got)
+	}
+}
@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "control.json")
+	for _, data := range []string{`{"seq":1}`, `{"seq":2}`} {
+		if err := writeFileAtomic(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(p); string(got) != data {
+			t.Errorf("read %q, want %q", got, data)
+		}
+	}
+	if fi, _ := os.Stat(p); fi.Mode().Perm() != 0644 {
+		t.Errorf("mode %v", fi.Mode())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestSnapshotCreateRedoesTornMetadata(t *testing.T) {
+	ctx := context.Background()
+	s := newLocalServer(t)
+	wsRoot := filepath.Join(s.cfg.MountPath, replTestWSID)
+	live := filepath.Join(wsRoot, "live", "l1")
+	writeTree(t, live, map[string]string{"a.txt": "a", "dir/b.txt": "b"})
+	if err := os.Symlink(filepath.Join("live", "l1"), filepath.Join(wsRoot, "current")); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := core.NewID()
+	root := s.snapshotPath(snap)
+	for name, meta := range map[string]string{
+		"truncated":      `{"snapshot_id": "` + snap + `", "wsid": "`,
+		"other snapshot": `{"snapshot_id": "` + core.NewID() + `", "wsid": "ws", "created_at": "2026-01-01T00:00:00Z"}`,
+		"no created_at":  `{"snapshot_id": "` + snap + `", "wsid": "ws"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			// What a crashed attempt left: part of the tree and broken metadata.
+			_ = os.RemoveAll(root)
+			writeTree(t, root, map[string]string{"a.txt": "a", ".wvs/snapshot.json": meta})
+			if _, ok := existingSnapshot(root); ok {
+				t.Fatal("broken metadata accepted")
+			}
+
+			res, err := s.snapshotCreate(ctx, replTestWSID, map[string]string{"snapshot_id": snap, "task_id": core.NewID()}, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res["file_count"] != "2" || res["tree_digest"] == "" {
+				t.Errorf("results %v", res)
+			}
+			if _, ok := existingSnapshot(root); !ok {
+				t.Error("recaptured snapshot not recognized")
+			}
+			if _, err := os.Stat(filepath.Join(root, "dir", "b.txt")); err != nil {
+				t.Errorf("snapshot incomplete: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/lzjever/mbos-wvs/gen/go/executor/v1"
+)
+
+// readChunkSize is the payload size of each FileChunk.
+const readChunkSize = 64 << 10
+
+// StatPath describes a single path inside a snapshot.
+func (s *Server) StatPath(ctx context.Context, req *pb.BrowseRequest) (*pb.TreeEntry, error) {
+	root, rel, err := s.snapshotRoot(req.Wsid, req.SnapshotId, req.Path)
+	if err != nil {
+		return nil, err
+	}
+	full, err := resolveInSnapshot(root, rel)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return nil, browseError(err)
+	}
+	return treeEntry(full, rel, info), nil
+}
+
+// ListTree streams the entries of a directory inside a snapshot, one level
+// deep. Listing a file streams just that file.
+func (s *Server) ListTree(req *pb.BrowseRequest, stream pb.ExecutorService_ListTreeServer) error {
+	root, rel, err := s.snapshotRoot(req.Wsid, req.SnapshotId, req.Path)
+	if err != nil {
+		return err
+	}
+	full, err := resolveInSnapshot(root, rel)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return browseError(err)
+	}
+	if !info.IsDir() {
+		return stream.Send(treeEntry(full, rel, info))
+	}
+
+	entries, err := os.ReadDir(full)
+	if err != nil {
+		return browseError(err)
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			// Vanished between ReadDir and Lstat; snapshots should not change, but skip it.
+			continue
+		}
+		if err := stream.Send(treeEntry(filepath.Join(full, e.Name()), path.Join(rel, e.Name()), info)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadFile streams a byte range of a regular file inside a snapshot.
+func (s *Server) ReadFile(req *pb.ReadFileRequest, stream pb.ExecutorService_ReadFileServer) error {
+	root, rel, err := s.snapshotRoot(req.Wsid, req.SnapshotId, req.Path)
+	if err != nil {
+		return err
+	}
+	full, err := resolveInSnapshot(root, rel)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		return browseError(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return browseError(err)
+	}
+	if !info.Mode().IsRegular() {
+		return status.Errorf(codes.FailedPrecondition, "%s is not a regular file", rel)
+	}
+	if req.Offset < 0 || req.Length < 0 || req.Offset > info.Size() {
+		return status.Errorf(codes.OutOfRange, "range %d+%d outside file of %d bytes", req.Offset, req.Length, info.Size())
+	}
+	length := info.Size() - req.Offset
+	if req.Length > 0 && req.Length < length {
+		length = req.Length
+	}
+
+	r := io.NewSectionReader(f, req.Offset, length)
+	buf := make([]byte, readChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if sendErr := stream.Send(&pb.FileChunk{Data: buf[:n]}); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			s.log.Warn("read snapshot file failed", zap.String("path", full), zap.Error(err))
+			return browseError(err)
+		}
+	}
+}
+
+// snapshotRoot validates a browse request and returns the snapshot's
+// resolved root and the cleaned relative path ("." for the root).
+func (s *Server) snapshotRoot(wsid, snapshotID, rel string) (string, string, error) {
+	if !isPathElement(wsid) || !isPathElement(snapshotID) {
+		return "", "", status.Error(codes.InvalidArgument, "invalid wsid or snapshot_id")
+	}
+	rel = path.Clean("/" + rel)[1:]
+	if rel == "" {
+		rel = "."
+	}
+	root, err := filepath.EvalSymlinks(filepath.Join(s.cfg.MountPath, wsid, "snapshots", snapshotID))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", "", status.Errorf(codes.NotFound, "snapshot %s not found", snapshotID)
+		}
+		return "", "", browseError(err)
+	}
+	return root, rel, nil
+}
+
+// resolveInSnapshot follows symlinks in rel and refuses results outside root.
+func resolveInSnapshot(root, rel string) (string, error) {
+	full, err := filepath.EvalSymlinks(filepath.Join(root, rel))
+	if err != nil {
+		return "", browseError(err)
+	}
+	if !isWithin(root, full) {
+		return "", status.Errorf(codes.PermissionDenied, "%s resolves outside the snapshot", rel)
+	}
+	return full, nil
+}
+
+func treeEntry(full, rel string, info fs.FileInfo) *pb.TreeEntry {
+	e := &pb.TreeEntry{
+		Name:        info.Name(),
+		Path:        rel,
+		Size:        info.Size(),
+		Mode:        uint32(info.Mode().Perm()),
+		ModTimeUnix: info.ModTime().Unix(),
+	}
+	switch {
+	case info.Mode().IsRegular():
+		e.Type = "file"
+	case info.IsDir():
+		e.Type = "dir"
+		e.Size = 0
+	case info.Mode()&fs.ModeSymlink != 0:
+		e.Type = "symlink"
+		e.LinkTarget, _ = os.Readlink(full)
+	default:
+		e.Type = "other"
+	}
+	if rel == "." {
+		e.Name = ""
+	}
+	return e
+}
+
+// browseError maps a filesystem error onto a gRPC status.
+func browseError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return status.Error(codes.NotFound, "path not found")
+	case errors.Is(err, fs.ErrPermission):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, syscall.ENOTDIR):
+		return status.Error(codes.NotFound, "path not found")
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
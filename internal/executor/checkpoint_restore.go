@@ -0,0 +1,128 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/observability"
+)
+
+// checkpointRestore captures current as the checkpoint snapshot and then
+// switches current to snapshot_id, holding a single quiesce across both so
+// no guest write can land between the checkpoint and the switch.
+func (s *Server) checkpointRestore(ctx context.Context, wsid string, params map[string]string, log *zap.Logger) (map[string]string, error) {
+	snapshotID := params["snapshot_id"]
+	checkpointID := params["checkpoint_id"]
+	newLiveID := params["new_live_id"]
+	wsRoot := filepath.Join(s.cfg.MountPath, wsid)
+
+	targetPath := filepath.Join(wsRoot, "snapshots", snapshotID)
+	checkpointPath := filepath.Join(wsRoot, "snapshots", checkpointID)
+	relTarget := filepath.Join("live", newLiveID)
+
+	// Idempotency: a previous attempt may have finished the checkpoint, or
+	// both steps. The checkpoint is cloned before the switch, so once
+	// current points at the new live directory it is at least captured.
+	checkpoint, captured := existingSnapshot(checkpointPath)
+	if captured {
+		if err := s.lockSnapshot(ctx, checkpointPath, log); err != nil {
+			return nil, fmt.Errorf("checkpoint: %w", err)
+		}
+	}
+	currentLink := filepath.Join(wsRoot, "current")
+	target, err := os.Readlink(currentLink)
+	switched := err == nil && target == relTarget
+	if switched && captured {
+		log.Info("checkpoint_restore: already restored, noop")
+		return checkpointResults(checkpointID, checkpoint, filepath.Join(wsRoot, relTarget)), nil
+	}
+	if switched && capturedSnapshot(checkpointPath) {
+		log.Info("checkpoint_restore: already switched, finishing the checkpoint")
+		checkpoint, err = s.finishSnapshot(ctx, wsid, checkpointID, params, log)
+		if err != nil {
+			return nil, fmt.Errorf("checkpoint: %w", err)
+		}
+		currentPath := filepath.Join(wsRoot, relTarget)
+		results := checkpointResults(checkpointID, checkpoint, currentPath)
+		return s.runPostRestoreHooks(ctx, currentPath, hookEnv{wsid, snapshotID, "checkpoint_restore"}, params, results, nil, log), nil
+	}
+
+	// Verify target snapshot exists
+	if _, err := os.Stat(targetPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, targetPath)
+	}
+
+	srcPath, err := filepath.EvalSymlinks(currentLink)
+	if err != nil {
+		return nil, fmt.Errorf("resolve current symlink: %w", err)
+	}
+
+	// Both clones must fit before the guest is paused.
+	preflight := []string{targetPath}
+	if !captured {
+		preflight = append(preflight, srcPath)
+	}
+	if err := s.preflightClone(log, preflight...); err != nil {
+		return nil, err
+	}
+
+	var hooks []HookResult
+	if !captured {
+		hooks, err = s.runHooks(ctx, core.HookPreSnapshot, srcPath, hookEnv{wsid, checkpointID, "checkpoint_restore"}, params, log)
+		if err != nil {
+			return nil, fmt.Errorf("checkpoint: %w", err)
+		}
+	}
+
+	// Quiesce once for both clones and the switch
+	start := time.Now()
+	if err := Quiesce(ctx, wsRoot, params["task_id"], s.quiesceOptions(params), log); err != nil {
+		observability.QuiesceTimeoutTotal.Inc()
+		return nil, fmt.Errorf("quiesce failed: %w", err)
+	}
+	observability.QuiesceWaitSeconds.Observe(time.Since(start).Seconds())
+	defer func() { _ = Resume(wsRoot, params["task_id"]) }()
+
+	if !captured {
+		if err := s.captureSnapshot(ctx, wsid, srcPath, checkpointID, params["message"], params, log); err != nil {
+			return nil, fmt.Errorf("checkpoint: %w", err)
+		}
+	}
+
+	currentPath, err := s.switchToSnapshot(ctx, wsRoot, targetPath, newLiveID, log)
+	if err != nil {
+		return nil, fmt.Errorf("restore: %w", err)
+	}
+
+	// The checkpoint is measured and digested with the guest running again.
+	_ = Resume(wsRoot, params["task_id"])
+	if !captured {
+		checkpoint, err = s.finishSnapshot(ctx, wsid, checkpointID, params, log)
+		if err != nil {
+			return nil, fmt.Errorf("checkpoint: %w", err)
+		}
+	}
+	results := checkpointResults(checkpointID, checkpoint, currentPath)
+	return s.runPostRestoreHooks(ctx, currentPath, hookEnv{wsid, snapshotID, "checkpoint_restore"}, params, results, hooks, log), nil
+}
+
+// checkpointResults reports the checkpoint's snapshot results under a
+// "checkpoint_" prefix alongside the new current path.
+func checkpointResults(checkpointID string, checkpoint map[string]string, currentPath string) map[string]string {
+	results := map[string]string{
+		"checkpoint_id": checkpointID,
+		"current_path":  currentPath,
+	}
+	for k, v := range checkpoint {
+		if k != "snapshot_id" {
+			results["checkpoint_"+k] = v
+		}
+	}
+	return results
+}
@@ -3,7 +3,12 @@ package executor
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -22,10 +27,126 @@ func Clone(ctx context.Context, src, dst, op string, log *zap.Logger) error {
 	observability.CloneDuration.WithLabelValues(op).Observe(duration)
 
 	if err != nil {
+		kind := ErrCloneFailed
+		if strings.Contains(strings.ToLower(string(output)), "no space left") {
+			kind = ErrInsufficientSpace
+		}
 		observability.CloneFailTotal.WithLabelValues("exec_error").Inc()
-		return fmt.Errorf("juicefs clone failed: %w, output: %s", err, string(output))
+		return fmt.Errorf("%w: juicefs clone: %v, output: %s", kind, err, string(output))
 	}
 
 	log.Info("clone: completed", zap.Float64("duration_s", duration))
 	return nil
 }
+
+// Clone modes; see Config.CloneMode.
+const (
+	CloneModeJuiceFS = "juicefs"
+	CloneModeCopy    = "copy"
+)
+
+// clone copies src to dst using the configured clone mode.
+func (s *Server) clone(ctx context.Context, src, dst, op string, log *zap.Logger) error {
+	if s.cfg.CloneMode == CloneModeCopy {
+		return CopyTree(ctx, src, dst, op, log)
+	}
+	return Clone(ctx, src, dst, op, log)
+}
+
+// CopyTree copies src to dst file by file, keeping modes, mtimes and
+// symlinks. Unlike Clone it shares nothing, so it needs the full size free.
+func CopyTree(ctx context.Context, src, dst, op string, log *zap.Logger) error {
+	return copyTree(ctx, src, dst, op, nil, log)
+}
+
+// copyTree is CopyTree leaving out the entries skip is true for, given
+// their slash-separated path relative to src. A skipped directory is left
+// out whole.
+func copyTree(ctx context.Context, src, dst, op string, skip func(rel string, isDir bool) bool, log *zap.Logger) error {
+	start := time.Now()
+	log.Info("copy: starting", zap.String("src", src), zap.String("dst", dst))
+
+	type dirAttrs struct {
+		path    string
+		mode    fs.FileMode
+		modTime time.Time
+	}
+	var dirs []dirAttrs
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if skip != nil && rel != "." && skip(filepath.ToSlash(rel), info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case info.IsDir():
+			if err := os.Mkdir(target, info.Mode().Perm()|0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirAttrs{target, info.Mode().Perm(), info.ModTime()})
+			return nil
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(p, target, info)
+		default:
+			return nil // sockets, devices and FIFOs are not copied
+		}
+	})
+	observability.CloneDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		observability.CloneFailTotal.WithLabelValues("copy_error").Inc()
+		return fmt.Errorf("%w: copy: %v", ErrCloneFailed, err)
+	}
+	// Directory modes and mtimes last, after their contents stopped changing
+	// them: a read-only source directory must stay writable until it is filled.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return fmt.Errorf("%w: copy: %v", ErrCloneFailed, err)
+		}
+		_ = os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime)
+	}
+
+	log.Info("copy: completed", zap.Float64("duration_s", time.Since(start).Seconds()))
+	return nil
+}
+
+func copyFile(src, dst string, info fs.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -14,4 +14,42 @@ type Config struct {
 	MinioAccessKey  string        `envconfig:"MINIO_ACCESS_KEY" required:"true"`
 	MinioSecretKey  string        `envconfig:"MINIO_SECRET_KEY" required:"true"`
 	MinioBucket     string        `envconfig:"MINIO_BUCKET" default:"jfs-data"`
+
+	// CloneMode is "juicefs" (copy-on-write clones) or "copy" (plain copies, for development and tests).
+	CloneMode string `envconfig:"EXECUTOR_CLONE_MODE" default:"juicefs"`
+
+	// QuiesceHeartbeatTTL is how old a guest agent's heartbeat may get before it counts as dead.
+	QuiesceHeartbeatTTL time.Duration `envconfig:"EXECUTOR_QUIESCE_HEARTBEAT_TTL" default:"30s"`
+	// QuiescePolicy applies to tasks that do not carry their workspace's own.
+	QuiescePolicy string `envconfig:"EXECUTOR_QUIESCE_POLICY" default:"best-effort"`
+
+	// HooksEnabled runs workspace hooks, shell commands on this host; a hook fails without it.
+	HooksEnabled bool `envconfig:"EXECUTOR_HOOKS_ENABLED" default:"false"`
+	// HookOutputLimit caps the output kept per hook, in bytes.
+	HookOutputLimit int `envconfig:"EXECUTOR_HOOK_OUTPUT_LIMIT" default:"16384"`
+
+	// SnapshotLock is "none", "readonly" or "immutable", which falls back to readonly where unsupported.
+	SnapshotLock string `envconfig:"EXECUTOR_SNAPSHOT_LOCK" default:"none"`
+
+	// PreflightMinFreeBytes and PreflightMinFreeInodes must remain free under MountPath after a clone.
+	PreflightMinFreeBytes  uint64 `envconfig:"EXECUTOR_PREFLIGHT_MIN_FREE_BYTES" default:"1073741824"`
+	PreflightMinFreeInodes uint64 `envconfig:"EXECUTOR_PREFLIGHT_MIN_FREE_INODES" default:"100000"`
+	// PreflightCloneByteRatio is the fraction of the source's bytes a clone is expected to write.
+	PreflightCloneByteRatio float64 `envconfig:"EXECUTOR_PREFLIGHT_CLONE_BYTE_RATIO" default:"0"`
+
+	// Exports go to this S3-compatible bucket if ExportS3Bucket is set, else under <MountPath>/<wsid>/exports.
+	ExportS3Endpoint  string `envconfig:"EXECUTOR_EXPORT_S3_ENDPOINT"`
+	ExportS3Region    string `envconfig:"EXECUTOR_EXPORT_S3_REGION" default:"us-east-1"`
+	ExportS3Bucket    string `envconfig:"EXECUTOR_EXPORT_S3_BUCKET"`
+	ExportS3Prefix    string `envconfig:"EXECUTOR_EXPORT_S3_PREFIX" default:"exports"`
+	ExportS3AccessKey string `envconfig:"EXECUTOR_EXPORT_S3_ACCESS_KEY"`
+	ExportS3SecretKey string `envconfig:"EXECUTOR_EXPORT_S3_SECRET_KEY"`
+
+	// ImportURLAllowlist lists the hosts http(s) import sources may name; empty allows none.
+	ImportURLAllowlist []string `envconfig:"EXECUTOR_IMPORT_URL_ALLOWLIST"`
+	// ImportMaxBytes caps what one import may stage or unpack; 0 leaves only the free space.
+	ImportMaxBytes int64 `envconfig:"EXECUTOR_IMPORT_MAX_BYTES" default:"0"`
+
+	// ReplicationPeerAllowlist lists the peer API hosts replication may reach; empty allows none.
+	ReplicationPeerAllowlist []string `envconfig:"EXECUTOR_REPLICATION_PEER_ALLOWLIST"`
 }
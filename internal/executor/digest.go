@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// TreeDigest computes a Merkle-style digest of the tree under root, leaving
+// out WVS bookkeeping in the top-level .wvs directory. Each directory hashes
+// the sorted list of its entries' kind, permission bits, name and digest; a
+// regular file's digest is that of its contents and a symlink's that of its
+// target. Modification times are left out: clones need not keep them, and
+// so are the write bits a snapshot lock took away.
+func TreeDigest(ctx context.Context, root string) (string, error) {
+	lock, err := readLockRecord(root)
+	if err != nil {
+		return "", err
+	}
+	sum, err := dirDigest(ctx, root, ".", lock)
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(sum), nil
+}
+
+func dirDigest(ctx context.Context, dir, rel string, lock *lockRecord) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if rel == "." && e.Name() == ".wvs" {
+			continue
+		}
+		p := filepath.Join(dir, e.Name())
+		entryRel := path.Join(rel, e.Name())
+		fi, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		var kind byte
+		var sum []byte
+		switch {
+		case fi.IsDir():
+			kind = 'd'
+			sum, err = dirDigest(ctx, p, entryRel, lock)
+		case fi.Mode()&fs.ModeSymlink != 0:
+			kind = 'l'
+			var target string
+			if target, err = os.Readlink(p); err == nil {
+				s := sha256.Sum256([]byte(target))
+				sum = s[:]
+			}
+		case fi.Mode().IsRegular():
+			kind = 'f'
+			sum, err = fileDigest(p)
+		default:
+			// Devices, FIFOs and sockets carry no content.
+			kind = 'o'
+		}
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(h, "%c %04o %s\x00%x\n", kind, lock.mode(entryRel, fi.Mode()).Perm(), e.Name(), sum)
+	}
+	return h.Sum(nil), nil
+}
+
+func fileDigest(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.CopyBuffer(h, f, make([]byte, readChunkSize)); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"syscall"
+
+	pb "github.com/lzjever/mbos-wvs/gen/go/executor/v1"
+)
+
+// Sentinel errors the ops wrap so ExecuteTask can classify failures.
+var (
+	ErrQuiesceTimeout   = errors.New("quiesce timeout")
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	ErrCloneFailed      = errors.New("clone failed")
+	ErrInvalidParams    = errors.New("invalid params")
+
+	// ErrInsufficientSpace means a clone was refused because it would eat into
+	// the configured free-space or free-inode reserve. Retrying will not help
+	// until space is freed.
+	ErrInsufficientSpace = errors.New("insufficient space")
+
+	// ErrHookFailed means a workspace hook failed with on_failure "fail".
+	ErrHookFailed = errors.New("hook failed")
+)
+
+// classifyError maps an op error onto the proto error class.
+func classifyError(err error) pb.ErrorClass {
+	switch {
+	case errors.Is(err, ErrInvalidParams):
+		return pb.ErrorClass_ERROR_CLASS_INVALID_PARAMS
+	case errors.Is(err, ErrSnapshotNotFound):
+		return pb.ErrorClass_ERROR_CLASS_SNAPSHOT_NOT_FOUND
+	case errors.Is(err, ErrInsufficientSpace), errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return pb.ErrorClass_ERROR_CLASS_NO_SPACE
+	case errors.Is(err, fs.ErrPermission):
+		return pb.ErrorClass_ERROR_CLASS_PERMISSION
+	case errors.Is(err, ErrQuiesceTimeout):
+		return pb.ErrorClass_ERROR_CLASS_QUIESCE_TIMEOUT
+	case errors.Is(err, context.Canceled):
+		return pb.ErrorClass_ERROR_CLASS_CANCELED
+	case errors.Is(err, ErrCloneFailed):
+		return pb.ErrorClass_ERROR_CLASS_CLONE_FAILED
+	case errors.Is(err, ErrHookFailed):
+		return pb.ErrorClass_ERROR_CLASS_HOOK_FAILED
+	default:
+		return pb.ErrorClass_ERROR_CLASS_UNSPECIFIED
+	}
+}
+
+// errorCodes are the error_code strings reported for each class.
+var errorCodes = map[pb.ErrorClass]string{
+	pb.ErrorClass_ERROR_CLASS_UNSPECIFIED:        "EXECUTOR_ERROR",
+	pb.ErrorClass_ERROR_CLASS_QUIESCE_TIMEOUT:    "QUIESCE_TIMEOUT",
+	pb.ErrorClass_ERROR_CLASS_SNAPSHOT_NOT_FOUND: "SNAPSHOT_NOT_FOUND",
+	pb.ErrorClass_ERROR_CLASS_NO_SPACE:           "NO_SPACE",
+	pb.ErrorClass_ERROR_CLASS_PERMISSION:         "PERMISSION",
+	pb.ErrorClass_ERROR_CLASS_CLONE_FAILED:       "CLONE_FAILED",
+	pb.ErrorClass_ERROR_CLASS_CANCELED:           "CANCELED",
+	pb.ErrorClass_ERROR_CLASS_INVALID_PARAMS:     "INVALID_PARAMS",
+	pb.ErrorClass_ERROR_CLASS_HOOK_FAILED:        "HOOK_FAILED",
+}
+
+// requiredParams lists the params each op cannot run without.
+var requiredParams = map[pb.TaskOp][]string{
+	pb.TaskOp_TASK_OP_SNAPSHOT_CREATE:    {"snapshot_id"},
+	pb.TaskOp_TASK_OP_SNAPSHOT_DROP:      {"snapshot_id"},
+	pb.TaskOp_TASK_OP_SET_CURRENT:        {"snapshot_id", "new_live_id"},
+	pb.TaskOp_TASK_OP_CHECKPOINT_RESTORE: {"snapshot_id", "new_live_id", "checkpoint_id"},
+	pb.TaskOp_TASK_OP_RESTORE_PATHS:      {"snapshot_id", "paths"},
+	pb.TaskOp_TASK_OP_EXPORT:             {"snapshot_id", "export_id"},
+	pb.TaskOp_TASK_OP_IMPORT:             {"snapshot_id", "source"},
+	pb.TaskOp_TASK_OP_REPLICATE:          {"snapshot_id", "peer_url", "peer_wsid"},
+	pb.TaskOp_TASK_OP_VERIFY:             {"snapshot_id"},
+}
+
+// validateRequest rejects missing params and IDs that would escape the
+// workspace directory when joined into a path.
+func validateRequest(req *pb.ExecuteTaskRequest) error {
+	if !isPathElement(req.Wsid) {
+		return fmt.Errorf("%w: wsid %q", ErrInvalidParams, req.Wsid)
+	}
+	for _, name := range requiredParams[req.Op] {
+		if req.Params[name] == "" {
+			return fmt.Errorf("%w: %s required", ErrInvalidParams, name)
+		}
+	}
+	for _, name := range []string{"snapshot_id", "new_live_id", "checkpoint_id", "export_id", "parent_snapshot_id", "current_snapshot_id",
+		"base_snapshot_id", "upload_task_id", "peer_wsid"} {
+		if v, ok := req.Params[name]; ok && v != "" && !isPathElement(v) {
+			return fmt.Errorf("%w: %s %q", ErrInvalidParams, name, v)
+		}
+	}
+	return nil
+}
+
+func isPathElement(s string) bool {
+	return s != "" && s != "." && s != ".." && filepath.Base(s) == s
+}
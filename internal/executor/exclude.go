@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+const (
+	// maxReportedExcludes caps the excluded paths listed in snapshot metadata.
+	maxReportedExcludes = 1000
+	// maxExcludeFileSize is how much of a .wvsignore is read.
+	maxExcludeFileSize = 64 << 10
+)
+
+// excludeReport decides what a snapshot leaves out and records it.
+type excludeReport struct {
+	ex       *core.Excluder
+	patterns []string
+	paths    []string // top-most excluded paths, up to maxReportedExcludes
+	count    int64
+}
+
+// skip reports whether the entry at rel is excluded, recording it if so.
+func (r *excludeReport) skip(rel string, isDir bool) bool {
+	if !r.ex.Excluded(rel, isDir) {
+		return false
+	}
+	r.count++
+	if len(r.paths) < maxReportedExcludes {
+		r.paths = append(r.paths, rel)
+	}
+	return true
+}
+
+// snapshotExcludes returns what a snapshot of srcPath leaves out: the
+// patterns in srcPath's .wvsignore followed by the workspace's own
+// (params["exclude_patterns"], a JSON array), which thereby take
+// precedence. It returns nil if there are none.
+func snapshotExcludes(srcPath string, params map[string]string, log *zap.Logger) (*excludeReport, error) {
+	var patterns []string
+	filePatterns, err := readExcludeFile(filepath.Join(srcPath, core.ExcludeFile), log)
+	if err != nil {
+		return nil, err
+	}
+	patterns = append(patterns, filePatterns...)
+	if raw := params["exclude_patterns"]; raw != "" {
+		var ws []string
+		if err := json.Unmarshal([]byte(raw), &ws); err != nil {
+			return nil, fmt.Errorf("%w: exclude_patterns: %v", ErrInvalidParams, err)
+		}
+		if err := core.ValidateExcludePatterns(ws); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+		patterns = append(patterns, ws...)
+	}
+
+	ex, err := core.CompileExcludes(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	if ex == nil {
+		return nil, nil
+	}
+	return &excludeReport{ex: ex, patterns: patterns}, nil
+}
+
+// readExcludeFile returns the patterns in the .wvsignore at path. The guest
+// owns the file, so what is wrong with it is logged rather than failing
+// the snapshot: lines that do not compile are dropped, anything past
+// maxExcludeFileSize is ignored, and so is anything but a regular file.
+func readExcludeFile(path string, log *zap.Logger) ([]string, error) {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		log.Warn("snapshot: ignoring exclude file that is not a regular file", zap.String("path", path))
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxExcludeFileSize))
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() > maxExcludeFileSize {
+		log.Warn("snapshot: exclude file too large, reading only its start",
+			zap.String("path", path), zap.Int("limit_bytes", maxExcludeFileSize))
+	}
+	patterns, invalid := core.ParseExcludeFile(data)
+	if len(invalid) > 0 {
+		log.Warn("snapshot: ignoring invalid exclude patterns", zap.String("path", path), zap.Strings("patterns", invalid))
+	}
+	return patterns, nil
+}
+
+// cloneExcluding clones src to dst leaving out what excludes says. Copy
+// mode skips excluded entries as it goes. A JuiceFS clone cannot filter,
+// so the excluded entries are removed from dst afterwards; they are only
+// metadata in a clone, so that costs no more than cloning them did.
+func (s *Server) cloneExcluding(ctx context.Context, src, dst, op string, excludes *excludeReport, log *zap.Logger) error {
+	if excludes == nil {
+		return s.clone(ctx, src, dst, op, log)
+	}
+	if s.cfg.CloneMode == CloneModeCopy {
+		return copyTree(ctx, src, dst, op, excludes.skip, log)
+	}
+	if err := Clone(ctx, src, dst, op, log); err != nil {
+		return err
+	}
+	return pruneExcluded(ctx, dst, excludes)
+}
+
+// pruneExcluded removes the entries of root that excludes leaves out.
+func pruneExcluded(ctx context.Context, root string, excludes *excludeReport) error {
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if !excludes.skip(filepath.ToSlash(rel), d.IsDir()) {
+			return nil
+		}
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: prune excluded paths: %v", ErrCloneFailed, err)
+	}
+	return nil
+}
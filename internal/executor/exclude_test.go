@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+var excludeTestTree = map[string]string{
+	".wvsignore":                  "# caches\nnode_modules/\n*.pyc\n[broken\n",
+	"src/main.py":                 "print()",
+	"src/main.pyc":                "bytecode",
+	"web/node_modules/x/index.js": "x",
+	"web/app.js":                  "app",
+	"build/out.bin":               "out",
+	"src/build/keep.txt":          "keep",
+	".wvs/keep":                   "",
+}
+
+func TestSnapshotExcludes(t *testing.T) {
+	ctx := context.Background()
+	s := newLocalServer(t)
+	wsRoot := filepath.Join(s.cfg.MountPath, replTestWSID)
+	live := filepath.Join(wsRoot, "live", "l1")
+	writeTree(t, live, excludeTestTree)
+	if err := os.Symlink(filepath.Join("live", "l1"), filepath.Join(wsRoot, "current")); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := core.NewID()
+	res, err := s.snapshotCreate(ctx, replTestWSID, map[string]string{
+		"snapshot_id":      snap,
+		"task_id":          core.NewID(),
+		"exclude_patterns": `["/build", "!src/main.pyc"]`,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := s.snapshotPath(snap)
+	for rel, want := range map[string]bool{
+		".wvsignore":         true,
+		"src/main.py":        true,
+		"src/main.pyc":       true, // re-included by the workspace's patterns
+		"web/app.js":         true,
+		"src/build/keep.txt": true,
+		".wvs/keep":          true,
+		"web/node_modules":   false,
+		"build":              false,
+	} {
+		if _, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel))); (err == nil) != want {
+			t.Errorf("%s: present=%v, want %v", rel, err == nil, want)
+		}
+	}
+
+	var meta SnapshotMeta
+	if err := json.Unmarshal([]byte(res["metadata"]), &meta); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(meta.Excluded)
+	if len(meta.Excluded) != 2 || meta.Excluded[0] != "build" || meta.Excluded[1] != "web/node_modules" || meta.ExcludedCount != 2 {
+		t.Errorf("excluded %q (%d)", meta.Excluded, meta.ExcludedCount)
+	}
+	if len(meta.ExcludePatterns) != 4 || res["excluded_count"] != "2" {
+		t.Errorf("patterns %q, results %v", meta.ExcludePatterns, res)
+	}
+	if got, _ := TreeDigest(ctx, root); got != res["tree_digest"] {
+		t.Errorf("digest %s, want %s", got, res["tree_digest"])
+	}
+
+	_, err = s.snapshotCreate(ctx, replTestWSID, map[string]string{
+		"snapshot_id": core.NewID(), "task_id": core.NewID(), "exclude_patterns": `["/"]`,
+	}, zap.NewNop())
+	if !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("invalid workspace pattern: %v", err)
+	}
+}
+
+// A JuiceFS clone is pruned after the fact; that must leave the same tree
+// as a filtered copy.
+func TestPruneExcludedMatchesFilteredCopy(t *testing.T) {
+	ctx := context.Background()
+	src := filepath.Join(t.TempDir(), "src")
+	writeTree(t, src, excludeTestTree)
+	newReport := func() *excludeReport {
+		rep, err := snapshotExcludes(src, nil, zap.NewNop())
+		if err != nil || rep == nil {
+			t.Fatalf("excludes: %v, %v", rep, err)
+		}
+		return rep
+	}
+
+	filtered := filepath.Join(t.TempDir(), "filtered")
+	copyRep := newReport()
+	if err := copyTree(ctx, src, filtered, "test", copyRep.skip, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	pruned := filepath.Join(t.TempDir(), "pruned")
+	if err := CopyTree(ctx, src, pruned, "test", zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	pruneRep := newReport()
+	if err := pruneExcluded(ctx, pruned, pruneRep); err != nil {
+		t.Fatal(err)
+	}
+
+	assertSameTree(t, filtered, pruned)
+	sort.Strings(copyRep.paths)
+	sort.Strings(pruneRep.paths)
+	if !reflect.DeepEqual(copyRep.paths, pruneRep.paths) || len(copyRep.paths) != 2 {
+		t.Errorf("copy excluded %q, prune excluded %q", copyRep.paths, pruneRep.paths)
+	}
+}
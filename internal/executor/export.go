@@ -0,0 +1,503 @@
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/lzjever/mbos-wvs/gen/go/executor/v1"
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+// exportSnapshot writes a snapshot out as a deterministic tar archive,
+// optionally zstd-compressed, ending with a manifest of per-file SHA-256
+// hashes. The archive is staged under <wsid>/exports and either kept there
+// or uploaded to the export bucket.
+func (s *Server) exportSnapshot(ctx context.Context, wsid string, params map[string]string, log *zap.Logger) (map[string]string, error) {
+	snapshotID := params["snapshot_id"]
+	exportID := params["export_id"]
+	compression, err := core.ParseExportCompression(params["compression"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	destination, err := core.ParseExportDestination(params["destination"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	wsRoot := filepath.Join(s.cfg.MountPath, wsid)
+	exportDir := filepath.Join(wsRoot, "exports")
+	resultsPath := filepath.Join(exportDir, exportID+".json")
+
+	// Idempotency: a previous attempt finished and recorded its results.
+	if b, err := os.ReadFile(resultsPath); err == nil {
+		var results map[string]string
+		if json.Unmarshal(b, &results) == nil && results["export_id"] == exportID && results["location"] != "" {
+			log.Info("export: already exported, noop")
+			return results, nil
+		}
+	}
+
+	snapRoot := filepath.Join(wsRoot, "snapshots", snapshotID)
+	if _, err := os.Stat(snapRoot); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, snapRoot)
+	}
+
+	var bucket *s3Client
+	if destination == core.DestinationS3 {
+		if bucket, err = s.exportS3(); err != nil {
+			return nil, err
+		}
+		if bucket == nil {
+			return nil, fmt.Errorf("%w: no export bucket configured", ErrInvalidParams)
+		}
+	}
+
+	// The archive is a real copy, not a clone: it needs the full size free.
+	est, err := EstimateClone(snapRoot)
+	if err != nil {
+		return nil, fmt.Errorf("estimate export: %w", err)
+	}
+	if err := s.preflightWrite(log, est.Bytes); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(exportDir, 0755); err != nil {
+		return nil, err
+	}
+	archiveName := exportID + compression.ArchiveExt()
+	archivePath := filepath.Join(exportDir, archiveName)
+	staging := archivePath + ".partial"
+	defer os.Remove(staging)
+
+	start := time.Now()
+	archive, err := writeArchiveFile(ctx, staging, snapRoot, "", compression)
+	if err != nil {
+		return nil, fmt.Errorf("write archive: %w", err)
+	}
+	manifestSum := sha256.Sum256(archive.manifest)
+	manifestName := exportID + ".MANIFEST.sha256"
+	manifestPath := filepath.Join(exportDir, manifestName)
+	if err := writeFileAtomic(manifestPath, archive.manifest, 0644); err != nil {
+		return nil, err
+	}
+
+	results := map[string]string{
+		"export_id":       exportID,
+		"compression":     string(compression),
+		"digest":          "sha256:" + archive.digest,
+		"size_bytes":      strconv.FormatInt(archive.size, 10),
+		"file_count":      strconv.FormatInt(archive.files, 10),
+		"manifest_digest": "sha256:" + hex.EncodeToString(manifestSum[:]),
+	}
+
+	switch destination {
+	case core.DestinationLocal:
+		if err := os.Rename(staging, archivePath); err != nil {
+			return nil, err
+		}
+		results["location"] = "file://" + archivePath
+		results["manifest_location"] = "file://" + manifestPath
+	case core.DestinationS3:
+		prefix := path.Join(s.cfg.ExportS3Prefix, wsid, snapshotID)
+		archiveKey := path.Join(prefix, archiveName)
+		manifestKey := path.Join(prefix, manifestName)
+		if err := bucket.EnsureBucket(ctx); err != nil {
+			return nil, err
+		}
+		if err := bucket.PutFile(ctx, archiveKey, staging, archive.digest); err != nil {
+			return nil, err
+		}
+		if err := bucket.PutFile(ctx, manifestKey, manifestPath, hex.EncodeToString(manifestSum[:])); err != nil {
+			return nil, err
+		}
+		_ = os.Remove(manifestPath)
+		results["location"] = bucket.Location(archiveKey)
+		results["manifest_location"] = bucket.Location(manifestKey)
+	}
+
+	b, _ := json.Marshal(results)
+	if err := writeFileAtomic(resultsPath, b, 0644); err != nil {
+		return nil, err
+	}
+
+	log.Info("export: completed",
+		zap.String("location", results["location"]),
+		zap.Int64("size_bytes", archive.size),
+		zap.Float64("duration_s", time.Since(start).Seconds()))
+	return results, nil
+}
+
+// ReadExport streams an export archive from its recorded location, which
+// must lie under the workspace's own export directory or bucket prefix.
+func (s *Server) ReadExport(req *pb.ReadExportRequest, stream pb.ExecutorService_ReadExportServer) error {
+	if !isPathElement(req.Wsid) {
+		return status.Error(codes.InvalidArgument, "invalid wsid")
+	}
+
+	var r io.ReadCloser
+	switch {
+	case strings.HasPrefix(req.Location, "file://"):
+		p := filepath.Clean(strings.TrimPrefix(req.Location, "file://"))
+		if !isWithin(filepath.Join(s.cfg.MountPath, req.Wsid, "exports"), p) {
+			return status.Error(codes.PermissionDenied, "location is outside the workspace's exports")
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return browseError(err)
+		}
+		r = f
+	case strings.HasPrefix(req.Location, "s3://"):
+		bucket, err := s.exportS3()
+		if err != nil || bucket == nil {
+			return status.Error(codes.FailedPrecondition, "no export bucket configured")
+		}
+		key, ok := strings.CutPrefix(req.Location, bucket.Location(""))
+		if !ok || path.Clean(key) != key || !strings.HasPrefix(key, path.Join(s.cfg.ExportS3Prefix, req.Wsid)+"/") {
+			return status.Error(codes.PermissionDenied, "location is outside the workspace's exports")
+		}
+		if r, err = bucket.Get(stream.Context(), key); err != nil {
+			return browseError(err)
+		}
+	default:
+		return status.Errorf(codes.InvalidArgument, "unsupported location %q", req.Location)
+	}
+	defer r.Close()
+
+	buf := make([]byte, readChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if sendErr := stream.Send(&pb.FileChunk{Data: buf[:n]}); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return browseError(err)
+		}
+	}
+}
+
+// archiveInfo describes a written archive.
+type archiveInfo struct {
+	digest   string // hex SHA-256 of the bytes written
+	size     int64
+	files    int64 // regular files shipped
+	deleted  int64 // entries of a delta's DELETED list
+	manifest []byte
+}
+
+// writeArchiveFile writes the archive of root, or its delta against base if
+// base is set, to dst.
+func writeArchiveFile(ctx context.Context, dst, root, base string, compression core.ExportCompression) (archiveInfo, error) {
+	f, err := os.Create(dst)
+	if err != nil {
+		return archiveInfo{}, err
+	}
+	defer f.Close()
+
+	out := &hashingWriter{w: f, h: sha256.New()}
+	var w io.Writer = out
+	var zw *zstd.Encoder
+	if compression == core.CompressionZstd {
+		// A single encoder goroutine keeps the output byte-for-byte reproducible.
+		zw, err = zstd.NewWriter(out, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return archiveInfo{}, err
+		}
+		w = zw
+	}
+
+	info, err := writeArchive(ctx, w, root, base)
+	if err != nil {
+		return archiveInfo{}, err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return archiveInfo{}, err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return archiveInfo{}, err
+	}
+	info.digest = hex.EncodeToString(out.h.Sum(nil))
+	info.size = out.n
+	return info, nil
+}
+
+// writeArchive writes root as a tar stream that depends only on the tree's
+// contents: entries in lexical order, PAX format, no owners, and mtimes
+// truncated to the second. The manifest is appended as the last entry and
+// also returned.
+//
+// With a base snapshot the stream is a delta: regular files and symlinks
+// the base already holds are left out, and the base paths root no longer
+// has are listed in a DELETED entry. Directories are always written, so
+// their modes and mtimes carry over. Unpacking the delta over a clone of
+// the base yields root.
+func writeArchive(ctx context.Context, w io.Writer, root, base string) (archiveInfo, error) {
+	tw := tar.NewWriter(w)
+	var manifest strings.Builder
+	var info archiveInfo
+	// Modes go out as they were before either snapshot was locked.
+	rootLock, err := readLockRecord(root)
+	if err != nil {
+		return archiveInfo{}, err
+	}
+	var baseLock *lockRecord
+	if base != "" {
+		if baseLock, err = readLockRecord(base); err != nil {
+			return archiveInfo{}, err
+		}
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == core.ExportManifestPath || rel == core.ArchiveDeletedPath {
+			// Left over from an imported archive; fresh ones are written last.
+			return nil
+		}
+		if rel == lockRecordPath {
+			return nil // see lockSnapshot
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := rootLock.mode(rel, fi.Mode())
+		if base != "" && !fi.IsDir() {
+			same, err := sameEntry(p, fi, mode, filepath.Join(base, filepath.FromSlash(rel)), func(m fs.FileMode) fs.FileMode {
+				return baseLock.mode(rel, m)
+			})
+			if err != nil {
+				return err
+			}
+			if same {
+				return nil
+			}
+		}
+		hdr := &tar.Header{
+			Name:    rel,
+			Mode:    int64(mode.Perm()),
+			ModTime: fi.ModTime().Truncate(time.Second),
+			Format:  tar.FormatPAX,
+		}
+		switch {
+		case fi.Mode().IsRegular():
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = fi.Size()
+		case fi.IsDir():
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+		case fi.Mode()&fs.ModeSymlink != 0:
+			hdr.Typeflag = tar.TypeSymlink
+			if hdr.Linkname, err = os.Readlink(p); err != nil {
+				return err
+			}
+		default:
+			// Devices, sockets and FIFOs have no place in an export.
+			return nil
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(tw, h), f); err != nil {
+			return err
+		}
+		fmt.Fprintf(&manifest, "%x  %s\n", h.Sum(nil), rel)
+		info.files++
+		return nil
+	})
+	if err != nil {
+		return archiveInfo{}, err
+	}
+
+	if base != "" {
+		deleted, err := deletedPaths(ctx, root, base)
+		if err != nil {
+			return archiveInfo{}, err
+		}
+		info.deleted = int64(len(deleted))
+		if err := writeMetaEntry(tw, core.ArchiveDeletedPath, []byte(strings.Join(deleted, ""))); err != nil {
+			return archiveInfo{}, err
+		}
+	}
+
+	info.manifest = []byte(manifest.String())
+	if err := writeMetaEntry(tw, core.ExportManifestPath, info.manifest); err != nil {
+		return archiveInfo{}, err
+	}
+	return info, tw.Close()
+}
+
+// writeMetaEntry writes a generated file, such as the manifest, with fixed
+// attributes so it does not disturb the archive's reproducibility.
+func writeMetaEntry(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Unix(0, 0),
+		Format:   tar.FormatPAX,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// sameEntry reports whether basePath holds the same file or symlink as p,
+// whose mode before any snapshot lock was mode; baseMode does the same for
+// basePath's. Type, mode and size must match; so must the contents unless
+// the mtimes do too, as rsync decides.
+func sameEntry(p string, fi fs.FileInfo, mode fs.FileMode, basePath string, baseMode func(fs.FileMode) fs.FileMode) (bool, error) {
+	bi, err := os.Lstat(basePath)
+	// ENOTDIR: a base file was replaced by a directory.
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if baseMode(bi.Mode()) != mode || bi.Size() != fi.Size() {
+		return false, nil
+	}
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		a, err := os.Readlink(p)
+		if err != nil {
+			return false, err
+		}
+		b, err := os.Readlink(basePath)
+		return a == b, err
+	}
+	if !fi.Mode().IsRegular() || bi.ModTime().Equal(fi.ModTime()) {
+		return true, nil
+	}
+	return sameContents(p, basePath)
+}
+
+func sameContents(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, readChunkSize)
+	bufB := make([]byte, readChunkSize)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == errA, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
+
+// deletedPaths lists, newline-terminated and in lexical order, the entries
+// of base that root does not have. A deleted directory is listed once,
+// without its contents.
+func deletedPaths(ctx context.Context, root, base string) ([]string, error) {
+	var deleted []string
+	err := filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		if fi, err := os.Lstat(filepath.Join(root, rel)); err == nil {
+			// A directory replaced by a file or link goes with it when the
+			// replacement is unpacked.
+			if d.IsDir() && !fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		deleted = append(deleted, filepath.ToSlash(rel)+"\n")
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// hashingWriter counts and hashes what it writes.
+type hashingWriter struct {
+	w io.Writer
+	h hash.Hash
+	n int64
+}
+
+func (hw *hashingWriter) Write(p []byte) (int, error) {
+	n, err := hw.w.Write(p)
+	hw.h.Write(p[:n])
+	hw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+// maxRedirects bounds the redirects an import source or a peer may send.
+const maxRedirects = 5
+
+// errRestrictedAddress is returned by a restricted client's dialer for an
+// address it will not connect to.
+var errRestrictedAddress = errors.New("address not allowed")
+
+// checkImportURL checks an http(s) import source, or a redirect target,
+// against EXECUTOR_IMPORT_URL_ALLOWLIST.
+func (s *Server) checkImportURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: import source scheme %q", ErrInvalidParams, u.Scheme)
+	}
+	if !core.ImportHostAllowed(u.Hostname(), s.cfg.ImportURLAllowlist) {
+		return fmt.Errorf("%w: import host %q is not on EXECUTOR_IMPORT_URL_ALLOWLIST", ErrInvalidParams, u.Hostname())
+	}
+	return nil
+}
+
+// restrictedClient makes requests on behalf of task params: http(s) import
+// sources and replication peers. check vets the first URL and every redirect
+// target, and the client never connects to a loopback, private, link-local
+// or otherwise internal address, whatever an allowed name resolves to. No
+// proxy is used, since the proxy would do the connecting.
+func restrictedClient(check func(*url.URL) error) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || internalIP(ip) {
+				return fmt.Errorf("%w: %s", errRestrictedAddress, host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: time.Minute,
+			MaxIdleConns:          4,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("%w: more than %d redirects", ErrInvalidParams, maxRedirects)
+			}
+			return check(req.URL)
+		},
+	}
+}
+
+// sharedAddressSpace is carrier-grade NAT space (RFC 6598), internal in
+// practice though not private.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// internalIP reports whether ip is an address a restricted client must not reach.
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// fetchImport opens an http(s) import source.
+func (s *Server) fetchImport(ctx context.Context, source string) (*http.Response, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	if err := s.checkImportURL(u); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	resp, err := restrictedClient(s.checkImportURL).Do(req)
+	if err != nil {
+		err = fmt.Errorf("fetch %s: %w", redactSource(source), err)
+		if errors.Is(err, errRestrictedAddress) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidParams, err)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFetchImportRefusesInternalHosts(t *testing.T) {
+	ctx := context.Background()
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte("secret"))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	s := NewServer(Config{}, zap.NewNop())
+	if _, err := s.fetchImport(ctx, srv.URL); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("host not on the allowlist: %v", err)
+	}
+
+	// Allowlisting the name does not make a loopback address reachable.
+	s.cfg.ImportURLAllowlist = []string{u.Hostname()}
+	if _, err := s.fetchImport(ctx, srv.URL); !errors.Is(err, ErrInvalidParams) || !errors.Is(err, errRestrictedAddress) {
+		t.Errorf("loopback fetch: %v", err)
+	}
+	if hits != 0 {
+		t.Errorf("server was reached %d times", hits)
+	}
+}
+
+func TestImportRedirectsChecked(t *testing.T) {
+	s := NewServer(Config{ImportURLAllowlist: []string{"artifacts.example.com"}}, zap.NewNop())
+	check := restrictedClient(s.checkImportURL).CheckRedirect
+	req := func(raw string) *http.Request {
+		r, _ := http.NewRequest(http.MethodGet, raw, nil)
+		return r
+	}
+	via := []*http.Request{req("https://artifacts.example.com/a.tar")}
+
+	if err := check(req("https://artifacts.example.com/b.tar"), via); err != nil {
+		t.Errorf("redirect within the allowlist: %v", err)
+	}
+	for _, target := range []string{"http://169.254.169.254/latest/meta-data/", "https://internal.example.com/", "file:///etc/passwd"} {
+		if err := check(req(target), via); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("redirect to %s: %v", target, err)
+		}
+	}
+	long := make([]*http.Request, maxRedirects)
+	if err := check(req("https://artifacts.example.com/b.tar"), long); err == nil {
+		t.Error("redirect chain not bounded")
+	}
+}
+
+func TestInternalIP(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.20.0.5":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.100.100.200":  true,
+		"0.0.0.0":          true,
+		"::1":              true,
+		"fd00:ec2::254":    true,
+		"fe80::1":          true,
+		"::ffff:127.0.0.1": true,
+		"93.184.216.34":    false,
+		"2606:4700::1111":  false,
+	} {
+		if got := internalIP(net.ParseIP(addr)); got != want {
+			t.Errorf("internalIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/observability"
+)
+
+// HookResult records one hook run. Ops that ran hooks report them as a JSON
+// array under the "hooks" result.
+type HookResult struct {
+	Point      core.HookPoint `json:"point"`
+	Name       string         `json:"name"`
+	Command    string         `json:"command"`
+	ExitCode   int            `json:"exit_code"`
+	DurationMS int64          `json:"duration_ms"`
+	// Output is stdout and stderr interleaved, cut at EXECUTOR_HOOK_OUTPUT_LIMIT.
+	Output    string `json:"output"`
+	Truncated bool   `json:"truncated,omitempty"`
+	// Error says why a hook without an exit status failed: a timeout, a
+	// command that could not start, hooks disabled.
+	Error string `json:"error,omitempty"`
+	// Ignored marks a failure let through by on_failure "ignore".
+	Ignored bool `json:"ignored,omitempty"`
+}
+
+func (r HookResult) failed() bool {
+	return r.ExitCode != 0 || r.Error != ""
+}
+
+// hookEnv is what a hook is told about its task.
+type hookEnv struct {
+	wsid       string
+	snapshotID string
+	op         string
+}
+
+// hookGrace is how long a hook's background children may keep its output
+// open after it exits.
+const hookGrace = 5 * time.Second
+
+// runHooks runs the workspace's hooks for point in dir, in order, and
+// returns what they did. The first hook to fail with on_failure "fail"
+// stops the rest and returns ErrHookFailed, which fails the op before a
+// snapshot; see runPostRestoreHooks for after a restore.
+func (s *Server) runHooks(ctx context.Context, point core.HookPoint, dir string, env hookEnv, params map[string]string, log *zap.Logger) ([]HookResult, error) {
+	hooks, err := core.ParseWorkspaceHooks([]byte(params["hooks"]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	var results []HookResult
+	for _, h := range hooks.At(point) {
+		res := s.runHook(ctx, point, h, dir, env, log)
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		if res.failed() && h.OnFailure == core.HookIgnore {
+			res.Ignored = true
+		}
+		results = append(results, res)
+		if res.failed() && !res.Ignored {
+			return results, fmt.Errorf("%w: %s hook %q: %s", ErrHookFailed, point, h.Name, res.summary())
+		}
+	}
+	return results, nil
+}
+
+func (s *Server) runHook(ctx context.Context, point core.HookPoint, h core.Hook, dir string, env hookEnv, log *zap.Logger) HookResult {
+	res := HookResult{Point: point, Name: h.Name, Command: h.Command}
+	log = log.With(zap.String("hook_point", string(point)), zap.String("hook", h.Name))
+	if !s.cfg.HooksEnabled {
+		res.ExitCode = -1
+		res.Error = "hooks are disabled on this executor"
+		observability.HookTotal.WithLabelValues(string(point), "disabled").Inc()
+		log.Warn("hook: disabled, not run")
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Dir = dir
+	// Not the executor's own environment: it holds storage credentials.
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"WVS_WSID=" + env.wsid,
+		"WVS_SNAPSHOT_ID=" + env.snapshotID,
+		"WVS_OP=" + env.op,
+		"WVS_HOOK=" + string(point),
+	}
+	out := &cappedBuffer{limit: s.cfg.HookOutputLimit}
+	cmd.Stdout, cmd.Stderr = out, out
+	killGroupOnCancel(cmd)
+	cmd.WaitDelay = hookGrace
+
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start)
+	res.DurationMS = elapsed.Milliseconds()
+	res.Output, res.Truncated = string(out.buf), out.truncated
+	observability.HookDuration.WithLabelValues(string(point)).Observe(elapsed.Seconds())
+
+	outcome := "ok"
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		res.ExitCode = -1
+		res.Error = fmt.Sprintf("timed out after %s", h.Timeout())
+		outcome = "timeout"
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		res.ExitCode = exitErr.ExitCode()
+		outcome = "failed"
+	default:
+		res.ExitCode = -1
+		res.Error = err.Error()
+		outcome = "failed"
+	}
+	observability.HookTotal.WithLabelValues(string(point), outcome).Inc()
+	if res.failed() {
+		log.Warn("hook: failed", zap.Int("exit_code", res.ExitCode), zap.String("error", res.Error),
+			zap.Duration("duration", elapsed))
+	} else {
+		log.Info("hook: completed", zap.Duration("duration", elapsed))
+	}
+	return res
+}
+
+// summary describes a failed run for the task error, with the end of its
+// output, which is where the reason usually is.
+func (r HookResult) summary() string {
+	why := r.Error
+	if why == "" {
+		why = fmt.Sprintf("exit status %d", r.ExitCode)
+	}
+	const tail = 512
+	out := r.Output
+	if len(out) > tail {
+		out = "..." + out[len(out)-tail:]
+	}
+	if out == "" {
+		return why
+	}
+	return why + ": " + out
+}
+
+// runPostRestoreHooks runs the post_restore hooks after current was switched
+// and adds what they did to results. The switch cannot be undone, so a
+// failure never fails the task, which must succeed for the switch to be
+// recorded: a hook that fails with on_failure "fail" only stops the hooks
+// after it, and results carry post_restore_failed and post_restore_error.
+func (s *Server) runPostRestoreHooks(ctx context.Context, dir string, env hookEnv, params, results map[string]string, prior []HookResult, log *zap.Logger) map[string]string {
+	hooks, err := s.runHooks(ctx, core.HookPostRestore, dir, env, params, log)
+	if err != nil {
+		log.Warn("post_restore hooks failed; the switch stands", zap.Error(err))
+		results["post_restore_failed"] = "true"
+		results["post_restore_error"] = err.Error()
+	}
+	return withHookResults(results, append(prior, hooks...))
+}
+
+// withHookResults adds the hooks that ran to an op's results.
+func withHookResults(results map[string]string, hooks []HookResult) map[string]string {
+	if len(hooks) == 0 {
+		return results
+	}
+	b, _ := json.Marshal(hooks)
+	results["hooks"] = string(b)
+	return results
+}
+
+// cappedBuffer keeps the first limit bytes written to it and drops the rest.
+type cappedBuffer struct {
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - len(b.buf); room < len(p) {
+		if room > 0 {
+			b.buf = append(b.buf, p[:room]...)
+		}
+		b.truncated = true
+		return len(p), nil
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package executor
+
+import "os/exec"
+
+// killGroupOnCancel leaves cmd alone; only the hook itself is killed.
+func killGroupOnCancel(cmd *exec.Cmd) {}
@@ -0,0 +1,156 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	pb "github.com/lzjever/mbos-wvs/gen/go/executor/v1"
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+func hooksParam(t *testing.T, h core.WorkspaceHooks) string {
+	t.Helper()
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRunHooks(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(Config{HooksEnabled: true, HookOutputLimit: 4096}, zap.NewNop())
+	dir := t.TempDir()
+	env := hookEnv{wsid: "ws1", snapshotID: "snap1", op: "snapshot_create"}
+	// Hooks must not see the executor's credentials.
+	t.Setenv("MINIO_SECRET_KEY", "hunter2")
+
+	params := map[string]string{"hooks": hooksParam(t, core.WorkspaceHooks{
+		PreSnapshot: []core.Hook{
+			{Name: "env", Command: `echo "$WVS_OP $WVS_WSID $WVS_SNAPSHOT_ID $WVS_HOOK [$MINIO_SECRET_KEY]"; pwd`},
+			{Name: "flaky", Command: "echo oops >&2; exit 3", OnFailure: core.HookIgnore},
+			{Name: "chatty", Command: "yes | head -c 10000"},
+			{Name: "stuck", Command: "sleep 30", TimeoutSeconds: 1},
+			{Name: "never", Command: "true"},
+		},
+		PostRestore: []core.Hook{{Command: "exit 1"}},
+	})}
+
+	results, err := s.runHooks(ctx, core.HookPreSnapshot, dir, env, params, zap.NewNop())
+	if !errors.Is(err, ErrHookFailed) || !strings.Contains(err.Error(), `"stuck"`) {
+		t.Fatalf("want ErrHookFailed for the stuck hook, got %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("%d results, want 4 (the failure stops the rest): %+v", len(results), results)
+	}
+
+	if got, want := results[0].Output, "snapshot_create ws1 snap1 pre_snapshot []\n"+dir+"\n"; got != want || results[0].failed() {
+		t.Errorf("env hook: %+v, want output %q", results[0], want)
+	}
+	if r := results[1]; r.ExitCode != 3 || !r.Ignored || r.Output != "oops\n" {
+		t.Errorf("flaky hook: %+v", r)
+	}
+	if r := results[2]; len(r.Output) != 4096 || !r.Truncated || r.failed() {
+		t.Errorf("chatty hook: %d bytes, %+v", len(r.Output), r)
+	}
+	if r := results[3]; r.ExitCode != -1 || !strings.Contains(r.Error, "timed out") {
+		t.Errorf("stuck hook: %+v", r)
+	}
+
+	// Disabled hooks fail like any other.
+	s.cfg.HooksEnabled = false
+	results, err = s.runHooks(ctx, core.HookPostRestore, dir, env, params, zap.NewNop())
+	if !errors.Is(err, ErrHookFailed) || len(results) != 1 || !strings.Contains(results[0].Error, "disabled") {
+		t.Errorf("disabled: %+v, %v", results, err)
+	}
+
+	if _, err := s.runHooks(ctx, core.HookPreSnapshot, dir, env, map[string]string{"hooks": `{"pre_snapshot": [{}]}`}, zap.NewNop()); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("invalid hooks: %v", err)
+	}
+}
+
+func TestSnapshotHooks(t *testing.T) {
+	ctx := context.Background()
+	s := newLocalServer(t)
+	s.cfg.HooksEnabled = true
+	s.cfg.HookOutputLimit = 1024
+	wsRoot := filepath.Join(s.cfg.MountPath, replTestWSID)
+	live := filepath.Join(wsRoot, "live", "l1")
+	writeTree(t, live, map[string]string{"db": "data"})
+	if err := os.Symlink(filepath.Join("live", "l1"), filepath.Join(wsRoot, "current")); err != nil {
+		t.Fatal(err)
+	}
+	hooks := hooksParam(t, core.WorkspaceHooks{
+		PreSnapshot: []core.Hook{{Name: "dump", Command: `cp db db.dump && echo dumped "$WVS_SNAPSHOT_ID"`}},
+		PostRestore: []core.Hook{{Name: "rebuild", Command: `echo "$WVS_SNAPSHOT_ID" > restored-from`}},
+	})
+
+	snap := core.NewID()
+	res, err := s.snapshotCreate(ctx, replTestWSID, map[string]string{"snapshot_id": snap, "task_id": core.NewID(), "hooks": hooks}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The dump is taken before the snapshot, so the snapshot holds it.
+	if b, err := os.ReadFile(filepath.Join(s.snapshotPath(snap), "db.dump")); err != nil || string(b) != "data" {
+		t.Errorf("snapshot lacks the dump: %q, %v", b, err)
+	}
+	var ran []HookResult
+	if err := json.Unmarshal([]byte(res["hooks"]), &ran); err != nil {
+		t.Fatalf("hooks result %q: %v", res["hooks"], err)
+	}
+	if len(ran) != 1 || ran[0].Name != "dump" || ran[0].Output != "dumped "+snap+"\n" {
+		t.Errorf("hooks result %+v", ran)
+	}
+
+	res, err = s.setCurrent(ctx, replTestWSID, map[string]string{"snapshot_id": snap, "new_live_id": "l2", "task_id": core.NewID(), "hooks": hooks}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(wsRoot, "live", "l2", "restored-from")); err != nil || string(b) != snap+"\n" {
+		t.Errorf("post_restore hook did not run in the new live directory: %q, %v", b, err)
+	}
+	if !strings.Contains(res["hooks"], `"rebuild"`) {
+		t.Errorf("set_current results %v", res)
+	}
+
+	// A failing post_restore hook cannot undo the switch, so the task
+	// still succeeds and says what went wrong.
+	broken := hooksParam(t, core.WorkspaceHooks{PostRestore: []core.Hook{
+		{Name: "migrate", Command: "echo schema mismatch >&2; exit 2"},
+		{Name: "after", Command: "touch after"},
+	}})
+	res, err = s.setCurrent(ctx, replTestWSID, map[string]string{"snapshot_id": snap, "new_live_id": "l3", "task_id": core.NewID(), "hooks": broken}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("post_restore failure failed the task: %v", err)
+	}
+	if res["post_restore_failed"] != "true" || !strings.Contains(res["post_restore_error"], "schema mismatch") {
+		t.Errorf("set_current results %v", res)
+	}
+	if target, _ := os.Readlink(filepath.Join(wsRoot, "current")); target != filepath.Join("live", "l3") {
+		t.Errorf("current -> %s", target)
+	}
+	if _, err := os.Stat(filepath.Join(wsRoot, "live", "l3", "after")); !os.IsNotExist(err) {
+		t.Errorf("hook after the failed one ran: %v", err)
+	}
+
+	// A failing pre_snapshot hook means no snapshot.
+	failing := hooksParam(t, core.WorkspaceHooks{PreSnapshot: []core.Hook{{Command: "echo locked >&2; exit 1"}}})
+	snap2 := core.NewID()
+	_, err = s.snapshotCreate(ctx, replTestWSID, map[string]string{"snapshot_id": snap2, "task_id": core.NewID(), "hooks": failing}, zap.NewNop())
+	if !errors.Is(err, ErrHookFailed) || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("want ErrHookFailed with the hook's output, got %v", err)
+	}
+	if classifyError(err) != pb.ErrorClass_ERROR_CLASS_HOOK_FAILED {
+		t.Errorf("classified as %v", classifyError(err))
+	}
+	if _, err := os.Stat(s.snapshotPath(snap2)); !os.IsNotExist(err) {
+		t.Errorf("snapshot taken despite the failed hook: %v", err)
+	}
+}
@@ -0,0 +1,608 @@
+package executor
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/lzjever/mbos-wvs/gen/go/executor/v1"
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+// zstdMagic starts every zstd frame; archives without it are read as plain tar.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// maxManifestSize bounds how much of an archive's manifest is read into memory.
+const maxManifestSize = 64 << 20
+
+// UploadImport stages an uploaded archive under <wsid>/imports until the
+// IMPORT task it was uploaded for picks it up. The archive is charged to an
+// unpack budget as it arrives, so an upload cannot fill the disk.
+func (s *Server) UploadImport(stream pb.ExecutorService_UploadImportServer) error {
+	chunk, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if !isPathElement(chunk.Wsid) || !isPathElement(chunk.TaskId) {
+		return status.Error(codes.InvalidArgument, "invalid wsid or task_id")
+	}
+	wsRoot := filepath.Join(s.cfg.MountPath, chunk.Wsid)
+	if _, err := os.Stat(wsRoot); err != nil {
+		return browseError(err)
+	}
+	budget, err := s.newUnpackBudget()
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if err := os.MkdirAll(filepath.Join(wsRoot, "imports"), 0755); err != nil {
+		return browseError(err)
+	}
+
+	dst := stagedImportPath(wsRoot, chunk.TaskId)
+	tmp := dst + ".partial"
+	defer os.Remove(tmp)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return browseError(err)
+	}
+	defer f.Close()
+
+	out := &hashingWriter{w: f, h: sha256.New()}
+	for {
+		if err := budget.take("upload", int64(len(chunk.Data))); err != nil {
+			if errors.Is(err, ErrInsufficientSpace) {
+				return status.Error(codes.ResourceExhausted, err.Error())
+			}
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		if _, err := out.Write(chunk.Data); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		chunk, err = stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return stream.SendAndClose(&pb.UploadImportResponse{
+		SizeBytes: out.n,
+		Digest:    "sha256:" + hex.EncodeToString(out.h.Sum(nil)),
+	})
+}
+
+// DiscardImport removes an archive staged for an IMPORT task the API failed
+// to create.
+func (s *Server) DiscardImport(ctx context.Context, req *pb.DiscardImportRequest) (*pb.DiscardImportResponse, error) {
+	if !isPathElement(req.Wsid) || !isPathElement(req.TaskId) {
+		return nil, status.Error(codes.InvalidArgument, "invalid wsid or task_id")
+	}
+	staged := stagedImportPath(filepath.Join(s.cfg.MountPath, req.Wsid), req.TaskId)
+	if err := os.Remove(staged); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.DiscardImportResponse{}, nil
+}
+
+// importSnapshot unpacks a tar archive, optionally zstd-compressed, into a
+// new snapshot. Entries that are absolute, climb out with "..", pass through
+// a symlink, or are device nodes fail the import. If the archive carries a
+// manifest, every regular file must match it.
+//
+// With base_snapshot_id the archive is a delta, as replication ships: it is
+// unpacked over a clone of that snapshot, and its DELETED list applied.
+func (s *Server) importSnapshot(ctx context.Context, wsid string, params map[string]string, log *zap.Logger) (map[string]string, error) {
+	snapshotID := params["snapshot_id"]
+	source := params["source"]
+	if err := core.ValidateImportSource(source); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	wsRoot := filepath.Join(s.cfg.MountPath, wsid)
+	dstPath := filepath.Join(wsRoot, "snapshots", snapshotID)
+	var basePath string
+	if baseID := params["base_snapshot_id"]; baseID != "" {
+		basePath = filepath.Join(wsRoot, "snapshots", baseID)
+		if _, err := os.Stat(basePath); os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, basePath)
+		}
+	}
+
+	// Idempotency: a previous attempt already moved the snapshot into place.
+	if results, ok := existingSnapshot(dstPath); ok {
+		log.Info("import: already imported, noop")
+		if err := s.lockSnapshot(ctx, dstPath, log); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+
+	src, size, err := s.openImportSource(ctx, wsRoot, params["upload_task_id"], source)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	// The unpacked size is unknown until the end; the archive is a lower
+	// bound, and unpackArchive enforces the upper one.
+	if basePath != "" {
+		if err := s.preflightClone(log, basePath); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.preflightWrite(log, size); err != nil {
+		return nil, err
+	}
+
+	staging := filepath.Join(wsRoot, "imports", snapshotID+".unpack")
+	_ = os.RemoveAll(staging) // left behind by a failed attempt
+	if basePath != "" {
+		if err := os.MkdirAll(filepath.Dir(staging), 0755); err != nil {
+			return nil, err
+		}
+		if err := s.clone(ctx, basePath, staging, "import", log); err != nil {
+			return nil, err
+		}
+		if err := unlockClone(ctx, basePath, basePath, staging); err != nil {
+			return nil, err
+		}
+	} else if err := os.MkdirAll(staging, 0755); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	budget, err := s.newUnpackBudget()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	verified, err := unpackArchive(ctx, src, staging, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := s.snapshotUsage(staging, basePath)
+	if err != nil {
+		return nil, fmt.Errorf("measure snapshot: %w", err)
+	}
+	digest, err := TreeDigest(ctx, staging)
+	if err != nil {
+		return nil, fmt.Errorf("digest snapshot: %w", err)
+	}
+	meta := SnapshotMeta{
+		SnapshotID: snapshotID,
+		WSID:       wsid,
+		TaskID:     params["task_id"],
+		SourcePath: redactSource(source),
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		Message:    params["message"],
+		SizeBytes:  usage.SizeBytes,
+		FileCount:  usage.FileCount,
+		TreeDigest: digest,
+	}
+	if usage.HasSharing {
+		meta.ParentSnapshotID = params["base_snapshot_id"]
+		meta.UniqueBytes = &usage.UniqueBytes
+		meta.SharedBytes = &usage.SharedBytes
+	}
+	if err := os.MkdirAll(filepath.Join(staging, ".wvs"), 0755); err != nil {
+		return nil, fmt.Errorf("mkdir snapshot .wvs: %w", err)
+	}
+	metaData, _ := json.MarshalIndent(meta, "", "  ")
+	metaPath := filepath.Join(staging, ".wvs", "snapshot.json")
+	// The archive may carry its own, possibly as a symlink; never write through it.
+	if err := os.Remove(metaPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	// Nor may it decide how the snapshot is locked.
+	if err := dropLockRecord(staging); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(metaPath, metaData, 0644); err != nil {
+		return nil, fmt.Errorf("write snapshot.json: %w", err)
+	}
+	if err := discardPartialSnapshot(ctx, dstPath, log); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(staging, dstPath); err != nil {
+		return nil, err
+	}
+	if err := s.lockSnapshot(ctx, dstPath, log); err != nil {
+		return nil, err
+	}
+	if source == core.ImportSourceUpload {
+		_ = os.Remove(stagedImportPath(wsRoot, params["upload_task_id"]))
+	}
+
+	log.Info("import: completed",
+		zap.Int64("size_bytes", usage.SizeBytes),
+		zap.Bool("manifest_verified", verified),
+		zap.Float64("duration_s", time.Since(start).Seconds()))
+
+	results := snapshotResults(snapshotID, dstPath, meta, metaData)
+	results["manifest_verified"] = strconv.FormatBool(verified)
+	return results, nil
+}
+
+// stagedImportPath is where UploadImport leaves an archive for the IMPORT
+// task taskID. The task names it in upload_task_id, which a DLQ retry keeps.
+func stagedImportPath(wsRoot, taskID string) string {
+	return filepath.Join(wsRoot, "imports", taskID+".archive")
+}
+
+// openImportSource opens the archive to import and returns its size, or 0
+// if unknown. s3:// sources must be in the export bucket and http(s)
+// sources on EXECUTOR_IMPORT_URL_ALLOWLIST.
+func (s *Server) openImportSource(ctx context.Context, wsRoot, taskID, source string) (io.ReadCloser, int64, error) {
+	if source == core.ImportSourceUpload {
+		f, err := os.Open(stagedImportPath(wsRoot, taskID))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, 0, fmt.Errorf("%w: no staged upload for task %s", ErrInvalidParams, taskID)
+			}
+			return nil, 0, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, info.Size(), nil
+	}
+
+	u, _ := url.Parse(source)
+	if u.Scheme == "s3" {
+		bucket, err := s.exportS3()
+		if err != nil {
+			return nil, 0, err
+		}
+		if bucket == nil || u.Host != bucket.bucket {
+			return nil, 0, fmt.Errorf("%w: bucket %q is not the configured export bucket", ErrInvalidParams, u.Host)
+		}
+		r, err := bucket.Get(ctx, strings.TrimPrefix(u.Path, "/"))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+		return r, 0, err
+	}
+
+	resp, err := s.fetchImport(ctx, source)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err := fmt.Errorf("fetch %s: %s", redactSource(source), resp.Status)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			// The URL itself is wrong or expired; retrying will not fix it.
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+		return nil, 0, err
+	}
+	return resp.Body, max(resp.ContentLength, 0), nil
+}
+
+// redactSource drops the query string, which for presigned URLs is a credential.
+func redactSource(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme == "" {
+		return source
+	}
+	u.RawQuery = ""
+	u.User = nil
+	return u.String()
+}
+
+// unpackArchive extracts a tar stream into root, which may already hold a
+// base snapshot, and reports whether it carried a manifest that every
+// regular file matched. A DELETED list is applied once the entries are out.
+// Regular files are charged to budget before they are written.
+func unpackArchive(ctx context.Context, r io.Reader, root string, budget *unpackBudget) (bool, error) {
+	br := bufio.NewReader(r)
+	var rd io.Reader = br
+	if magic, _ := br.Peek(len(zstdMagic)); bytes.Equal(magic, zstdMagic) {
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return false, err
+		}
+		defer zr.Close()
+		rd = zr
+	}
+
+	tr := tar.NewReader(rd)
+	hashes := map[string]string{}
+	type dirAttr struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	var dirs []dirAttr
+	var manifest, deleted []byte
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if errors.Is(err, tar.ErrHeader) || errors.Is(err, zstd.ErrMagicMismatch) {
+				return false, fmt.Errorf("%w: read archive: %v", ErrInvalidParams, err)
+			}
+			return false, fmt.Errorf("read archive: %w", err)
+		}
+
+		name, err := core.CleanArchivePath(hdr.Name)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+		if name == "." {
+			continue
+		}
+		if err := checkParents(root, name); err != nil {
+			return false, err
+		}
+		if name == core.ExportManifestPath {
+			if manifest, err = io.ReadAll(io.LimitReader(tr, maxManifestSize)); err != nil {
+				return false, fmt.Errorf("read manifest: %w", err)
+			}
+			continue
+		}
+		if name == core.ArchiveDeletedPath {
+			if deleted, err = io.ReadAll(io.LimitReader(tr, maxManifestSize)); err != nil {
+				return false, fmt.Errorf("read deleted list: %w", err)
+			}
+			continue
+		}
+		if name == ".wvs" && hdr.Typeflag != tar.TypeDir {
+			// Snapshot metadata is written there after unpacking.
+			return false, fmt.Errorf("%w: entry %q must be a directory", ErrInvalidParams, hdr.Name)
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+		delete(hashes, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			// A file or symlink of the same name, from the base or an
+			// earlier entry, gives way.
+			if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+				if err := os.Remove(target); err != nil {
+					return false, err
+				}
+			} else if err == nil && info.Mode().Perm()&0700 != 0700 {
+				// A read-only directory of the base must take new entries.
+				if err := os.Chmod(target, info.Mode().Perm()|0700); err != nil {
+					return false, err
+				}
+			}
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return false, err
+			}
+			dirs = append(dirs, dirAttr{target, os.FileMode(hdr.Mode).Perm(), hdr.ModTime})
+
+		case tar.TypeReg:
+			if err := budget.take(name, hdr.Size); err != nil {
+				return false, err
+			}
+			if err := prepareEntry(target, name, hashes); err != nil {
+				return false, err
+			}
+			sum, err := writeEntry(target, tr, hdr)
+			if err != nil {
+				return false, err
+			}
+			hashes[name] = sum
+
+		case tar.TypeLink:
+			linkName, err := core.CleanArchivePath(hdr.Linkname)
+			if err != nil {
+				return false, fmt.Errorf("%w: hard link %q: %v", ErrInvalidParams, hdr.Name, err)
+			}
+			sum, ok := hashes[linkName]
+			if !ok {
+				return false, fmt.Errorf("%w: hard link %q to %q, which is not an earlier file", ErrInvalidParams, hdr.Name, hdr.Linkname)
+			}
+			if err := checkParents(root, linkName); err != nil {
+				return false, err
+			}
+			if err := prepareEntry(target, name, hashes); err != nil {
+				return false, err
+			}
+			if err := os.Link(filepath.Join(root, filepath.FromSlash(linkName)), target); err != nil {
+				return false, err
+			}
+			hashes[name] = sum
+
+		case tar.TypeSymlink:
+			if err := prepareEntry(target, name, hashes); err != nil {
+				return false, err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return false, err
+			}
+
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			return false, fmt.Errorf("%w: entry %q is a device node or FIFO", ErrInvalidParams, hdr.Name)
+
+		default:
+			return false, fmt.Errorf("%w: entry %q has unsupported type %q", ErrInvalidParams, hdr.Name, hdr.Typeflag)
+		}
+	}
+
+	if err := applyDeleted(root, deleted, hashes); err != nil {
+		return false, err
+	}
+
+	// Directory modes last, so read-only directories could still be filled.
+	// They stay owner-writable so the snapshot can be dropped later.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if info, err := os.Lstat(d.path); err != nil || !info.IsDir() {
+			continue // replaced by a later entry
+		}
+		if err := os.Chmod(d.path, d.mode|0700); err != nil {
+			return false, err
+		}
+		_ = os.Chtimes(d.path, d.modTime, d.modTime)
+	}
+
+	if manifest == nil {
+		return false, nil
+	}
+	if err := verifyManifest(manifest, hashes); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	return true, nil
+}
+
+// prepareEntry makes target's parent and clears whatever the base or an
+// earlier entry left at name, so new files are never written through it. A
+// directory goes with its contents, which drop out of hashes.
+func prepareEntry(target, name string, hashes map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	info, err := os.Lstat(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return os.Remove(target)
+	}
+	for p := range hashes {
+		if strings.HasPrefix(p, name+"/") {
+			delete(hashes, p)
+		}
+	}
+	return os.RemoveAll(target)
+}
+
+// checkParents fails if a directory on the way to name is a symlink, left
+// there by the base snapshot or an earlier entry. Missing parents are fine;
+// they are made as real directories.
+func checkParents(root, name string) error {
+	p := root
+	for _, elem := range strings.Split(path.Dir(name), "/") {
+		if elem == "." {
+			break
+		}
+		p = filepath.Join(p, elem)
+		info, err := os.Lstat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%w: entry %q is beneath a symlink", ErrInvalidParams, name)
+		}
+	}
+	return nil
+}
+
+// applyDeleted removes the paths of a DELETED list from root. Each must be
+// a clean relative path that no entry of the same archive wrote.
+func applyDeleted(root string, list []byte, hashes map[string]string) error {
+	for _, line := range strings.Split(string(list), "\n") {
+		if line == "" {
+			continue
+		}
+		name, err := core.CleanArchivePath(line)
+		if err != nil || name == "." {
+			return fmt.Errorf("%w: deleted list entry %q", ErrInvalidParams, line)
+		}
+		if _, ok := hashes[name]; ok {
+			return fmt.Errorf("%w: %q is both shipped and deleted", ErrInvalidParams, name)
+		}
+		if err := checkParents(root, name); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEntry writes a regular file entry and returns its hex SHA-256.
+func writeEntry(target string, r io.Reader, hdr *tar.Header) (string, error) {
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		return "", fmt.Errorf("extract %s: %w", hdr.Name, err)
+	}
+	if err := f.Chmod(os.FileMode(hdr.Mode).Perm()); err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	_ = os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyManifest checks a sha256sum-format manifest against the hashes of
+// the files actually extracted, in both directions.
+func verifyManifest(manifest []byte, hashes map[string]string) error {
+	listed := make(map[string]bool, len(hashes))
+	for _, line := range strings.Split(strings.TrimRight(string(manifest), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return fmt.Errorf("malformed manifest line %q", line)
+		}
+		got, found := hashes[name]
+		if !found {
+			return fmt.Errorf("manifest lists %q, which the archive does not contain", name)
+		}
+		if !strings.EqualFold(got, sum) {
+			return fmt.Errorf("%q does not match its manifest hash", name)
+		}
+		listed[name] = true
+	}
+	for name := range hashes {
+		if !listed[name] {
+			return fmt.Errorf("%q is not in the manifest", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/lzjever/mbos-wvs/gen/go/executor/v1"
+)
+
+// A small compressed archive can unpack to far more than its size; the
+// budget is charged per file, before anything is written.
+func TestUnpackArchiveBudget(t *testing.T) {
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(zw)
+	zeros := make([]byte, 1<<20)
+	for _, name := range []string{"a.bin", "b.bin"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(zeros))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(zeros); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+
+	root := t.TempDir()
+	budget := &unpackBudget{left: 3 << 19, err: ErrInvalidParams}
+	_, err = unpackArchive(context.Background(), bytes.NewReader(archive), root, budget)
+	if !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("want the budget to stop the unpack, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "b.bin")); !os.IsNotExist(err) {
+		t.Errorf("file past the budget was written: %v", err)
+	}
+
+	budget = &unpackBudget{left: 2 << 20, err: ErrInsufficientSpace}
+	if _, err := unpackArchive(context.Background(), bytes.NewReader(archive), t.TempDir(), budget); err != nil || budget.left != 0 {
+		t.Errorf("archive within budget: %v, %d left", err, budget.left)
+	}
+}
+
+// uploadStream feeds UploadImport a fixed series of chunks.
+type uploadStream struct {
+	grpc.ServerStream
+	chunks []*pb.UploadImportChunk
+	resp   *pb.UploadImportResponse
+}
+
+func (u *uploadStream) Recv() (*pb.UploadImportChunk, error) {
+	if len(u.chunks) == 0 {
+		return nil, io.EOF
+	}
+	c := u.chunks[0]
+	u.chunks = u.chunks[1:]
+	return c, nil
+}
+
+func (u *uploadStream) SendAndClose(resp *pb.UploadImportResponse) error {
+	u.resp = resp
+	return nil
+}
+
+func TestUploadImportLimit(t *testing.T) {
+	mount := t.TempDir()
+	wsRoot := filepath.Join(mount, "ws1")
+	if err := os.MkdirAll(wsRoot, 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(Config{MountPath: mount, ImportMaxBytes: 1 << 10}, zap.NewNop())
+	chunk := make([]byte, 600)
+
+	over := &uploadStream{chunks: []*pb.UploadImportChunk{
+		{Wsid: "ws1", TaskId: "t1", Data: chunk},
+		{Data: chunk},
+	}}
+	if err := s.UploadImport(over); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("upload past EXECUTOR_IMPORT_MAX_BYTES: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(wsRoot, "imports")); len(entries) != 0 {
+		t.Errorf("rejected upload left %d files behind", len(entries))
+	}
+
+	within := &uploadStream{chunks: []*pb.UploadImportChunk{{Wsid: "ws1", TaskId: "t2", Data: chunk}}}
+	if err := s.UploadImport(within); err != nil || within.resp.SizeBytes != int64(len(chunk)) {
+		t.Fatalf("upload within the limit: %v", err)
+	}
+	if _, err := s.DiscardImport(context.Background(), &pb.DiscardImportRequest{Wsid: "ws1", TaskId: "t2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stagedImportPath(wsRoot, "t2")); !os.IsNotExist(err) {
+		t.Errorf("discarded upload still staged: %v", err)
+	}
+}
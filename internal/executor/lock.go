@@ -0,0 +1,242 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// Snapshot lock modes; see Config.SnapshotLock.
+const (
+	SnapshotLockNone      = "none"
+	SnapshotLockReadOnly  = "readonly"
+	SnapshotLockImmutable = "immutable"
+)
+
+// lockRecordPath is where a locked snapshot records how it was locked.
+// It is bookkeeping, not content: clones, digests and archives leave it out.
+const lockRecordPath = ".wvs/lock.json"
+
+// errImmutableUnsupported means the immutable attribute cannot be set here,
+// because of the filesystem or missing CAP_LINUX_IMMUTABLE.
+var errImmutableUnsupported = errors.New("immutable attribute not supported")
+
+// lockRecord is the contents of lockRecordPath.
+type lockRecord struct {
+	Mode string `json:"mode"`
+	// WriteBits maps slash-separated paths relative to the snapshot root
+	// ("." for the root) to the write permission bits a readonly lock took
+	// away. Entries without any are left out.
+	WriteBits map[string]fs.FileMode `json:"write_bits,omitempty"`
+}
+
+// readLockRecord returns root's lock record, or nil if root is not locked.
+func readLockRecord(root string) (*lockRecord, error) {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(lockRecordPath)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read lock record: %w", err)
+	}
+	var rec lockRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("parse lock record: %w", err)
+	}
+	return &rec, nil
+}
+
+// mode returns the mode the entry at rel had before locking, given its
+// mode m on disk. A nil record leaves m alone.
+func (r *lockRecord) mode(rel string, m fs.FileMode) fs.FileMode {
+	if r == nil {
+		return m
+	}
+	return m | r.WriteBits[rel]
+}
+
+func writeLockRecord(root string, rec *lockRecord) error {
+	p := filepath.Join(root, filepath.FromSlash(lockRecordPath))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("mkdir snapshot .wvs: %w", err)
+	}
+	data, _ := json.Marshal(rec)
+	if err := writeFileAtomic(p, data, 0644); err != nil {
+		return fmt.Errorf("write lock record: %w", err)
+	}
+	return nil
+}
+
+// dropLockRecord removes a lock record root inherited from a locked source.
+func dropLockRecord(root string) error {
+	err := os.Remove(filepath.Join(root, filepath.FromSlash(lockRecordPath)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove lock record: %w", err)
+	}
+	return nil
+}
+
+// lockSnapshot locks the finished snapshot at root as configured. The lock
+// record is written before anything is locked, so a retry after a partial
+// lock picks up where it stopped without losing the original modes.
+func (s *Server) lockSnapshot(ctx context.Context, root string, log *zap.Logger) error {
+	rec, err := readLockRecord(root)
+	if err != nil {
+		return err
+	}
+	mode := s.cfg.SnapshotLock
+	if rec != nil {
+		mode = rec.Mode // finish the lock a previous attempt started
+	}
+
+	switch mode {
+	case "", SnapshotLockNone:
+		return nil
+	case SnapshotLockImmutable:
+		if rec == nil {
+			if err := writeLockRecord(root, &lockRecord{Mode: SnapshotLockImmutable}); err != nil {
+				return err
+			}
+		}
+		err := setImmutable(ctx, root, true)
+		if err == nil {
+			log.Info("lock: snapshot immutable", zap.String("path", root))
+			return nil
+		}
+		if !errors.Is(err, errImmutableUnsupported) {
+			return fmt.Errorf("lock snapshot: %w", err)
+		}
+		log.Warn("lock: immutable attribute unavailable, falling back to readonly", zap.Error(err))
+		if err := setImmutable(ctx, root, false); err != nil && !errors.Is(err, errImmutableUnsupported) {
+			return fmt.Errorf("lock snapshot: %w", err)
+		}
+		rec = nil
+	case SnapshotLockReadOnly:
+	default:
+		return fmt.Errorf("unknown snapshot lock mode %q", mode)
+	}
+
+	if rec == nil {
+		if rec, err = collectWriteBits(ctx, root); err != nil {
+			return fmt.Errorf("lock snapshot: %w", err)
+		}
+		if err := writeLockRecord(root, rec); err != nil {
+			return err
+		}
+	}
+	err = walkLockable(ctx, root, func(p, _ string, fi fs.FileInfo) error {
+		if fi.Mode().Perm()&0222 == 0 {
+			return nil
+		}
+		return os.Chmod(p, fi.Mode().Perm()&^0222)
+	})
+	if err != nil {
+		return fmt.Errorf("lock snapshot: %w", err)
+	}
+	log.Info("lock: snapshot read-only", zap.String("path", root))
+	return nil
+}
+
+func collectWriteBits(ctx context.Context, root string) (*lockRecord, error) {
+	rec := &lockRecord{Mode: SnapshotLockReadOnly, WriteBits: map[string]fs.FileMode{}}
+	err := walkLockable(ctx, root, func(_, rel string, fi fs.FileInfo) error {
+		if bits := fi.Mode().Perm() & 0222; bits != 0 {
+			rec.WriteBits[rel] = bits
+		}
+		return nil
+	})
+	return rec, err
+}
+
+// unlockSnapshot undoes lockSnapshot far enough for root to be removed:
+// the immutable attribute comes off and directories become writable again.
+func unlockSnapshot(ctx context.Context, root string) error {
+	rec, err := readLockRecord(root)
+	if err != nil || rec == nil {
+		return err
+	}
+	if rec.Mode == SnapshotLockImmutable {
+		if err := setImmutable(ctx, root, false); err != nil && !errors.Is(err, errImmutableUnsupported) {
+			return fmt.Errorf("unlock snapshot: %w", err)
+		}
+	}
+	err = walkLockable(ctx, root, func(p, _ string, fi fs.FileInfo) error {
+		if !fi.IsDir() || fi.Mode().Perm()&0700 == 0700 {
+			return nil
+		}
+		return os.Chmod(p, fi.Mode().Perm()|0700)
+	})
+	if err != nil {
+		return fmt.Errorf("unlock snapshot: %w", err)
+	}
+	return nil
+}
+
+// unlockClone gives dst, a fresh clone of src inside the snapshot at
+// snapRoot, back what locking the snapshot took from it: the write bits,
+// and the immutable attribute if the clone kept it. A clone of the whole
+// snapshot also sheds the lock record.
+func unlockClone(ctx context.Context, snapRoot, src, dst string) error {
+	rec, err := readLockRecord(snapRoot)
+	if err != nil || rec == nil {
+		return err
+	}
+	prefix, err := filepath.Rel(snapRoot, src)
+	if err != nil {
+		return err
+	}
+	if rec.Mode == SnapshotLockImmutable {
+		if err := setImmutable(ctx, dst, false); err != nil && !errors.Is(err, errImmutableUnsupported) {
+			return fmt.Errorf("unlock clone: %w", err)
+		}
+	}
+	if len(rec.WriteBits) > 0 {
+		err := walkLockable(ctx, dst, func(p, rel string, fi fs.FileInfo) error {
+			bits := rec.WriteBits[filepath.ToSlash(filepath.Join(prefix, rel))]
+			if bits == 0 || fi.Mode().Perm()&bits == bits {
+				return nil
+			}
+			return os.Chmod(p, fi.Mode().Perm()|bits)
+		})
+		if err != nil {
+			return fmt.Errorf("unlock clone: %w", err)
+		}
+	}
+	if prefix == "." {
+		return dropLockRecord(dst)
+	}
+	return nil
+}
+
+// walkLockable calls fn for root and every directory and regular file
+// under it, with its slash-separated path relative to root. Symlinks and
+// special files are skipped: their permission bits mean nothing or are not
+// ours to change.
+func walkLockable(ctx context.Context, root string, fn func(p, rel string, fi fs.FileInfo) error) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(p, filepath.ToSlash(rel), fi)
+	})
+}
@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// fsImmutableFL is FS_IMMUTABLE_FL from linux/fs.h, the flag `chattr +i` sets.
+const fsImmutableFL = 0x00000010
+
+// setImmutable sets or clears the immutable attribute on root and every
+// directory and regular file under it.
+func setImmutable(ctx context.Context, root string, on bool) error {
+	return walkLockable(ctx, root, func(p, _ string, _ fs.FileInfo) error {
+		if err := setImmutableFlag(p, on); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		return nil
+	})
+}
+
+func setImmutableFlag(p string, on bool) error {
+	fd, err := unix.Open(p, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: p, Err: err}
+	}
+	defer unix.Close(fd)
+
+	flags, err := unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		return immutableErr(err)
+	}
+	want := flags &^ fsImmutableFL
+	if on {
+		want = flags | fsImmutableFL
+	}
+	if want == flags {
+		return nil
+	}
+	return immutableErr(unix.IoctlSetPointerInt(fd, unix.FS_IOC_SETFLAGS, int(want)))
+}
+
+// immutableErr marks the errors that mean the attribute cannot be used here
+// at all, as opposed to failing on one file.
+func immutableErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, unix.ENOTTY), errors.Is(err, unix.EOPNOTSUPP),
+		errors.Is(err, unix.EINVAL), errors.Is(err, unix.EPERM):
+		return fmt.Errorf("%w: %v", errImmutableUnsupported, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package executor
+
+import "context"
+
+// setImmutable is only implemented on Linux.
+func setImmutable(ctx context.Context, root string, on bool) error {
+	return errImmutableUnsupported
+}
@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+func TestLockedSnapshotLifecycle(t *testing.T) {
+	for _, mode := range []string{SnapshotLockReadOnly, SnapshotLockImmutable} {
+		t.Run(mode, func(t *testing.T) {
+			ctx := context.Background()
+			s := newLocalServer(t)
+			s.cfg.SnapshotLock = mode
+			wsRoot := filepath.Join(s.cfg.MountPath, replTestWSID)
+
+			live := filepath.Join(wsRoot, "live", "l1")
+			writeTree(t, live, map[string]string{
+				"a.txt":       "a",
+				"bin/run":     "#!/bin/sh",
+				"ro.txt":      "ro",
+				".wvs/keep":   "",
+				"dir/sub/b.c": "b",
+			})
+			if err := os.Chmod(filepath.Join(live, "bin", "run"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(filepath.Join(live, "ro.txt"), 0444); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(filepath.Join("live", "l1"), filepath.Join(wsRoot, "current")); err != nil {
+				t.Fatal(err)
+			}
+
+			snap := core.NewID()
+			res, err := s.snapshotCreate(ctx, replTestWSID, map[string]string{"snapshot_id": snap, "task_id": core.NewID()}, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			root := s.snapshotPath(snap)
+			rec, err := readLockRecord(root)
+			if err != nil || rec == nil {
+				t.Fatalf("lock record: %v, %v", rec, err)
+			}
+			switch rec.Mode {
+			case SnapshotLockReadOnly:
+				if fi, _ := os.Stat(filepath.Join(root, "a.txt")); fi.Mode().Perm()&0222 != 0 {
+					t.Errorf("a.txt is %v after locking", fi.Mode())
+				}
+			case SnapshotLockImmutable:
+				if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("x"), 0644); err == nil {
+					t.Error("wrote to an immutable snapshot")
+				}
+			default:
+				t.Fatalf("lock mode %q", rec.Mode)
+			}
+			if got, _ := TreeDigest(ctx, root); got != res["tree_digest"] {
+				t.Errorf("locked snapshot digests %s, want %s", got, res["tree_digest"])
+			}
+
+			// Retrying the task leaves the recorded modes alone.
+			if _, err := s.snapshotCreate(ctx, replTestWSID, map[string]string{"snapshot_id": snap, "task_id": core.NewID()}, zap.NewNop()); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := TreeDigest(ctx, root); got != res["tree_digest"] {
+				t.Errorf("relocked snapshot digests %s, want %s", got, res["tree_digest"])
+			}
+
+			// set_current hands out a live tree with the original modes.
+			if _, err := s.setCurrent(ctx, replTestWSID, map[string]string{"snapshot_id": snap, "new_live_id": "l2", "task_id": core.NewID()}, zap.NewNop()); err != nil {
+				t.Fatal(err)
+			}
+			l2 := filepath.Join(wsRoot, "live", "l2")
+			assertSameTree(t, live, l2)
+			if _, err := os.Lstat(filepath.Join(l2, filepath.FromSlash(lockRecordPath))); !os.IsNotExist(err) {
+				t.Errorf("live tree kept the lock record: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(l2, "dir", "sub", "new.txt"), []byte("new"), 0644); err != nil {
+				t.Errorf("live tree not writable: %v", err)
+			}
+
+			if _, err := s.snapshotDrop(ctx, replTestWSID, map[string]string{"snapshot_id": snap}, zap.NewNop()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Lstat(root); !os.IsNotExist(err) {
+				t.Errorf("snapshot still there after drop: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package executor
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/observability"
+)
+
+// CloneEstimate is the expected cost of cloning a directory tree.
+type CloneEstimate struct {
+	Entries uint64 // files, directories and symlinks, i.e. inodes to allocate
+	Bytes   int64  // apparent size of regular files
+}
+
+// EstimateClone walks src and counts what a clone of it would allocate.
+func EstimateClone(src string) (CloneEstimate, error) {
+	var est CloneEstimate
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		est.Entries++
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			est.Bytes += info.Size()
+		}
+		return nil
+	})
+	return est, err
+}
+
+// preflightClone checks that cloning every src leaves the configured reserves
+// free under MountPath. It must run before quiescing so a full disk fails fast
+// without pausing the guest or leaving a half-written clone behind.
+func (s *Server) preflightClone(log *zap.Logger, srcs ...string) error {
+	var est CloneEstimate
+	for _, src := range srcs {
+		e, err := EstimateClone(src)
+		if err != nil {
+			return fmt.Errorf("estimate clone: %w", err)
+		}
+		est.Entries += e.Entries
+		est.Bytes += e.Bytes
+	}
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(s.cfg.MountPath, &st); err != nil {
+		return fmt.Errorf("statfs %s: %w", s.cfg.MountPath, err)
+	}
+	availBytes := st.Bavail * uint64(st.Bsize)
+
+	needBytes := uint64(float64(est.Bytes)*s.cfg.PreflightCloneByteRatio) + s.cfg.PreflightMinFreeBytes
+	if availBytes < needBytes {
+		observability.PreflightRejectTotal.WithLabelValues("bytes").Inc()
+		return fmt.Errorf("%w: need %d bytes free under %s, have %d",
+			ErrInsufficientSpace, needBytes, s.cfg.MountPath, availBytes)
+	}
+
+	// Some FUSE filesystems report no inode limit at all.
+	if st.Files > 0 {
+		needInodes := est.Entries + s.cfg.PreflightMinFreeInodes
+		if st.Ffree < needInodes {
+			observability.PreflightRejectTotal.WithLabelValues("inodes").Inc()
+			return fmt.Errorf("%w: need %d free inodes under %s, have %d",
+				ErrInsufficientSpace, needInodes, s.cfg.MountPath, st.Ffree)
+		}
+	}
+
+	log.Info("preflight: ok",
+		zap.Uint64("entries", est.Entries), zap.Int64("bytes", est.Bytes),
+		zap.Uint64("avail_bytes", availBytes), zap.Uint64("free_inodes", st.Ffree))
+	return nil
+}
+
+// preflightWrite checks that writing n new bytes under MountPath leaves the
+// configured byte reserve free. Unlike a clone, nothing is shared.
+func (s *Server) preflightWrite(log *zap.Logger, n int64) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(s.cfg.MountPath, &st); err != nil {
+		return fmt.Errorf("statfs %s: %w", s.cfg.MountPath, err)
+	}
+	availBytes := st.Bavail * uint64(st.Bsize)
+	needBytes := uint64(n) + s.cfg.PreflightMinFreeBytes
+	if availBytes < needBytes {
+		observability.PreflightRejectTotal.WithLabelValues("bytes").Inc()
+		return fmt.Errorf("%w: need %d bytes free under %s, have %d",
+			ErrInsufficientSpace, needBytes, s.cfg.MountPath, availBytes)
+	}
+	log.Info("preflight: ok", zap.Int64("bytes", n), zap.Uint64("avail_bytes", availBytes))
+	return nil
+}
+
+// unpackBudget bounds the bytes an import may write, whether staging an
+// uploaded archive or unpacking one. The archive's size says little about
+// the latter, so regular files are charged as they are written.
+type unpackBudget struct {
+	left int64
+	err  error // what running out means: a full disk or too big an archive
+}
+
+// newUnpackBudget allows what is free under MountPath beyond the configured
+// byte reserve, or ImportMaxBytes if that is set and lower.
+func (s *Server) newUnpackBudget() (*unpackBudget, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(s.cfg.MountPath, &st); err != nil {
+		return nil, fmt.Errorf("statfs %s: %w", s.cfg.MountPath, err)
+	}
+	b := &unpackBudget{err: ErrInsufficientSpace}
+	if avail := st.Bavail * uint64(st.Bsize); avail > s.cfg.PreflightMinFreeBytes {
+		b.left = int64(min(avail-s.cfg.PreflightMinFreeBytes, math.MaxInt64))
+	}
+	if limit := s.cfg.ImportMaxBytes; limit > 0 && limit < b.left {
+		b.left, b.err = limit, ErrInvalidParams
+	}
+	return b, nil
+}
+
+// take charges n bytes of the entry name to the budget.
+func (b *unpackBudget) take(name string, n int64) error {
+	if n > b.left {
+		if b.err == ErrInsufficientSpace {
+			observability.PreflightRejectTotal.WithLabelValues("bytes").Inc()
+		}
+		return fmt.Errorf("%w: writing %q would exceed the import's limit of %d more bytes", b.err, name, b.left)
+	}
+	b.left -= n
+	return nil
+}
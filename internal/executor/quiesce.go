@@ -9,6 +9,9 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/observability"
 )
 
 type QuiesceState string
@@ -20,25 +23,84 @@ const (
 	QuiesceRequestResume QuiesceState = "REQUEST_RESUME"
 )
 
+// QuiesceProtocolVersion is the version of the control protocol the
+// executor speaks. Guest agents announcing another one are ignored.
+const QuiesceProtocolVersion = 2
+
+// ControlFile is .wvs/control.json in the workspace root. Only the executor
+// writes it. Every freeze request gets a new, larger Seq; the resume that
+// ends it repeats that Seq.
 type ControlFile struct {
+	Version   int          `json:"version"`
 	State     QuiesceState `json:"state"`
+	Seq       int64        `json:"seq"`
 	Timestamp string       `json:"timestamp"`
 	TaskID    string       `json:"task_id,omitempty"`
 }
 
-// Quiesce writes REQUEST_FREEZE to control.json and polls for FROZEN ack.
-// Returns nil on success. On timeout, writes REQUEST_RESUME and returns error.
-// If no guest workload is running (control.json didn't exist, FROZEN, or REQUEST_RESUME), auto-succeeds.
-func Quiesce(ctx context.Context, wsRoot string, taskID string, timeout time.Duration, log *zap.Logger) error {
+// AgentFile is .wvs/agent.json in the workspace root. Only the guest agent
+// writes it: creating it registers the agent, Heartbeat (RFC 3339) shows it
+// is alive, and State FROZEN with AckSeq acknowledges the freeze request
+// with that Seq. An ack for any other Seq is stale.
+type AgentFile struct {
+	ProtocolVersion int          `json:"protocol_version"`
+	AgentID         string       `json:"agent_id,omitempty"`
+	Heartbeat       string       `json:"heartbeat"`
+	State           QuiesceState `json:"state"`
+	AckSeq          int64        `json:"ack_seq"`
+}
+
+// QuiesceOptions configures one Quiesce.
+type QuiesceOptions struct {
+	// Policy is the workspace's; empty means core.DefaultQuiescePolicy.
+	Policy  core.QuiescePolicy
+	Timeout time.Duration
+	// HeartbeatTTL is how stale the agent's heartbeat may be before the
+	// agent is taken for dead.
+	HeartbeatTTL time.Duration
+}
+
+// quiesceOptions returns the options for a task's quiesce: its workspace's
+// policy if the worker passed one, else the executor's default.
+func (s *Server) quiesceOptions(params map[string]string) QuiesceOptions {
+	policy := params["quiesce_policy"]
+	if policy == "" {
+		policy = s.cfg.QuiescePolicy
+	}
+	return QuiesceOptions{
+		Policy:       core.QuiescePolicy(policy),
+		Timeout:      s.cfg.QuiesceTimeout,
+		HeartbeatTTL: s.cfg.QuiesceHeartbeatTTL,
+	}
+}
+
+// Quiesce asks the workspace's guest agent to freeze and waits for it to
+// acknowledge. It writes REQUEST_FREEZE with a new sequence number to
+// control.json and polls agent.json for a FROZEN ack of that number from a
+// live, registered agent. On timeout it writes REQUEST_RESUME.
+//
+// What happens without such an ack depends on the policy: require-guest
+// fails with ErrQuiesceTimeout, best-effort carries on (immediately if no
+// live agent is registered), and none never asks.
+func Quiesce(ctx context.Context, wsRoot string, taskID string, opts QuiesceOptions, log *zap.Logger) error {
+	policy, err := core.ParseQuiescePolicy(string(opts.Policy))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	if policy == core.QuiesceNone {
+		log.Info("quiesce: policy none, skipping")
+		observability.QuiesceTotal.WithLabelValues("skipped").Inc()
+		return nil
+	}
+
 	controlPath := filepath.Join(wsRoot, ".wvs", "control.json")
+	agentPath := filepath.Join(wsRoot, ".wvs", "agent.json")
 
-	// Check if control file already exists and its current state
-	existingState := QuiesceState("")
-	if data, err := os.ReadFile(controlPath); err == nil {
-		var cf ControlFile
-		if err := json.Unmarshal(data, &cf); err == nil {
-			existingState = cf.State
-		}
+	agent, _ := readAgent(agentPath)
+	if alive, why := agentAlive(agent, opts.HeartbeatTTL); !alive && policy == core.QuiesceBestEffort {
+		log.Info("quiesce: no live guest agent, proceeding", zap.String("reason", why))
+		observability.QuiesceTotal.WithLabelValues("no_guest").Inc()
+		return nil
 	}
 
 	// Ensure .wvs directory exists
@@ -46,59 +108,99 @@ func Quiesce(ctx context.Context, wsRoot string, taskID string, timeout time.Dur
 		return fmt.Errorf("mkdir .wvs: %w", err)
 	}
 
-	// If no prior control file existed, or state is FROZEN/REQUEST_RESUME (no active guest),
-	// there's no active guest workload - auto-succeed
-	if existingState == "" || existingState == QuiesceFrozen || existingState == QuiesceRequestResume {
-		log.Info("quiesce: no guest workload detected, auto-succeeding")
-		// Write FROZEN state directly to indicate ready for snapshot
-		if err := writeControl(controlPath, QuiesceFrozen, taskID); err != nil {
-			return fmt.Errorf("write FROZEN: %w", err)
-		}
-		return nil
+	// Sequence numbers only grow, past any ack on record, so an ack left
+	// over from an earlier request can never match this one.
+	seq := int64(0)
+	if cf, err := readControl(controlPath); err == nil {
+		seq = cf.Seq
+	}
+	if agent != nil && agent.AckSeq > seq {
+		seq = agent.AckSeq
 	}
+	seq++
 
 	// Write REQUEST_FREEZE
-	if err := writeControl(controlPath, QuiesceRequestFreeze, taskID); err != nil {
+	if err := writeControl(controlPath, QuiesceRequestFreeze, seq, taskID); err != nil {
 		return fmt.Errorf("write REQUEST_FREEZE: %w", err)
 	}
-	log.Info("quiesce: REQUEST_FREEZE written", zap.String("path", controlPath))
+	log.Info("quiesce: REQUEST_FREEZE written", zap.String("path", controlPath), zap.Int64("seq", seq))
 
 	// Poll for FROZEN
-	deadline := time.Now().Add(timeout)
+	deadline := time.Now().Add(opts.Timeout)
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			_ = writeControl(controlPath, QuiesceRequestResume, taskID)
+			_ = writeControl(controlPath, QuiesceRequestResume, seq, taskID)
 			return ctx.Err()
 		case <-ticker.C:
-			if time.Now().After(deadline) {
-				_ = writeControl(controlPath, QuiesceRequestResume, taskID)
-				return fmt.Errorf("quiesce timeout after %s", timeout)
+			agent, _ := readAgent(agentPath)
+			alive, why := agentAlive(agent, opts.HeartbeatTTL)
+			if alive {
+				if agent.State == QuiesceFrozen && agent.AckSeq == seq {
+					log.Info("quiesce: FROZEN ack received", zap.String("agent_id", agent.AgentID), zap.Int64("seq", seq))
+					observability.QuiesceTotal.WithLabelValues("acked").Inc()
+					return nil
+				}
+				why = "agent did not acknowledge"
 			}
-			state, err := readControlState(controlPath)
-			if err != nil {
+			if time.Now().Before(deadline) {
 				continue
 			}
-			if state == QuiesceFrozen {
-				log.Info("quiesce: FROZEN ack received")
+			_ = writeControl(controlPath, QuiesceRequestResume, seq, taskID)
+			observability.QuiesceTotal.WithLabelValues("timeout").Inc()
+			if policy == core.QuiesceBestEffort {
+				log.Warn("quiesce: no ack in time, proceeding unfrozen", zap.String("reason", why), zap.Duration("timeout", opts.Timeout))
 				return nil
 			}
+			return fmt.Errorf("%w after %s: %s", ErrQuiesceTimeout, opts.Timeout, why)
 		}
 	}
 }
 
-// Resume writes REQUEST_RESUME to control.json.
+// Resume writes REQUEST_RESUME to control.json if a freeze request is
+// outstanding, repeating its sequence number.
 func Resume(wsRoot string, taskID string) error {
 	controlPath := filepath.Join(wsRoot, ".wvs", "control.json")
-	return writeControl(controlPath, QuiesceRequestResume, taskID)
+	cf, err := readControl(controlPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if cf.State != QuiesceRequestFreeze {
+		return nil
+	}
+	return writeControl(controlPath, QuiesceRequestResume, cf.Seq, taskID)
 }
 
-func writeControl(path string, state QuiesceState, taskID string) error {
+// agentAlive reports whether agent is registered, speaks this protocol and
+// has a heartbeat no older than ttl; if not, it says why.
+func agentAlive(agent *AgentFile, ttl time.Duration) (bool, string) {
+	if agent == nil {
+		return false, "no agent registered"
+	}
+	if agent.ProtocolVersion != QuiesceProtocolVersion {
+		return false, fmt.Sprintf("agent speaks protocol version %d, want %d", agent.ProtocolVersion, QuiesceProtocolVersion)
+	}
+	beat, err := time.Parse(time.RFC3339Nano, agent.Heartbeat)
+	if err != nil {
+		return false, "agent heartbeat unreadable"
+	}
+	if age := time.Since(beat); age > ttl {
+		return false, fmt.Sprintf("agent heartbeat is %s old", age.Round(time.Second))
+	}
+	return true, ""
+}
+
+func writeControl(path string, state QuiesceState, seq int64, taskID string) error {
 	cf := ControlFile{
+		Version:   QuiesceProtocolVersion,
 		State:     state,
+		Seq:       seq,
 		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
 		TaskID:    taskID,
 	}
@@ -106,17 +208,31 @@ func writeControl(path string, state QuiesceState, taskID string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	return writeFileAtomic(path, data, 0644)
 }
 
-func readControlState(path string) (QuiesceState, error) {
+func readControl(path string) (*ControlFile, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	var cf ControlFile
 	if err := json.Unmarshal(data, &cf); err != nil {
-		return "", err
+		return nil, err
+	}
+	return &cf, nil
+}
+
+// readAgent returns nil and an error while the agent is unregistered or its
+// file is mid-write.
+func readAgent(path string) (*AgentFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var af AgentFile
+	if err := json.Unmarshal(data, &af); err != nil {
+		return nil, err
 	}
-	return cf.State, nil
+	return &af, nil
 }
@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+func writeAgentFile(t *testing.T, wsRoot string, af AgentFile) {
+	t.Helper()
+	data, _ := json.Marshal(af)
+	if err := os.MkdirAll(filepath.Join(wsRoot, ".wvs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wsRoot, ".wvs", "agent.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func liveAgent(state QuiesceState, ackSeq int64) AgentFile {
+	return AgentFile{
+		ProtocolVersion: QuiesceProtocolVersion,
+		AgentID:         "test",
+		Heartbeat:       time.Now().UTC().Format(time.RFC3339Nano),
+		State:           state,
+		AckSeq:          ackSeq,
+	}
+}
+
+func TestQuiesceWithoutAgent(t *testing.T) {
+	ctx := context.Background()
+	opts := QuiesceOptions{Timeout: 300 * time.Millisecond, HeartbeatTTL: time.Minute}
+
+	for _, c := range []struct {
+		policy  core.QuiescePolicy
+		agent   *AgentFile
+		wantErr bool
+	}{
+		{core.QuiesceNone, nil, false},
+		{core.QuiesceBestEffort, nil, false},
+		{core.QuiesceRequireGuest, nil, true},
+		// A crashed agent's last word was FROZEN; it must not count.
+		{core.QuiesceRequireGuest, &AgentFile{ProtocolVersion: QuiesceProtocolVersion, Heartbeat: time.Now().Add(-time.Hour).Format(time.RFC3339Nano), State: QuiesceFrozen, AckSeq: 1}, true},
+		{core.QuiesceRequireGuest, &AgentFile{ProtocolVersion: 1, Heartbeat: time.Now().Format(time.RFC3339Nano), State: QuiesceFrozen, AckSeq: 1}, true},
+	} {
+		wsRoot := t.TempDir()
+		if c.agent != nil {
+			writeAgentFile(t, wsRoot, *c.agent)
+		}
+		opts.Policy = c.policy
+		err := Quiesce(ctx, wsRoot, "t1", opts, zap.NewNop())
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s (agent %v): err = %v", c.policy, c.agent, err)
+		}
+		if c.wantErr && !errors.Is(err, ErrQuiesceTimeout) {
+			t.Errorf("%s: want ErrQuiesceTimeout, got %v", c.policy, err)
+		}
+	}
+}
+
+func TestQuiesceRejectsStaleAck(t *testing.T) {
+	ctx := context.Background()
+	wsRoot := t.TempDir()
+	opts := QuiesceOptions{Policy: core.QuiesceRequireGuest, Timeout: 300 * time.Millisecond, HeartbeatTTL: time.Minute}
+
+	// Left FROZEN by the previous request.
+	writeAgentFile(t, wsRoot, liveAgent(QuiesceFrozen, 7))
+	if err := Quiesce(ctx, wsRoot, "t1", opts, zap.NewNop()); !errors.Is(err, ErrQuiesceTimeout) {
+		t.Fatalf("stale ack accepted: %v", err)
+	}
+	cf, err := readControl(filepath.Join(wsRoot, ".wvs", "control.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cf.Seq != 8 || cf.State != QuiesceRequestResume {
+		t.Errorf("control after timeout: %+v", cf)
+	}
+}
+
+func TestQuiesceAck(t *testing.T) {
+	ctx := context.Background()
+	wsRoot := t.TempDir()
+	opts := QuiesceOptions{Policy: core.QuiesceRequireGuest, Timeout: 5 * time.Second, HeartbeatTTL: time.Minute}
+	writeAgentFile(t, wsRoot, liveAgent(QuiesceRunning, 0))
+
+	// A minimal agent: ack whatever freeze request shows up.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		controlPath := filepath.Join(wsRoot, ".wvs", "control.json")
+		for i := 0; i < 100; i++ {
+			if cf, err := readControl(controlPath); err == nil && cf.State == QuiesceRequestFreeze {
+				data, _ := json.Marshal(liveAgent(QuiesceFrozen, cf.Seq))
+				_ = os.WriteFile(filepath.Join(wsRoot, ".wvs", "agent.json"), data, 0644)
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+	if err := Quiesce(ctx, wsRoot, "t1", opts, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if err := Resume(wsRoot, "t1"); err != nil {
+		t.Fatal(err)
+	}
+	cf, err := readControl(filepath.Join(wsRoot, ".wvs", "control.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cf.State != QuiesceRequestResume || cf.Seq != 1 || cf.Version != QuiesceProtocolVersion {
+		t.Errorf("control after resume: %+v", cf)
+	}
+}
@@ -0,0 +1,284 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/observability"
+)
+
+// peerPollInterval is how often a replicate task polls the peer's import task.
+var peerPollInterval = 2 * time.Second
+
+// replicateSnapshot ships a snapshot to the peer WVS API as a zstd archive
+// that the peer imports under the same snapshot ID. With base_snapshot_id,
+// which the peer must already hold, only the files that changed since that
+// snapshot are shipped, plus the list of those deleted.
+func (s *Server) replicateSnapshot(ctx context.Context, wsid string, params map[string]string, log *zap.Logger) (map[string]string, error) {
+	snapshotID := params["snapshot_id"]
+	baseID := params["base_snapshot_id"]
+	peerURL, err := core.NormalizePeerURL(params["peer_url"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	u, _ := url.Parse(peerURL)
+	if err := s.checkPeerURL(u); err != nil {
+		return nil, err
+	}
+	client := s.peerHTTP
+	if client == nil {
+		client = restrictedClient(s.checkPeerURL)
+	}
+	peer := &peerClient{url: peerURL, wsid: params["peer_wsid"], client: client}
+
+	wsRoot := filepath.Join(s.cfg.MountPath, wsid)
+	snapRoot := filepath.Join(wsRoot, "snapshots", snapshotID)
+	if _, err := os.Stat(snapRoot); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, snapRoot)
+	}
+	var basePath string
+	if baseID != "" {
+		basePath = filepath.Join(wsRoot, "snapshots", baseID)
+		if _, err := os.Stat(basePath); os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, basePath)
+		}
+	}
+
+	results := map[string]string{
+		"snapshot_id":      snapshotID,
+		"base_snapshot_id": baseID,
+		"peer_url":         peerURL,
+		"peer_wsid":        peer.wsid,
+	}
+
+	// Idempotency: a previous attempt's import already finished on the peer.
+	ready, err := peer.snapshotReady(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	if ready {
+		log.Info("replicate: peer already holds snapshot, noop")
+		results["bytes_shipped"] = "0"
+		return results, nil
+	}
+
+	est, err := EstimateClone(snapRoot)
+	if err != nil {
+		return nil, fmt.Errorf("estimate replication: %w", err)
+	}
+	if err := s.preflightWrite(log, est.Bytes); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(wsRoot, "replication")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	archivePath := filepath.Join(dir, snapshotID+core.CompressionZstd.ArchiveExt())
+	defer os.Remove(archivePath)
+
+	start := time.Now()
+	archive, err := writeArchiveFile(ctx, archivePath, snapRoot, basePath, core.CompressionZstd)
+	if err != nil {
+		return nil, fmt.Errorf("write archive: %w", err)
+	}
+
+	// Keyed by task, so a retried attempt picks up the peer task it started
+	// and a dead-letter retry starts afresh.
+	peerTaskID, err := peer.importArchive(ctx, archivePath, "replicate:"+params["task_id"], url.Values{
+		"snapshot_id":      {snapshotID},
+		"base_snapshot_id": {baseID},
+		"message":          {params["message"]},
+	})
+	if err != nil {
+		return nil, err
+	}
+	observability.ReplicationBytesTotal.Add(float64(archive.size))
+	log.Info("replicate: shipped",
+		zap.String("peer_task_id", peerTaskID),
+		zap.String("base_snapshot_id", baseID),
+		zap.Int64("size_bytes", archive.size),
+		zap.Int64("files_changed", archive.files),
+		zap.Int64("files_deleted", archive.deleted))
+
+	if err := peer.waitTask(ctx, peerTaskID); err != nil {
+		return nil, err
+	}
+
+	log.Info("replicate: completed", zap.Float64("duration_s", time.Since(start).Seconds()))
+	results["peer_task_id"] = peerTaskID
+	results["bytes_shipped"] = strconv.FormatInt(archive.size, 10)
+	results["files_changed"] = strconv.FormatInt(archive.files, 10)
+	results["files_deleted"] = strconv.FormatInt(archive.deleted, 10)
+	return results, nil
+}
+
+// checkPeerURL checks a peer API URL, or a redirect target, against
+// EXECUTOR_REPLICATION_PEER_ALLOWLIST, whose entries match hosts the way
+// import allowlist entries do.
+func (s *Server) checkPeerURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: peer scheme %q", ErrInvalidParams, u.Scheme)
+	}
+	if !core.ImportHostAllowed(u.Hostname(), s.cfg.ReplicationPeerAllowlist) {
+		return fmt.Errorf("%w: peer host %q is not on EXECUTOR_REPLICATION_PEER_ALLOWLIST", ErrInvalidParams, u.Hostname())
+	}
+	return nil
+}
+
+// peerClient talks to the public API of the peer WVS deployment.
+type peerClient struct {
+	url    string
+	wsid   string
+	client *http.Client
+}
+
+// peerError is the error body the WVS API returns.
+type peerError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// snapshotReady reports whether the peer holds snapshotID, READY and not deleted.
+func (p *peerClient) snapshotReady(ctx context.Context, snapshotID string) (bool, error) {
+	var snap struct {
+		State     string `json:"state"`
+		DeletedAt string `json:"deleted_at"`
+	}
+	err := p.do(ctx, http.MethodGet, "/v1/workspaces/"+p.wsid+"/snapshots/"+snapshotID, nil, nil, &snap)
+	var httpErr *peerHTTPError
+	if errors.As(err, &httpErr) && httpErr.status == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return snap.State == string(core.SnapshotReady) && snap.DeletedAt == "", nil
+}
+
+// importArchive uploads an archive to the peer's import endpoint and
+// returns the peer's task ID.
+func (p *peerClient) importArchive(ctx context.Context, archivePath, idempotencyKey string, query url.Values) (string, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	for k, v := range query {
+		if v[0] == "" {
+			delete(query, k)
+		}
+	}
+	headers := http.Header{
+		"Content-Type":    {"application/zstd"},
+		"Idempotency-Key": {idempotencyKey},
+	}
+	var accepted struct {
+		TaskID string `json:"task_id"`
+	}
+	path := "/v1/workspaces/" + p.wsid + "/snapshots:import?" + query.Encode()
+	if err := p.do(ctx, http.MethodPost, path, headers, &sizedReader{f, info.Size()}, &accepted); err != nil {
+		return "", err
+	}
+	if accepted.TaskID == "" {
+		return "", fmt.Errorf("peer import: response carries no task_id")
+	}
+	return accepted.TaskID, nil
+}
+
+// waitTask polls a peer task until it ends, failing unless it succeeded.
+func (p *peerClient) waitTask(ctx context.Context, taskID string) error {
+	for {
+		var task struct {
+			Status string                 `json:"status"`
+			Error  map[string]interface{} `json:"error"`
+		}
+		if err := p.do(ctx, http.MethodGet, "/v1/tasks/"+taskID, nil, nil, &task); err != nil {
+			return err
+		}
+		switch core.TaskStatus(task.Status) {
+		case core.TaskSucceeded:
+			return nil
+		case core.TaskDead, core.TaskCanceled:
+			return fmt.Errorf("peer import task %s %s: %v", taskID, task.Status, task.Error["error"])
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(peerPollInterval):
+		}
+	}
+}
+
+// sizedReader lets an upload carry a Content-Length.
+type sizedReader struct {
+	io.Reader
+	size int64
+}
+
+// peerHTTPError is a non-2xx answer from the peer.
+type peerHTTPError struct {
+	status int
+	body   peerError
+}
+
+func (e *peerHTTPError) Error() string {
+	return fmt.Sprintf("peer returned %d %s: %s", e.status, e.body.Code, e.body.Message)
+}
+
+func (p *peerClient) do(ctx context.Context, method, path string, headers http.Header, body *sizedReader, out interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		rd = body
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.url+path, rd)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	if body != nil {
+		req.ContentLength = body.size
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("peer %s %s: %w", method, path, err)
+		if errors.Is(err, errRestrictedAddress) {
+			return fmt.Errorf("%w: %w", ErrInvalidParams, err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		httpErr := &peerHTTPError{status: resp.StatusCode}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&httpErr.body)
+		switch resp.StatusCode {
+		case http.StatusBadRequest, http.StatusNotFound, http.StatusGone, http.StatusUnprocessableEntity:
+			// The peer workspace or the request is wrong; retrying will not fix it.
+			return fmt.Errorf("%w: %w", ErrInvalidParams, httpErr)
+		}
+		return httpErr
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("peer %s %s: decode response: %w", method, path, err)
+	}
+	return nil
+}
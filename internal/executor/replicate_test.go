@@ -0,0 +1,358 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+// Two executors on local directories: the source replicates into the peer
+// through a fake of the peer's public API that runs imports inline.
+
+const replTestWSID = "ws-repl"
+
+func newLocalServer(t *testing.T) *Server {
+	t.Helper()
+	mount := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(mount, replTestWSID, "snapshots"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(Config{MountPath: mount, CloneMode: CloneModeCopy}, zap.NewNop())
+}
+
+func (s *Server) snapshotPath(id string) string {
+	return filepath.Join(s.cfg.MountPath, replTestWSID, "snapshots", id)
+}
+
+type fakePeer struct {
+	t      *testing.T
+	exec   *Server
+	mu     sync.Mutex
+	tasks  map[string]core.TaskStatus
+	ready  map[string]bool
+	byKey  map[string]string
+	params []map[string]string
+}
+
+func newFakePeer(t *testing.T, exec *Server) *httptest.Server {
+	p := &fakePeer{
+		t:     t,
+		exec:  exec,
+		tasks: map[string]core.TaskStatus{},
+		ready: map[string]bool{},
+		byKey: map[string]string{},
+	}
+	srv := httptest.NewServer(p)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// trustPeer lets src replicate to peer, a loopback server the restricted
+// peer client would refuse.
+func trustPeer(src *Server, peer *httptest.Server) {
+	u, _ := url.Parse(peer.URL)
+	src.cfg.ReplicationPeerAllowlist = []string{u.Hostname()}
+	src.peerHTTP = peer.Client()
+}
+
+func (p *fakePeer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prefix := "/v1/workspaces/" + replTestWSID + "/snapshots"
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/tasks/"):
+		status, ok := p.tasks[strings.TrimPrefix(r.URL.Path, "/v1/tasks/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": string(status)})
+
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, prefix+"/"):
+		if !p.ready[strings.TrimPrefix(r.URL.Path, prefix+"/")] {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"code": "WVS_NOT_FOUND", "message": "snapshot not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"state": string(core.SnapshotReady)})
+
+	case r.Method == http.MethodPost && r.URL.Path == prefix+":import":
+		if taskID, ok := p.byKey[r.Header.Get("Idempotency-Key")]; ok {
+			json.NewEncoder(w).Encode(map[string]string{"task_id": taskID})
+			return
+		}
+		q := r.URL.Query()
+		snapshotID := q.Get("snapshot_id")
+		taskID := core.NewID()
+		wsRoot := filepath.Join(p.exec.cfg.MountPath, replTestWSID)
+		staged := stagedImportPath(wsRoot, taskID)
+		if err := os.MkdirAll(filepath.Dir(staged), 0755); err != nil {
+			p.t.Error(err)
+		}
+		f, err := os.Create(staged)
+		if err != nil {
+			p.t.Error(err)
+			return
+		}
+		_, _ = io.Copy(f, r.Body)
+		f.Close()
+
+		params := map[string]string{
+			"snapshot_id":      snapshotID,
+			"source":           core.ImportSourceUpload,
+			"message":          q.Get("message"),
+			"base_snapshot_id": q.Get("base_snapshot_id"),
+			"upload_task_id":   taskID,
+		}
+		p.params = append(p.params, params)
+		p.byKey[r.Header.Get("Idempotency-Key")] = taskID
+		if _, err := p.exec.importSnapshot(r.Context(), replTestWSID, params, zap.NewNop()); err != nil {
+			p.t.Logf("peer import failed: %v", err)
+			p.tasks[taskID] = core.TaskDead
+		} else {
+			p.tasks[taskID] = core.TaskSucceeded
+			p.ready[snapshotID] = true
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"task_id": taskID})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// treeOf lists every entry under root but snapshot.json, which each side
+// writes for itself.
+func treeOf(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := map[string]string{}
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		if rel == "." || rel == filepath.Join(".wvs", "snapshot.json") {
+			return nil
+		}
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, _ := os.Readlink(p)
+			tree[rel] = "link:" + target
+		case fi.IsDir():
+			tree[rel] = "dir:" + fi.Mode().Perm().String()
+		default:
+			data, _ := os.ReadFile(p)
+			tree[rel] = "file:" + fi.Mode().Perm().String() + ":" + string(data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func assertSameTree(t *testing.T, want, got string) {
+	t.Helper()
+	w, g := treeOf(t, want), treeOf(t, got)
+	for name, v := range w {
+		if g[name] != v {
+			t.Errorf("%s: want %q, got %q", name, v, g[name])
+		}
+	}
+	for name := range g {
+		if _, ok := w[name]; !ok {
+			t.Errorf("%s: unexpected on peer", name)
+		}
+	}
+}
+
+func TestReplicateFullThenDelta(t *testing.T) {
+	peerPollInterval = 10 * time.Millisecond
+	ctx := context.Background()
+	log := zap.NewNop()
+
+	src := newLocalServer(t)
+	dst := newLocalServer(t)
+	peer := newFakePeer(t, dst)
+	trustPeer(src, peer)
+
+	// Full: no base.
+	snapA := core.NewID()
+	writeTree(t, src.snapshotPath(snapA), map[string]string{
+		"README.md":          "hello",
+		"src/main.go":        "package main",
+		"src/util/util.go":   "package util",
+		"data/keep.txt":      "keep",
+		"data/gone.txt":      "gone",
+		"old/a.txt":          "a",
+		"old/b/c.txt":        "c",
+		"swap/file":          "becomes a dir",
+		"tree/x.txt":         "becomes a file",
+		".wvs/snapshot.json": "{}",
+	})
+	if err := os.Symlink("README.md", filepath.Join(src.snapshotPath(snapA), "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	params := map[string]string{
+		"task_id":     core.NewID(),
+		"snapshot_id": snapA,
+		"peer_url":    peer.URL,
+		"peer_wsid":   replTestWSID,
+	}
+	res, err := src.replicateSnapshot(ctx, replTestWSID, params, log)
+	if err != nil {
+		t.Fatalf("replicate full: %v", err)
+	}
+	if res["files_deleted"] != "0" {
+		t.Errorf("full replica deleted %s files", res["files_deleted"])
+	}
+	assertSameTree(t, src.snapshotPath(snapA), dst.snapshotPath(snapA))
+
+	// Delta: B is a copy of A with changes of every kind.
+	snapB := core.NewID()
+	if err := CopyTree(ctx, src.snapshotPath(snapA), src.snapshotPath(snapB), "test", log); err != nil {
+		t.Fatal(err)
+	}
+	b := src.snapshotPath(snapB)
+	for _, p := range []string{"data/gone.txt", "old", "tree", "swap/file"} {
+		if err := os.RemoveAll(filepath.Join(b, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later := time.Now().Add(time.Hour)
+	writeTree(t, b, map[string]string{
+		"README.md":    "HELLO", // same size, new content
+		"src/new.go":   "package main // new",
+		"swap/file/in": "now a dir",
+		"tree":         "now a file",
+	})
+	_ = os.Chtimes(filepath.Join(b, "README.md"), later, later)
+	if err := os.Chmod(filepath.Join(b, "src/main.go"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(b, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("src", filepath.Join(b, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	params = map[string]string{
+		"task_id":          core.NewID(),
+		"snapshot_id":      snapB,
+		"base_snapshot_id": snapA,
+		"peer_url":         peer.URL,
+		"peer_wsid":        replTestWSID,
+	}
+	res, err = src.replicateSnapshot(ctx, replTestWSID, params, log)
+	if err != nil {
+		t.Fatalf("replicate delta: %v", err)
+	}
+	assertSameTree(t, b, dst.snapshotPath(snapB))
+
+	// README.md, src/main.go (mode), src/new.go, swap/file/in and tree.
+	if res["files_changed"] != "5" {
+		t.Errorf("files_changed = %q, want 5", res["files_changed"])
+	}
+	if res["files_deleted"] != "2" {
+		t.Errorf("files_deleted = %q, want 2 (data/gone.txt, old)", res["files_deleted"])
+	}
+	// The base on the peer is untouched.
+	assertSameTree(t, src.snapshotPath(snapA), dst.snapshotPath(snapA))
+
+	// Replicating again finds the snapshot on the peer and ships nothing.
+	params["task_id"] = core.NewID()
+	res, err = src.replicateSnapshot(ctx, replTestWSID, params, log)
+	if err != nil {
+		t.Fatalf("replicate again: %v", err)
+	}
+	if res["bytes_shipped"] != "0" {
+		t.Errorf("bytes_shipped = %q, want 0", res["bytes_shipped"])
+	}
+}
+
+func TestReplicatePeerWorkspaceMissing(t *testing.T) {
+	src := newLocalServer(t)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"code": "WVS_NOT_FOUND", "message": "workspace not found"})
+	}))
+	defer peer.Close()
+	trustPeer(src, peer)
+
+	snap := core.NewID()
+	writeTree(t, src.snapshotPath(snap), map[string]string{"a.txt": "a"})
+	_, err := src.replicateSnapshot(context.Background(), replTestWSID, map[string]string{
+		"task_id":     core.NewID(),
+		"snapshot_id": snap,
+		"peer_url":    peer.URL,
+		"peer_wsid":   replTestWSID,
+	}, zap.NewNop())
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "workspace not found") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestReplicatePeerRestricted(t *testing.T) {
+	src := newLocalServer(t)
+	hits := 0
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer peer.Close()
+	u, _ := url.Parse(peer.URL)
+
+	snap := core.NewID()
+	writeTree(t, src.snapshotPath(snap), map[string]string{"a.txt": "a"})
+	params := map[string]string{
+		"task_id":     core.NewID(),
+		"snapshot_id": snap,
+		"peer_url":    peer.URL,
+		"peer_wsid":   replTestWSID,
+	}
+	if _, err := src.replicateSnapshot(context.Background(), replTestWSID, params, zap.NewNop()); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("peer not on the allowlist: %v", err)
+	}
+
+	// Allowlisting the name does not make a loopback address reachable.
+	src.cfg.ReplicationPeerAllowlist = []string{u.Hostname()}
+	if _, err := src.replicateSnapshot(context.Background(), replTestWSID, params, zap.NewNop()); !errors.Is(err, errRestrictedAddress) {
+		t.Errorf("loopback peer: %v", err)
+	}
+	if hits != 0 {
+		t.Errorf("peer was reached %d times", hits)
+	}
+}
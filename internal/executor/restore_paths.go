@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/observability"
+)
+
+// restorePaths copies the requested files and subtrees from a snapshot into
+// the live tree current points at. Each path is cloned next to its
+// destination and renamed into place, so the guest never sees a half-copied
+// file; paths not listed are left untouched.
+func (s *Server) restorePaths(ctx context.Context, wsid string, params map[string]string, log *zap.Logger) (map[string]string, error) {
+	snapshotID := params["snapshot_id"]
+	taskID := params["task_id"]
+	wsRoot := filepath.Join(s.cfg.MountPath, wsid)
+
+	paths, err := core.DecodeRestorePaths(params["paths"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+
+	snapRoot, err := filepath.EvalSymlinks(filepath.Join(wsRoot, "snapshots", snapshotID))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, snapshotID)
+	} else if err != nil {
+		return nil, fmt.Errorf("resolve snapshot: %w", err)
+	}
+	liveRoot, err := filepath.EvalSymlinks(filepath.Join(wsRoot, "current"))
+	if err != nil {
+		return nil, fmt.Errorf("resolve current symlink: %w", err)
+	}
+
+	srcs := make([]string, len(paths))
+	for i, rel := range paths {
+		src, err := resolveWithin(snapRoot, rel)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s not in snapshot %s", ErrInvalidParams, rel, snapshotID)
+		} else if err != nil {
+			return nil, err
+		}
+		srcs[i] = src
+	}
+
+	if err := s.preflightClone(log, srcs...); err != nil {
+		return nil, err
+	}
+
+	// Quiesce
+	start := time.Now()
+	if err := Quiesce(ctx, wsRoot, taskID, s.quiesceOptions(params), log); err != nil {
+		observability.QuiesceTimeoutTotal.Inc()
+		return nil, fmt.Errorf("quiesce failed: %w", err)
+	}
+	observability.QuiesceWaitSeconds.Observe(time.Since(start).Seconds())
+	defer func() { _ = Resume(wsRoot, taskID) }()
+
+	for i, rel := range paths {
+		// Resolve again under quiesce: the guest may have moved things since.
+		dst, err := resolveWithin(liveRoot, rel)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.replacePath(ctx, snapRoot, srcs[i], dst, taskID, log); err != nil {
+			return nil, fmt.Errorf("restore %s: %w", rel, err)
+		}
+	}
+
+	log.Info("restore_paths: completed", zap.Int("paths", len(paths)))
+	return map[string]string{
+		"current_path":   liveRoot,
+		"restored_count": strconv.Itoa(len(paths)),
+	}, nil
+}
+
+// resolveWithin joins rel onto root and checks that the parent directory,
+// once symlinks are followed, is still inside root. The last element is not
+// followed: restore replaces or copies it as-is.
+func resolveWithin(root, rel string) (string, error) {
+	joined := filepath.Join(root, rel)
+	if !isWithin(root, joined) {
+		return "", fmt.Errorf("%w: path %q escapes the workspace", ErrInvalidParams, rel)
+	}
+	// Resolve the deepest ancestor that exists; missing ones are created later.
+	for dir := filepath.Dir(joined); ; dir = filepath.Dir(dir) {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			if !isWithin(root, resolved) {
+				return "", fmt.Errorf("%w: path %q escapes the workspace via a symlink", ErrInvalidParams, rel)
+			}
+			return joined, nil
+		}
+		if !os.IsNotExist(err) || dir == root {
+			return "", fmt.Errorf("resolve %s: %w", dir, err)
+		}
+	}
+}
+
+func isWithin(root, p string) bool {
+	return p == root || strings.HasPrefix(p, root+string(filepath.Separator))
+}
+
+// replacePath clones src, a path inside the snapshot at snapRoot, to a
+// temporary sibling of dst and renames it over dst. A directory in the way
+// is moved aside first, since rename(2) will not replace a non-empty
+// directory.
+func (s *Server) replacePath(ctx context.Context, snapRoot, src, dst, taskID string, log *zap.Logger) error {
+	tmp := dst + ".wvs-restore-" + taskID
+	old := dst + ".wvs-old-" + taskID
+
+	// Clear leftovers from an earlier attempt of this task.
+	_ = os.RemoveAll(tmp)
+	_ = os.RemoveAll(old)
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := s.clone(ctx, src, tmp, "restore_paths", log); err != nil {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+	if err := unlockClone(ctx, snapRoot, src, tmp); err != nil {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+
+	srcInfo, err := os.Lstat(tmp)
+	if err != nil {
+		return err
+	}
+	if dstInfo, err := os.Lstat(dst); err == nil && (dstInfo.IsDir() || srcInfo.IsDir()) {
+		if err := os.Rename(dst, old); err != nil {
+			_ = os.RemoveAll(tmp)
+			return err
+		}
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		// Put the original back rather than leave dst missing.
+		_ = os.Rename(old, dst)
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+	_ = os.RemoveAll(old)
+	return nil
+}
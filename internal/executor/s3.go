@@ -0,0 +1,206 @@
+package executor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Client is a minimal path-style S3 client: single-part PUT and GET,
+// signed with AWS Signature Version 4. It is enough for MinIO and AWS.
+type s3Client struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	http      *http.Client
+}
+
+// exportS3 returns the export bucket client, or nil if none is configured.
+func (s *Server) exportS3() (*s3Client, error) {
+	if s.cfg.ExportS3Bucket == "" {
+		return nil, nil
+	}
+	endpoint := s.cfg.ExportS3Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.cfg.ExportS3Region + ".amazonaws.com"
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("export s3 endpoint: %w", err)
+	}
+	return &s3Client{
+		endpoint:  u,
+		region:    s.cfg.ExportS3Region,
+		bucket:    s.cfg.ExportS3Bucket,
+		accessKey: s.cfg.ExportS3AccessKey,
+		secretKey: s.cfg.ExportS3SecretKey,
+		http:      &http.Client{},
+	}, nil
+}
+
+// EnsureBucket creates the bucket if it does not already exist.
+func (c *s3Client) EnsureBucket(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.objectURL(""), nil)
+	if err != nil {
+		return err
+	}
+	c.sign(req, emptyPayloadHash, time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3 create bucket %s: %w", c.bucket, err)
+	}
+	defer resp.Body.Close()
+	// 409 is BucketAlreadyOwnedByYou (or, on AWS, owned by someone else; the
+	// upload that follows reports that properly).
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 create bucket %s: %s: %s", c.bucket, resp.Status, body)
+	}
+	return nil
+}
+
+// PutFile uploads the file at path as key. payloadHash is its hex SHA-256.
+func (c *s3Client) PutFile(ctx context.Context, key, path, payloadHash string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.objectURL(key), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	c.sign(req, payloadHash, time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3 put %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 put %s: %s: %s", key, resp.Status, body)
+	}
+	return nil
+}
+
+// Get opens key for reading. The caller closes the body.
+func (c *s3Client) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, emptyPayloadHash, time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 get %s: %w", key, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("s3 get %s: %w", key, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("s3 get %s: %s: %s", key, resp.Status, body)
+	}
+	return resp.Body, nil
+}
+
+// Location is the s3:// URL recorded for key.
+func (c *s3Client) Location(key string) string {
+	return "s3://" + c.bucket + "/" + key
+}
+
+func (c *s3Client) objectURL(key string) string {
+	u := *c.endpoint
+	u.Path = "/" + c.bucket + "/" + key
+	u.RawPath = "/" + uriEncode(c.bucket) + "/" + uriEncodePath(key)
+	return u.String()
+}
+
+// sign adds SigV4 headers to req.
+func (c *s3Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncodePath encodes each segment of an object key as SigV4 requires.
+func uriEncodePath(key string) string {
+	segs := strings.Split(key, "/")
+	for i, s := range segs {
+		segs[i] = uriEncode(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+// uriEncode percent-encodes everything but the SigV4 unreserved characters.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -3,6 +3,8 @@ package executor
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strconv"
 
 	"go.uber.org/zap"
 
@@ -14,6 +16,8 @@ type Server struct {
 	pb.UnimplementedExecutorServiceServer
 	cfg Config
 	log *zap.Logger
+
+	peerHTTP *http.Client // replaces the restricted peer client; tests reach loopback peers with it
 }
 
 func NewServer(cfg Config, log *zap.Logger) *Server {
@@ -31,37 +35,95 @@ func (s *Server) ExecuteTask(ctx context.Context, req *pb.ExecuteTaskRequest) (*
 	defer observability.ExecutorActiveTasks.Dec()
 
 	var results map[string]string
-	var err error
-
-	switch req.Op {
-	case pb.TaskOp_TASK_OP_INIT_WORKSPACE:
-		results, err = s.initWorkspace(ctx, req.Wsid, req.Params, log)
-	case pb.TaskOp_TASK_OP_SNAPSHOT_CREATE:
-		results, err = s.snapshotCreate(ctx, req.Wsid, req.Params, log)
-	case pb.TaskOp_TASK_OP_SNAPSHOT_DROP:
-		results, err = s.snapshotDrop(ctx, req.Wsid, req.Params, log)
-	case pb.TaskOp_TASK_OP_SET_CURRENT:
-		results, err = s.setCurrent(ctx, req.Wsid, req.Params, log)
-	default:
-		return &pb.ExecuteTaskResponse{
-			Success:      false,
-			ErrorCode:    "UNKNOWN_OP",
-			ErrorMessage: fmt.Sprintf("unknown op: %s", req.Op),
-		}, nil
+	err := validateRequest(req)
+	if err == nil {
+		switch req.Op {
+		case pb.TaskOp_TASK_OP_INIT_WORKSPACE:
+			results, err = s.initWorkspace(ctx, req.Wsid, req.Params, log)
+		case pb.TaskOp_TASK_OP_SNAPSHOT_CREATE:
+			results, err = s.snapshotCreate(ctx, req.Wsid, req.Params, log)
+		case pb.TaskOp_TASK_OP_SNAPSHOT_DROP:
+			results, err = s.snapshotDrop(ctx, req.Wsid, req.Params, log)
+		case pb.TaskOp_TASK_OP_SET_CURRENT:
+			results, err = s.setCurrent(ctx, req.Wsid, req.Params, log)
+		case pb.TaskOp_TASK_OP_CHECKPOINT_RESTORE:
+			results, err = s.checkpointRestore(ctx, req.Wsid, req.Params, log)
+		case pb.TaskOp_TASK_OP_RESTORE_PATHS:
+			results, err = s.restorePaths(ctx, req.Wsid, req.Params, log)
+		case pb.TaskOp_TASK_OP_EXPORT:
+			results, err = s.exportSnapshot(ctx, req.Wsid, req.Params, log)
+		case pb.TaskOp_TASK_OP_IMPORT:
+			results, err = s.importSnapshot(ctx, req.Wsid, req.Params, log)
+		case pb.TaskOp_TASK_OP_REPLICATE:
+			results, err = s.replicateSnapshot(ctx, req.Wsid, req.Params, log)
+		case pb.TaskOp_TASK_OP_VERIFY:
+			results, err = s.verifySnapshot(ctx, req.Wsid, req.Params, log)
+		default:
+			err = fmt.Errorf("%w: unknown op: %s", ErrInvalidParams, req.Op)
+		}
 	}
 
 	if err != nil {
-		log.Error("executor: task failed", zap.Error(err))
+		class := classifyError(err)
+		log.Error("executor: task failed", zap.Error(err), zap.String("error_code", errorCodes[class]))
 		return &pb.ExecuteTaskResponse{
 			Success:      false,
-			ErrorCode:    "EXECUTOR_ERROR",
+			ErrorCode:    errorCodes[class],
 			ErrorMessage: err.Error(),
+			ErrorClass:   class,
 		}, nil
 	}
 
+	if results == nil {
+		results = map[string]string{}
+	}
+	s.reportUsage(req, results, log)
+
 	log.Info("executor: task succeeded")
 	return &pb.ExecuteTaskResponse{
 		Success: true,
 		Results: results,
 	}, nil
 }
+
+// reportUsage adds the live directory's usage to the results of an op that
+// changed it. Measuring walks the whole live tree, so ops that leave it alone
+// report nothing and the worker keeps the last measurement. After set_current
+// and checkpoint_restore the live directory is a clone of
+// params["snapshot_id"]; after init_workspace and restore_paths it descends
+// from the current snapshot.
+func (s *Server) reportUsage(req *pb.ExecuteTaskRequest, results map[string]string, log *zap.Logger) {
+	var base string
+	switch req.Op {
+	case pb.TaskOp_TASK_OP_SNAPSHOT_CREATE:
+		// The live directory was just captured whole: none of it is unique
+		// unless the snapshot left paths out, or was copied rather than cloned.
+		if n := results["excluded_count"]; n == "" || n == "0" {
+			results["live_bytes"] = results["size_bytes"]
+			results["live_unique_bytes"] = "0"
+			if s.cfg.CloneMode == CloneModeCopy {
+				results["live_unique_bytes"] = results["size_bytes"]
+			}
+			return
+		}
+		base = req.Params["snapshot_id"]
+	case pb.TaskOp_TASK_OP_SET_CURRENT, pb.TaskOp_TASK_OP_CHECKPOINT_RESTORE:
+		base = req.Params["snapshot_id"]
+	case pb.TaskOp_TASK_OP_INIT_WORKSPACE, pb.TaskOp_TASK_OP_RESTORE_PATHS:
+		base = req.Params["current_snapshot_id"]
+	default:
+		return
+	}
+
+	usage, err := s.liveUsage(req.Wsid, base)
+	if err != nil {
+		log.Warn("executor: measure live usage failed", zap.Error(err))
+		return
+	}
+	unique := usage.SizeBytes
+	if usage.HasSharing {
+		unique = usage.UniqueBytes
+	}
+	results["live_bytes"] = strconv.FormatInt(usage.SizeBytes, 10)
+	results["live_unique_bytes"] = strconv.FormatInt(unique, 10)
+}
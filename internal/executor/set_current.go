@@ -18,7 +18,6 @@ func (s *Server) setCurrent(ctx context.Context, wsid string, params map[string]
 	wsRoot := filepath.Join(s.cfg.MountPath, wsid)
 
 	srcPath := filepath.Join(wsRoot, "snapshots", snapshotID)
-	dstPath := filepath.Join(wsRoot, "live", newLiveID)
 	relTarget := filepath.Join("live", newLiveID)
 
 	// Idempotency: if current already points to target
@@ -30,27 +29,54 @@ func (s *Server) setCurrent(ctx context.Context, wsid string, params map[string]
 
 	// Verify source snapshot exists
 	if _, err := os.Stat(srcPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("snapshot dir not found: %s", srcPath)
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, srcPath)
+	}
+
+	if err := s.preflightClone(log, srcPath); err != nil {
+		return nil, err
 	}
 
 	// Quiesce
 	start := time.Now()
-	if err := Quiesce(ctx, wsRoot, params["task_id"], s.cfg.QuiesceTimeout, log); err != nil {
+	if err := Quiesce(ctx, wsRoot, params["task_id"], s.quiesceOptions(params), log); err != nil {
 		observability.QuiesceTimeoutTotal.Inc()
 		return nil, fmt.Errorf("quiesce failed: %w", err)
 	}
 	observability.QuiesceWaitSeconds.Observe(time.Since(start).Seconds())
 	defer func() { _ = Resume(wsRoot, params["task_id"]) }()
 
-	// Clone snapshot to new live directory
-	if err := Clone(ctx, srcPath, dstPath, "set_current", log); err != nil {
+	currentPath, err := s.switchToSnapshot(ctx, wsRoot, srcPath, newLiveID, log)
+	if err != nil {
 		return nil, err
 	}
 
+	// The guest runs again before post_restore hooks, which may need it.
+	_ = Resume(wsRoot, params["task_id"])
+	results := map[string]string{"current_path": currentPath}
+	return s.runPostRestoreHooks(ctx, currentPath, hookEnv{wsid, snapshotID, "set_current"}, params, results, nil, log), nil
+}
+
+// switchToSnapshot clones srcPath into a new live directory and points
+// current at it. The caller must hold the workspace quiesced.
+func (s *Server) switchToSnapshot(ctx context.Context, wsRoot, srcPath, newLiveID string, log *zap.Logger) (string, error) {
+	dstPath := filepath.Join(wsRoot, "live", newLiveID)
+	relTarget := filepath.Join("live", newLiveID)
+
+	// Clone snapshot to new live directory
+	if err := s.clone(ctx, srcPath, dstPath, "set_current", log); err != nil {
+		// Do not leave a partial clone behind for the next attempt to trip over.
+		_ = os.RemoveAll(dstPath)
+		return "", err
+	}
+	// The live tree must be writable even if the snapshot is locked.
+	if err := unlockClone(ctx, srcPath, srcPath, dstPath); err != nil {
+		_ = os.RemoveAll(dstPath)
+		return "", err
+	}
+
 	// Atomic switch current symlink
 	if err := SwitchCurrent(wsRoot, relTarget, log); err != nil {
-		return nil, err
+		return "", err
 	}
-
-	return map[string]string{"current_path": filepath.Join(wsRoot, relTarget)}, nil
+	return filepath.Join(wsRoot, relTarget), nil
 }
@@ -3,23 +3,46 @@ package executor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/lzjever/mbos-wvs/internal/core"
 	"github.com/lzjever/mbos-wvs/internal/observability"
 )
 
+// SnapshotMeta is written to <snapshot>/.wvs/snapshot.json and echoed back
+// to the worker so the catalog carries the same facts as the filesystem.
 type SnapshotMeta struct {
-	SnapshotID string `json:"snapshot_id"`
-	WSID       string `json:"wsid"`
-	CreatedAt  string `json:"created_at"`
-	Message    string `json:"message,omitempty"`
+	SnapshotID       string `json:"snapshot_id"`
+	WSID             string `json:"wsid"`
+	TaskID           string `json:"task_id,omitempty"`
+	SourcePath       string `json:"source_path,omitempty"`
+	ParentSnapshotID string `json:"parent_snapshot_id,omitempty"`
+	CreatedAt        string `json:"created_at"`
+	Message          string `json:"message,omitempty"`
+	SizeBytes        int64  `json:"size_bytes"`
+	FileCount        int64  `json:"file_count"`
+	UniqueBytes      *int64 `json:"unique_bytes,omitempty"`
+	SharedBytes      *int64 `json:"shared_bytes,omitempty"`
+	TreeDigest       string `json:"tree_digest,omitempty"`
+
+	// What the snapshot left out: the exclude patterns applied and the
+	// top-most excluded paths, the first 1000 of ExcludedCount.
+	ExcludePatterns []string `json:"exclude_patterns,omitempty"`
+	Excluded        []string `json:"excluded,omitempty"`
+	ExcludedCount   int64    `json:"excluded_count,omitempty"`
 }
 
+// captureRecordPath holds the metadata of a snapshot cloned under quiesce
+// until finishSnapshot has measured it and written snapshot.json.
+const captureRecordPath = ".wvs/capture.json"
+
 func (s *Server) snapshotCreate(ctx context.Context, wsid string, params map[string]string, log *zap.Logger) (map[string]string, error) {
 	snapshotID := params["snapshot_id"]
 	message := params["message"]
@@ -27,10 +50,18 @@ func (s *Server) snapshotCreate(ctx context.Context, wsid string, params map[str
 	dstPath := filepath.Join(wsRoot, "snapshots", snapshotID)
 
 	// Idempotency: check if snapshot dir + meta already exist
-	metaPath := filepath.Join(dstPath, ".wvs", "snapshot.json")
-	if _, err := os.Stat(metaPath); err == nil {
+	if results, ok := existingSnapshot(dstPath); ok {
 		log.Info("snapshot_create: already exists, noop")
-		return map[string]string{"snapshot_id": snapshotID, "fs_path": dstPath}, nil
+		// A previous attempt may have stopped before or while locking it.
+		if err := s.lockSnapshot(ctx, dstPath, log); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+	// A previous attempt cloned the tree but stopped before finishing it.
+	if capturedSnapshot(dstPath) {
+		log.Info("snapshot_create: finishing snapshot captured by an earlier attempt")
+		return s.finishSnapshot(ctx, wsid, snapshotID, params, log)
 	}
 
 	// Resolve current target
@@ -40,34 +71,224 @@ func (s *Server) snapshotCreate(ctx context.Context, wsid string, params map[str
 		return nil, fmt.Errorf("resolve current symlink: %w", err)
 	}
 
+	if err := s.preflightClone(log, srcPath); err != nil {
+		return nil, err
+	}
+
+	hooks, err := s.runHooks(ctx, core.HookPreSnapshot, srcPath, hookEnv{wsid, snapshotID, "snapshot_create"}, params, log)
+	if err != nil {
+		return nil, err
+	}
+
 	// Quiesce
 	start := time.Now()
-	if err := Quiesce(ctx, wsRoot, params["task_id"], s.cfg.QuiesceTimeout, log); err != nil {
+	if err := Quiesce(ctx, wsRoot, params["task_id"], s.quiesceOptions(params), log); err != nil {
 		observability.QuiesceTimeoutTotal.Inc()
 		return nil, fmt.Errorf("quiesce failed: %w", err)
 	}
 	observability.QuiesceWaitSeconds.Observe(time.Since(start).Seconds())
-	defer func() { _ = Resume(wsRoot, params["task_id"]) }()
+	err = s.captureSnapshot(ctx, wsid, srcPath, snapshotID, message, params, log)
+	_ = Resume(wsRoot, params["task_id"])
+	if err != nil {
+		return nil, err
+	}
 
-	// Clone
-	if err := Clone(ctx, srcPath, dstPath, "snapshot_create", log); err != nil {
+	results, err := s.finishSnapshot(ctx, wsid, snapshotID, params, log)
+	if err != nil {
 		return nil, err
 	}
+	return withHookResults(results, hooks), nil
+}
+
+// captureSnapshot clones srcPath into a new snapshot directory and records
+// what it captured. The caller must hold the workspace quiesced, but only
+// for this: finishSnapshot does the rest once the guest runs again.
+func (s *Server) captureSnapshot(ctx context.Context, wsid, srcPath, snapshotID, message string, params map[string]string, log *zap.Logger) error {
+	wsRoot := filepath.Join(s.cfg.MountPath, wsid)
+	dstPath := filepath.Join(wsRoot, "snapshots", snapshotID)
+
+	// An attempt that died before its metadata was written leaves a tree
+	// existingSnapshot does not accept; start over.
+	if err := discardPartialSnapshot(ctx, dstPath, log); err != nil {
+		return err
+	}
+
+	excludes, err := snapshotExcludes(srcPath, params, log)
+	if err != nil {
+		return err
+	}
+
+	// Clone
+	if err := s.cloneExcluding(ctx, srcPath, dstPath, "snapshot_create", excludes, log); err != nil {
+		// Do not leave a partial clone behind for the next attempt to trip over.
+		_ = os.RemoveAll(dstPath)
+		return err
+	}
+	if err := dropLockRecord(dstPath); err != nil {
+		return err
+	}
 
-	// Write snapshot metadata
 	meta := SnapshotMeta{
 		SnapshotID: snapshotID,
 		WSID:       wsid,
+		TaskID:     params["task_id"],
+		SourcePath: srcPath,
 		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
 		Message:    message,
 	}
+	if excludes != nil {
+		meta.ExcludePatterns = excludes.patterns
+		meta.Excluded = excludes.paths
+		meta.ExcludedCount = excludes.count
+		log.Info("snapshot: excluded paths", zap.Int64("excluded_count", excludes.count))
+	}
 	if err := os.MkdirAll(filepath.Join(dstPath, ".wvs"), 0755); err != nil {
-		return nil, fmt.Errorf("mkdir snapshot .wvs: %w", err)
+		return fmt.Errorf("mkdir snapshot .wvs: %w", err)
+	}
+	record, _ := json.Marshal(meta)
+	if err := writeFileAtomic(filepath.Join(dstPath, captureRecordPath), record, 0644); err != nil {
+		return fmt.Errorf("write capture record: %w", err)
+	}
+	return nil
+}
+
+// capturedSnapshot reports whether dstPath holds a complete clone that
+// captureSnapshot recorded but finishSnapshot has not finished.
+func capturedSnapshot(dstPath string) bool {
+	_, err := readCaptureRecord(dstPath)
+	return err == nil
+}
+
+func readCaptureRecord(dstPath string) (SnapshotMeta, error) {
+	var meta SnapshotMeta
+	data, err := os.ReadFile(filepath.Join(dstPath, captureRecordPath))
+	if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("capture record: %w", err)
+	}
+	if err := meta.validate(filepath.Base(dstPath)); err != nil {
+		return meta, fmt.Errorf("capture record: %w", err)
+	}
+	return meta, nil
+}
+
+// finishSnapshot measures and digests a snapshot captureSnapshot cloned,
+// writes its metadata and locks it. Nothing writes to the clone any more, so
+// this walk over all of its data runs with the guest resumed.
+func (s *Server) finishSnapshot(ctx context.Context, wsid, snapshotID string, params map[string]string, log *zap.Logger) (map[string]string, error) {
+	wsRoot := filepath.Join(s.cfg.MountPath, wsid)
+	dstPath := filepath.Join(wsRoot, "snapshots", snapshotID)
+	meta, err := readCaptureRecord(dstPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var parentPath string
+	if parentID := params["parent_snapshot_id"]; parentID != "" {
+		parentPath = filepath.Join(wsRoot, "snapshots", parentID)
+	}
+	usage, err := s.snapshotUsage(dstPath, parentPath)
+	if err != nil {
+		return nil, fmt.Errorf("measure snapshot: %w", err)
+	}
+	digest, err := TreeDigest(ctx, dstPath)
+	if err != nil {
+		return nil, fmt.Errorf("digest snapshot: %w", err)
+	}
+
+	// Write snapshot metadata
+	meta.SizeBytes = usage.SizeBytes
+	meta.FileCount = usage.FileCount
+	meta.TreeDigest = digest
+	if usage.HasSharing {
+		meta.ParentSnapshotID = params["parent_snapshot_id"]
+		meta.UniqueBytes = &usage.UniqueBytes
+		meta.SharedBytes = &usage.SharedBytes
 	}
 	metaData, _ := json.MarshalIndent(meta, "", "  ")
-	if err := os.WriteFile(metaPath, metaData, 0644); err != nil {
+	if err := writeFileAtomic(filepath.Join(dstPath, ".wvs", "snapshot.json"), metaData, 0644); err != nil {
 		return nil, fmt.Errorf("write snapshot.json: %w", err)
 	}
+	if err := os.Remove(filepath.Join(dstPath, captureRecordPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := s.lockSnapshot(ctx, dstPath, log); err != nil {
+		return nil, err
+	}
 
-	return map[string]string{"snapshot_id": snapshotID, "fs_path": dstPath}, nil
+	return snapshotResults(snapshotID, dstPath, meta, metaData), nil
+}
+
+// existingSnapshot returns the results for a snapshot a previous attempt
+// already captured, or false if there is none. Metadata that does not parse
+// or does not describe this snapshot counts as none.
+func existingSnapshot(dstPath string) (map[string]string, bool) {
+	metaData, err := os.ReadFile(filepath.Join(dstPath, ".wvs", "snapshot.json"))
+	if err != nil {
+		return nil, false
+	}
+	var meta SnapshotMeta
+	if err := json.Unmarshal(metaData, &meta); err != nil {
+		return nil, false
+	}
+	if err := meta.validate(filepath.Base(dstPath)); err != nil {
+		return nil, false
+	}
+	return snapshotResults(filepath.Base(dstPath), dstPath, meta, metaData), true
+}
+
+// validate checks that m is complete metadata for snapshotID.
+func (m *SnapshotMeta) validate(snapshotID string) error {
+	if m.SnapshotID != snapshotID {
+		return fmt.Errorf("snapshot_id %q, want %q", m.SnapshotID, snapshotID)
+	}
+	if m.WSID == "" {
+		return errors.New("no wsid")
+	}
+	if _, err := time.Parse(time.RFC3339, m.CreatedAt); err != nil {
+		return fmt.Errorf("created_at: %w", err)
+	}
+	if m.SizeBytes < 0 || m.FileCount < 0 {
+		return errors.New("negative size or file count")
+	}
+	return nil
+}
+
+// discardPartialSnapshot removes whatever an earlier attempt left at
+// dstPath, so the snapshot can be captured from scratch.
+func discardPartialSnapshot(ctx context.Context, dstPath string, log *zap.Logger) error {
+	if _, err := os.Lstat(dstPath); os.IsNotExist(err) {
+		return nil
+	}
+	log.Warn("snapshot: discarding incomplete snapshot from an earlier attempt", zap.String("path", dstPath))
+	if err := unlockSnapshot(ctx, dstPath); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dstPath); err != nil {
+		return fmt.Errorf("remove incomplete snapshot: %w", err)
+	}
+	return nil
+}
+
+func snapshotResults(snapshotID, dstPath string, meta SnapshotMeta, metaData []byte) map[string]string {
+	results := map[string]string{
+		"snapshot_id": snapshotID,
+		"fs_path":     dstPath,
+		"size_bytes":  strconv.FormatInt(meta.SizeBytes, 10),
+		"file_count":  strconv.FormatInt(meta.FileCount, 10),
+		"metadata":    string(metaData),
+	}
+	if meta.TreeDigest != "" {
+		results["tree_digest"] = meta.TreeDigest
+	}
+	if meta.ExcludedCount > 0 {
+		results["excluded_count"] = strconv.FormatInt(meta.ExcludedCount, 10)
+	}
+	if meta.UniqueBytes != nil && meta.SharedBytes != nil {
+		results["unique_bytes"] = strconv.FormatInt(*meta.UniqueBytes, 10)
+		results["shared_bytes"] = strconv.FormatInt(*meta.SharedBytes, 10)
+	}
+	return results
 }
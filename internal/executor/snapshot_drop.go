@@ -20,6 +20,11 @@ func (s *Server) snapshotDrop(ctx context.Context, wsid string, params map[strin
 		return map[string]string{}, nil
 	}
 
+	// A locked snapshot cannot be removed until it is unlocked.
+	if err := unlockSnapshot(ctx, targetPath); err != nil {
+		return nil, err
+	}
+
 	// Remove directory tree
 	if err := os.RemoveAll(targetPath); err != nil {
 		return nil, fmt.Errorf("remove snapshot dir: %w", err)
@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Usage describes the storage consumed by a snapshot directory.
+type Usage struct {
+	SizeBytes int64
+	FileCount int64
+
+	// UniqueBytes and SharedBytes split SizeBytes into data written since
+	// the parent snapshot and data still shared with it through the clone.
+	// They are only meaningful when HasSharing is set.
+	UniqueBytes int64
+	SharedBytes int64
+	HasSharing  bool
+}
+
+// SnapshotUsage measures the regular files under root, excluding WVS
+// bookkeeping in the top-level .wvs directory. If parent is a directory it also
+// estimates how much of root is shared with parent. JuiceFS clones share
+// chunks with their source until rewritten, so a file with the same size and
+// mtime as its counterpart in parent is counted as shared.
+func SnapshotUsage(root, parent string) (Usage, error) {
+	var u Usage
+	if parent != "" {
+		if fi, err := os.Stat(parent); err == nil && fi.IsDir() {
+			u.HasSharing = true
+		}
+	}
+
+	wvsDir := filepath.Join(root, ".wvs")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == wvsDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		u.SizeBytes += info.Size()
+		u.FileCount++
+
+		if !u.HasSharing {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if pi, err := os.Lstat(filepath.Join(parent, rel)); err == nil &&
+			pi.Mode().IsRegular() && pi.Size() == info.Size() && pi.ModTime().Equal(info.ModTime()) {
+			u.SharedBytes += info.Size()
+		} else {
+			u.UniqueBytes += info.Size()
+		}
+		return nil
+	})
+	return u, err
+}
+
+// snapshotUsage is SnapshotUsage under the configured clone mode. Copies
+// share nothing with their source, so in copy mode parent is ignored.
+func (s *Server) snapshotUsage(root, parent string) (Usage, error) {
+	if s.cfg.CloneMode == CloneModeCopy {
+		parent = ""
+	}
+	return SnapshotUsage(root, parent)
+}
+
+// liveUsage measures the workspace's live directory against the snapshot it
+// was cloned from, so unchanged files are not charged twice.
+func (s *Server) liveUsage(wsid, baseSnapshotID string) (Usage, error) {
+	wsRoot := filepath.Join(s.cfg.MountPath, wsid)
+	livePath, err := filepath.EvalSymlinks(filepath.Join(wsRoot, "current"))
+	if err != nil {
+		return Usage{}, err
+	}
+	var basePath string
+	if baseSnapshotID != "" {
+		basePath = filepath.Join(wsRoot, "snapshots", baseSnapshotID)
+	}
+	return s.snapshotUsage(livePath, basePath)
+}
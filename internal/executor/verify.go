@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/observability"
+)
+
+// verifySnapshot recomputes a snapshot's tree digest and compares it with
+// the recorded one: tree_digest from the catalog if given, since a stray
+// write could have rewritten the snapshot's own metadata too, else the one
+// in .wvs/snapshot.json. A mismatch is reported in the results, not as an
+// error; running again would not change it.
+func (s *Server) verifySnapshot(ctx context.Context, wsid string, params map[string]string, log *zap.Logger) (map[string]string, error) {
+	snapshotID := params["snapshot_id"]
+	snapRoot := filepath.Join(s.cfg.MountPath, wsid, "snapshots", snapshotID)
+	if _, err := os.Stat(snapRoot); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, snapRoot)
+	}
+
+	expected := params["tree_digest"]
+	if expected == "" {
+		if data, err := os.ReadFile(filepath.Join(snapRoot, ".wvs", "snapshot.json")); err == nil {
+			var meta SnapshotMeta
+			_ = json.Unmarshal(data, &meta)
+			expected = meta.TreeDigest
+		}
+	}
+
+	start := time.Now()
+	actual, err := TreeDigest(ctx, snapRoot)
+	if err != nil {
+		return nil, fmt.Errorf("digest snapshot: %w", err)
+	}
+	observability.SnapshotVerifyDuration.Observe(time.Since(start).Seconds())
+
+	state := core.VerifyStateOf(expected, actual)
+	observability.SnapshotVerifyTotal.WithLabelValues(string(state)).Inc()
+	if state == core.VerifyCorrupt {
+		log.Error("verify: tree digest mismatch",
+			zap.String("expected_digest", expected), zap.String("tree_digest", actual))
+	} else {
+		log.Info("verify: ok", zap.String("tree_digest", actual), zap.Bool("baseline", expected == ""))
+	}
+
+	return map[string]string{
+		"snapshot_id":     snapshotID,
+		"tree_digest":     actual,
+		"expected_digest": expected,
+		"verify_state":    string(state),
+	}, nil
+}
@@ -0,0 +1,152 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+func TestTreeDigest(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	a := filepath.Join(root, "a")
+	writeTree(t, a, map[string]string{
+		"README.md":          "hello",
+		"src/main.go":        "package main",
+		".wvs/snapshot.json": "{}",
+	})
+	if err := os.Symlink("README.md", filepath.Join(a, "link")); err != nil {
+		t.Fatal(err)
+	}
+	want, err := TreeDigest(ctx, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A copy digests the same, whatever its bookkeeping says.
+	b := filepath.Join(root, "b")
+	if err := CopyTree(ctx, a, b, "test", zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, b, map[string]string{".wvs/snapshot.json": `{"other": true}`})
+	if got, _ := TreeDigest(ctx, b); got != want {
+		t.Errorf("copy digests %s, want %s", got, want)
+	}
+
+	changes := map[string]func(dir string) error{
+		"content": func(dir string) error {
+			return os.WriteFile(filepath.Join(dir, "README.md"), []byte("HELLO"), 0644)
+		},
+		"mode": func(dir string) error {
+			return os.Chmod(filepath.Join(dir, "src", "main.go"), 0755)
+		},
+		"rename": func(dir string) error {
+			return os.Rename(filepath.Join(dir, "src", "main.go"), filepath.Join(dir, "src", "app.go"))
+		},
+		"link target": func(dir string) error {
+			_ = os.Remove(filepath.Join(dir, "link"))
+			return os.Symlink("src", filepath.Join(dir, "link"))
+		},
+		"new empty dir": func(dir string) error {
+			return os.Mkdir(filepath.Join(dir, "empty"), 0755)
+		},
+	}
+	for name, change := range changes {
+		dir := filepath.Join(root, "change-"+name)
+		if err := CopyTree(ctx, a, dir, "test", zap.NewNop()); err != nil {
+			t.Fatal(err)
+		}
+		if err := change(dir); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := TreeDigest(ctx, dir); got == want {
+			t.Errorf("%s: digest unchanged", name)
+		}
+	}
+}
+
+func TestVerifySnapshot(t *testing.T) {
+	ctx := context.Background()
+	s := newLocalServer(t)
+	snap := core.NewID()
+	root := s.snapshotPath(snap)
+	writeTree(t, root, map[string]string{"a.txt": "a", "dir/b.txt": "b"})
+	digest, err := TreeDigest(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, root, map[string]string{".wvs/snapshot.json": `{"tree_digest": "` + digest + `"}`})
+
+	verify := func(params map[string]string) map[string]string {
+		t.Helper()
+		params["snapshot_id"] = snap
+		res, err := s.verifySnapshot(ctx, replTestWSID, params, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	if res := verify(map[string]string{}); res["verify_state"] != string(core.VerifyOK) || res["expected_digest"] != digest {
+		t.Errorf("intact snapshot: %v", res)
+	}
+
+	writeTree(t, root, map[string]string{"dir/b.txt": "x"})
+	res := verify(map[string]string{"tree_digest": digest})
+	if res["verify_state"] != string(core.VerifyCorrupt) || res["tree_digest"] == digest {
+		t.Errorf("corrupted snapshot: %v", res)
+	}
+
+	// With nothing recorded anywhere, the digest becomes the baseline.
+	if err := os.Remove(filepath.Join(root, ".wvs", "snapshot.json")); err != nil {
+		t.Fatal(err)
+	}
+	if res := verify(map[string]string{}); res["verify_state"] != string(core.VerifyOK) || res["expected_digest"] != "" {
+		t.Errorf("snapshot without a digest: %v", res)
+	}
+
+	if _, err := s.verifySnapshot(ctx, replTestWSID, map[string]string{"snapshot_id": core.NewID()}, zap.NewNop()); err == nil {
+		t.Error("expected an error for a missing snapshot")
+	}
+}
+
+func TestSnapshotCreateFinishesCapturedClone(t *testing.T) {
+	ctx := context.Background()
+	s := newLocalServer(t)
+	wsRoot := filepath.Join(s.cfg.MountPath, replTestWSID)
+	live := filepath.Join(wsRoot, "live", "l1")
+	writeTree(t, live, map[string]string{"a.txt": "a"})
+	if err := os.Symlink(filepath.Join("live", "l1"), filepath.Join(wsRoot, "current")); err != nil {
+		t.Fatal(err)
+	}
+
+	// An attempt that cloned under quiesce and died before finishing.
+	snap := core.NewID()
+	params := map[string]string{"snapshot_id": snap, "task_id": core.NewID()}
+	if err := s.captureSnapshot(ctx, replTestWSID, live, snap, "", params, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := existingSnapshot(s.snapshotPath(snap)); ok {
+		t.Fatal("captured snapshot accepted before it was finished")
+	}
+	writeTree(t, live, map[string]string{"b.txt": "written after the capture"})
+
+	res, err := s.snapshotCreate(ctx, replTestWSID, params, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["file_count"] != "1" {
+		t.Errorf("snapshot re-cloned instead of finished: %v", res)
+	}
+	if _, err := os.Stat(filepath.Join(s.snapshotPath(snap), captureRecordPath)); !os.IsNotExist(err) {
+		t.Errorf("capture record left behind: %v", err)
+	}
+	if _, ok := existingSnapshot(s.snapshotPath(snap)); !ok {
+		t.Error("finished snapshot not recognized")
+	}
+}
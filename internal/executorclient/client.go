@@ -27,6 +27,30 @@ func (c *Client) ExecuteTask(ctx context.Context, req *pb.ExecuteTaskRequest) (*
 	return c.client.ExecuteTask(ctx, req)
 }
 
+func (c *Client) StatPath(ctx context.Context, req *pb.BrowseRequest) (*pb.TreeEntry, error) {
+	return c.client.StatPath(ctx, req)
+}
+
+func (c *Client) ListTree(ctx context.Context, req *pb.BrowseRequest) (pb.ExecutorService_ListTreeClient, error) {
+	return c.client.ListTree(ctx, req)
+}
+
+func (c *Client) ReadFile(ctx context.Context, req *pb.ReadFileRequest) (pb.ExecutorService_ReadFileClient, error) {
+	return c.client.ReadFile(ctx, req)
+}
+
+func (c *Client) ReadExport(ctx context.Context, req *pb.ReadExportRequest) (pb.ExecutorService_ReadExportClient, error) {
+	return c.client.ReadExport(ctx, req)
+}
+
+func (c *Client) UploadImport(ctx context.Context) (pb.ExecutorService_UploadImportClient, error) {
+	return c.client.UploadImport(ctx)
+}
+
+func (c *Client) DiscardImport(ctx context.Context, req *pb.DiscardImportRequest) (*pb.DiscardImportResponse, error) {
+	return c.client.DiscardImport(ctx, req)
+}
+
 func (c *Client) Close() error {
 	return c.conn.Close()
 }
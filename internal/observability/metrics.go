@@ -23,8 +23,8 @@ var (
 	// wvs-worker metrics
 	TaskTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "wvs_task_total",
-		Help: "Task completion count",
-	}, []string{"op", "status"})
+		Help: "Task completion count; error_class is empty for successes",
+	}, []string{"op", "status", "error_class"})
 
 	TaskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "wvs_task_duration_seconds",
@@ -37,6 +37,11 @@ var (
 		Help: "Pending + retryable FAILED tasks",
 	})
 
+	DeadTasks = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wvs_dead_tasks",
+		Help: "DEAD tasks awaiting retry or discard",
+	}, []string{"op"})
+
 	TaskRetryTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "wvs_task_retry_total",
 		Help: "Task retry count",
@@ -58,6 +63,46 @@ var (
 		Help: "Workspace state transition count",
 	}, []string{"from", "to"})
 
+	WorkspaceStorageBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wvs_workspace_storage_bytes",
+		Help: "Workspace storage by kind (logical, unique, live or usage)",
+	}, []string{"wsid", "kind"})
+
+	WorkspaceSnapshots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wvs_workspace_snapshots",
+		Help: "Live snapshot count per workspace",
+	}, []string{"wsid"})
+
+	QuotaWarningsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wvs_quota_warnings_total",
+		Help: "Soft quota crossings",
+	}, []string{"kind"})
+
+	QuotaExceededTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wvs_quota_exceeded_total",
+		Help: "Requests rejected by a hard quota",
+	}, []string{"kind"})
+
+	ReplicationLagSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wvs_replication_lag_seconds",
+		Help: "Age of the oldest READY snapshot not yet on the workspace's peer",
+	}, []string{"wsid"})
+
+	ReplicationPendingSnapshots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wvs_replication_pending_snapshots",
+		Help: "READY snapshots not yet on the workspace's peer",
+	}, []string{"wsid"})
+
+	SnapshotsCorrupt = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "wvs_snapshots_corrupt",
+		Help: "Live snapshots whose last verify found their tree digest changed",
+	})
+
+	ScrubEnqueuedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "wvs_scrub_enqueued_total",
+		Help: "Verify tasks enqueued by the background scrub",
+	})
+
 	// executor metrics
 	CloneDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "wvs_clone_duration_seconds",
@@ -75,6 +120,11 @@ var (
 		Help: "Clone failure count",
 	}, []string{"reason"})
 
+	PreflightRejectTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wvs_preflight_reject_total",
+		Help: "Clones refused by the disk space preflight",
+	}, []string{"resource"})
+
 	QuiesceWaitSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
 		Name:    "wvs_quiesce_wait_seconds",
 		Help:    "Wait for agent ack duration",
@@ -86,6 +136,22 @@ var (
 		Help: "Quiesce timeout count",
 	})
 
+	QuiesceTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wvs_quiesce_total",
+		Help: "Quiesce attempts by outcome (acked, no_guest, timeout, skipped)",
+	}, []string{"outcome"})
+
+	HookTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wvs_hook_total",
+		Help: "Workspace hook runs by point and outcome (ok, failed, timeout, disabled)",
+	}, []string{"point", "outcome"})
+
+	HookDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wvs_hook_duration_seconds",
+		Help:    "Workspace hook run duration",
+		Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"point"})
+
 	SwitchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
 		Name:    "wvs_switch_duration_seconds",
 		Help:    "Symlink switch duration",
@@ -96,14 +162,34 @@ var (
 		Name: "wvs_executor_active_tasks",
 		Help: "Currently executing tasks",
 	})
+
+	ReplicationBytesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "wvs_replication_bytes_total",
+		Help: "Archive bytes shipped to replication peers",
+	})
+
+	SnapshotVerifyTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wvs_snapshot_verify_total",
+		Help: "Snapshot verifications by outcome (OK or CORRUPT)",
+	}, []string{"result"})
+
+	SnapshotVerifyDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "wvs_snapshot_verify_duration_seconds",
+		Help:    "Snapshot tree digest recompute duration",
+		Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+	})
 )
 
 func RegisterAll(reg prometheus.Registerer) {
 	reg.MustRegister(
 		HTTPRequestsTotal, HTTPRequestDuration, ActiveRequests,
-		TaskTotal, TaskDuration, TaskQueueDepth, TaskRetryTotal,
+		TaskTotal, TaskDuration, TaskQueueDepth, DeadTasks, TaskRetryTotal,
 		LockWaitSeconds, DequeueEmptyTotal, WorkspaceStateTransitions,
-		CloneDuration, CloneEntriesTotal, CloneFailTotal,
-		QuiesceWaitSeconds, QuiesceTimeoutTotal, SwitchDuration, ExecutorActiveTasks,
+		WorkspaceStorageBytes, WorkspaceSnapshots, QuotaWarningsTotal, QuotaExceededTotal,
+		ReplicationLagSeconds, ReplicationPendingSnapshots, SnapshotsCorrupt, ScrubEnqueuedTotal,
+		CloneDuration, CloneEntriesTotal, CloneFailTotal, PreflightRejectTotal,
+		QuiesceWaitSeconds, QuiesceTimeoutTotal, QuiesceTotal, HookTotal, HookDuration,
+		SwitchDuration, ExecutorActiveTasks,
+		ReplicationBytesTotal, SnapshotVerifyTotal, SnapshotVerifyDuration,
 	)
 }
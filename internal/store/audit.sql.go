@@ -11,6 +11,17 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const getLatestAuditEventID = `-- name: GetLatestAuditEventID :one
+SELECT COALESCE(max(event_id), 0)::bigint AS event_id FROM wvs.audit
+`
+
+func (q *Queries) GetLatestAuditEventID(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, getLatestAuditEventID)
+	var event_id int64
+	err := row.Scan(&event_id)
+	return event_id, err
+}
+
 const insertAudit = `-- name: InsertAudit :one
 INSERT INTO wvs.audit (wsid, actor, action, request_id, task_id, payload)
 VALUES ($1, $2, $3, $4, $5, $6)
@@ -48,3 +59,53 @@ func (q *Queries) InsertAudit(ctx context.Context, arg InsertAuditParams) (WvsAu
 	)
 	return i, err
 }
+
+const listAuditEventsAfter = `-- name: ListAuditEventsAfter :many
+SELECT event_id, ts, wsid, actor, action, request_id, task_id, payload FROM wvs.audit
+WHERE event_id > $1
+  AND ($3::text IS NULL OR wsid = $3::text)
+  AND ($4::text IS NULL OR task_id = $4::text)
+ORDER BY event_id
+LIMIT $2
+`
+
+type ListAuditEventsAfterParams struct {
+	EventID int64       `json:"event_id"`
+	Limit   int32       `json:"limit"`
+	Wsid    pgtype.Text `json:"wsid"`
+	TaskID  pgtype.Text `json:"task_id"`
+}
+
+func (q *Queries) ListAuditEventsAfter(ctx context.Context, arg ListAuditEventsAfterParams) ([]WvsAudit, error) {
+	rows, err := q.db.Query(ctx, listAuditEventsAfter,
+		arg.EventID,
+		arg.Limit,
+		arg.Wsid,
+		arg.TaskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WvsAudit{}
+	for rows.Next() {
+		var i WvsAudit
+		if err := rows.Scan(
+			&i.EventID,
+			&i.Ts,
+			&i.Wsid,
+			&i.Actor,
+			&i.Action,
+			&i.RequestID,
+			&i.TaskID,
+			&i.Payload,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
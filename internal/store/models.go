@@ -19,13 +19,74 @@ type WvsAudit struct {
 	Payload   []byte             `json:"payload"`
 }
 
+type WvsBatch struct {
+	BatchID        string             `json:"batch_id"`
+	Wsid           string             `json:"wsid"`
+	IdempotencyKey string             `json:"idempotency_key"`
+	RequestHash    string             `json:"request_hash"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
+type WvsExport struct {
+	ExportID         string             `json:"export_id"`
+	Wsid             string             `json:"wsid"`
+	SnapshotID       string             `json:"snapshot_id"`
+	TaskID           string             `json:"task_id"`
+	Compression      string             `json:"compression"`
+	Destination      string             `json:"destination"`
+	Location         pgtype.Text        `json:"location"`
+	Digest           pgtype.Text        `json:"digest"`
+	SizeBytes        pgtype.Int8        `json:"size_bytes"`
+	FileCount        pgtype.Int8        `json:"file_count"`
+	ManifestLocation pgtype.Text        `json:"manifest_location"`
+	ManifestDigest   pgtype.Text        `json:"manifest_digest"`
+	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+	CompletedAt      pgtype.Timestamptz `json:"completed_at"`
+}
+
+type WvsQueueFairness struct {
+	Wsid           string             `json:"wsid"`
+	LastDequeuedAt pgtype.Timestamptz `json:"last_dequeued_at"`
+}
+
+type WvsReplicationTarget struct {
+	Wsid      string             `json:"wsid"`
+	PeerUrl   string             `json:"peer_url"`
+	PeerWsid  string             `json:"peer_wsid"`
+	Enabled   bool               `json:"enabled"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
 type WvsSnapshot struct {
-	SnapshotID string             `json:"snapshot_id"`
-	Wsid       string             `json:"wsid"`
-	FsPath     string             `json:"fs_path"`
-	Message    pgtype.Text        `json:"message"`
-	CreatedAt  pgtype.Timestamptz `json:"created_at"`
-	DeletedAt  pgtype.Timestamptz `json:"deleted_at"`
+	SnapshotID  string             `json:"snapshot_id"`
+	Wsid        string             `json:"wsid"`
+	FsPath      string             `json:"fs_path"`
+	Message     pgtype.Text        `json:"message"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	DeletedAt   pgtype.Timestamptz `json:"deleted_at"`
+	State       string             `json:"state"`
+	TaskID      pgtype.Text        `json:"task_id"`
+	SizeBytes   pgtype.Int8        `json:"size_bytes"`
+	FileCount   pgtype.Int8        `json:"file_count"`
+	Metadata    []byte             `json:"metadata"`
+	UniqueBytes pgtype.Int8        `json:"unique_bytes"`
+	SharedBytes pgtype.Int8        `json:"shared_bytes"`
+	TreeDigest  pgtype.Text        `json:"tree_digest"`
+	VerifyState pgtype.Text        `json:"verify_state"`
+	VerifiedAt  pgtype.Timestamptz `json:"verified_at"`
+}
+
+type WvsSnapshotReplica struct {
+	SnapshotID     string             `json:"snapshot_id"`
+	Wsid           string             `json:"wsid"`
+	TaskID         string             `json:"task_id"`
+	BaseSnapshotID pgtype.Text        `json:"base_snapshot_id"`
+	BytesShipped   pgtype.Int8        `json:"bytes_shipped"`
+	FilesChanged   pgtype.Int8        `json:"files_changed"`
+	FilesDeleted   pgtype.Int8        `json:"files_deleted"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	ReplicatedAt   pgtype.Timestamptz `json:"replicated_at"`
 }
 
 type WvsTask struct {
@@ -46,15 +107,37 @@ type WvsTask struct {
 	Params          []byte             `json:"params"`
 	Result          []byte             `json:"result"`
 	Error           []byte             `json:"error"`
+	RetryOf         pgtype.Text        `json:"retry_of"`
+	DlqResolution   pgtype.Text        `json:"dlq_resolution"`
+	DlqResolvedAt   pgtype.Timestamptz `json:"dlq_resolved_at"`
+	RetryBaseMs     int32              `json:"retry_base_ms"`
+	RetryMultiplier float64            `json:"retry_multiplier"`
+	RetryMaxDelayMs int32              `json:"retry_max_delay_ms"`
+	RetryJitter     float64            `json:"retry_jitter"`
+	Priority        int32              `json:"priority"`
+	DependsOn       pgtype.Text        `json:"depends_on"`
+	BatchID         pgtype.Text        `json:"batch_id"`
+	BatchSeq        pgtype.Int4        `json:"batch_seq"`
 }
 
 type WvsWorkspace struct {
-	Wsid              string             `json:"wsid"`
-	RootPath          string             `json:"root_path"`
-	Owner             string             `json:"owner"`
-	State             string             `json:"state"`
-	CurrentSnapshotID pgtype.Text        `json:"current_snapshot_id"`
-	CurrentPath       string             `json:"current_path"`
-	CreatedAt         pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+	Wsid               string             `json:"wsid"`
+	RootPath           string             `json:"root_path"`
+	Owner              string             `json:"owner"`
+	State              string             `json:"state"`
+	CurrentSnapshotID  pgtype.Text        `json:"current_snapshot_id"`
+	CurrentPath        string             `json:"current_path"`
+	CreatedAt          pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt          pgtype.Timestamptz `json:"updated_at"`
+	QuotaSoftBytes     pgtype.Int8        `json:"quota_soft_bytes"`
+	QuotaHardBytes     pgtype.Int8        `json:"quota_hard_bytes"`
+	QuotaSoftSnapshots pgtype.Int4        `json:"quota_soft_snapshots"`
+	QuotaHardSnapshots pgtype.Int4        `json:"quota_hard_snapshots"`
+	UsageBytes         int64              `json:"usage_bytes"`
+	UsageSnapshots     int32              `json:"usage_snapshots"`
+	LiveUniqueBytes    int64              `json:"live_unique_bytes"`
+	UsageUpdatedAt     pgtype.Timestamptz `json:"usage_updated_at"`
+	QuiescePolicy      string             `json:"quiesce_policy"`
+	Hooks              []byte             `json:"hooks"`
+	ExcludePatterns    []string           `json:"exclude_patterns"`
 }
@@ -2,3 +2,14 @@
 INSERT INTO wvs.audit (wsid, actor, action, request_id, task_id, payload)
 VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
+
+-- name: ListAuditEventsAfter :many
+SELECT * FROM wvs.audit
+WHERE event_id > $1
+  AND (sqlc.narg('wsid')::text IS NULL OR wsid = sqlc.narg('wsid')::text)
+  AND (sqlc.narg('task_id')::text IS NULL OR task_id = sqlc.narg('task_id')::text)
+ORDER BY event_id
+LIMIT $2;
+
+-- name: GetLatestAuditEventID :one
+SELECT COALESCE(max(event_id), 0)::bigint AS event_id FROM wvs.audit;
@@ -0,0 +1,10 @@
+-- name: CreateBatch :one
+INSERT INTO wvs.batches (batch_id, wsid, idempotency_key, request_hash)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetBatch :one
+SELECT * FROM wvs.batches WHERE batch_id = $1;
+
+-- name: GetBatchByIdempotencyKey :one
+SELECT * FROM wvs.batches WHERE wsid = $1 AND idempotency_key = $2;
@@ -0,0 +1,18 @@
+-- name: CreateExport :one
+INSERT INTO wvs.exports (export_id, wsid, snapshot_id, task_id, compression, destination)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetExport :one
+SELECT * FROM wvs.exports WHERE export_id = $1;
+
+-- name: ListSnapshotExports :many
+SELECT * FROM wvs.exports
+WHERE wsid = $1 AND snapshot_id = $2
+ORDER BY created_at DESC;
+
+-- name: CompleteExport :exec
+UPDATE wvs.exports
+SET location = $2, digest = $3, size_bytes = $4, file_count = $5,
+    manifest_location = $6, manifest_digest = $7, completed_at = now()
+WHERE export_id = $1;
@@ -0,0 +1,79 @@
+-- name: UpsertReplicationTarget :one
+INSERT INTO wvs.replication_targets (wsid, peer_url, peer_wsid, enabled)
+VALUES ($1, $2, $3, true)
+ON CONFLICT (wsid) DO UPDATE
+SET peer_url = EXCLUDED.peer_url,
+    peer_wsid = EXCLUDED.peer_wsid,
+    enabled = true,
+    updated_at = now()
+RETURNING *;
+
+-- name: GetReplicationTarget :one
+SELECT * FROM wvs.replication_targets WHERE wsid = $1;
+
+-- name: DisableReplicationTarget :execrows
+UPDATE wvs.replication_targets SET enabled = false, updated_at = now()
+WHERE wsid = $1 AND enabled;
+
+-- name: ListEnabledReplicationTargets :many
+SELECT * FROM wvs.replication_targets WHERE enabled ORDER BY wsid;
+
+-- name: DeleteSnapshotReplicas :exec
+DELETE FROM wvs.snapshot_replicas WHERE wsid = $1;
+
+-- name: CreateSnapshotReplica :one
+INSERT INTO wvs.snapshot_replicas (snapshot_id, wsid, task_id, base_snapshot_id)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetSnapshotReplica :one
+SELECT * FROM wvs.snapshot_replicas WHERE snapshot_id = $1;
+
+-- name: ListSnapshotReplicas :many
+SELECT * FROM wvs.snapshot_replicas
+WHERE wsid = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: CompleteSnapshotReplica :exec
+UPDATE wvs.snapshot_replicas
+SET bytes_shipped = $3, files_changed = $4, files_deleted = $5, replicated_at = now()
+WHERE snapshot_id = $1 AND task_id = $2;
+
+-- name: RepointSnapshotReplica :exec
+UPDATE wvs.snapshot_replicas SET task_id = $2 WHERE snapshot_id = $1;
+
+-- name: CountInFlightReplicas :one
+SELECT count(*) FROM wvs.snapshot_replicas r
+JOIN wvs.tasks t ON t.task_id = r.task_id
+WHERE r.wsid = $1 AND r.replicated_at IS NULL AND t.status IN ('PENDING', 'RUNNING', 'FAILED');
+
+-- name: NextSnapshotToReplicate :one
+SELECT * FROM wvs.snapshots s
+WHERE s.wsid = $1 AND s.state = 'READY' AND s.deleted_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM wvs.snapshot_replicas r WHERE r.snapshot_id = s.snapshot_id)
+ORDER BY s.created_at
+LIMIT 1;
+
+-- name: GetReplicationLag :one
+SELECT count(*) AS pending_snapshots,
+       min(s.created_at)::timestamptz AS oldest_pending_at
+FROM wvs.snapshots s
+WHERE s.wsid = $1 AND s.state = 'READY' AND s.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM wvs.snapshot_replicas r
+    WHERE r.snapshot_id = s.snapshot_id AND r.replicated_at IS NOT NULL
+  );
+
+-- name: GetSnapshotParentID :one
+SELECT COALESCE(t.params->>'parent_snapshot_id', t.params->>'base_snapshot_id', '')::text AS parent_snapshot_id
+FROM wvs.snapshots s
+JOIN wvs.tasks t ON t.task_id = s.task_id
+WHERE s.snapshot_id = $1;
+
+-- name: GetLatestReplicatedSnapshot :one
+SELECT s.* FROM wvs.snapshots s
+JOIN wvs.snapshot_replicas r ON r.snapshot_id = s.snapshot_id
+WHERE s.wsid = $1 AND s.deleted_at IS NULL AND r.replicated_at IS NOT NULL
+ORDER BY r.replicated_at DESC
+LIMIT 1;
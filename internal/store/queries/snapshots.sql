@@ -1,11 +1,14 @@
 -- name: CreateSnapshot :one
-INSERT INTO wvs.snapshots (snapshot_id, wsid, fs_path, message, created_at)
-VALUES ($1, $2, $3, $4, now())
+INSERT INTO wvs.snapshots (snapshot_id, wsid, fs_path, message, state, task_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, now())
 RETURNING *;
 
 -- name: GetSnapshot :one
 SELECT * FROM wvs.snapshots WHERE snapshot_id = $1;
 
+-- name: GetWorkspaceSnapshot :one
+SELECT * FROM wvs.snapshots WHERE wsid = $1 AND snapshot_id = $2;
+
 -- name: ListSnapshots :many
 SELECT * FROM wvs.snapshots
 WHERE wsid = $1 AND deleted_at IS NULL
@@ -16,6 +19,39 @@ LIMIT $2;
 -- name: MarkSnapshotDeleted :exec
 UPDATE wvs.snapshots SET deleted_at = now() WHERE snapshot_id = $1;
 
+-- name: UpdateSnapshotState :exec
+UPDATE wvs.snapshots SET state = $2 WHERE snapshot_id = $1;
+
+-- name: MarkSnapshotReady :exec
+INSERT INTO wvs.snapshots (snapshot_id, wsid, fs_path, message, state, task_id, size_bytes, file_count, unique_bytes, shared_bytes, metadata, tree_digest, created_at)
+VALUES ($1, $2, $3, $4, 'READY', $5, $6, $7, $8, $9, $10, $11, now())
+ON CONFLICT (snapshot_id) DO UPDATE
+SET fs_path = EXCLUDED.fs_path,
+    size_bytes = EXCLUDED.size_bytes,
+    file_count = EXCLUDED.file_count,
+    unique_bytes = EXCLUDED.unique_bytes,
+    shared_bytes = EXCLUDED.shared_bytes,
+    metadata = EXCLUDED.metadata,
+    tree_digest = EXCLUDED.tree_digest,
+    state = 'READY';
+
+-- name: GetWorkspaceUsage :one
+SELECT
+  count(*)::bigint AS snapshot_count,
+  COALESCE(sum(file_count), 0)::bigint AS file_count,
+  COALESCE(sum(size_bytes), 0)::bigint AS logical_bytes,
+  COALESCE(sum(COALESCE(unique_bytes, size_bytes)), 0)::bigint AS unique_bytes
+FROM wvs.snapshots
+WHERE wsid = $1 AND deleted_at IS NULL AND state = 'READY';
+
+-- name: ClearSharedUsage :exec
+UPDATE wvs.snapshots SET unique_bytes = NULL, shared_bytes = NULL
+WHERE wsid = $1 AND metadata->>'parent_snapshot_id' = sqlc.arg('parent_snapshot_id')::text;
+
+-- name: CountActiveSnapshots :one
+SELECT count(*)::bigint AS count FROM wvs.snapshots
+WHERE wsid = $1 AND deleted_at IS NULL AND state <> 'FAILED';
+
 -- name: IsSnapshotReferencedByTasks :one
 SELECT EXISTS(
   SELECT 1 FROM wvs.tasks
@@ -23,5 +59,35 @@ SELECT EXISTS(
     AND status IN ('PENDING', 'RUNNING', 'FAILED')
     AND attempt < max_attempts
     AND op != 'snapshot_drop'
-    AND params->>'snapshot_id' = sqlc.narg('snapshot_id')::text
+    AND (params->>'snapshot_id' = sqlc.narg('snapshot_id')::text
+         OR params->>'base_snapshot_id' = sqlc.narg('snapshot_id')::text)
 ) AS referenced;
+
+-- name: ResetSnapshotForRetry :execrows
+UPDATE wvs.snapshots SET state = 'PENDING', task_id = $2
+WHERE snapshot_id = $1 AND state = 'FAILED';
+
+-- name: RecordSnapshotVerify :exec
+UPDATE wvs.snapshots
+SET verify_state = $2,
+    verified_at = now(),
+    tree_digest = COALESCE(tree_digest, sqlc.narg('tree_digest')::text)
+WHERE snapshot_id = $1;
+
+-- name: ListSnapshotsDueForVerify :many
+SELECT s.* FROM wvs.snapshots s
+JOIN wvs.workspaces w ON w.wsid = s.wsid
+WHERE s.deleted_at IS NULL AND s.state = 'READY' AND w.state = 'ACTIVE'
+  AND COALESCE(s.verified_at, s.created_at) < sqlc.arg('verified_before')::timestamptz
+  AND NOT EXISTS (
+    SELECT 1 FROM wvs.tasks t
+    WHERE t.wsid = s.wsid AND t.op = 'verify'
+      AND t.status IN ('PENDING', 'RUNNING', 'FAILED')
+      AND t.params->>'snapshot_id' = s.snapshot_id
+  )
+ORDER BY COALESCE(s.verified_at, s.created_at)
+LIMIT $1;
+
+-- name: CountCorruptSnapshots :one
+SELECT count(*)::bigint AS count FROM wvs.snapshots
+WHERE deleted_at IS NULL AND verify_state = 'CORRUPT';
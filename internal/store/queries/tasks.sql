@@ -1,6 +1,8 @@
 -- name: CreateTask :one
-INSERT INTO wvs.tasks (task_id, wsid, op, status, idempotency_key, request_hash, params, max_attempts, timeout_seconds)
-VALUES ($1, $2, $3, 'PENDING', $4, $5, $6, $7, $8)
+INSERT INTO wvs.tasks (task_id, wsid, op, status, idempotency_key, request_hash, params, max_attempts, timeout_seconds,
+                       retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority,
+                       depends_on, batch_id, batch_seq)
+VALUES ($1, $2, $3, 'PENDING', $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 RETURNING *;
 
 -- name: GetTask :one
@@ -20,14 +22,22 @@ LIMIT $1;
 
 -- name: DequeueTask :one
 WITH picked AS (
-  SELECT task_id
-  FROM wvs.tasks
-  WHERE status IN ('PENDING', 'FAILED')
-    AND next_run_at <= now()
-    AND attempt < max_attempts
-  ORDER BY created_at
-  FOR UPDATE SKIP LOCKED
+  SELECT t.task_id, t.wsid
+  FROM wvs.tasks t
+  LEFT JOIN wvs.queue_fairness f ON f.wsid = t.wsid
+  WHERE t.status IN ('PENDING', 'FAILED')
+    AND t.next_run_at <= now()
+    AND t.attempt < t.max_attempts
+    AND (t.depends_on IS NULL OR EXISTS (
+      SELECT 1 FROM wvs.tasks d WHERE d.task_id = t.depends_on AND d.status = 'SUCCEEDED'
+    ))
+  ORDER BY t.priority DESC, f.last_dequeued_at NULLS FIRST, t.created_at
+  FOR UPDATE OF t SKIP LOCKED
   LIMIT 1
+), served AS (
+  INSERT INTO wvs.queue_fairness (wsid, last_dequeued_at)
+  SELECT wsid, clock_timestamp() FROM picked
+  ON CONFLICT (wsid) DO UPDATE SET last_dequeued_at = EXCLUDED.last_dequeued_at
 )
 UPDATE wvs.tasks t
 SET status = 'RUNNING', started_at = now(), attempt = attempt + 1
@@ -43,7 +53,7 @@ WHERE task_id = $1;
 -- name: FailTask :exec
 UPDATE wvs.tasks
 SET status = 'FAILED', ended_at = now(), error = $2,
-    next_run_at = now() + make_interval(secs => least(5 * power(2, attempt - 1), 300))
+    next_run_at = now() + make_interval(secs => sqlc.arg('backoff_seconds')::float8)
 WHERE task_id = $1;
 
 -- name: MarkTaskDead :exec
@@ -69,3 +79,45 @@ WHERE wsid = $1 AND status IN ('PENDING', 'RUNNING', 'FAILED') AND attempt < max
 -- name: GetQueueDepth :one
 SELECT count(*) FROM wvs.tasks
 WHERE status IN ('PENDING', 'FAILED') AND next_run_at <= now() AND attempt < max_attempts;
+
+-- name: CreateRetryTask :one
+INSERT INTO wvs.tasks (task_id, wsid, op, status, idempotency_key, request_hash, params, max_attempts, timeout_seconds,
+                       retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, retry_of)
+VALUES ($1, $2, $3, 'PENDING', $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+RETURNING *;
+
+-- name: GetTaskRetry :one
+SELECT * FROM wvs.tasks WHERE retry_of = $1;
+
+-- name: ResolveDeadTask :execrows
+UPDATE wvs.tasks SET dlq_resolution = $2, dlq_resolved_at = now()
+WHERE task_id = $1 AND status = 'DEAD' AND dlq_resolution IS NULL;
+
+-- name: ListDeadTasks :many
+SELECT * FROM wvs.tasks
+WHERE status = 'DEAD' AND dlq_resolution IS NULL
+  AND (sqlc.narg('wsid')::text IS NULL OR wsid = sqlc.narg('wsid')::text)
+  AND (sqlc.narg('op')::text IS NULL OR op = sqlc.narg('op')::text)
+  AND (sqlc.narg('error_code')::text IS NULL OR error->>'code' = sqlc.narg('error_code')::text)
+  AND (sqlc.narg('since')::timestamptz IS NULL OR ended_at >= sqlc.narg('since')::timestamptz)
+  AND (sqlc.narg('until')::timestamptz IS NULL OR ended_at < sqlc.narg('until')::timestamptz)
+ORDER BY ended_at
+LIMIT $1;
+
+-- name: CountDeadTasksByOp :many
+SELECT op, count(*)::bigint AS count FROM wvs.tasks
+WHERE status = 'DEAD' AND dlq_resolution IS NULL
+GROUP BY op;
+
+-- name: CancelDependentTasks :many
+WITH RECURSIVE deps AS (
+  SELECT task_id FROM wvs.tasks WHERE depends_on = sqlc.arg('task_id')::text
+  UNION
+  SELECT t.task_id FROM wvs.tasks t JOIN deps ON t.depends_on = deps.task_id
+)
+UPDATE wvs.tasks SET status = 'CANCELED', ended_at = now(), error = sqlc.arg('error')
+WHERE task_id IN (SELECT task_id FROM deps) AND status IN ('PENDING', 'FAILED')
+RETURNING *;
+
+-- name: ListBatchTasks :many
+SELECT * FROM wvs.tasks WHERE batch_id = $1 ORDER BY batch_seq;
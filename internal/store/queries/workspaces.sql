@@ -1,6 +1,6 @@
 -- name: CreateWorkspace :one
-INSERT INTO wvs.workspaces (wsid, root_path, owner, state, current_path, created_at, updated_at)
-VALUES ($1, $2, $3, 'PROVISIONING', $4, now(), now())
+INSERT INTO wvs.workspaces (wsid, root_path, owner, state, current_path, quiesce_policy, hooks, exclude_patterns, created_at, updated_at)
+VALUES ($1, $2, $3, 'PROVISIONING', $4, $5, $6, $7, now(), now())
 RETURNING *;
 
 -- name: GetWorkspace :one
@@ -24,3 +24,35 @@ WHERE wsid = $1;
 UPDATE wvs.workspaces SET state = 'DISABLED', updated_at = now()
 WHERE wsid = $1
 RETURNING *;
+
+-- name: SetWorkspaceQuota :one
+UPDATE wvs.workspaces
+SET quota_soft_bytes = $2, quota_hard_bytes = $3,
+    quota_soft_snapshots = $4, quota_hard_snapshots = $5,
+    updated_at = now()
+WHERE wsid = $1
+RETURNING *;
+
+-- name: SetWorkspaceQuiescePolicy :one
+UPDATE wvs.workspaces
+SET quiesce_policy = $2, updated_at = now()
+WHERE wsid = $1
+RETURNING *;
+
+-- name: SetWorkspaceHooks :one
+UPDATE wvs.workspaces
+SET hooks = $2, updated_at = now()
+WHERE wsid = $1
+RETURNING *;
+
+-- name: SetWorkspaceExcludePatterns :one
+UPDATE wvs.workspaces
+SET exclude_patterns = $2, updated_at = now()
+WHERE wsid = $1
+RETURNING *;
+
+-- name: UpdateWorkspaceUsage :one
+UPDATE wvs.workspaces
+SET usage_bytes = $2, usage_snapshots = $3, live_unique_bytes = $4, usage_updated_at = now()
+WHERE wsid = $1
+RETURNING *;
@@ -11,10 +11,49 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const clearSharedUsage = `-- name: ClearSharedUsage :exec
+UPDATE wvs.snapshots SET unique_bytes = NULL, shared_bytes = NULL
+WHERE wsid = $1 AND metadata->>'parent_snapshot_id' = $2::text
+`
+
+type ClearSharedUsageParams struct {
+	Wsid             string `json:"wsid"`
+	ParentSnapshotID string `json:"parent_snapshot_id"`
+}
+
+func (q *Queries) ClearSharedUsage(ctx context.Context, arg ClearSharedUsageParams) error {
+	_, err := q.db.Exec(ctx, clearSharedUsage, arg.Wsid, arg.ParentSnapshotID)
+	return err
+}
+
+const countActiveSnapshots = `-- name: CountActiveSnapshots :one
+SELECT count(*)::bigint AS count FROM wvs.snapshots
+WHERE wsid = $1 AND deleted_at IS NULL AND state <> 'FAILED'
+`
+
+func (q *Queries) CountActiveSnapshots(ctx context.Context, wsid string) (int64, error) {
+	row := q.db.QueryRow(ctx, countActiveSnapshots, wsid)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countCorruptSnapshots = `-- name: CountCorruptSnapshots :one
+SELECT count(*)::bigint AS count FROM wvs.snapshots
+WHERE deleted_at IS NULL AND verify_state = 'CORRUPT'
+`
+
+func (q *Queries) CountCorruptSnapshots(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, countCorruptSnapshots)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createSnapshot = `-- name: CreateSnapshot :one
-INSERT INTO wvs.snapshots (snapshot_id, wsid, fs_path, message, created_at)
-VALUES ($1, $2, $3, $4, now())
-RETURNING snapshot_id, wsid, fs_path, message, created_at, deleted_at
+INSERT INTO wvs.snapshots (snapshot_id, wsid, fs_path, message, state, task_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, now())
+RETURNING snapshot_id, wsid, fs_path, message, created_at, deleted_at, state, task_id, size_bytes, file_count, metadata, unique_bytes, shared_bytes, tree_digest, verify_state, verified_at
 `
 
 type CreateSnapshotParams struct {
@@ -22,6 +61,8 @@ type CreateSnapshotParams struct {
 	Wsid       string      `json:"wsid"`
 	FsPath     string      `json:"fs_path"`
 	Message    pgtype.Text `json:"message"`
+	State      string      `json:"state"`
+	TaskID     pgtype.Text `json:"task_id"`
 }
 
 func (q *Queries) CreateSnapshot(ctx context.Context, arg CreateSnapshotParams) (WvsSnapshot, error) {
@@ -30,6 +71,8 @@ func (q *Queries) CreateSnapshot(ctx context.Context, arg CreateSnapshotParams)
 		arg.Wsid,
 		arg.FsPath,
 		arg.Message,
+		arg.State,
+		arg.TaskID,
 	)
 	var i WvsSnapshot
 	err := row.Scan(
@@ -39,12 +82,22 @@ func (q *Queries) CreateSnapshot(ctx context.Context, arg CreateSnapshotParams)
 		&i.Message,
 		&i.CreatedAt,
 		&i.DeletedAt,
+		&i.State,
+		&i.TaskID,
+		&i.SizeBytes,
+		&i.FileCount,
+		&i.Metadata,
+		&i.UniqueBytes,
+		&i.SharedBytes,
+		&i.TreeDigest,
+		&i.VerifyState,
+		&i.VerifiedAt,
 	)
 	return i, err
 }
 
 const getSnapshot = `-- name: GetSnapshot :one
-SELECT snapshot_id, wsid, fs_path, message, created_at, deleted_at FROM wvs.snapshots WHERE snapshot_id = $1
+SELECT snapshot_id, wsid, fs_path, message, created_at, deleted_at, state, task_id, size_bytes, file_count, metadata, unique_bytes, shared_bytes, tree_digest, verify_state, verified_at FROM wvs.snapshots WHERE snapshot_id = $1
 `
 
 func (q *Queries) GetSnapshot(ctx context.Context, snapshotID string) (WvsSnapshot, error) {
@@ -57,6 +110,78 @@ func (q *Queries) GetSnapshot(ctx context.Context, snapshotID string) (WvsSnapsh
 		&i.Message,
 		&i.CreatedAt,
 		&i.DeletedAt,
+		&i.State,
+		&i.TaskID,
+		&i.SizeBytes,
+		&i.FileCount,
+		&i.Metadata,
+		&i.UniqueBytes,
+		&i.SharedBytes,
+		&i.TreeDigest,
+		&i.VerifyState,
+		&i.VerifiedAt,
+	)
+	return i, err
+}
+
+const getWorkspaceSnapshot = `-- name: GetWorkspaceSnapshot :one
+SELECT snapshot_id, wsid, fs_path, message, created_at, deleted_at, state, task_id, size_bytes, file_count, metadata, unique_bytes, shared_bytes, tree_digest, verify_state, verified_at FROM wvs.snapshots WHERE wsid = $1 AND snapshot_id = $2
+`
+
+type GetWorkspaceSnapshotParams struct {
+	Wsid       string `json:"wsid"`
+	SnapshotID string `json:"snapshot_id"`
+}
+
+func (q *Queries) GetWorkspaceSnapshot(ctx context.Context, arg GetWorkspaceSnapshotParams) (WvsSnapshot, error) {
+	row := q.db.QueryRow(ctx, getWorkspaceSnapshot, arg.Wsid, arg.SnapshotID)
+	var i WvsSnapshot
+	err := row.Scan(
+		&i.SnapshotID,
+		&i.Wsid,
+		&i.FsPath,
+		&i.Message,
+		&i.CreatedAt,
+		&i.DeletedAt,
+		&i.State,
+		&i.TaskID,
+		&i.SizeBytes,
+		&i.FileCount,
+		&i.Metadata,
+		&i.UniqueBytes,
+		&i.SharedBytes,
+		&i.TreeDigest,
+		&i.VerifyState,
+		&i.VerifiedAt,
+	)
+	return i, err
+}
+
+const getWorkspaceUsage = `-- name: GetWorkspaceUsage :one
+SELECT
+  count(*)::bigint AS snapshot_count,
+  COALESCE(sum(file_count), 0)::bigint AS file_count,
+  COALESCE(sum(size_bytes), 0)::bigint AS logical_bytes,
+  COALESCE(sum(COALESCE(unique_bytes, size_bytes)), 0)::bigint AS unique_bytes
+FROM wvs.snapshots
+WHERE wsid = $1 AND deleted_at IS NULL AND state = 'READY'
+`
+
+type GetWorkspaceUsageRow struct {
+	SnapshotCount int64 `json:"snapshot_count"`
+	FileCount     int64 `json:"file_count"`
+	LogicalBytes  int64 `json:"logical_bytes"`
+	UniqueBytes   int64 `json:"unique_bytes"`
+}
+
+func (q *Queries) GetWorkspaceUsage(ctx context.Context, wsid string) (GetWorkspaceUsageRow, error) {
+	row := q.db.QueryRow(ctx, getWorkspaceUsage, wsid)
+	var i GetWorkspaceUsageRow
+	err := row.Scan(
+		&i.SnapshotCount,
+		&i.FileCount,
+		&i.LogicalBytes,
+		&i.UniqueBytes,
 	)
 	return i, err
 }
@@ -68,7 +193,8 @@ SELECT EXISTS(
     AND status IN ('PENDING', 'RUNNING', 'FAILED')
     AND attempt < max_attempts
     AND op != 'snapshot_drop'
-    AND params->>'snapshot_id' = $2::text
+    AND (params->>'snapshot_id' = $2::text
+         OR params->>'base_snapshot_id' = $2::text)
 ) AS referenced
 `
 
@@ -85,7 +211,7 @@ func (q *Queries) IsSnapshotReferencedByTasks(ctx context.Context, arg IsSnapsho
 }
 
 const listSnapshots = `-- name: ListSnapshots :many
-SELECT snapshot_id, wsid, fs_path, message, created_at, deleted_at FROM wvs.snapshots
+SELECT snapshot_id, wsid, fs_path, message, created_at, deleted_at, state, task_id, size_bytes, file_count, metadata, unique_bytes, shared_bytes, tree_digest, verify_state, verified_at FROM wvs.snapshots
 WHERE wsid = $1 AND deleted_at IS NULL
   AND ($3::timestamptz IS NULL OR created_at < $3::timestamptz)
 ORDER BY created_at DESC
@@ -114,6 +240,73 @@ func (q *Queries) ListSnapshots(ctx context.Context, arg ListSnapshotsParams) ([
 			&i.Message,
 			&i.CreatedAt,
 			&i.DeletedAt,
+			&i.State,
+			&i.TaskID,
+			&i.SizeBytes,
+			&i.FileCount,
+			&i.Metadata,
+			&i.UniqueBytes,
+			&i.SharedBytes,
+			&i.TreeDigest,
+			&i.VerifyState,
+			&i.VerifiedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSnapshotsDueForVerify = `-- name: ListSnapshotsDueForVerify :many
+SELECT s.snapshot_id, s.wsid, s.fs_path, s.message, s.created_at, s.deleted_at, s.state, s.task_id, s.size_bytes, s.file_count, s.metadata, s.unique_bytes, s.shared_bytes, s.tree_digest, s.verify_state, s.verified_at FROM wvs.snapshots s
+JOIN wvs.workspaces w ON w.wsid = s.wsid
+WHERE s.deleted_at IS NULL AND s.state = 'READY' AND w.state = 'ACTIVE'
+  AND COALESCE(s.verified_at, s.created_at) < $2::timestamptz
+  AND NOT EXISTS (
+    SELECT 1 FROM wvs.tasks t
+    WHERE t.wsid = s.wsid AND t.op = 'verify'
+      AND t.status IN ('PENDING', 'RUNNING', 'FAILED')
+      AND t.params->>'snapshot_id' = s.snapshot_id
+  )
+ORDER BY COALESCE(s.verified_at, s.created_at)
+LIMIT $1
+`
+
+type ListSnapshotsDueForVerifyParams struct {
+	Limit          int32              `json:"limit"`
+	VerifiedBefore pgtype.Timestamptz `json:"verified_before"`
+}
+
+func (q *Queries) ListSnapshotsDueForVerify(ctx context.Context, arg ListSnapshotsDueForVerifyParams) ([]WvsSnapshot, error) {
+	rows, err := q.db.Query(ctx, listSnapshotsDueForVerify, arg.Limit, arg.VerifiedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WvsSnapshot{}
+	for rows.Next() {
+		var i WvsSnapshot
+		if err := rows.Scan(
+			&i.SnapshotID,
+			&i.Wsid,
+			&i.FsPath,
+			&i.Message,
+			&i.CreatedAt,
+			&i.DeletedAt,
+			&i.State,
+			&i.TaskID,
+			&i.SizeBytes,
+			&i.FileCount,
+			&i.Metadata,
+			&i.UniqueBytes,
+			&i.SharedBytes,
+			&i.TreeDigest,
+			&i.VerifyState,
+			&i.VerifiedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -133,3 +326,99 @@ func (q *Queries) MarkSnapshotDeleted(ctx context.Context, snapshotID string) er
 	_, err := q.db.Exec(ctx, markSnapshotDeleted, snapshotID)
 	return err
 }
+
+const markSnapshotReady = `-- name: MarkSnapshotReady :exec
+INSERT INTO wvs.snapshots (snapshot_id, wsid, fs_path, message, state, task_id, size_bytes, file_count, unique_bytes, shared_bytes, metadata, tree_digest, created_at)
+VALUES ($1, $2, $3, $4, 'READY', $5, $6, $7, $8, $9, $10, $11, now())
+ON CONFLICT (snapshot_id) DO UPDATE
+SET fs_path = EXCLUDED.fs_path,
+    size_bytes = EXCLUDED.size_bytes,
+    file_count = EXCLUDED.file_count,
+    unique_bytes = EXCLUDED.unique_bytes,
+    shared_bytes = EXCLUDED.shared_bytes,
+    metadata = EXCLUDED.metadata,
+    tree_digest = EXCLUDED.tree_digest,
+    state = 'READY'
+`
+
+type MarkSnapshotReadyParams struct {
+	SnapshotID  string      `json:"snapshot_id"`
+	Wsid        string      `json:"wsid"`
+	FsPath      string      `json:"fs_path"`
+	Message     pgtype.Text `json:"message"`
+	TaskID      pgtype.Text `json:"task_id"`
+	SizeBytes   pgtype.Int8 `json:"size_bytes"`
+	FileCount   pgtype.Int8 `json:"file_count"`
+	UniqueBytes pgtype.Int8 `json:"unique_bytes"`
+	SharedBytes pgtype.Int8 `json:"shared_bytes"`
+	Metadata    []byte      `json:"metadata"`
+	TreeDigest  pgtype.Text `json:"tree_digest"`
+}
+
+func (q *Queries) MarkSnapshotReady(ctx context.Context, arg MarkSnapshotReadyParams) error {
+	_, err := q.db.Exec(ctx, markSnapshotReady,
+		arg.SnapshotID,
+		arg.Wsid,
+		arg.FsPath,
+		arg.Message,
+		arg.TaskID,
+		arg.SizeBytes,
+		arg.FileCount,
+		arg.UniqueBytes,
+		arg.SharedBytes,
+		arg.Metadata,
+		arg.TreeDigest,
+	)
+	return err
+}
+
+const recordSnapshotVerify = `-- name: RecordSnapshotVerify :exec
+UPDATE wvs.snapshots
+SET verify_state = $2,
+    verified_at = now(),
+    tree_digest = COALESCE(tree_digest, $3::text)
+WHERE snapshot_id = $1
+`
+
+type RecordSnapshotVerifyParams struct {
+	SnapshotID  string      `json:"snapshot_id"`
+	VerifyState pgtype.Text `json:"verify_state"`
+	TreeDigest  pgtype.Text `json:"tree_digest"`
+}
+
+func (q *Queries) RecordSnapshotVerify(ctx context.Context, arg RecordSnapshotVerifyParams) error {
+	_, err := q.db.Exec(ctx, recordSnapshotVerify, arg.SnapshotID, arg.VerifyState, arg.TreeDigest)
+	return err
+}
+
+const resetSnapshotForRetry = `-- name: ResetSnapshotForRetry :execrows
+UPDATE wvs.snapshots SET state = 'PENDING', task_id = $2
+WHERE snapshot_id = $1 AND state = 'FAILED'
+`
+
+type ResetSnapshotForRetryParams struct {
+	SnapshotID string      `json:"snapshot_id"`
+	TaskID     pgtype.Text `json:"task_id"`
+}
+
+func (q *Queries) ResetSnapshotForRetry(ctx context.Context, arg ResetSnapshotForRetryParams) (int64, error) {
+	result, err := q.db.Exec(ctx, resetSnapshotForRetry, arg.SnapshotID, arg.TaskID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateSnapshotState = `-- name: UpdateSnapshotState :exec
+UPDATE wvs.snapshots SET state = $2 WHERE snapshot_id = $1
+`
+
+type UpdateSnapshotStateParams struct {
+	SnapshotID string `json:"snapshot_id"`
+	State      string `json:"state"`
+}
+
+func (q *Queries) UpdateSnapshotState(ctx context.Context, arg UpdateSnapshotStateParams) error {
+	_, err := q.db.Exec(ctx, updateSnapshotState, arg.SnapshotID, arg.State)
+	return err
+}
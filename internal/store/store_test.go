@@ -52,7 +52,19 @@ func TestStoreIntegration(t *testing.T) {
 			current_snapshot_id TEXT,
 			current_path TEXT NOT NULL,
 			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			quota_soft_bytes BIGINT,
+			quota_hard_bytes BIGINT,
+			quota_soft_snapshots INT,
+			quota_hard_snapshots INT,
+			usage_bytes BIGINT NOT NULL DEFAULT 0,
+			usage_snapshots INT NOT NULL DEFAULT 0,
+			live_unique_bytes BIGINT NOT NULL DEFAULT 0,
+			usage_updated_at TIMESTAMPTZ,
+			quiesce_policy TEXT NOT NULL DEFAULT 'best-effort'
+				CHECK (quiesce_policy IN ('require-guest', 'best-effort', 'none')),
+			hooks JSONB NOT NULL DEFAULT '{}',
+			exclude_patterns TEXT[] NOT NULL DEFAULT '{}'
 		);
 		CREATE TABLE wvs.tasks (
 			task_id TEXT PRIMARY KEY,
@@ -71,7 +83,18 @@ func TestStoreIntegration(t *testing.T) {
 			cancel_requested BOOLEAN NOT NULL DEFAULT false,
 			params JSONB NOT NULL DEFAULT '{}'::jsonb,
 			result JSONB,
-			error JSONB
+			error JSONB,
+			retry_of TEXT REFERENCES wvs.tasks(task_id),
+			dlq_resolution TEXT,
+			dlq_resolved_at TIMESTAMPTZ,
+			retry_base_ms INT NOT NULL DEFAULT 5000,
+			retry_multiplier DOUBLE PRECISION NOT NULL DEFAULT 2,
+			retry_max_delay_ms INT NOT NULL DEFAULT 300000,
+			retry_jitter DOUBLE PRECISION NOT NULL DEFAULT 0,
+			priority INT NOT NULL DEFAULT 50,
+			depends_on TEXT REFERENCES wvs.tasks(task_id),
+			batch_id TEXT,
+			batch_seq INT
 		);
 	`)
 	if err != nil {
@@ -82,10 +105,13 @@ func TestStoreIntegration(t *testing.T) {
 
 	t.Run("CreateWorkspace", func(t *testing.T) {
 		ws, err := queries.CreateWorkspace(ctx, CreateWorkspaceParams{
-			Wsid:        "test-ws-1",
-			RootPath:    "/ws/test-ws-1",
-			Owner:       "test-user",
-			CurrentPath: "/ws/test-ws-1",
+			Wsid:            "test-ws-1",
+			RootPath:        "/ws/test-ws-1",
+			Owner:           "test-user",
+			CurrentPath:     "/ws/test-ws-1",
+			QuiescePolicy:   "best-effort",
+			Hooks:           []byte("{}"),
+			ExcludePatterns: []string{},
 		})
 		if err != nil {
 			t.Fatalf("failed to create workspace: %s", err)
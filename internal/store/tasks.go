@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
+
+// AbandonTask cleans up after a task that ended without succeeding: the
+// snapshot it reserved is marked FAILED, and every task that (transitively)
+// waits on it is canceled along with the snapshots they reserved. It returns
+// the dependents it canceled so callers can record their events. The worker
+// and the API's cancel path both go through here.
+func (q *Queries) AbandonTask(ctx context.Context, task WvsTask) ([]WvsTask, error) {
+	errFail := q.failReservedSnapshot(ctx, task)
+
+	errJSON, _ := json.Marshal(map[string]string{
+		"error": "predecessor " + task.TaskID + " did not succeed",
+		"code":  string(core.ErrClassCanceled),
+	})
+	canceled, err := q.CancelDependentTasks(ctx, CancelDependentTasksParams{
+		TaskID: task.TaskID,
+		Error:  errJSON,
+	})
+	if err != nil {
+		return nil, errors.Join(errFail, err)
+	}
+	errs := []error{errFail}
+	for _, dep := range canceled {
+		errs = append(errs, q.failReservedSnapshot(ctx, dep))
+	}
+	return canceled, errors.Join(errs...)
+}
+
+// failReservedSnapshot marks the snapshot reserved by a snapshot_create,
+// import or checkpoint_restore task as FAILED.
+func (q *Queries) failReservedSnapshot(ctx context.Context, task WvsTask) error {
+	var params map[string]string
+	_ = json.Unmarshal(task.Params, &params)
+	snapshotID := core.ReservedSnapshotID(core.TaskOp(task.Op), params)
+	if snapshotID == "" {
+		return nil
+	}
+	return q.UpdateSnapshotState(ctx, UpdateSnapshotStateParams{
+		SnapshotID: snapshotID,
+		State:      string(core.SnapshotFailed),
+	})
+}
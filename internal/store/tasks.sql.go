@@ -20,10 +20,75 @@ func (q *Queries) AcquireWorkspaceLock(ctx context.Context, hashtext string) err
 	return err
 }
 
+const cancelDependentTasks = `-- name: CancelDependentTasks :many
+WITH RECURSIVE deps AS (
+  SELECT task_id FROM wvs.tasks WHERE depends_on = $1::text
+  UNION
+  SELECT t.task_id FROM wvs.tasks t JOIN deps ON t.depends_on = deps.task_id
+)
+UPDATE wvs.tasks SET status = 'CANCELED', ended_at = now(), error = $2
+WHERE task_id IN (SELECT task_id FROM deps) AND status IN ('PENDING', 'FAILED')
+RETURNING task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error, retry_of, dlq_resolution, dlq_resolved_at, retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, depends_on, batch_id, batch_seq
+`
+
+type CancelDependentTasksParams struct {
+	TaskID string `json:"task_id"`
+	Error  []byte `json:"error"`
+}
+
+func (q *Queries) CancelDependentTasks(ctx context.Context, arg CancelDependentTasksParams) ([]WvsTask, error) {
+	rows, err := q.db.Query(ctx, cancelDependentTasks, arg.TaskID, arg.Error)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WvsTask{}
+	for rows.Next() {
+		var i WvsTask
+		if err := rows.Scan(
+			&i.TaskID,
+			&i.Wsid,
+			&i.Op,
+			&i.Status,
+			&i.IdempotencyKey,
+			&i.RequestHash,
+			&i.CreatedAt,
+			&i.StartedAt,
+			&i.EndedAt,
+			&i.Attempt,
+			&i.MaxAttempts,
+			&i.NextRunAt,
+			&i.TimeoutSeconds,
+			&i.CancelRequested,
+			&i.Params,
+			&i.Result,
+			&i.Error,
+			&i.RetryOf,
+			&i.DlqResolution,
+			&i.DlqResolvedAt,
+			&i.RetryBaseMs,
+			&i.RetryMultiplier,
+			&i.RetryMaxDelayMs,
+			&i.RetryJitter,
+			&i.Priority,
+			&i.DependsOn,
+			&i.BatchID,
+			&i.BatchSeq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const cancelPendingTask = `-- name: CancelPendingTask :one
 UPDATE wvs.tasks SET status = 'CANCELED', ended_at = now()
 WHERE task_id = $1 AND status = 'PENDING'
-RETURNING task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error
+RETURNING task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error, retry_of, dlq_resolution, dlq_resolved_at, retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, depends_on, batch_id, batch_seq
 `
 
 func (q *Queries) CancelPendingTask(ctx context.Context, taskID string) (WvsTask, error) {
@@ -47,6 +112,17 @@ func (q *Queries) CancelPendingTask(ctx context.Context, taskID string) (WvsTask
 		&i.Params,
 		&i.Result,
 		&i.Error,
+		&i.RetryOf,
+		&i.DlqResolution,
+		&i.DlqResolvedAt,
+		&i.RetryBaseMs,
+		&i.RetryMultiplier,
+		&i.RetryMaxDelayMs,
+		&i.RetryJitter,
+		&i.Priority,
+		&i.DependsOn,
+		&i.BatchID,
+		&i.BatchSeq,
 	)
 	return i, err
 }
@@ -86,21 +162,140 @@ func (q *Queries) CountActiveTasks(ctx context.Context, wsid string) (int64, err
 	return count, err
 }
 
+const countDeadTasksByOp = `-- name: CountDeadTasksByOp :many
+SELECT op, count(*)::bigint AS count FROM wvs.tasks
+WHERE status = 'DEAD' AND dlq_resolution IS NULL
+GROUP BY op
+`
+
+type CountDeadTasksByOpRow struct {
+	Op    string `json:"op"`
+	Count int64  `json:"count"`
+}
+
+func (q *Queries) CountDeadTasksByOp(ctx context.Context) ([]CountDeadTasksByOpRow, error) {
+	rows, err := q.db.Query(ctx, countDeadTasksByOp)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CountDeadTasksByOpRow{}
+	for rows.Next() {
+		var i CountDeadTasksByOpRow
+		if err := rows.Scan(
+			&i.Op,
+			&i.Count,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createRetryTask = `-- name: CreateRetryTask :one
+INSERT INTO wvs.tasks (task_id, wsid, op, status, idempotency_key, request_hash, params, max_attempts, timeout_seconds,
+                       retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, retry_of)
+VALUES ($1, $2, $3, 'PENDING', $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+RETURNING task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error, retry_of, dlq_resolution, dlq_resolved_at, retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, depends_on, batch_id, batch_seq
+`
+
+type CreateRetryTaskParams struct {
+	TaskID          string      `json:"task_id"`
+	Wsid            string      `json:"wsid"`
+	Op              string      `json:"op"`
+	IdempotencyKey  string      `json:"idempotency_key"`
+	RequestHash     string      `json:"request_hash"`
+	Params          []byte      `json:"params"`
+	MaxAttempts     int32       `json:"max_attempts"`
+	TimeoutSeconds  int32       `json:"timeout_seconds"`
+	RetryBaseMs     int32       `json:"retry_base_ms"`
+	RetryMultiplier float64     `json:"retry_multiplier"`
+	RetryMaxDelayMs int32       `json:"retry_max_delay_ms"`
+	RetryJitter     float64     `json:"retry_jitter"`
+	Priority        int32       `json:"priority"`
+	RetryOf         pgtype.Text `json:"retry_of"`
+}
+
+func (q *Queries) CreateRetryTask(ctx context.Context, arg CreateRetryTaskParams) (WvsTask, error) {
+	row := q.db.QueryRow(ctx, createRetryTask,
+		arg.TaskID,
+		arg.Wsid,
+		arg.Op,
+		arg.IdempotencyKey,
+		arg.RequestHash,
+		arg.Params,
+		arg.MaxAttempts,
+		arg.TimeoutSeconds,
+		arg.RetryBaseMs,
+		arg.RetryMultiplier,
+		arg.RetryMaxDelayMs,
+		arg.RetryJitter,
+		arg.Priority,
+		arg.RetryOf,
+	)
+	var i WvsTask
+	err := row.Scan(
+		&i.TaskID,
+		&i.Wsid,
+		&i.Op,
+		&i.Status,
+		&i.IdempotencyKey,
+		&i.RequestHash,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.EndedAt,
+		&i.Attempt,
+		&i.MaxAttempts,
+		&i.NextRunAt,
+		&i.TimeoutSeconds,
+		&i.CancelRequested,
+		&i.Params,
+		&i.Result,
+		&i.Error,
+		&i.RetryOf,
+		&i.DlqResolution,
+		&i.DlqResolvedAt,
+		&i.RetryBaseMs,
+		&i.RetryMultiplier,
+		&i.RetryMaxDelayMs,
+		&i.RetryJitter,
+		&i.Priority,
+		&i.DependsOn,
+		&i.BatchID,
+		&i.BatchSeq,
+	)
+	return i, err
+}
+
 const createTask = `-- name: CreateTask :one
-INSERT INTO wvs.tasks (task_id, wsid, op, status, idempotency_key, request_hash, params, max_attempts, timeout_seconds)
-VALUES ($1, $2, $3, 'PENDING', $4, $5, $6, $7, $8)
-RETURNING task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error
+INSERT INTO wvs.tasks (task_id, wsid, op, status, idempotency_key, request_hash, params, max_attempts, timeout_seconds,
+                       retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority,
+                       depends_on, batch_id, batch_seq)
+VALUES ($1, $2, $3, 'PENDING', $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+RETURNING task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error, retry_of, dlq_resolution, dlq_resolved_at, retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, depends_on, batch_id, batch_seq
 `
 
 type CreateTaskParams struct {
-	TaskID         string `json:"task_id"`
-	Wsid           string `json:"wsid"`
-	Op             string `json:"op"`
-	IdempotencyKey string `json:"idempotency_key"`
-	RequestHash    string `json:"request_hash"`
-	Params         []byte `json:"params"`
-	MaxAttempts    int32  `json:"max_attempts"`
-	TimeoutSeconds int32  `json:"timeout_seconds"`
+	TaskID          string      `json:"task_id"`
+	Wsid            string      `json:"wsid"`
+	Op              string      `json:"op"`
+	IdempotencyKey  string      `json:"idempotency_key"`
+	RequestHash     string      `json:"request_hash"`
+	Params          []byte      `json:"params"`
+	MaxAttempts     int32       `json:"max_attempts"`
+	TimeoutSeconds  int32       `json:"timeout_seconds"`
+	RetryBaseMs     int32       `json:"retry_base_ms"`
+	RetryMultiplier float64     `json:"retry_multiplier"`
+	RetryMaxDelayMs int32       `json:"retry_max_delay_ms"`
+	RetryJitter     float64     `json:"retry_jitter"`
+	Priority        int32       `json:"priority"`
+	DependsOn       pgtype.Text `json:"depends_on"`
+	BatchID         pgtype.Text `json:"batch_id"`
+	BatchSeq        pgtype.Int4 `json:"batch_seq"`
 }
 
 func (q *Queries) CreateTask(ctx context.Context, arg CreateTaskParams) (WvsTask, error) {
@@ -113,6 +308,14 @@ func (q *Queries) CreateTask(ctx context.Context, arg CreateTaskParams) (WvsTask
 		arg.Params,
 		arg.MaxAttempts,
 		arg.TimeoutSeconds,
+		arg.RetryBaseMs,
+		arg.RetryMultiplier,
+		arg.RetryMaxDelayMs,
+		arg.RetryJitter,
+		arg.Priority,
+		arg.DependsOn,
+		arg.BatchID,
+		arg.BatchSeq,
 	)
 	var i WvsTask
 	err := row.Scan(
@@ -133,26 +336,45 @@ func (q *Queries) CreateTask(ctx context.Context, arg CreateTaskParams) (WvsTask
 		&i.Params,
 		&i.Result,
 		&i.Error,
+		&i.RetryOf,
+		&i.DlqResolution,
+		&i.DlqResolvedAt,
+		&i.RetryBaseMs,
+		&i.RetryMultiplier,
+		&i.RetryMaxDelayMs,
+		&i.RetryJitter,
+		&i.Priority,
+		&i.DependsOn,
+		&i.BatchID,
+		&i.BatchSeq,
 	)
 	return i, err
 }
 
 const dequeueTask = `-- name: DequeueTask :one
 WITH picked AS (
-  SELECT task_id
-  FROM wvs.tasks
-  WHERE status IN ('PENDING', 'FAILED')
-    AND next_run_at <= now()
-    AND attempt < max_attempts
-  ORDER BY created_at
-  FOR UPDATE SKIP LOCKED
+  SELECT t.task_id, t.wsid
+  FROM wvs.tasks t
+  LEFT JOIN wvs.queue_fairness f ON f.wsid = t.wsid
+  WHERE t.status IN ('PENDING', 'FAILED')
+    AND t.next_run_at <= now()
+    AND t.attempt < t.max_attempts
+    AND (t.depends_on IS NULL OR EXISTS (
+      SELECT 1 FROM wvs.tasks d WHERE d.task_id = t.depends_on AND d.status = 'SUCCEEDED'
+    ))
+  ORDER BY t.priority DESC, f.last_dequeued_at NULLS FIRST, t.created_at
+  FOR UPDATE OF t SKIP LOCKED
   LIMIT 1
+), served AS (
+  INSERT INTO wvs.queue_fairness (wsid, last_dequeued_at)
+  SELECT wsid, clock_timestamp() FROM picked
+  ON CONFLICT (wsid) DO UPDATE SET last_dequeued_at = EXCLUDED.last_dequeued_at
 )
 UPDATE wvs.tasks t
 SET status = 'RUNNING', started_at = now(), attempt = attempt + 1
 FROM picked
 WHERE t.task_id = picked.task_id
-RETURNING t.task_id, t.wsid, t.op, t.status, t.idempotency_key, t.request_hash, t.created_at, t.started_at, t.ended_at, t.attempt, t.max_attempts, t.next_run_at, t.timeout_seconds, t.cancel_requested, t.params, t.result, t.error
+RETURNING t.task_id, t.wsid, t.op, t.status, t.idempotency_key, t.request_hash, t.created_at, t.started_at, t.ended_at, t.attempt, t.max_attempts, t.next_run_at, t.timeout_seconds, t.cancel_requested, t.params, t.result, t.error, t.retry_of, t.dlq_resolution, t.dlq_resolved_at, t.retry_base_ms, t.retry_multiplier, t.retry_max_delay_ms, t.retry_jitter, t.priority, t.depends_on, t.batch_id, t.batch_seq
 `
 
 func (q *Queries) DequeueTask(ctx context.Context) (WvsTask, error) {
@@ -176,6 +398,17 @@ func (q *Queries) DequeueTask(ctx context.Context) (WvsTask, error) {
 		&i.Params,
 		&i.Result,
 		&i.Error,
+		&i.RetryOf,
+		&i.DlqResolution,
+		&i.DlqResolvedAt,
+		&i.RetryBaseMs,
+		&i.RetryMultiplier,
+		&i.RetryMaxDelayMs,
+		&i.RetryJitter,
+		&i.Priority,
+		&i.DependsOn,
+		&i.BatchID,
+		&i.BatchSeq,
 	)
 	return i, err
 }
@@ -183,17 +416,18 @@ func (q *Queries) DequeueTask(ctx context.Context) (WvsTask, error) {
 const failTask = `-- name: FailTask :exec
 UPDATE wvs.tasks
 SET status = 'FAILED', ended_at = now(), error = $2,
-    next_run_at = now() + make_interval(secs => least(5 * power(2, attempt - 1), 300))
+    next_run_at = now() + make_interval(secs => $3::float8)
 WHERE task_id = $1
 `
 
 type FailTaskParams struct {
-	TaskID string `json:"task_id"`
-	Error  []byte `json:"error"`
+	TaskID         string  `json:"task_id"`
+	Error          []byte  `json:"error"`
+	BackoffSeconds float64 `json:"backoff_seconds"`
 }
 
 func (q *Queries) FailTask(ctx context.Context, arg FailTaskParams) error {
-	_, err := q.db.Exec(ctx, failTask, arg.TaskID, arg.Error)
+	_, err := q.db.Exec(ctx, failTask, arg.TaskID, arg.Error, arg.BackoffSeconds)
 	return err
 }
 
@@ -210,7 +444,7 @@ func (q *Queries) GetQueueDepth(ctx context.Context) (int64, error) {
 }
 
 const getTask = `-- name: GetTask :one
-SELECT task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error FROM wvs.tasks WHERE task_id = $1
+SELECT task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error, retry_of, dlq_resolution, dlq_resolved_at, retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, depends_on, batch_id, batch_seq FROM wvs.tasks WHERE task_id = $1
 `
 
 func (q *Queries) GetTask(ctx context.Context, taskID string) (WvsTask, error) {
@@ -234,12 +468,23 @@ func (q *Queries) GetTask(ctx context.Context, taskID string) (WvsTask, error) {
 		&i.Params,
 		&i.Result,
 		&i.Error,
+		&i.RetryOf,
+		&i.DlqResolution,
+		&i.DlqResolvedAt,
+		&i.RetryBaseMs,
+		&i.RetryMultiplier,
+		&i.RetryMaxDelayMs,
+		&i.RetryJitter,
+		&i.Priority,
+		&i.DependsOn,
+		&i.BatchID,
+		&i.BatchSeq,
 	)
 	return i, err
 }
 
 const getTaskByIdempotencyKey = `-- name: GetTaskByIdempotencyKey :one
-SELECT task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error FROM wvs.tasks WHERE wsid = $1 AND op = $2 AND idempotency_key = $3
+SELECT task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error, retry_of, dlq_resolution, dlq_resolved_at, retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, depends_on, batch_id, batch_seq FROM wvs.tasks WHERE wsid = $1 AND op = $2 AND idempotency_key = $3
 `
 
 type GetTaskByIdempotencyKeyParams struct {
@@ -269,12 +514,193 @@ func (q *Queries) GetTaskByIdempotencyKey(ctx context.Context, arg GetTaskByIdem
 		&i.Params,
 		&i.Result,
 		&i.Error,
+		&i.RetryOf,
+		&i.DlqResolution,
+		&i.DlqResolvedAt,
+		&i.RetryBaseMs,
+		&i.RetryMultiplier,
+		&i.RetryMaxDelayMs,
+		&i.RetryJitter,
+		&i.Priority,
+		&i.DependsOn,
+		&i.BatchID,
+		&i.BatchSeq,
+	)
+	return i, err
+}
+
+const getTaskRetry = `-- name: GetTaskRetry :one
+SELECT task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error, retry_of, dlq_resolution, dlq_resolved_at, retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, depends_on, batch_id, batch_seq FROM wvs.tasks WHERE retry_of = $1
+`
+
+func (q *Queries) GetTaskRetry(ctx context.Context, retryOf pgtype.Text) (WvsTask, error) {
+	row := q.db.QueryRow(ctx, getTaskRetry, retryOf)
+	var i WvsTask
+	err := row.Scan(
+		&i.TaskID,
+		&i.Wsid,
+		&i.Op,
+		&i.Status,
+		&i.IdempotencyKey,
+		&i.RequestHash,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.EndedAt,
+		&i.Attempt,
+		&i.MaxAttempts,
+		&i.NextRunAt,
+		&i.TimeoutSeconds,
+		&i.CancelRequested,
+		&i.Params,
+		&i.Result,
+		&i.Error,
+		&i.RetryOf,
+		&i.DlqResolution,
+		&i.DlqResolvedAt,
+		&i.RetryBaseMs,
+		&i.RetryMultiplier,
+		&i.RetryMaxDelayMs,
+		&i.RetryJitter,
+		&i.Priority,
+		&i.DependsOn,
+		&i.BatchID,
+		&i.BatchSeq,
 	)
 	return i, err
 }
 
+const listBatchTasks = `-- name: ListBatchTasks :many
+SELECT task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error, retry_of, dlq_resolution, dlq_resolved_at, retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, depends_on, batch_id, batch_seq FROM wvs.tasks WHERE batch_id = $1 ORDER BY batch_seq
+`
+
+func (q *Queries) ListBatchTasks(ctx context.Context, batchID pgtype.Text) ([]WvsTask, error) {
+	rows, err := q.db.Query(ctx, listBatchTasks, batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WvsTask{}
+	for rows.Next() {
+		var i WvsTask
+		if err := rows.Scan(
+			&i.TaskID,
+			&i.Wsid,
+			&i.Op,
+			&i.Status,
+			&i.IdempotencyKey,
+			&i.RequestHash,
+			&i.CreatedAt,
+			&i.StartedAt,
+			&i.EndedAt,
+			&i.Attempt,
+			&i.MaxAttempts,
+			&i.NextRunAt,
+			&i.TimeoutSeconds,
+			&i.CancelRequested,
+			&i.Params,
+			&i.Result,
+			&i.Error,
+			&i.RetryOf,
+			&i.DlqResolution,
+			&i.DlqResolvedAt,
+			&i.RetryBaseMs,
+			&i.RetryMultiplier,
+			&i.RetryMaxDelayMs,
+			&i.RetryJitter,
+			&i.Priority,
+			&i.DependsOn,
+			&i.BatchID,
+			&i.BatchSeq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeadTasks = `-- name: ListDeadTasks :many
+SELECT task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error, retry_of, dlq_resolution, dlq_resolved_at, retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, depends_on, batch_id, batch_seq FROM wvs.tasks
+WHERE status = 'DEAD' AND dlq_resolution IS NULL
+  AND ($2::text IS NULL OR wsid = $2::text)
+  AND ($3::text IS NULL OR op = $3::text)
+  AND ($4::text IS NULL OR error->>'code' = $4::text)
+  AND ($5::timestamptz IS NULL OR ended_at >= $5::timestamptz)
+  AND ($6::timestamptz IS NULL OR ended_at < $6::timestamptz)
+ORDER BY ended_at
+LIMIT $1
+`
+
+type ListDeadTasksParams struct {
+	Limit     int32              `json:"limit"`
+	Wsid      pgtype.Text        `json:"wsid"`
+	Op        pgtype.Text        `json:"op"`
+	ErrorCode pgtype.Text        `json:"error_code"`
+	Since     pgtype.Timestamptz `json:"since"`
+	Until     pgtype.Timestamptz `json:"until"`
+}
+
+func (q *Queries) ListDeadTasks(ctx context.Context, arg ListDeadTasksParams) ([]WvsTask, error) {
+	rows, err := q.db.Query(ctx, listDeadTasks,
+		arg.Limit,
+		arg.Wsid,
+		arg.Op,
+		arg.ErrorCode,
+		arg.Since,
+		arg.Until,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WvsTask{}
+	for rows.Next() {
+		var i WvsTask
+		if err := rows.Scan(
+			&i.TaskID,
+			&i.Wsid,
+			&i.Op,
+			&i.Status,
+			&i.IdempotencyKey,
+			&i.RequestHash,
+			&i.CreatedAt,
+			&i.StartedAt,
+			&i.EndedAt,
+			&i.Attempt,
+			&i.MaxAttempts,
+			&i.NextRunAt,
+			&i.TimeoutSeconds,
+			&i.CancelRequested,
+			&i.Params,
+			&i.Result,
+			&i.Error,
+			&i.RetryOf,
+			&i.DlqResolution,
+			&i.DlqResolvedAt,
+			&i.RetryBaseMs,
+			&i.RetryMultiplier,
+			&i.RetryMaxDelayMs,
+			&i.RetryJitter,
+			&i.Priority,
+			&i.DependsOn,
+			&i.BatchID,
+			&i.BatchSeq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTasks = `-- name: ListTasks :many
-SELECT task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error FROM wvs.tasks
+SELECT task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error, retry_of, dlq_resolution, dlq_resolved_at, retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, depends_on, batch_id, batch_seq FROM wvs.tasks
 WHERE ($2::text IS NULL OR wsid = $2::text)
   AND ($3::text IS NULL OR status = $3::text)
   AND ($4::text IS NULL OR op = $4::text)
@@ -324,6 +750,17 @@ func (q *Queries) ListTasks(ctx context.Context, arg ListTasksParams) ([]WvsTask
 			&i.Params,
 			&i.Result,
 			&i.Error,
+			&i.RetryOf,
+			&i.DlqResolution,
+			&i.DlqResolvedAt,
+			&i.RetryBaseMs,
+			&i.RetryMultiplier,
+			&i.RetryMaxDelayMs,
+			&i.RetryJitter,
+			&i.Priority,
+			&i.DependsOn,
+			&i.BatchID,
+			&i.BatchSeq,
 		); err != nil {
 			return nil, err
 		}
@@ -352,7 +789,7 @@ func (q *Queries) MarkTaskDead(ctx context.Context, arg MarkTaskDeadParams) erro
 const requestCancelRunningTask = `-- name: RequestCancelRunningTask :one
 UPDATE wvs.tasks SET cancel_requested = true
 WHERE task_id = $1 AND status = 'RUNNING'
-RETURNING task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error
+RETURNING task_id, wsid, op, status, idempotency_key, request_hash, created_at, started_at, ended_at, attempt, max_attempts, next_run_at, timeout_seconds, cancel_requested, params, result, error, retry_of, dlq_resolution, dlq_resolved_at, retry_base_ms, retry_multiplier, retry_max_delay_ms, retry_jitter, priority, depends_on, batch_id, batch_seq
 `
 
 func (q *Queries) RequestCancelRunningTask(ctx context.Context, taskID string) (WvsTask, error) {
@@ -376,6 +813,35 @@ func (q *Queries) RequestCancelRunningTask(ctx context.Context, taskID string) (
 		&i.Params,
 		&i.Result,
 		&i.Error,
+		&i.RetryOf,
+		&i.DlqResolution,
+		&i.DlqResolvedAt,
+		&i.RetryBaseMs,
+		&i.RetryMultiplier,
+		&i.RetryMaxDelayMs,
+		&i.RetryJitter,
+		&i.Priority,
+		&i.DependsOn,
+		&i.BatchID,
+		&i.BatchSeq,
 	)
 	return i, err
 }
+
+const resolveDeadTask = `-- name: ResolveDeadTask :execrows
+UPDATE wvs.tasks SET dlq_resolution = $2, dlq_resolved_at = now()
+WHERE task_id = $1 AND status = 'DEAD' AND dlq_resolution IS NULL
+`
+
+type ResolveDeadTaskParams struct {
+	TaskID        string      `json:"task_id"`
+	DlqResolution pgtype.Text `json:"dlq_resolution"`
+}
+
+func (q *Queries) ResolveDeadTask(ctx context.Context, arg ResolveDeadTaskParams) (int64, error) {
+	result, err := q.db.Exec(ctx, resolveDeadTask, arg.TaskID, arg.DlqResolution)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
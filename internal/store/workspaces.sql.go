@@ -12,16 +12,19 @@ import (
 )
 
 const createWorkspace = `-- name: CreateWorkspace :one
-INSERT INTO wvs.workspaces (wsid, root_path, owner, state, current_path, created_at, updated_at)
-VALUES ($1, $2, $3, 'PROVISIONING', $4, now(), now())
-RETURNING wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at
+INSERT INTO wvs.workspaces (wsid, root_path, owner, state, current_path, quiesce_policy, hooks, exclude_patterns, created_at, updated_at)
+VALUES ($1, $2, $3, 'PROVISIONING', $4, $5, $6, $7, now(), now())
+RETURNING wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at, quota_soft_bytes, quota_hard_bytes, quota_soft_snapshots, quota_hard_snapshots, usage_bytes, usage_snapshots, live_unique_bytes, usage_updated_at, quiesce_policy, hooks, exclude_patterns
 `
 
 type CreateWorkspaceParams struct {
-	Wsid        string `json:"wsid"`
-	RootPath    string `json:"root_path"`
-	Owner       string `json:"owner"`
-	CurrentPath string `json:"current_path"`
+	Wsid            string   `json:"wsid"`
+	RootPath        string   `json:"root_path"`
+	Owner           string   `json:"owner"`
+	CurrentPath     string   `json:"current_path"`
+	QuiescePolicy   string   `json:"quiesce_policy"`
+	Hooks           []byte   `json:"hooks"`
+	ExcludePatterns []string `json:"exclude_patterns"`
 }
 
 func (q *Queries) CreateWorkspace(ctx context.Context, arg CreateWorkspaceParams) (WvsWorkspace, error) {
@@ -30,6 +33,9 @@ func (q *Queries) CreateWorkspace(ctx context.Context, arg CreateWorkspaceParams
 		arg.RootPath,
 		arg.Owner,
 		arg.CurrentPath,
+		arg.QuiescePolicy,
+		arg.Hooks,
+		arg.ExcludePatterns,
 	)
 	var i WvsWorkspace
 	err := row.Scan(
@@ -41,6 +47,17 @@ func (q *Queries) CreateWorkspace(ctx context.Context, arg CreateWorkspaceParams
 		&i.CurrentPath,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.QuotaSoftBytes,
+		&i.QuotaHardBytes,
+		&i.QuotaSoftSnapshots,
+		&i.QuotaHardSnapshots,
+		&i.UsageBytes,
+		&i.UsageSnapshots,
+		&i.LiveUniqueBytes,
+		&i.UsageUpdatedAt,
+		&i.QuiescePolicy,
+		&i.Hooks,
+		&i.ExcludePatterns,
 	)
 	return i, err
 }
@@ -48,7 +65,7 @@ func (q *Queries) CreateWorkspace(ctx context.Context, arg CreateWorkspaceParams
 const disableWorkspace = `-- name: DisableWorkspace :one
 UPDATE wvs.workspaces SET state = 'DISABLED', updated_at = now()
 WHERE wsid = $1
-RETURNING wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at
+RETURNING wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at, quota_soft_bytes, quota_hard_bytes, quota_soft_snapshots, quota_hard_snapshots, usage_bytes, usage_snapshots, live_unique_bytes, usage_updated_at, quiesce_policy, hooks, exclude_patterns
 `
 
 func (q *Queries) DisableWorkspace(ctx context.Context, wsid string) (WvsWorkspace, error) {
@@ -63,12 +80,23 @@ func (q *Queries) DisableWorkspace(ctx context.Context, wsid string) (WvsWorkspa
 		&i.CurrentPath,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.QuotaSoftBytes,
+		&i.QuotaHardBytes,
+		&i.QuotaSoftSnapshots,
+		&i.QuotaHardSnapshots,
+		&i.UsageBytes,
+		&i.UsageSnapshots,
+		&i.LiveUniqueBytes,
+		&i.UsageUpdatedAt,
+		&i.QuiescePolicy,
+		&i.Hooks,
+		&i.ExcludePatterns,
 	)
 	return i, err
 }
 
 const getWorkspace = `-- name: GetWorkspace :one
-SELECT wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at FROM wvs.workspaces WHERE wsid = $1
+SELECT wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at, quota_soft_bytes, quota_hard_bytes, quota_soft_snapshots, quota_hard_snapshots, usage_bytes, usage_snapshots, live_unique_bytes, usage_updated_at, quiesce_policy, hooks, exclude_patterns FROM wvs.workspaces WHERE wsid = $1
 `
 
 func (q *Queries) GetWorkspace(ctx context.Context, wsid string) (WvsWorkspace, error) {
@@ -83,12 +111,23 @@ func (q *Queries) GetWorkspace(ctx context.Context, wsid string) (WvsWorkspace,
 		&i.CurrentPath,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.QuotaSoftBytes,
+		&i.QuotaHardBytes,
+		&i.QuotaSoftSnapshots,
+		&i.QuotaHardSnapshots,
+		&i.UsageBytes,
+		&i.UsageSnapshots,
+		&i.LiveUniqueBytes,
+		&i.UsageUpdatedAt,
+		&i.QuiescePolicy,
+		&i.Hooks,
+		&i.ExcludePatterns,
 	)
 	return i, err
 }
 
 const listWorkspaces = `-- name: ListWorkspaces :many
-SELECT wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at FROM wvs.workspaces
+SELECT wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at, quota_soft_bytes, quota_hard_bytes, quota_soft_snapshots, quota_hard_snapshots, usage_bytes, usage_snapshots, live_unique_bytes, usage_updated_at, quiesce_policy, hooks, exclude_patterns FROM wvs.workspaces
 WHERE ($2::timestamptz IS NULL OR created_at < $2::timestamptz)
 ORDER BY created_at DESC
 LIMIT $1
@@ -117,6 +156,17 @@ func (q *Queries) ListWorkspaces(ctx context.Context, arg ListWorkspacesParams)
 			&i.CurrentPath,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.QuotaSoftBytes,
+			&i.QuotaHardBytes,
+			&i.QuotaSoftSnapshots,
+			&i.QuotaHardSnapshots,
+			&i.UsageBytes,
+			&i.UsageSnapshots,
+			&i.LiveUniqueBytes,
+			&i.UsageUpdatedAt,
+			&i.QuiescePolicy,
+			&i.Hooks,
+			&i.ExcludePatterns,
 		); err != nil {
 			return nil, err
 		}
@@ -128,6 +178,173 @@ func (q *Queries) ListWorkspaces(ctx context.Context, arg ListWorkspacesParams)
 	return items, nil
 }
 
+const setWorkspaceExcludePatterns = `-- name: SetWorkspaceExcludePatterns :one
+UPDATE wvs.workspaces
+SET exclude_patterns = $2, updated_at = now()
+WHERE wsid = $1
+RETURNING wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at, quota_soft_bytes, quota_hard_bytes, quota_soft_snapshots, quota_hard_snapshots, usage_bytes, usage_snapshots, live_unique_bytes, usage_updated_at, quiesce_policy, hooks, exclude_patterns
+`
+
+type SetWorkspaceExcludePatternsParams struct {
+	Wsid            string   `json:"wsid"`
+	ExcludePatterns []string `json:"exclude_patterns"`
+}
+
+func (q *Queries) SetWorkspaceExcludePatterns(ctx context.Context, arg SetWorkspaceExcludePatternsParams) (WvsWorkspace, error) {
+	row := q.db.QueryRow(ctx, setWorkspaceExcludePatterns, arg.Wsid, arg.ExcludePatterns)
+	var i WvsWorkspace
+	err := row.Scan(
+		&i.Wsid,
+		&i.RootPath,
+		&i.Owner,
+		&i.State,
+		&i.CurrentSnapshotID,
+		&i.CurrentPath,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.QuotaSoftBytes,
+		&i.QuotaHardBytes,
+		&i.QuotaSoftSnapshots,
+		&i.QuotaHardSnapshots,
+		&i.UsageBytes,
+		&i.UsageSnapshots,
+		&i.LiveUniqueBytes,
+		&i.UsageUpdatedAt,
+		&i.QuiescePolicy,
+		&i.Hooks,
+		&i.ExcludePatterns,
+	)
+	return i, err
+}
+
+const setWorkspaceHooks = `-- name: SetWorkspaceHooks :one
+UPDATE wvs.workspaces
+SET hooks = $2, updated_at = now()
+WHERE wsid = $1
+RETURNING wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at, quota_soft_bytes, quota_hard_bytes, quota_soft_snapshots, quota_hard_snapshots, usage_bytes, usage_snapshots, live_unique_bytes, usage_updated_at, quiesce_policy, hooks, exclude_patterns
+`
+
+type SetWorkspaceHooksParams struct {
+	Wsid  string `json:"wsid"`
+	Hooks []byte `json:"hooks"`
+}
+
+func (q *Queries) SetWorkspaceHooks(ctx context.Context, arg SetWorkspaceHooksParams) (WvsWorkspace, error) {
+	row := q.db.QueryRow(ctx, setWorkspaceHooks, arg.Wsid, arg.Hooks)
+	var i WvsWorkspace
+	err := row.Scan(
+		&i.Wsid,
+		&i.RootPath,
+		&i.Owner,
+		&i.State,
+		&i.CurrentSnapshotID,
+		&i.CurrentPath,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.QuotaSoftBytes,
+		&i.QuotaHardBytes,
+		&i.QuotaSoftSnapshots,
+		&i.QuotaHardSnapshots,
+		&i.UsageBytes,
+		&i.UsageSnapshots,
+		&i.LiveUniqueBytes,
+		&i.UsageUpdatedAt,
+		&i.QuiescePolicy,
+		&i.Hooks,
+		&i.ExcludePatterns,
+	)
+	return i, err
+}
+
+const setWorkspaceQuiescePolicy = `-- name: SetWorkspaceQuiescePolicy :one
+UPDATE wvs.workspaces
+SET quiesce_policy = $2, updated_at = now()
+WHERE wsid = $1
+RETURNING wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at, quota_soft_bytes, quota_hard_bytes, quota_soft_snapshots, quota_hard_snapshots, usage_bytes, usage_snapshots, live_unique_bytes, usage_updated_at, quiesce_policy, hooks, exclude_patterns
+`
+
+type SetWorkspaceQuiescePolicyParams struct {
+	Wsid          string `json:"wsid"`
+	QuiescePolicy string `json:"quiesce_policy"`
+}
+
+func (q *Queries) SetWorkspaceQuiescePolicy(ctx context.Context, arg SetWorkspaceQuiescePolicyParams) (WvsWorkspace, error) {
+	row := q.db.QueryRow(ctx, setWorkspaceQuiescePolicy, arg.Wsid, arg.QuiescePolicy)
+	var i WvsWorkspace
+	err := row.Scan(
+		&i.Wsid,
+		&i.RootPath,
+		&i.Owner,
+		&i.State,
+		&i.CurrentSnapshotID,
+		&i.CurrentPath,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.QuotaSoftBytes,
+		&i.QuotaHardBytes,
+		&i.QuotaSoftSnapshots,
+		&i.QuotaHardSnapshots,
+		&i.UsageBytes,
+		&i.UsageSnapshots,
+		&i.LiveUniqueBytes,
+		&i.UsageUpdatedAt,
+		&i.QuiescePolicy,
+		&i.Hooks,
+		&i.ExcludePatterns,
+	)
+	return i, err
+}
+
+const setWorkspaceQuota = `-- name: SetWorkspaceQuota :one
+UPDATE wvs.workspaces
+SET quota_soft_bytes = $2, quota_hard_bytes = $3,
+    quota_soft_snapshots = $4, quota_hard_snapshots = $5,
+    updated_at = now()
+WHERE wsid = $1
+RETURNING wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at, quota_soft_bytes, quota_hard_bytes, quota_soft_snapshots, quota_hard_snapshots, usage_bytes, usage_snapshots, live_unique_bytes, usage_updated_at, quiesce_policy, hooks, exclude_patterns
+`
+
+type SetWorkspaceQuotaParams struct {
+	Wsid               string      `json:"wsid"`
+	QuotaSoftBytes     pgtype.Int8 `json:"quota_soft_bytes"`
+	QuotaHardBytes     pgtype.Int8 `json:"quota_hard_bytes"`
+	QuotaSoftSnapshots pgtype.Int4 `json:"quota_soft_snapshots"`
+	QuotaHardSnapshots pgtype.Int4 `json:"quota_hard_snapshots"`
+}
+
+func (q *Queries) SetWorkspaceQuota(ctx context.Context, arg SetWorkspaceQuotaParams) (WvsWorkspace, error) {
+	row := q.db.QueryRow(ctx, setWorkspaceQuota,
+		arg.Wsid,
+		arg.QuotaSoftBytes,
+		arg.QuotaHardBytes,
+		arg.QuotaSoftSnapshots,
+		arg.QuotaHardSnapshots,
+	)
+	var i WvsWorkspace
+	err := row.Scan(
+		&i.Wsid,
+		&i.RootPath,
+		&i.Owner,
+		&i.State,
+		&i.CurrentSnapshotID,
+		&i.CurrentPath,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.QuotaSoftBytes,
+		&i.QuotaHardBytes,
+		&i.QuotaSoftSnapshots,
+		&i.QuotaHardSnapshots,
+		&i.UsageBytes,
+		&i.UsageSnapshots,
+		&i.LiveUniqueBytes,
+		&i.UsageUpdatedAt,
+		&i.QuiescePolicy,
+		&i.Hooks,
+		&i.ExcludePatterns,
+	)
+	return i, err
+}
+
 const updateWorkspaceCurrent = `-- name: UpdateWorkspaceCurrent :exec
 UPDATE wvs.workspaces
 SET current_snapshot_id = $2, current_path = $3, updated_at = now()
@@ -158,3 +375,49 @@ func (q *Queries) UpdateWorkspaceState(ctx context.Context, arg UpdateWorkspaceS
 	_, err := q.db.Exec(ctx, updateWorkspaceState, arg.Wsid, arg.State)
 	return err
 }
+
+const updateWorkspaceUsage = `-- name: UpdateWorkspaceUsage :one
+UPDATE wvs.workspaces
+SET usage_bytes = $2, usage_snapshots = $3, live_unique_bytes = $4, usage_updated_at = now()
+WHERE wsid = $1
+RETURNING wsid, root_path, owner, state, current_snapshot_id, current_path, created_at, updated_at, quota_soft_bytes, quota_hard_bytes, quota_soft_snapshots, quota_hard_snapshots, usage_bytes, usage_snapshots, live_unique_bytes, usage_updated_at, quiesce_policy, hooks, exclude_patterns
+`
+
+type UpdateWorkspaceUsageParams struct {
+	Wsid            string `json:"wsid"`
+	UsageBytes      int64  `json:"usage_bytes"`
+	UsageSnapshots  int32  `json:"usage_snapshots"`
+	LiveUniqueBytes int64  `json:"live_unique_bytes"`
+}
+
+func (q *Queries) UpdateWorkspaceUsage(ctx context.Context, arg UpdateWorkspaceUsageParams) (WvsWorkspace, error) {
+	row := q.db.QueryRow(ctx, updateWorkspaceUsage,
+		arg.Wsid,
+		arg.UsageBytes,
+		arg.UsageSnapshots,
+		arg.LiveUniqueBytes,
+	)
+	var i WvsWorkspace
+	err := row.Scan(
+		&i.Wsid,
+		&i.RootPath,
+		&i.Owner,
+		&i.State,
+		&i.CurrentSnapshotID,
+		&i.CurrentPath,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.QuotaSoftBytes,
+		&i.QuotaHardBytes,
+		&i.QuotaSoftSnapshots,
+		&i.QuotaHardSnapshots,
+		&i.UsageBytes,
+		&i.UsageSnapshots,
+		&i.LiveUniqueBytes,
+		&i.UsageUpdatedAt,
+		&i.QuiescePolicy,
+		&i.Hooks,
+		&i.ExcludePatterns,
+	)
+	return i, err
+}
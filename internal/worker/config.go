@@ -1,6 +1,10 @@
 package worker
 
-import "time"
+import (
+	"time"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+)
 
 type Config struct {
 	DBDSN           string        `envconfig:"WVS_DB_DSN" required:"true"`
@@ -10,4 +14,19 @@ type Config struct {
 	PollInterval    time.Duration `envconfig:"WORKER_POLL_INTERVAL" default:"1s"`
 	IdleBackoff     time.Duration `envconfig:"WORKER_IDLE_BACKOFF" default:"5s"`
 	ShutdownTimeout time.Duration `envconfig:"WORKER_SHUTDOWN_TIMEOUT" default:"120s"`
+
+	// ReplicationInterval is how often replication targets are checked for
+	// snapshots to ship.
+	ReplicationInterval time.Duration `envconfig:"WORKER_REPLICATION_INTERVAL" default:"30s"`
+	// RetryReplicate is the retry policy of the replicate tasks the worker
+	// enqueues, in the API's WVS_RETRY_* format.
+	RetryReplicate core.RetryPolicy `envconfig:"WVS_RETRY_REPLICATE"`
+
+	// Background scrub: every ScrubInterval, enqueue verify tasks for up to
+	// ScrubBatch snapshots not verified within ScrubMaxAge, oldest first.
+	// A zero ScrubInterval disables the scrub.
+	ScrubInterval time.Duration    `envconfig:"WORKER_SCRUB_INTERVAL" default:"1h"`
+	ScrubBatch    int32            `envconfig:"WORKER_SCRUB_BATCH" default:"10"`
+	ScrubMaxAge   time.Duration    `envconfig:"WORKER_SCRUB_MAX_AGE" default:"168h"`
+	RetryVerify   core.RetryPolicy `envconfig:"WVS_RETRY_VERIFY"`
 }
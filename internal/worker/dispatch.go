@@ -4,22 +4,44 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
+	"strconv"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 	"go.uber.org/zap"
 
+	pb "github.com/lzjever/mbos-wvs/gen/go/executor/v1"
 	"github.com/lzjever/mbos-wvs/internal/core"
 	"github.com/lzjever/mbos-wvs/internal/observability"
 	"github.com/lzjever/mbos-wvs/internal/store"
-	pb "github.com/lzjever/mbos-wvs/gen/go/executor/v1"
 )
 
+// errorClasses maps executor error classes onto task error codes.
+var errorClasses = map[pb.ErrorClass]core.ErrorClass{
+	pb.ErrorClass_ERROR_CLASS_UNSPECIFIED:        core.ErrClassInternal,
+	pb.ErrorClass_ERROR_CLASS_QUIESCE_TIMEOUT:    core.ErrClassQuiesceTimeout,
+	pb.ErrorClass_ERROR_CLASS_SNAPSHOT_NOT_FOUND: core.ErrClassSnapshotNotFound,
+	pb.ErrorClass_ERROR_CLASS_NO_SPACE:           core.ErrClassNoSpace,
+	pb.ErrorClass_ERROR_CLASS_PERMISSION:         core.ErrClassPermission,
+	pb.ErrorClass_ERROR_CLASS_CLONE_FAILED:       core.ErrClassCloneFailed,
+	pb.ErrorClass_ERROR_CLASS_CANCELED:           core.ErrClassCanceled,
+	pb.ErrorClass_ERROR_CLASS_INVALID_PARAMS:     core.ErrClassInvalidParams,
+	pb.ErrorClass_ERROR_CLASS_HOOK_FAILED:        core.ErrClassHookFailed,
+}
+
 var opMap = map[core.TaskOp]pb.TaskOp{
 	core.OpInitWorkspace:  pb.TaskOp_TASK_OP_INIT_WORKSPACE,
 	core.OpSnapshotCreate: pb.TaskOp_TASK_OP_SNAPSHOT_CREATE,
 	core.OpSnapshotDrop:   pb.TaskOp_TASK_OP_SNAPSHOT_DROP,
 	core.OpSetCurrent:     pb.TaskOp_TASK_OP_SET_CURRENT,
+
+	core.OpCheckpointRestore: pb.TaskOp_TASK_OP_CHECKPOINT_RESTORE,
+	core.OpRestorePaths:      pb.TaskOp_TASK_OP_RESTORE_PATHS,
+	core.OpExport:            pb.TaskOp_TASK_OP_EXPORT,
+	core.OpImport:            pb.TaskOp_TASK_OP_IMPORT,
+	core.OpReplicate:         pb.TaskOp_TASK_OP_REPLICATE,
+	core.OpVerify:            pb.TaskOp_TASK_OP_VERIFY,
 }
 
 func (w *Worker) dispatch(ctx context.Context, task *store.WvsTask, log *zap.Logger) {
@@ -43,10 +65,26 @@ func (w *Worker) dispatch(ctx context.Context, task *store.WvsTask, log *zap.Log
 		}
 	}
 
+	w.setSnapshotState(ctx, core.ReservedSnapshotID(core.TaskOp(task.Op), params), core.SnapshotCreating)
+	if ws, err := w.queries.GetWorkspace(ctx, task.Wsid); err == nil {
+		// Lets the executor measure the live directory against its base snapshot.
+		if ws.CurrentSnapshotID.Valid {
+			params["current_snapshot_id"] = ws.CurrentSnapshotID.String
+		}
+		params["quiesce_policy"] = ws.QuiescePolicy
+		if hooks, err := core.ParseWorkspaceHooks(ws.Hooks); err == nil && !hooks.Empty() {
+			params["hooks"] = string(ws.Hooks)
+		}
+		if len(ws.ExcludePatterns) > 0 {
+			patterns, _ := json.Marshal(ws.ExcludePatterns)
+			params["exclude_patterns"] = string(patterns)
+		}
+	}
+
 	// Call executor
 	pbOp, ok := opMap[core.TaskOp(task.Op)]
 	if !ok {
-		w.failTask(ctx, task, fmt.Errorf("unknown op: %s", task.Op), log)
+		w.failTask(ctx, task, core.NewTaskError(core.ErrClassInvalidParams, "unknown op: "+task.Op), log)
 		return
 	}
 
@@ -61,7 +99,7 @@ func (w *Worker) dispatch(ctx context.Context, task *store.WvsTask, log *zap.Log
 		return
 	}
 	if !resp.Success {
-		w.failTask(ctx, task, fmt.Errorf("%s: %s", resp.ErrorCode, resp.ErrorMessage), log)
+		w.failTask(ctx, task, core.NewTaskError(errorClasses[resp.ErrorClass], resp.ErrorMessage), log)
 		return
 	}
 
@@ -81,7 +119,8 @@ func (w *Worker) checkSetCurrentNoop(ctx context.Context, task *store.WvsTask, p
 			Status: string(core.TaskSucceeded),
 			Result: result,
 		})
-		observability.TaskTotal.WithLabelValues(task.Op, string(core.TaskSucceeded)).Inc()
+		observability.TaskTotal.WithLabelValues(task.Op, string(core.TaskSucceeded), "").Inc()
+		w.writeTaskEvent(ctx, task, core.TaskSucceeded, map[string]interface{}{"noop": true})
 		return true, nil
 	}
 	return false, nil
@@ -97,15 +136,22 @@ func (w *Worker) onSuccess(ctx context.Context, task *store.WvsTask, results map
 		})
 		observability.WorkspaceStateTransitions.WithLabelValues("PROVISIONING", "ACTIVE").Inc()
 
-	case core.OpSnapshotCreate:
-		// Insert snapshot record
+	case core.OpSnapshotCreate, core.OpImport:
+		// Promote the reserved snapshot record
 		var params map[string]string
 		_ = json.Unmarshal(task.Params, &params)
-		_, _ = w.queries.CreateSnapshot(ctx, store.CreateSnapshotParams{
-			SnapshotID: params["snapshot_id"],
-			Wsid:       task.Wsid,
-			FsPath:     results["fs_path"],
-			Message:    textFromString(params["message"]),
+		_ = w.queries.MarkSnapshotReady(ctx, store.MarkSnapshotReadyParams{
+			SnapshotID:  params["snapshot_id"],
+			Wsid:        task.Wsid,
+			FsPath:      results["fs_path"],
+			Message:     textFromString(params["message"]),
+			TaskID:      textFromString(task.TaskID),
+			SizeBytes:   int8FromString(results["size_bytes"]),
+			FileCount:   int8FromString(results["file_count"]),
+			UniqueBytes: int8FromString(results["unique_bytes"]),
+			SharedBytes: int8FromString(results["shared_bytes"]),
+			Metadata:    jsonFromString(results["metadata"]),
+			TreeDigest:  textFromString(results["tree_digest"]),
 		})
 
 	case core.OpSetCurrent:
@@ -118,38 +164,166 @@ func (w *Worker) onSuccess(ctx context.Context, task *store.WvsTask, results map
 			CurrentPath:       results["current_path"],
 		})
 
+	case core.OpCheckpointRestore:
+		// Promote the checkpoint, then record the switch
+		var params map[string]string
+		_ = json.Unmarshal(task.Params, &params)
+		_ = w.queries.MarkSnapshotReady(ctx, store.MarkSnapshotReadyParams{
+			SnapshotID:  params["checkpoint_id"],
+			Wsid:        task.Wsid,
+			FsPath:      results["checkpoint_fs_path"],
+			Message:     textFromString(params["message"]),
+			TaskID:      textFromString(task.TaskID),
+			SizeBytes:   int8FromString(results["checkpoint_size_bytes"]),
+			FileCount:   int8FromString(results["checkpoint_file_count"]),
+			UniqueBytes: int8FromString(results["checkpoint_unique_bytes"]),
+			SharedBytes: int8FromString(results["checkpoint_shared_bytes"]),
+			Metadata:    jsonFromString(results["checkpoint_metadata"]),
+			TreeDigest:  textFromString(results["checkpoint_tree_digest"]),
+		})
+		_ = w.queries.UpdateWorkspaceCurrent(ctx, store.UpdateWorkspaceCurrentParams{
+			Wsid:              task.Wsid,
+			CurrentSnapshotID: textFromString(params["snapshot_id"]),
+			CurrentPath:       results["current_path"],
+		})
+
+	case core.OpExport:
+		_ = w.queries.CompleteExport(ctx, store.CompleteExportParams{
+			ExportID:         results["export_id"],
+			Location:         textFromString(results["location"]),
+			Digest:           textFromString(results["digest"]),
+			SizeBytes:        int8FromString(results["size_bytes"]),
+			FileCount:        int8FromString(results["file_count"]),
+			ManifestLocation: textFromString(results["manifest_location"]),
+			ManifestDigest:   textFromString(results["manifest_digest"]),
+		})
+
+	case core.OpReplicate:
+		_ = w.queries.CompleteSnapshotReplica(ctx, store.CompleteSnapshotReplicaParams{
+			SnapshotID:   results["snapshot_id"],
+			TaskID:       task.TaskID,
+			BytesShipped: int8FromString(results["bytes_shipped"]),
+			FilesChanged: int8FromString(results["files_changed"]),
+			FilesDeleted: int8FromString(results["files_deleted"]),
+		})
+
+	case core.OpVerify:
+		// The digest only sticks as a baseline for snapshots that had none.
+		_ = w.queries.RecordSnapshotVerify(ctx, store.RecordSnapshotVerifyParams{
+			SnapshotID:  results["snapshot_id"],
+			VerifyState: textFromString(results["verify_state"]),
+			TreeDigest:  textFromString(results["tree_digest"]),
+		})
+		if results["verify_state"] == string(core.VerifyCorrupt) {
+			log.Error("snapshot failed verification",
+				zap.String("snapshot_id", results["snapshot_id"]),
+				zap.String("expected_digest", results["expected_digest"]),
+				zap.String("tree_digest", results["tree_digest"]))
+		}
+
 	case core.OpSnapshotDrop:
 		// deleted_at already written in the lock transaction
 	}
 
+	if results["post_restore_failed"] == "true" {
+		log.Warn("post_restore hook failed after the switch", zap.String("error", results["post_restore_error"]))
+	}
+
 	_ = w.queries.CompleteTask(ctx, store.CompleteTaskParams{
 		TaskID: task.TaskID,
 		Status: string(core.TaskSucceeded),
 		Result: resultJSON,
 	})
-	observability.TaskTotal.WithLabelValues(task.Op, string(core.TaskSucceeded)).Inc()
+	observability.TaskTotal.WithLabelValues(task.Op, string(core.TaskSucceeded), "").Inc()
+	w.writeTaskEvent(ctx, task, core.TaskSucceeded, map[string]interface{}{"result": results})
+	w.refreshUsage(ctx, task, results)
 	log.Info("task succeeded")
 }
 
+// failTask records a failed attempt. Tasks are retried until max_attempts,
+// unless the error's class is permanent, in which case they go straight to DEAD.
 func (w *Worker) failTask(ctx context.Context, task *store.WvsTask, taskErr error, log *zap.Logger) {
-	errJSON, _ := json.Marshal(map[string]string{"error": taskErr.Error()})
-
-	if task.Attempt >= task.MaxAttempts {
-		_ = w.queries.MarkTaskDead(ctx, store.MarkTaskDeadParams{TaskID: task.TaskID, Error: errJSON})
-		observability.TaskTotal.WithLabelValues(task.Op, string(core.TaskDead)).Inc()
-		// If init_workspace, mark workspace INIT_FAILED
-		if core.TaskOp(task.Op) == core.OpInitWorkspace {
-			_ = w.queries.UpdateWorkspaceState(ctx, store.UpdateWorkspaceStateParams{
-				Wsid: task.Wsid, State: string(core.WorkspaceInitFailed),
-			})
-			observability.WorkspaceStateTransitions.WithLabelValues("PROVISIONING", "INIT_FAILED").Inc()
-		}
-		log.Error("task dead", zap.Error(taskErr))
-	} else {
-		_ = w.queries.FailTask(ctx, store.FailTaskParams{TaskID: task.TaskID, Error: errJSON})
-		observability.TaskTotal.WithLabelValues(task.Op, string(core.TaskFailed)).Inc()
-		observability.TaskRetryTotal.WithLabelValues(task.Op).Inc()
-		log.Warn("task failed, will retry", zap.Error(taskErr), zap.Int("attempt", int(task.Attempt)))
+	class := core.ErrorClassOf(taskErr)
+	if task.Attempt >= task.MaxAttempts || !class.Retryable() {
+		w.markDead(ctx, task, taskErr, log)
+		return
+	}
+
+	backoff := taskRetryPolicy(task).Delay(task.Attempt, rand.Float64())
+	_ = w.queries.FailTask(ctx, store.FailTaskParams{
+		TaskID:         task.TaskID,
+		Error:          taskErrorJSON(taskErr),
+		BackoffSeconds: backoff.Seconds(),
+	})
+	observability.TaskTotal.WithLabelValues(task.Op, string(core.TaskFailed), string(class)).Inc()
+	observability.TaskRetryTotal.WithLabelValues(task.Op).Inc()
+	w.writeTaskEvent(ctx, task, core.TaskFailed, map[string]interface{}{"error": taskErr.Error(), "code": class})
+	log.Warn("task failed, will retry", zap.Error(taskErr), zap.Int("attempt", int(task.Attempt)), zap.Duration("backoff", backoff))
+}
+
+// taskRetryPolicy rebuilds the retry policy the task was created with.
+func taskRetryPolicy(task *store.WvsTask) core.RetryPolicy {
+	return core.RetryPolicy{
+		MaxAttempts: task.MaxAttempts,
+		BaseDelay:   time.Duration(task.RetryBaseMs) * time.Millisecond,
+		Multiplier:  task.RetryMultiplier,
+		MaxDelay:    time.Duration(task.RetryMaxDelayMs) * time.Millisecond,
+		Jitter:      task.RetryJitter,
+	}
+}
+
+// markDead fails a task permanently, regardless of remaining attempts.
+func (w *Worker) markDead(ctx context.Context, task *store.WvsTask, taskErr error, log *zap.Logger) {
+	class := core.ErrorClassOf(taskErr)
+	_ = w.queries.MarkTaskDead(ctx, store.MarkTaskDeadParams{TaskID: task.TaskID, Error: taskErrorJSON(taskErr)})
+	observability.TaskTotal.WithLabelValues(task.Op, string(core.TaskDead), string(class)).Inc()
+	w.writeTaskEvent(ctx, task, core.TaskDead, map[string]interface{}{"error": taskErr.Error(), "code": class})
+	// If init_workspace, mark workspace INIT_FAILED
+	if core.TaskOp(task.Op) == core.OpInitWorkspace {
+		_ = w.queries.UpdateWorkspaceState(ctx, store.UpdateWorkspaceStateParams{
+			Wsid: task.Wsid, State: string(core.WorkspaceInitFailed),
+		})
+		observability.WorkspaceStateTransitions.WithLabelValues("PROVISIONING", "INIT_FAILED").Inc()
+	}
+	w.abandonTask(ctx, task, log)
+	log.Error("task dead", zap.Error(taskErr), zap.String("error_code", string(class)))
+}
+
+// abandonTask cleans up after a task that ended without succeeding: its
+// reserved snapshot fails and its dependents, which can no longer succeed,
+// are canceled.
+func (w *Worker) abandonTask(ctx context.Context, task *store.WvsTask, log *zap.Logger) {
+	canceled, err := w.queries.AbandonTask(ctx, *task)
+	if err != nil {
+		log.Warn("abandon task cleanup failed", zap.Error(err))
+	}
+	for i := range canceled {
+		dep := &canceled[i]
+		w.writeTaskEvent(ctx, dep, core.TaskCanceled, map[string]interface{}{"depends_on": dep.DependsOn.String})
+	}
+	if len(canceled) > 0 {
+		log.Info("canceled dependent tasks", zap.Int("count", len(canceled)))
+	}
+}
+
+// taskErrorJSON is the task's error column: a message plus its class as code.
+func taskErrorJSON(err error) []byte {
+	b, _ := json.Marshal(map[string]string{
+		"error": err.Error(),
+		"code":  string(core.ErrorClassOf(err)),
+	})
+	return b
+}
+
+func (w *Worker) setSnapshotState(ctx context.Context, snapshotID string, state core.SnapshotState) {
+	if snapshotID == "" {
+		return
+	}
+	if err := w.queries.UpdateSnapshotState(ctx, store.UpdateSnapshotStateParams{
+		SnapshotID: snapshotID,
+		State:      string(state),
+	}); err != nil {
+		w.log.Warn("update snapshot state failed", zap.String("snapshot_id", snapshotID), zap.Error(err))
 	}
 }
 
@@ -159,3 +333,19 @@ func textFromString(s string) pgtype.Text {
 	}
 	return pgtype.Text{String: s, Valid: true}
 }
+
+func int8FromString(s string) pgtype.Int8 {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return pgtype.Int8{Valid: false}
+	}
+	return pgtype.Int8{Int64: n, Valid: true}
+}
+
+// jsonFromString returns s as a JSONB value, or nil if it is not valid JSON.
+func jsonFromString(s string) []byte {
+	if s == "" || !json.Valid([]byte(s)) {
+		return nil
+	}
+	return []byte(s)
+}
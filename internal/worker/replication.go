@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/observability"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+// maxLineageDepth bounds the walk up a snapshot's ancestors for a base.
+const maxLineageDepth = 64
+
+// RunReplication enqueues replicate tasks for every enabled replication
+// target until ctx is canceled. Each target has at most one snapshot in
+// flight, shipped oldest first as a delta against an ancestor the peer
+// already holds.
+func (w *Worker) RunReplication(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.ReplicationInterval)
+	defer ticker.Stop()
+	for {
+		w.replicateOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) replicateOnce(ctx context.Context) {
+	targets, err := w.queries.ListEnabledReplicationTargets(ctx)
+	if err != nil {
+		w.log.Warn("list replication targets failed", zap.Error(err))
+		return
+	}
+	// Disabled targets drop out of the gauges.
+	observability.ReplicationLagSeconds.Reset()
+	observability.ReplicationPendingSnapshots.Reset()
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			return
+		}
+		w.replicateTarget(ctx, t)
+	}
+}
+
+func (w *Worker) replicateTarget(ctx context.Context, t store.WvsReplicationTarget) {
+	log := w.log.With(zap.String("wsid", t.Wsid), zap.String("peer_url", t.PeerUrl))
+
+	lag, err := w.queries.GetReplicationLag(ctx, t.Wsid)
+	if err != nil {
+		log.Warn("get replication lag failed", zap.Error(err))
+		return
+	}
+	lagSeconds := 0.0
+	if lag.OldestPendingAt.Valid {
+		lagSeconds = time.Since(lag.OldestPendingAt.Time).Seconds()
+	}
+	observability.ReplicationLagSeconds.WithLabelValues(t.Wsid).Set(lagSeconds)
+	observability.ReplicationPendingSnapshots.WithLabelValues(t.Wsid).Set(float64(lag.PendingSnapshots))
+
+	inFlight, err := w.queries.CountInFlightReplicas(ctx, t.Wsid)
+	if err != nil || inFlight > 0 {
+		return
+	}
+	snap, err := w.queries.NextSnapshotToReplicate(ctx, t.Wsid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Warn("pick snapshot to replicate failed", zap.Error(err))
+		return
+	}
+
+	baseID := w.replicationBase(ctx, snap)
+	if err := w.enqueueReplicate(ctx, t, snap, baseID); err != nil {
+		log.Warn("enqueue replicate failed", zap.String("snapshot_id", snap.SnapshotID), zap.Error(err))
+		return
+	}
+	log.Info("replicate enqueued", zap.String("snapshot_id", snap.SnapshotID), zap.String("base_snapshot_id", baseID))
+}
+
+// replicationBase picks the snapshot to diff snap against: its nearest
+// replicated ancestor, else the most recently replicated snapshot, else
+// none, and snap is shipped in full.
+func (w *Worker) replicationBase(ctx context.Context, snap store.WvsSnapshot) string {
+	id := snap.SnapshotID
+	for i := 0; i < maxLineageDepth; i++ {
+		parentID, err := w.queries.GetSnapshotParentID(ctx, id)
+		if err != nil || parentID == "" {
+			break
+		}
+		if w.isReplicated(ctx, snap.Wsid, parentID) {
+			return parentID
+		}
+		id = parentID
+	}
+	if latest, err := w.queries.GetLatestReplicatedSnapshot(ctx, snap.Wsid); err == nil {
+		return latest.SnapshotID
+	}
+	return ""
+}
+
+// isReplicated reports whether the peer holds snapshotID and it is still
+// here, in wsid, to diff against.
+func (w *Worker) isReplicated(ctx context.Context, wsid, snapshotID string) bool {
+	replica, err := w.queries.GetSnapshotReplica(ctx, snapshotID)
+	if err != nil || !replica.ReplicatedAt.Valid {
+		return false
+	}
+	snap, err := w.queries.GetWorkspaceSnapshot(ctx, store.GetWorkspaceSnapshotParams{
+		Wsid:       wsid,
+		SnapshotID: snapshotID,
+	})
+	return err == nil && !snap.DeletedAt.Valid
+}
+
+// enqueueReplicate creates the replicate task and reserves the replica row
+// atomically. A second worker racing for the same snapshot loses on the
+// replica's primary key.
+func (w *Worker) enqueueReplicate(ctx context.Context, t store.WvsReplicationTarget, snap store.WvsSnapshot, baseID string) error {
+	taskID := core.NewID()
+	taskParams := map[string]string{
+		"snapshot_id": snap.SnapshotID,
+		"peer_url":    t.PeerUrl,
+		"peer_wsid":   t.PeerWsid,
+		"message":     snap.Message.String,
+	}
+	if baseID != "" {
+		taskParams["base_snapshot_id"] = baseID
+	}
+	params, _ := json.Marshal(taskParams)
+	pol := w.cfg.RetryReplicate.WithDefaults(core.DefaultRetryPolicy)
+
+	tx, err := w.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	qtx := w.queries.WithTx(tx)
+
+	_, err = qtx.CreateTask(ctx, store.CreateTaskParams{
+		TaskID:          taskID,
+		Wsid:            snap.Wsid,
+		Op:              string(core.OpReplicate),
+		IdempotencyKey:  "replicate:" + snap.SnapshotID + ":" + taskID,
+		RequestHash:     core.ComputeRequestHash(params, "REPLICATE", snap.SnapshotID),
+		Params:          params,
+		MaxAttempts:     pol.MaxAttempts,
+		TimeoutSeconds:  3600,
+		RetryBaseMs:     int32(pol.BaseDelay.Milliseconds()),
+		RetryMultiplier: pol.Multiplier,
+		RetryMaxDelayMs: int32(pol.MaxDelay.Milliseconds()),
+		RetryJitter:     pol.Jitter,
+		Priority:        int32(core.DefaultPriority(core.OpReplicate)),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = qtx.CreateSnapshotReplica(ctx, store.CreateSnapshotReplicaParams{
+		SnapshotID:     snap.SnapshotID,
+		Wsid:           snap.Wsid,
+		TaskID:         taskID,
+		BaseSnapshotID: textFromString(baseID),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/observability"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+// RunScrub periodically enqueues verify tasks for the snapshots that have
+// gone longest without one, until ctx is canceled. Snapshots are immutable,
+// so any change to their tree digest is corruption or a stray write.
+func (w *Worker) RunScrub(ctx context.Context) {
+	if w.cfg.ScrubInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(w.cfg.ScrubInterval)
+	defer ticker.Stop()
+	for {
+		w.scrubOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) scrubOnce(ctx context.Context) {
+	if n, err := w.queries.CountCorruptSnapshots(ctx); err == nil {
+		observability.SnapshotsCorrupt.Set(float64(n))
+	}
+
+	snaps, err := w.queries.ListSnapshotsDueForVerify(ctx, store.ListSnapshotsDueForVerifyParams{
+		Limit:          w.cfg.ScrubBatch,
+		VerifiedBefore: pgtype.Timestamptz{Time: time.Now().Add(-w.cfg.ScrubMaxAge), Valid: true},
+	})
+	if err != nil {
+		w.log.Warn("list snapshots due for verify failed", zap.Error(err))
+		return
+	}
+	for _, snap := range snaps {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := w.enqueueVerify(ctx, snap); err != nil {
+			w.log.Warn("enqueue verify failed", zap.String("snapshot_id", snap.SnapshotID), zap.Error(err))
+			continue
+		}
+		observability.ScrubEnqueuedTotal.Inc()
+	}
+	if len(snaps) > 0 {
+		w.log.Info("scrub enqueued", zap.Int("snapshots", len(snaps)))
+	}
+}
+
+// enqueueVerify creates a background verify task against the digest the
+// catalog holds for snap.
+func (w *Worker) enqueueVerify(ctx context.Context, snap store.WvsSnapshot) error {
+	taskID := core.NewID()
+	taskParams := map[string]string{"snapshot_id": snap.SnapshotID}
+	if snap.TreeDigest.Valid {
+		taskParams["tree_digest"] = snap.TreeDigest.String
+	}
+	params, _ := json.Marshal(taskParams)
+	pol := w.cfg.RetryVerify.WithDefaults(core.DefaultRetryPolicy)
+
+	_, err := w.queries.CreateTask(ctx, store.CreateTaskParams{
+		TaskID:          taskID,
+		Wsid:            snap.Wsid,
+		Op:              string(core.OpVerify),
+		IdempotencyKey:  "scrub:" + snap.SnapshotID + ":" + taskID,
+		RequestHash:     core.ComputeRequestHash(params, "VERIFY", snap.SnapshotID),
+		Params:          params,
+		MaxAttempts:     pol.MaxAttempts,
+		TimeoutSeconds:  3600,
+		RetryBaseMs:     int32(pol.BaseDelay.Milliseconds()),
+		RetryMultiplier: pol.Multiplier,
+		RetryMaxDelayMs: int32(pol.MaxDelay.Milliseconds()),
+		RetryJitter:     pol.Jitter,
+		Priority:        int32(core.PriorityBackground),
+	})
+	return err
+}
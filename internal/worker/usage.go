@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/observability"
+	"github.com/lzjever/mbos-wvs/internal/store"
+)
+
+// refreshUsage recomputes a workspace's usage after a successful task,
+// combining the snapshot catalog with the live usage the executor reported,
+// then publishes gauges and warns on soft quota crossings.
+func (w *Worker) refreshUsage(ctx context.Context, task *store.WvsTask, results map[string]string) {
+	log := w.log.With(zap.String("wsid", task.Wsid))
+
+	prev, err := w.queries.GetWorkspace(ctx, task.Wsid)
+	if err != nil {
+		log.Warn("get workspace failed", zap.Error(err))
+		return
+	}
+	usage, err := w.queries.GetWorkspaceUsage(ctx, task.Wsid)
+	if err != nil {
+		log.Warn("get workspace usage failed", zap.Error(err))
+		return
+	}
+
+	live := prev.LiveUniqueBytes
+	if v, err := strconv.ParseInt(results["live_unique_bytes"], 10, 64); err == nil {
+		live = v
+	}
+
+	ws, err := w.queries.UpdateWorkspaceUsage(ctx, store.UpdateWorkspaceUsageParams{
+		Wsid:            task.Wsid,
+		UsageBytes:      usage.UniqueBytes + live,
+		UsageSnapshots:  int32(usage.SnapshotCount),
+		LiveUniqueBytes: live,
+	})
+	if err != nil {
+		log.Warn("update workspace usage failed", zap.Error(err))
+		return
+	}
+
+	observability.WorkspaceStorageBytes.WithLabelValues(ws.Wsid, "logical").Set(float64(usage.LogicalBytes))
+	observability.WorkspaceStorageBytes.WithLabelValues(ws.Wsid, "unique").Set(float64(usage.UniqueBytes))
+	observability.WorkspaceStorageBytes.WithLabelValues(ws.Wsid, "live").Set(float64(ws.LiveUniqueBytes))
+	observability.WorkspaceStorageBytes.WithLabelValues(ws.Wsid, "usage").Set(float64(ws.UsageBytes))
+	observability.WorkspaceSnapshots.WithLabelValues(ws.Wsid).Set(float64(ws.UsageSnapshots))
+
+	if ws.QuotaSoftBytes.Valid && core.SoftLimitCrossed(prev.UsageBytes, ws.UsageBytes, ws.QuotaSoftBytes.Int64) {
+		w.writeQuotaWarning(ctx, task, core.QuotaBytes, ws.UsageBytes, ws.QuotaSoftBytes.Int64, ws.QuotaHardBytes)
+	}
+	if ws.QuotaSoftSnapshots.Valid &&
+		core.SoftLimitCrossed(int64(prev.UsageSnapshots), int64(ws.UsageSnapshots), int64(ws.QuotaSoftSnapshots.Int32)) {
+		hard := pgtype.Int8{Int64: int64(ws.QuotaHardSnapshots.Int32), Valid: ws.QuotaHardSnapshots.Valid}
+		w.writeQuotaWarning(ctx, task, core.QuotaSnapshots, int64(ws.UsageSnapshots), int64(ws.QuotaSoftSnapshots.Int32), hard)
+	}
+}
+
+func (w *Worker) writeQuotaWarning(ctx context.Context, task *store.WvsTask, kind core.QuotaKind, usage, soft int64, hard pgtype.Int8) {
+	observability.QuotaWarningsTotal.WithLabelValues(string(kind)).Inc()
+	w.log.Warn("workspace soft quota crossed",
+		zap.String("wsid", task.Wsid), zap.String("kind", string(kind)),
+		zap.Int64("usage", usage), zap.Int64("soft_limit", soft))
+
+	payload := map[string]interface{}{
+		"kind":       kind,
+		"usage":      usage,
+		"soft_limit": soft,
+	}
+	if hard.Valid {
+		payload["hard_limit"] = hard.Int64
+	}
+	payloadBytes, _ := json.Marshal(payload)
+	actor, _ := json.Marshal(map[string]string{"source": "worker"})
+
+	_, err := w.queries.InsertAudit(ctx, store.InsertAuditParams{
+		Wsid:    pgtype.Text{String: task.Wsid, Valid: true},
+		Actor:   actor,
+		Action:  "workspace.quota_warning",
+		TaskID:  pgtype.Text{String: task.TaskID, Valid: true},
+		Payload: payloadBytes,
+	})
+	if err != nil {
+		w.log.Warn("write quota warning failed", zap.String("wsid", task.Wsid), zap.Error(err))
+	}
+}
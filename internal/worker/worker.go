@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
@@ -48,6 +49,7 @@ func (w *Worker) Run(ctx context.Context) {
 		if err != nil {
 			// No task available
 			observability.DequeueEmptyTotal.Inc()
+			w.updateDeadTasks(ctx)
 			select {
 			case <-ctx.Done():
 				return
@@ -63,15 +65,18 @@ func (w *Worker) Run(ctx context.Context) {
 			zap.Int("attempt", int(task.Attempt)),
 		)
 		log.Info("task dequeued")
+		w.writeTaskEvent(ctx, &task, core.TaskRunning, nil)
 
 		// Check cancel_requested
 		if task.CancelRequested {
-			errJSON, _ := json.Marshal(map[string]string{"error": "canceled"})
+			errJSON, _ := json.Marshal(map[string]string{"error": "canceled", "code": string(core.ErrClassCanceled)})
 			_ = w.queries.CompleteTask(ctx, store.CompleteTaskParams{
 				TaskID: task.TaskID,
 				Status: string(core.TaskCanceled),
 				Error:  errJSON,
 			})
+			w.writeTaskEvent(ctx, &task, core.TaskCanceled, nil)
+			w.abandonTask(ctx, &task, log)
 			log.Info("task canceled")
 			continue
 		}
@@ -83,6 +88,20 @@ func (w *Worker) Run(ctx context.Context) {
 		if depth, err := w.queries.GetQueueDepth(ctx); err == nil {
 			observability.TaskQueueDepth.Set(float64(depth))
 		}
+		w.updateDeadTasks(ctx)
+	}
+}
+
+// updateDeadTasks refreshes the dead-letter gauge. Ops whose dead tasks were
+// all retried or discarded drop back to zero.
+func (w *Worker) updateDeadTasks(ctx context.Context) {
+	counts, err := w.queries.CountDeadTasksByOp(ctx)
+	if err != nil {
+		return
+	}
+	observability.DeadTasks.Reset()
+	for _, c := range counts {
+		observability.DeadTasks.WithLabelValues(c.Op).Set(float64(c.Count))
 	}
 }
 
@@ -126,6 +145,15 @@ func (w *Worker) executeWithLock(ctx context.Context, task *store.WvsTask, log *
 			w.failTask(ctx, task, err, log)
 			return
 		}
+		// Its children no longer share anything with a live snapshot;
+		// charge them their full size.
+		if err := qtx.ClearSharedUsage(ctx, store.ClearSharedUsageParams{
+			Wsid:             task.Wsid,
+			ParentSnapshotID: snapshotID,
+		}); err != nil {
+			w.failTask(ctx, task, err, log)
+			return
+		}
 	}
 
 	if err := tx.Commit(ctx); err != nil {
@@ -136,3 +164,29 @@ func (w *Worker) executeWithLock(ctx context.Context, task *store.WvsTask, log *
 	// Now dispatch to executor (outside lock)
 	w.dispatch(ctx, task, log)
 }
+
+// writeTaskEvent records a task status transition in the audit log. Audit
+// inserts are broadcast via NOTIFY, which drives the API's SSE streams.
+func (w *Worker) writeTaskEvent(ctx context.Context, task *store.WvsTask, status core.TaskStatus, extra map[string]interface{}) {
+	payload := map[string]interface{}{
+		"status":  string(status),
+		"op":      task.Op,
+		"attempt": task.Attempt,
+	}
+	for k, v := range extra {
+		payload[k] = v
+	}
+	payloadBytes, _ := json.Marshal(payload)
+	actor, _ := json.Marshal(map[string]string{"source": "worker"})
+
+	_, err := w.queries.InsertAudit(ctx, store.InsertAuditParams{
+		Wsid:    pgtype.Text{String: task.Wsid, Valid: true},
+		Actor:   actor,
+		Action:  "task." + strings.ToLower(string(status)),
+		TaskID:  pgtype.Text{String: task.TaskID, Valid: true},
+		Payload: payloadBytes,
+	})
+	if err != nil {
+		w.log.Warn("write task event failed", zap.String("task_id", task.TaskID), zap.Error(err))
+	}
+}
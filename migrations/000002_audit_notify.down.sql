@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS trg_audit_notify ON wvs.audit;
+DROP FUNCTION IF EXISTS wvs.notify_audit_event();
@@ -0,0 +1,14 @@
+CREATE OR REPLACE FUNCTION wvs.notify_audit_event() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('wvs_events', json_build_object(
+    'event_id', NEW.event_id,
+    'wsid', NEW.wsid,
+    'task_id', NEW.task_id
+  )::text);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_audit_notify
+  AFTER INSERT ON wvs.audit
+  FOR EACH ROW EXECUTE FUNCTION wvs.notify_audit_event();
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS wvs.idx_snapshots_task;
+ALTER TABLE wvs.snapshots
+  DROP COLUMN IF EXISTS task_id,
+  DROP COLUMN IF EXISTS state;
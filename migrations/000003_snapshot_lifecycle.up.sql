@@ -0,0 +1,6 @@
+ALTER TABLE wvs.snapshots
+  ADD COLUMN state   TEXT NOT NULL DEFAULT 'READY'
+                     CHECK (state IN ('PENDING', 'CREATING', 'READY', 'FAILED')),
+  ADD COLUMN task_id TEXT;
+
+CREATE INDEX idx_snapshots_task ON wvs.snapshots(task_id) WHERE task_id IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE wvs.snapshots
+  DROP COLUMN IF EXISTS metadata,
+  DROP COLUMN IF EXISTS file_count,
+  DROP COLUMN IF EXISTS size_bytes;
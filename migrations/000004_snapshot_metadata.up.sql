@@ -0,0 +1,4 @@
+ALTER TABLE wvs.snapshots
+  ADD COLUMN size_bytes BIGINT,
+  ADD COLUMN file_count BIGINT,
+  ADD COLUMN metadata   JSONB;
@@ -0,0 +1,3 @@
+ALTER TABLE wvs.snapshots
+  DROP COLUMN IF EXISTS shared_bytes,
+  DROP COLUMN IF EXISTS unique_bytes;
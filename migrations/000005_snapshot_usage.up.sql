@@ -0,0 +1,3 @@
+ALTER TABLE wvs.snapshots
+  ADD COLUMN unique_bytes BIGINT,
+  ADD COLUMN shared_bytes BIGINT;
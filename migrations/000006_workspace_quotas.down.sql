@@ -0,0 +1,9 @@
+ALTER TABLE wvs.workspaces
+  DROP COLUMN IF EXISTS usage_updated_at,
+  DROP COLUMN IF EXISTS live_unique_bytes,
+  DROP COLUMN IF EXISTS usage_snapshots,
+  DROP COLUMN IF EXISTS usage_bytes,
+  DROP COLUMN IF EXISTS quota_hard_snapshots,
+  DROP COLUMN IF EXISTS quota_soft_snapshots,
+  DROP COLUMN IF EXISTS quota_hard_bytes,
+  DROP COLUMN IF EXISTS quota_soft_bytes;
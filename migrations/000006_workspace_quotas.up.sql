@@ -0,0 +1,9 @@
+ALTER TABLE wvs.workspaces
+  ADD COLUMN quota_soft_bytes     BIGINT  CHECK (quota_soft_bytes >= 0),
+  ADD COLUMN quota_hard_bytes     BIGINT  CHECK (quota_hard_bytes >= 0),
+  ADD COLUMN quota_soft_snapshots INTEGER CHECK (quota_soft_snapshots >= 0),
+  ADD COLUMN quota_hard_snapshots INTEGER CHECK (quota_hard_snapshots >= 0),
+  ADD COLUMN usage_bytes          BIGINT  NOT NULL DEFAULT 0,
+  ADD COLUMN usage_snapshots      INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN live_unique_bytes    BIGINT  NOT NULL DEFAULT 0,
+  ADD COLUMN usage_updated_at     TIMESTAMPTZ;
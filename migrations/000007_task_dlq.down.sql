@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS wvs.idx_tasks_dead;
+DROP INDEX IF EXISTS wvs.uq_tasks_retry_of;
+ALTER TABLE wvs.tasks
+  DROP COLUMN IF EXISTS dlq_resolved_at,
+  DROP COLUMN IF EXISTS dlq_resolution,
+  DROP COLUMN IF EXISTS retry_of;
@@ -0,0 +1,8 @@
+ALTER TABLE wvs.tasks
+  ADD COLUMN retry_of        TEXT REFERENCES wvs.tasks(task_id),
+  ADD COLUMN dlq_resolution  TEXT CHECK (dlq_resolution IN ('RETRIED', 'DISCARDED')),
+  ADD COLUMN dlq_resolved_at TIMESTAMPTZ;
+
+-- A DEAD task is retried at most once; the retry carries the lineage forward.
+CREATE UNIQUE INDEX uq_tasks_retry_of ON wvs.tasks(retry_of) WHERE retry_of IS NOT NULL;
+CREATE INDEX idx_tasks_dead ON wvs.tasks(ended_at) WHERE status = 'DEAD' AND dlq_resolution IS NULL;
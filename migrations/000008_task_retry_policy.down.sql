@@ -0,0 +1,5 @@
+ALTER TABLE wvs.tasks
+  DROP COLUMN IF EXISTS retry_jitter,
+  DROP COLUMN IF EXISTS retry_max_delay_ms,
+  DROP COLUMN IF EXISTS retry_multiplier,
+  DROP COLUMN IF EXISTS retry_base_ms;
@@ -0,0 +1,7 @@
+-- Each task carries the retry policy it was created with; defaults match the
+-- backoff used before policies were configurable.
+ALTER TABLE wvs.tasks
+  ADD COLUMN retry_base_ms      INT NOT NULL DEFAULT 5000,
+  ADD COLUMN retry_multiplier   DOUBLE PRECISION NOT NULL DEFAULT 2,
+  ADD COLUMN retry_max_delay_ms INT NOT NULL DEFAULT 300000,
+  ADD COLUMN retry_jitter       DOUBLE PRECISION NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS wvs.queue_fairness;
+DROP INDEX IF EXISTS wvs.idx_tasks_dequeue_priority;
+ALTER TABLE wvs.tasks DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,13 @@
+ALTER TABLE wvs.tasks
+  ADD COLUMN priority INT NOT NULL DEFAULT 50 CHECK (priority >= 0 AND priority <= 100);
+
+CREATE INDEX idx_tasks_dequeue_priority ON wvs.tasks(priority DESC, created_at)
+  WHERE status IN ('PENDING', 'FAILED');
+
+-- When each workspace last had a task dequeued; within a priority the
+-- least recently served workspace goes first, so one busy workspace cannot
+-- starve the rest.
+CREATE TABLE wvs.queue_fairness (
+  wsid                TEXT PRIMARY KEY REFERENCES wvs.workspaces(wsid),
+  last_dequeued_at    TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS wvs.idx_tasks_batch;
+DROP INDEX IF EXISTS wvs.idx_tasks_depends_on;
+ALTER TABLE wvs.tasks
+  DROP COLUMN IF EXISTS batch_seq,
+  DROP COLUMN IF EXISTS batch_id,
+  DROP COLUMN IF EXISTS depends_on;
+DROP TABLE IF EXISTS wvs.batches;
@@ -0,0 +1,19 @@
+CREATE TABLE wvs.batches (
+  batch_id            TEXT PRIMARY KEY,
+  wsid                TEXT NOT NULL REFERENCES wvs.workspaces(wsid),
+  idempotency_key     TEXT NOT NULL,
+  request_hash        TEXT NOT NULL,
+  created_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX uq_batches_idempotency ON wvs.batches(wsid, idempotency_key);
+
+-- A task with depends_on only runs once that task has SUCCEEDED; batch steps
+-- form a chain in batch_seq order.
+ALTER TABLE wvs.tasks
+  ADD COLUMN depends_on TEXT REFERENCES wvs.tasks(task_id),
+  ADD COLUMN batch_id   TEXT REFERENCES wvs.batches(batch_id),
+  ADD COLUMN batch_seq  INT;
+
+CREATE INDEX idx_tasks_depends_on ON wvs.tasks(depends_on) WHERE depends_on IS NOT NULL;
+CREATE INDEX idx_tasks_batch ON wvs.tasks(batch_id, batch_seq) WHERE batch_id IS NOT NULL;
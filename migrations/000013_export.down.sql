@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS wvs.exports;
+
+ALTER TABLE wvs.tasks DROP CONSTRAINT tasks_op_check;
+ALTER TABLE wvs.tasks ADD CONSTRAINT tasks_op_check
+  CHECK (op IN ('init_workspace', 'snapshot_create', 'snapshot_drop', 'set_current', 'checkpoint_restore', 'restore_paths'));
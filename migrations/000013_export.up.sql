@@ -0,0 +1,25 @@
+ALTER TABLE wvs.tasks DROP CONSTRAINT tasks_op_check;
+ALTER TABLE wvs.tasks ADD CONSTRAINT tasks_op_check
+  CHECK (op IN ('init_workspace', 'snapshot_create', 'snapshot_drop', 'set_current', 'checkpoint_restore', 'restore_paths',
+                'export'));
+
+-- One row per export task, reserved when the task is created and filled in
+-- when it succeeds. Its state otherwise follows the task.
+CREATE TABLE wvs.exports (
+  export_id           TEXT PRIMARY KEY,
+  wsid                TEXT NOT NULL REFERENCES wvs.workspaces(wsid),
+  snapshot_id         TEXT NOT NULL REFERENCES wvs.snapshots(snapshot_id),
+  task_id             TEXT NOT NULL REFERENCES wvs.tasks(task_id),
+  compression         TEXT NOT NULL CHECK (compression IN ('zstd', 'none')),
+  destination         TEXT NOT NULL CHECK (destination IN ('local', 's3')),
+  location            TEXT,
+  digest              TEXT,
+  size_bytes          BIGINT,
+  file_count          BIGINT,
+  manifest_location   TEXT,
+  manifest_digest     TEXT,
+  created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+  completed_at        TIMESTAMPTZ
+);
+
+CREATE INDEX idx_exports_snapshot ON wvs.exports(snapshot_id, created_at DESC);
@@ -0,0 +1,32 @@
+ALTER TABLE wvs.tasks DROP CONSTRAINT tasks_op_check;
+ALTER TABLE wvs.tasks ADD CONSTRAINT tasks_op_check
+  CHECK (op IN ('init_workspace', 'snapshot_create', 'snapshot_drop', 'set_current', 'checkpoint_restore', 'restore_paths',
+                'export', 'import', 'replicate'));
+
+-- The peer deployment a workspace's snapshots are replicated to, at most one
+-- per workspace. Disabling keeps the row and its replica history.
+CREATE TABLE wvs.replication_targets (
+  wsid                TEXT PRIMARY KEY REFERENCES wvs.workspaces(wsid),
+  peer_url            TEXT NOT NULL,
+  peer_wsid           TEXT NOT NULL,
+  enabled             BOOLEAN NOT NULL DEFAULT true,
+  created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One row per snapshot shipped, or being shipped, to the workspace's current
+-- target, reserved when the replicate task is created and completed when it
+-- succeeds. Its state otherwise follows the task.
+CREATE TABLE wvs.snapshot_replicas (
+  snapshot_id         TEXT PRIMARY KEY REFERENCES wvs.snapshots(snapshot_id),
+  wsid                TEXT NOT NULL REFERENCES wvs.workspaces(wsid),
+  task_id             TEXT NOT NULL REFERENCES wvs.tasks(task_id),
+  base_snapshot_id    TEXT,
+  bytes_shipped       BIGINT,
+  files_changed       BIGINT,
+  files_deleted       BIGINT,
+  created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+  replicated_at       TIMESTAMPTZ
+);
+
+CREATE INDEX idx_snapshot_replicas_wsid ON wvs.snapshot_replicas(wsid, created_at DESC);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS wvs.idx_snapshots_scrub;
+
+ALTER TABLE wvs.snapshots
+  DROP COLUMN IF EXISTS verified_at,
+  DROP COLUMN IF EXISTS verify_state,
+  DROP COLUMN IF EXISTS tree_digest;
+
+ALTER TABLE wvs.tasks DROP CONSTRAINT tasks_op_check;
+ALTER TABLE wvs.tasks ADD CONSTRAINT tasks_op_check
+  CHECK (op IN ('init_workspace', 'snapshot_create', 'snapshot_drop', 'set_current', 'checkpoint_restore', 'restore_paths',
+                'export', 'import', 'replicate'));
@@ -0,0 +1,15 @@
+ALTER TABLE wvs.tasks DROP CONSTRAINT tasks_op_check;
+ALTER TABLE wvs.tasks ADD CONSTRAINT tasks_op_check
+  CHECK (op IN ('init_workspace', 'snapshot_create', 'snapshot_drop', 'set_current', 'checkpoint_restore', 'restore_paths',
+                'export', 'import', 'replicate', 'verify'));
+
+-- tree_digest is recorded when the snapshot is taken; snapshots older than
+-- this migration get theirs from their first verify. verify_state and
+-- verified_at are the outcome of the last verify.
+ALTER TABLE wvs.snapshots
+  ADD COLUMN tree_digest  TEXT,
+  ADD COLUMN verify_state TEXT CHECK (verify_state IN ('OK', 'CORRUPT')),
+  ADD COLUMN verified_at  TIMESTAMPTZ;
+
+CREATE INDEX idx_snapshots_scrub ON wvs.snapshots(COALESCE(verified_at, created_at))
+  WHERE deleted_at IS NULL AND state = 'READY';
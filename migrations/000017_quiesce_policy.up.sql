@@ -0,0 +1,5 @@
+-- How the executor quiesces the workspace's guest before snapshots and
+-- switches; see core.QuiescePolicy.
+ALTER TABLE wvs.workspaces
+  ADD COLUMN quiesce_policy TEXT NOT NULL DEFAULT 'best-effort'
+    CHECK (quiesce_policy IN ('require-guest', 'best-effort', 'none'));
@@ -0,0 +1,4 @@
+-- Commands the executor runs around snapshots and restores; see
+-- core.WorkspaceHooks.
+ALTER TABLE wvs.workspaces
+  ADD COLUMN hooks JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1 @@
+ALTER TABLE wvs.workspaces DROP COLUMN IF EXISTS exclude_patterns;
@@ -0,0 +1,4 @@
+-- Paths left out of the workspace's snapshots, on top of its .wvsignore;
+-- see core.Excluder.
+ALTER TABLE wvs.workspaces
+  ADD COLUMN exclude_patterns TEXT[] NOT NULL DEFAULT '{}';
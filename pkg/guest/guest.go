@@ -0,0 +1,368 @@
+// Package guest implements the guest side of the WVS quiesce protocol, so
+// a workload can be frozen consistently while the executor snapshots or
+// switches its workspace.
+//
+// An Agent registers with the executor by writing .wvs/agent.json in the
+// workspace root and refreshes the heartbeat in it while it runs. When the
+// executor writes REQUEST_FREEZE to .wvs/control.json, the agent runs the
+// workload's freeze callback and acknowledges with FROZEN and the request's
+// sequence number; REQUEST_RESUME runs the resume callback. The executor
+// only ever writes control.json and the agent only ever writes agent.json.
+package guest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// ProtocolVersion is the version of the quiesce protocol this package speaks.
+const ProtocolVersion = 2
+
+// Protocol states, as in the executor's control.json and agent.json.
+const (
+	StateRunning       = "RUNNING"
+	StateRequestFreeze = "REQUEST_FREEZE"
+	StateFrozen        = "FROZEN"
+	StateRequestResume = "REQUEST_RESUME"
+)
+
+// controlFile mirrors the executor's ControlFile.
+type controlFile struct {
+	Version int    `json:"version"`
+	State   string `json:"state"`
+	Seq     int64  `json:"seq"`
+	TaskID  string `json:"task_id,omitempty"`
+}
+
+// agentFile mirrors the executor's AgentFile.
+type agentFile struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	AgentID         string `json:"agent_id,omitempty"`
+	Heartbeat       string `json:"heartbeat"`
+	State           string `json:"state"`
+	AckSeq          int64  `json:"ack_seq"`
+}
+
+// Config configures an Agent. Root, Freeze and Resume are required.
+type Config struct {
+	// Root is the workspace root: the directory holding .wvs.
+	Root string
+	// AgentID names the agent in the executor's logs. Defaults to
+	// <hostname>-<pid>.
+	AgentID string
+
+	// Freeze brings the workload to a consistent state on disk and holds it
+	// there: flush buffers, pause writers. Resume undoes it. Both must
+	// return by their timeout; the context they get is canceled then.
+	Freeze        func(ctx context.Context) error
+	Resume        func(ctx context.Context) error
+	FreezeTimeout time.Duration // default 10s
+	ResumeTimeout time.Duration // default 10s
+
+	// HeartbeatInterval must stay well under the executor's heartbeat TTL
+	// (EXECUTOR_QUIESCE_HEARTBEAT_TTL, 30s by default). Default 5s.
+	HeartbeatInterval time.Duration
+	// PollInterval is how often control.json is read in case a change
+	// notification went missing. Default 1s.
+	PollInterval time.Duration
+	// DisableNotify relies on polling alone, for mounts that do not deliver
+	// inotify events for changes made by the executor.
+	DisableNotify bool
+	// MaxFrozen, if set, resumes the workload after it has been frozen this
+	// long even without REQUEST_RESUME, in case the executor died mid-task.
+	MaxFrozen time.Duration
+
+	Logger *zap.Logger
+}
+
+// Agent answers the executor's quiesce requests for one workspace.
+type Agent struct {
+	cfg         Config
+	log         *zap.Logger
+	controlPath string
+	agentPath   string
+
+	state    string
+	ackSeq   int64
+	handled  int64 // last freeze request acted on
+	frozenAt time.Time
+}
+
+// New validates cfg, fills in defaults and returns an Agent ready to Run.
+func New(cfg Config) (*Agent, error) {
+	if cfg.Root == "" {
+		return nil, errors.New("guest: Root is required")
+	}
+	if cfg.Freeze == nil || cfg.Resume == nil {
+		return nil, errors.New("guest: Freeze and Resume are required")
+	}
+	if cfg.AgentID == "" {
+		host, _ := os.Hostname()
+		cfg.AgentID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if cfg.FreezeTimeout <= 0 {
+		cfg.FreezeTimeout = 10 * time.Second
+	}
+	if cfg.ResumeTimeout <= 0 {
+		cfg.ResumeTimeout = 10 * time.Second
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = 5 * time.Second
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	log := cfg.Logger
+	if log == nil {
+		log = zap.NewNop()
+	}
+	return &Agent{
+		cfg:         cfg,
+		log:         log.With(zap.String("agent_id", cfg.AgentID)),
+		controlPath: filepath.Join(cfg.Root, ".wvs", "control.json"),
+		agentPath:   filepath.Join(cfg.Root, ".wvs", "agent.json"),
+		state:       StateRunning,
+	}, nil
+}
+
+// Run registers the agent and answers quiesce requests until ctx is
+// canceled. On the way out it resumes the workload if it is frozen and
+// deregisters, so the executor stops waiting for it.
+func (a *Agent) Run(ctx context.Context) error {
+	if err := os.MkdirAll(filepath.Dir(a.agentPath), 0755); err != nil {
+		return fmt.Errorf("guest: mkdir .wvs: %w", err)
+	}
+
+	var changed <-chan struct{}
+	if !a.cfg.DisableNotify {
+		ch, stop, err := a.watch()
+		if err != nil {
+			a.log.Warn("guest: change notifications unavailable, polling only", zap.Error(err))
+		} else {
+			defer stop()
+			changed = ch
+		}
+	}
+
+	if err := a.writeAgent(); err != nil {
+		return fmt.Errorf("guest: register: %w", err)
+	}
+	a.log.Info("guest: registered", zap.String("root", a.cfg.Root))
+	defer a.shutdown()
+
+	poll := time.NewTicker(a.cfg.PollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(a.cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	// A request may be outstanding from before the agent started.
+	a.check(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+			a.check(ctx)
+		case <-poll.C:
+			a.check(ctx)
+		case <-heartbeat.C:
+			if err := a.writeAgent(); err != nil {
+				a.log.Warn("guest: heartbeat failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// check reads control.json and acts on a request the agent has not
+// answered yet.
+func (a *Agent) check(ctx context.Context) {
+	cf, err := readControl(a.controlPath)
+	if err != nil {
+		// Missing until the executor's first request.
+		if !errors.Is(err, os.ErrNotExist) {
+			a.log.Debug("guest: control.json unreadable", zap.Error(err))
+		}
+	} else if cf.Version != ProtocolVersion {
+		a.log.Debug("guest: ignoring control.json of another protocol version", zap.Int("version", cf.Version))
+	} else {
+		switch cf.State {
+		case StateRequestFreeze:
+			if cf.Seq > a.handled {
+				a.handled = cf.Seq
+				a.freeze(ctx, cf)
+			}
+		case StateRequestResume:
+			// A resume older than the freeze being held is stale.
+			if a.state == StateFrozen && cf.Seq >= a.ackSeq {
+				a.resume(ctx, "requested")
+			}
+		}
+	}
+
+	if a.state == StateFrozen && a.cfg.MaxFrozen > 0 && time.Since(a.frozenAt) > a.cfg.MaxFrozen {
+		a.log.Warn("guest: frozen too long without a resume request", zap.Duration("max_frozen", a.cfg.MaxFrozen))
+		a.resume(ctx, "max_frozen")
+	}
+}
+
+func (a *Agent) freeze(ctx context.Context, cf *controlFile) {
+	log := a.log.With(zap.Int64("seq", cf.Seq), zap.String("task_id", cf.TaskID))
+	if a.state != StateFrozen {
+		start := time.Now()
+		if err := a.call(ctx, a.cfg.Freeze, a.cfg.FreezeTimeout); err != nil {
+			// No ack: the executor times out, and the workload must not be
+			// left half frozen meanwhile.
+			log.Error("guest: freeze failed", zap.Error(err))
+			if err := a.call(ctx, a.cfg.Resume, a.cfg.ResumeTimeout); err != nil {
+				log.Error("guest: resume after failed freeze failed", zap.Error(err))
+			}
+			return
+		}
+		a.state = StateFrozen
+		a.frozenAt = time.Now()
+		log.Info("guest: frozen", zap.Duration("took", time.Since(start)))
+	}
+	a.ackSeq = cf.Seq
+	if err := a.writeAgent(); err != nil {
+		log.Error("guest: write FROZEN ack failed", zap.Error(err))
+	}
+}
+
+func (a *Agent) resume(ctx context.Context, why string) {
+	if err := a.call(ctx, a.cfg.Resume, a.cfg.ResumeTimeout); err != nil {
+		// Running is the safer guess; staying frozen would stall the workload.
+		a.log.Error("guest: resume failed", zap.Error(err))
+	}
+	a.state = StateRunning
+	a.log.Info("guest: resumed", zap.String("reason", why), zap.Int64("seq", a.ackSeq))
+	if err := a.writeAgent(); err != nil {
+		a.log.Warn("guest: write RUNNING failed", zap.Error(err))
+	}
+}
+
+func (a *Agent) shutdown() {
+	if a.state == StateFrozen {
+		a.resume(context.Background(), "shutdown")
+	}
+	if err := os.Remove(a.agentPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		a.log.Warn("guest: deregister failed", zap.Error(err))
+		return
+	}
+	a.log.Info("guest: deregistered")
+}
+
+// call runs fn with a timeout, returning when the timeout expires even if
+// fn has not.
+func (a *Agent) call(ctx context.Context, fn func(context.Context) error, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// watch reports changes to control.json. The directory is watched rather
+// than the file, which the executor may replace.
+func (a *Agent) watch() (<-chan struct{}, func(), error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := w.Add(filepath.Dir(a.controlPath)); err != nil {
+		w.Close()
+		return nil, nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if filepath.Base(ev.Name) != filepath.Base(a.controlPath) {
+					continue
+				}
+				select {
+				case ch <- struct{}{}:
+				default: // a check is already pending
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				a.log.Warn("guest: watch error", zap.Error(err))
+			}
+		}
+	}()
+	return ch, func() { w.Close() }, nil
+}
+
+func (a *Agent) writeAgent() error {
+	data, _ := json.Marshal(agentFile{
+		ProtocolVersion: ProtocolVersion,
+		AgentID:         a.cfg.AgentID,
+		Heartbeat:       time.Now().UTC().Format(time.RFC3339Nano),
+		State:           a.state,
+		AckSeq:          a.ackSeq,
+	})
+	return writeFileAtomic(a.agentPath, data)
+}
+
+// writeFileAtomic replaces path with data so readers see the old contents
+// or the new, never a torn write.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func readControl(path string) (*controlFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cf controlFile
+	if err := json.Unmarshal(data, &cf); err != nil {
+		return nil, err
+	}
+	return &cf, nil
+}
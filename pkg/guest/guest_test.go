@@ -0,0 +1,219 @@
+package guest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lzjever/mbos-wvs/internal/core"
+	"github.com/lzjever/mbos-wvs/internal/executor"
+)
+
+type workload struct {
+	freezes, resumes atomic.Int32
+	freezeErr        error
+	freezeDelay      time.Duration
+}
+
+func (w *workload) freeze(ctx context.Context) error {
+	w.freezes.Add(1)
+	if w.freezeDelay > 0 {
+		select {
+		case <-time.After(w.freezeDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return w.freezeErr
+}
+
+func (w *workload) resume(context.Context) error {
+	w.resumes.Add(1)
+	return nil
+}
+
+// startAgent runs an agent on a fresh workspace root until the test ends
+// and waits for it to register.
+func startAgent(t *testing.T, w *workload, tweak func(*Config)) (string, context.CancelFunc, <-chan struct{}) {
+	t.Helper()
+	root := t.TempDir()
+	cfg := Config{
+		Root:          root,
+		AgentID:       "test-agent",
+		Freeze:        w.freeze,
+		Resume:        w.resume,
+		FreezeTimeout: time.Second,
+		PollInterval:  50 * time.Millisecond,
+	}
+	if tweak != nil {
+		tweak(&cfg)
+	}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := a.Run(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	waitFor(t, "registration", func() bool {
+		_, err := os.Stat(filepath.Join(root, ".wvs", "agent.json"))
+		return err == nil
+	})
+	return root, cancel, done
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readAgentFile(t *testing.T, root string) agentFile {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, ".wvs", "agent.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var af agentFile
+	if err := json.Unmarshal(data, &af); err != nil {
+		t.Fatal(err)
+	}
+	return af
+}
+
+func quiesceOpts(policy core.QuiescePolicy, timeout time.Duration) executor.QuiesceOptions {
+	return executor.QuiesceOptions{Policy: policy, Timeout: timeout, HeartbeatTTL: 30 * time.Second}
+}
+
+func TestAgentFreezeResume(t *testing.T) {
+	for _, notify := range []bool{true, false} {
+		name := "notify"
+		if !notify {
+			name = "poll"
+		}
+		t.Run(name, func(t *testing.T) {
+			w := &workload{}
+			root, _, _ := startAgent(t, w, func(c *Config) { c.DisableNotify = !notify })
+			ctx := context.Background()
+
+			for round := 1; round <= 2; round++ {
+				if err := executor.Quiesce(ctx, root, "task", quiesceOpts(core.QuiesceRequireGuest, 5*time.Second), zap.NewNop()); err != nil {
+					t.Fatalf("round %d: %v", round, err)
+				}
+				if got := w.freezes.Load(); got != int32(round) {
+					t.Errorf("round %d: %d freezes", round, got)
+				}
+				if af := readAgentFile(t, root); af.State != StateFrozen || af.AckSeq != int64(round) {
+					t.Errorf("round %d: agent.json %+v", round, af)
+				}
+
+				if err := executor.Resume(root, "task"); err != nil {
+					t.Fatal(err)
+				}
+				waitFor(t, "resume", func() bool { return w.resumes.Load() == int32(round) })
+				waitFor(t, "RUNNING", func() bool { return readAgentFile(t, root).State == StateRunning })
+			}
+		})
+	}
+}
+
+func TestAgentFreezeFailure(t *testing.T) {
+	w := &workload{freezeErr: errors.New("database busy")}
+	root, _, _ := startAgent(t, w, nil)
+
+	err := executor.Quiesce(context.Background(), root, "task", quiesceOpts(core.QuiesceRequireGuest, 500*time.Millisecond), zap.NewNop())
+	if !errors.Is(err, executor.ErrQuiesceTimeout) {
+		t.Fatalf("want ErrQuiesceTimeout, got %v", err)
+	}
+	if w.freezes.Load() != 1 {
+		t.Errorf("%d freezes, want 1 (no retry of a failed request)", w.freezes.Load())
+	}
+	// A partial freeze is undone right away.
+	if w.resumes.Load() < 1 {
+		t.Error("workload not resumed after the failed freeze")
+	}
+	if af := readAgentFile(t, root); af.State != StateRunning {
+		t.Errorf("agent.json %+v", af)
+	}
+}
+
+func TestAgentFreezeTimeout(t *testing.T) {
+	w := &workload{freezeDelay: time.Minute}
+	root, _, _ := startAgent(t, w, func(c *Config) { c.FreezeTimeout = 100 * time.Millisecond })
+
+	err := executor.Quiesce(context.Background(), root, "task", quiesceOpts(core.QuiesceRequireGuest, time.Second), zap.NewNop())
+	if !errors.Is(err, executor.ErrQuiesceTimeout) {
+		t.Fatalf("want ErrQuiesceTimeout, got %v", err)
+	}
+}
+
+func TestAgentMaxFrozen(t *testing.T) {
+	w := &workload{}
+	root, _, _ := startAgent(t, w, func(c *Config) { c.MaxFrozen = 200 * time.Millisecond })
+
+	if err := executor.Quiesce(context.Background(), root, "task", quiesceOpts(core.QuiesceRequireGuest, 5*time.Second), zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	// The executor never resumes, as if it died mid-task.
+	waitFor(t, "auto resume", func() bool { return w.resumes.Load() == 1 })
+}
+
+func TestAgentDeregistersOnExit(t *testing.T) {
+	w := &workload{}
+	root, cancel, done := startAgent(t, w, nil)
+
+	if err := executor.Quiesce(context.Background(), root, "task", quiesceOpts(core.QuiesceRequireGuest, 5*time.Second), zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	<-done
+
+	if w.resumes.Load() != 1 {
+		t.Errorf("%d resumes on exit while frozen, want 1", w.resumes.Load())
+	}
+	if _, err := os.Stat(filepath.Join(root, ".wvs", "agent.json")); !os.IsNotExist(err) {
+		t.Errorf("agent.json left behind: %v", err)
+	}
+	// With the agent gone, best-effort does not wait for it.
+	start := time.Now()
+	if err := executor.Quiesce(context.Background(), root, "task", quiesceOpts(core.QuiesceBestEffort, 5*time.Second), zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("best-effort waited %s for a deregistered agent", time.Since(start))
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	noop := func(context.Context) error { return nil }
+	for _, cfg := range []Config{
+		{Freeze: noop, Resume: noop},
+		{Root: "/ws", Resume: noop},
+		{Root: "/ws", Freeze: noop},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) accepted", cfg)
+		}
+	}
+}